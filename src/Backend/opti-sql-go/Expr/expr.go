@@ -13,6 +13,7 @@ import (
 	"github.com/apache/arrow/go/v17/arrow/array"
 	"github.com/apache/arrow/go/v17/arrow/compute"
 	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/arrow/scalar"
 )
 
 var (
@@ -72,6 +73,8 @@ var (
 	_ = (Expression)(&BinaryExpr{})
 	_ = (Expression)(&ScalarFunction{})
 	_ = (Expression)(&CastExpr{})
+	_ = (Expression)(&NullCheckExpr{})
+	_ = (Expression)(&NotExpr{})
 )
 
 /*
@@ -91,22 +94,24 @@ type Expression interface {
 	fmt.Stringer
 }
 
-func EvalExpression(expr Expression, batch *operators.RecordBatch) (arrow.Array, error) {
+func EvalExpression(ctx context.Context, expr Expression, batch *operators.RecordBatch) (arrow.Array, error) {
 	switch e := expr.(type) {
 	case *Alias:
-		return EvalAlias(e, batch)
+		return EvalAlias(ctx, e, batch)
 	case *ColumnResolve:
-		return EvalColumn(e, batch)
+		return EvalColumn(ctx, e, batch)
 	case *LiteralResolve:
-		return EvalLiteral(e, batch)
+		return EvalLiteral(ctx, e, batch)
 	case *BinaryExpr:
-		return EvalBinary(e, batch)
+		return EvalBinary(ctx, e, batch)
 	case *ScalarFunction:
-		return EvalScalarFunction(e, batch)
+		return EvalScalarFunction(ctx, e, batch)
 	case *CastExpr:
-		return EvalCast(e, batch)
+		return EvalCast(ctx, e, batch)
 	case *NullCheckExpr:
-		return EvalNullCheckMask(e.Expr, batch)
+		return EvalNullCheckMask(ctx, e.Expr, batch)
+	case *NotExpr:
+		return EvalNot(ctx, e, batch)
 	default:
 		return nil, ErrUnsupportedExpression(expr.String())
 	}
@@ -150,6 +155,8 @@ func ExprDataType(e Expression, inputSchema *arrow.Schema) (arrow.DataType, erro
 		return inferScalarFunctionType(ex.Function, argType), nil
 	case *NullCheckExpr:
 		return arrow.FixedWidthTypes.Boolean, nil
+	case *NotExpr:
+		return arrow.FixedWidthTypes.Boolean, nil
 
 	default:
 		return nil, ErrUnsupportedExpression(ex.String())
@@ -175,8 +182,8 @@ func NewAlias(expr Expression, name string) *Alias {
 	}
 }
 
-func EvalAlias(a *Alias, batch *operators.RecordBatch) (arrow.Array, error) {
-	return EvalExpression(a.Expr, batch)
+func EvalAlias(ctx context.Context, a *Alias, batch *operators.RecordBatch) (arrow.Array, error) {
+	return EvalExpression(ctx, a.Expr, batch)
 }
 func (a *Alias) ExprNode() {}
 func (a *Alias) String() string {
@@ -194,7 +201,7 @@ func NewColumnResolve(name string) *ColumnResolve {
 	return &ColumnResolve{Name: name}
 }
 
-func EvalColumn(c *ColumnResolve, batch *operators.RecordBatch) (arrow.Array, error) {
+func EvalColumn(ctx context.Context, c *ColumnResolve, batch *operators.RecordBatch) (arrow.Array, error) {
 	// schema and columns are always aligned
 	for i, f := range batch.Schema.Fields() {
 		if f.Name == c.Name {
@@ -264,8 +271,9 @@ func NewLiteralResolve(Type arrow.DataType, Value any) *LiteralResolve {
 	}
 	return &LiteralResolve{Type: Type, Value: castVal}
 }
-func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array, error) {
+func EvalLiteral(ctx context.Context, l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array, error) {
 	n := int(batch.RowCount)
+	mem := compute.GetAllocator(ctx)
 
 	switch l.Type.ID() {
 
@@ -274,7 +282,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	// ------------------------------
 	case arrow.BOOL:
 		val := l.Value.(bool)
-		b := array.NewBooleanBuilder(memory.DefaultAllocator)
+		b := array.NewBooleanBuilder(mem)
 		defer b.Release()
 
 		for i := 0; i < n; i++ {
@@ -288,7 +296,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	case arrow.INT8:
 		v := l.Value.(int8)
 
-		b := array.NewInt8Builder(memory.DefaultAllocator)
+		b := array.NewInt8Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -298,7 +306,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	case arrow.UINT8:
 		v := l.Value.(uint8)
 
-		b := array.NewUint8Builder(memory.DefaultAllocator)
+		b := array.NewUint8Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -307,7 +315,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.INT16:
 		v := l.Value.(int16)
-		b := array.NewInt16Builder(memory.DefaultAllocator)
+		b := array.NewInt16Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -316,7 +324,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.UINT16:
 		v := l.Value.(uint16)
-		b := array.NewUint16Builder(memory.DefaultAllocator)
+		b := array.NewUint16Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -325,7 +333,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.INT32:
 		v := l.Value.(int32)
-		b := array.NewInt32Builder(memory.DefaultAllocator)
+		b := array.NewInt32Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -334,7 +342,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.UINT32:
 		v := l.Value.(uint32)
-		b := array.NewUint32Builder(memory.DefaultAllocator)
+		b := array.NewUint32Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -342,7 +350,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 		return b.NewArray(), nil
 	case arrow.INT64:
 		v := l.Value.(int64)
-		b := array.NewInt64Builder(memory.DefaultAllocator)
+		b := array.NewInt64Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -351,7 +359,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.UINT64:
 		v := l.Value.(uint64)
-		b := array.NewUint64Builder(memory.DefaultAllocator)
+		b := array.NewUint64Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -363,7 +371,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	// ------------------------------
 	case arrow.FLOAT32:
 		v := l.Value.(float32)
-		b := array.NewFloat32Builder(memory.DefaultAllocator)
+		b := array.NewFloat32Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -372,7 +380,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 
 	case arrow.FLOAT64:
 		v := l.Value.(float64)
-		b := array.NewFloat64Builder(memory.DefaultAllocator)
+		b := array.NewFloat64Builder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -384,7 +392,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	// ------------------------------
 	case arrow.STRING:
 		v := l.Value.(string)
-		b := array.NewStringBuilder(memory.DefaultAllocator)
+		b := array.NewStringBuilder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -396,7 +404,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	// ------------------------------
 	case arrow.BINARY:
 		v := l.Value.([]byte)
-		b := array.NewBinaryBuilder(memory.DefaultAllocator, arrow.BinaryTypes.Binary)
+		b := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.Append(v)
@@ -406,7 +414,7 @@ func EvalLiteral(l *LiteralResolve, batch *operators.RecordBatch) (arrow.Array,
 	// Nulls
 	// ------------------------------
 	case arrow.NULL:
-		b := array.NewNullBuilder(memory.DefaultAllocator)
+		b := array.NewNullBuilder(mem)
 		defer b.Release()
 		for i := 0; i < n; i++ {
 			b.AppendNull()
@@ -437,40 +445,41 @@ func NewBinaryExpr(left Expression, op binaryOperator, right Expression) *Binary
 	}
 }
 
-func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error) {
-	leftArr, err := EvalExpression(b.Left, batch)
+func EvalBinary(ctx context.Context, b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error) {
+	leftArr, err := EvalExpression(ctx, b.Left, batch)
 	if err != nil {
 		return nil, err
 	}
-	rightArr, err := EvalExpression(b.Right, batch)
+	defer leftArr.Release()
+	rightArr, err := EvalExpression(ctx, b.Right, batch)
 	if err != nil {
 		return nil, err
 	}
-	ctx := context.Background()
+	defer rightArr.Release()
 	opt := compute.ArithmeticOptions{}
 	switch b.Op {
 	// arithmetic
 	case Addition:
-		datum, err := compute.Add(ctx, opt, compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.Add(ctx, opt, compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
 		return unpackDatum(datum)
 	case Subtraction:
-		datum, err := compute.Subtract(ctx, opt, compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.Subtract(ctx, opt, compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
 		return unpackDatum(datum)
 
 	case Multiplication:
-		datum, err := compute.Multiply(ctx, opt, compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.Multiply(ctx, opt, compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
 		return unpackDatum(datum)
 	case Division:
-		datum, err := compute.Divide(ctx, opt, compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.Divide(ctx, opt, compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -480,7 +489,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "equal", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "equal", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -489,7 +498,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "not_equal", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "not_equal", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -498,7 +507,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "less", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "less", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -507,7 +516,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "less_equal", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "less_equal", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -516,7 +525,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "greater", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "greater", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -525,7 +534,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "greater_equal", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "greater_equal", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -535,7 +544,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "and", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "and", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -544,7 +553,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 		if leftArr.DataType() != rightArr.DataType() {
 			return nil, ErrCantCompareDifferentTypes(leftArr.DataType(), rightArr.DataType())
 		}
-		datum, err := compute.CallFunction(context.Background(), "or", compute.DefaultFilterOptions(), compute.NewDatum(leftArr), compute.NewDatum(rightArr))
+		datum, err := compute.CallFunction(ctx, "or", compute.DefaultFilterOptions(), compute.NewDatumWithoutOwning(leftArr), compute.NewDatumWithoutOwning(rightArr))
 		if err != nil {
 			return nil, err
 		}
@@ -554,7 +563,7 @@ func EvalBinary(b *BinaryExpr, batch *operators.RecordBatch) (arrow.Array, error
 			return nil, errors.New("binary operator Like only works on arrays of strings")
 		}
 		var compiledRegEx = compileSqlRegEx(rightArr.ValueStr(0))
-		filterBuilder := array.NewBooleanBuilder(memory.NewGoAllocator())
+		filterBuilder := array.NewBooleanBuilder(compute.GetAllocator(ctx))
 		leftStrArray := leftArr.(*array.String)
 		for i := 0; i < leftStrArray.Len(); i++ {
 			valid := validRegEx(leftStrArray.Value(i), compiledRegEx)
@@ -569,7 +578,10 @@ func (b *BinaryExpr) ExprNode() {}
 func (b *BinaryExpr) String() string {
 	return fmt.Sprintf("BinaryExpr(%s %d %s)", b.Left, b.Op, b.Right)
 }
+
+// unpackDatum turns a kernel's result into an array, d is released
 func unpackDatum(d compute.Datum) (arrow.Array, error) {
+	defer d.Release()
 	array, ok := d.(*compute.ArrayDatum)
 	if !ok {
 		return nil, fmt.Errorf("datum %v is not of type array", d)
@@ -589,38 +601,41 @@ func NewScalarFunction(function supportedFunctions, Argument Expression) *Scalar
 	}
 }
 
-func EvalScalarFunction(s *ScalarFunction, batch *operators.RecordBatch) (arrow.Array, error) {
-	ctx := context.Background()
+func EvalScalarFunction(ctx context.Context, s *ScalarFunction, batch *operators.RecordBatch) (arrow.Array, error) {
 	switch s.Function {
 	case Upper:
-		arr, err := EvalExpression(s.Arguments, batch)
+		arr, err := EvalExpression(ctx, s.Arguments, batch)
 		if err != nil {
 			return nil, err
 		}
-		return upperImpl(arr)
+		defer arr.Release()
+		return upperImpl(arr, compute.GetAllocator(ctx))
 
 	case Lower:
-		arr, err := EvalExpression(s.Arguments, batch)
+		arr, err := EvalExpression(ctx, s.Arguments, batch)
 		if err != nil {
 			return nil, err
 		}
-		return lowerImpl(arr)
+		defer arr.Release()
+		return lowerImpl(arr, compute.GetAllocator(ctx))
 	case Abs:
-		arr, err := EvalExpression(s.Arguments, batch)
+		arr, err := EvalExpression(ctx, s.Arguments, batch)
 		if err != nil {
 			return nil, err
 		}
-		datum, err := compute.AbsoluteValue(ctx, compute.ArithmeticOptions{}, compute.NewDatum(arr))
+		defer arr.Release()
+		datum, err := compute.AbsoluteValue(ctx, compute.ArithmeticOptions{}, compute.NewDatumWithoutOwning(arr))
 		if err != nil {
 			return nil, err
 		}
 		return unpackDatum(datum)
 	case Round:
-		arr, err := EvalExpression(s.Arguments, batch)
+		arr, err := EvalExpression(ctx, s.Arguments, batch)
 		if err != nil {
 			return nil, err
 		}
-		datum, err := compute.Round(ctx, compute.DefaultRoundOptions, compute.NewDatum(arr))
+		defer arr.Release()
+		datum, err := compute.Round(ctx, compute.DefaultRoundOptions, compute.NewDatumWithoutOwning(arr))
 		if err != nil {
 			return nil, err
 		}
@@ -647,15 +662,16 @@ func NewCastExpr(expr Expression, targetType arrow.DataType) *CastExpr {
 	}
 }
 
-func EvalCast(c *CastExpr, batch *operators.RecordBatch) (arrow.Array, error) {
-	arr, err := EvalExpression(c.Expr, batch)
+func EvalCast(ctx context.Context, c *CastExpr, batch *operators.RecordBatch) (arrow.Array, error) {
+	arr, err := EvalExpression(ctx, c.Expr, batch)
 	if err != nil {
 		return nil, err
 	}
+	defer arr.Release()
 
 	// Use Arrow compute kernel to cast
 	castOpts := compute.SafeCastOptions(c.TargetType)
-	out, err := compute.CastArray(context.Background(), arr, castOpts)
+	out, err := compute.CastArray(ctx, arr, castOpts)
 	if err != nil {
 		return nil, fmt.Errorf("cast error: cannot cast %s to %s: %w",
 			arr.DataType(), c.TargetType, err)
@@ -680,17 +696,18 @@ func (n *NullCheckExpr) ExprNode() {}
 func (n *NullCheckExpr) String() string {
 	return fmt.Sprintf("NullCheck(%s)", n.Expr.String())
 }
-func EvalNullCheckMask(expr Expression, batch *operators.RecordBatch) (arrow.Array, error) {
+func EvalNullCheckMask(ctx context.Context, expr Expression, batch *operators.RecordBatch) (arrow.Array, error) {
 	// Step 1: Evaluate underlying expression
-	arr, err := EvalExpression(expr, batch)
+	arr, err := EvalExpression(ctx, expr, batch)
 	if err != nil {
 		return nil, err
 	}
+	defer arr.Release()
 
 	length := arr.Len()
 
 	// Step 2: Build boolean mask
-	builder := array.NewBooleanBuilder(memory.DefaultAllocator)
+	builder := array.NewBooleanBuilder(compute.GetAllocator(ctx))
 	builder.Resize(length)
 
 	for i := 0; i < length; i++ {
@@ -702,12 +719,41 @@ func EvalNullCheckMask(expr Expression, batch *operators.RecordBatch) (arrow.Arr
 	return mask, nil
 }
 
-func upperImpl(arr arrow.Array) (arrow.Array, error) {
+// NotExpr negates a boolean expression, null stays null
+type NotExpr struct {
+	Expr Expression
+}
+
+func NewNotExpr(expr Expression) *NotExpr {
+	return &NotExpr{Expr: expr}
+}
+func (n *NotExpr) ExprNode() {}
+func (n *NotExpr) String() string {
+	return fmt.Sprintf("Not(%s)", n.Expr.String())
+}
+func EvalNot(ctx context.Context, n *NotExpr, batch *operators.RecordBatch) (arrow.Array, error) {
+	arr, err := EvalExpression(ctx, n.Expr, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	if arr.DataType().ID() != arrow.BOOL {
+		return nil, fmt.Errorf("NOT expects a boolean, got %s", arr.DataType())
+	}
+	// arrow go has no invert kernel, x xor true is not x and keeps nulls null
+	datum, err := compute.CallFunction(ctx, "xor", nil, compute.NewDatumWithoutOwning(arr), compute.NewDatum(scalar.NewBooleanScalar(true)))
+	if err != nil {
+		return nil, err
+	}
+	return unpackDatum(datum)
+}
+
+func upperImpl(arr arrow.Array, mem memory.Allocator) (arrow.Array, error) {
 	strArr, ok := arr.(*array.String)
 	if !ok {
 		return nil, fmt.Errorf("upper function only supports string arrays, got %s", arr.DataType())
 	}
-	b := array.NewStringBuilder(memory.DefaultAllocator)
+	b := array.NewStringBuilder(mem)
 	defer b.Release()
 	for i := 0; i < strArr.Len(); i++ {
 		if strArr.IsNull(i) {
@@ -718,13 +764,13 @@ func upperImpl(arr arrow.Array) (arrow.Array, error) {
 	}
 	return b.NewArray(), nil
 }
-func lowerImpl(arr arrow.Array) (arrow.Array, error) {
+func lowerImpl(arr arrow.Array, mem memory.Allocator) (arrow.Array, error) {
 	{
 		strArr, ok := arr.(*array.String)
 		if !ok {
 			return nil, fmt.Errorf("lower function only supports string arrays, got %s", arr.DataType())
 		}
-		b := array.NewStringBuilder(memory.DefaultAllocator)
+		b := array.NewStringBuilder(mem)
 		defer b.Release()
 		for i := 0; i < strArr.Len(); i++ {
 			if strArr.IsNull(i) {
@@ -763,7 +809,7 @@ func inferBinaryType(left arrow.DataType, op binaryOperator, right arrow.DataTyp
 	case Equal, NotEqual, LessThan, LessThanOrEqual, GreaterThan, GreaterThanOrEqual:
 		return arrow.FixedWidthTypes.Boolean
 
-	case And, Or:
+	case And, Or, Like:
 		return arrow.FixedWidthTypes.Boolean
 
 	default:
@@ -1,6 +1,7 @@
 package Expr
 
 import (
+	"context"
 	"log"
 	"opti-sql-go/operators"
 	"testing"
@@ -83,7 +84,7 @@ func TestAliasExpr(t *testing.T) {
 			Name: "employee_name",
 		}
 		_ = a.String()
-		arr, err := EvalExpression(&a, rc)
+		arr, err := EvalExpression(context.Background(), &a, rc)
 		if err != nil {
 			t.Fatalf("failed to evaluate alias expression: %v", err)
 		}
@@ -106,7 +107,7 @@ func TestAliasExpr(t *testing.T) {
 			Name: "employee_salary",
 		}
 		_ = a.String()
-		arr, err := EvalExpression(&a, rc)
+		arr, err := EvalExpression(context.Background(), &a, rc)
 		if err != nil {
 			t.Fatalf("failed to evaluate alias expression: %v", err)
 		}
@@ -129,7 +130,7 @@ func TestAliasExpr(t *testing.T) {
 			Name: "active_status",
 		}
 		_ = a.String()
-		arr, err := EvalExpression(&a, rc)
+		arr, err := EvalExpression(context.Background(), &a, rc)
 		if err != nil {
 			t.Fatalf("failed to evaluate alias expression: %v", err)
 		}
@@ -155,7 +156,7 @@ func TestColumnResolve(t *testing.T) {
 	rc := generateTestColumns() //
 	t.Run("ColumnResolve on age", func(t *testing.T) {
 		cr := ColumnResolve{Name: "age"}
-		arr, err := EvalExpression(&cr, rc)
+		arr, err := EvalExpression(context.Background(), &cr, rc)
 		if err != nil {
 			t.Fatalf("failed to evaluate column resolve expression: %v", err)
 		}
@@ -173,7 +174,7 @@ func TestColumnResolve(t *testing.T) {
 	})
 	t.Run("ColumnResolve on ID", func(t *testing.T) {
 		cr := ColumnResolve{Name: "id"}
-		arr, err := EvalExpression(&cr, rc)
+		arr, err := EvalExpression(context.Background(), &cr, rc)
 		if err != nil {
 			t.Fatalf("failed to evaluate column resolve expression: %v", err)
 		}
@@ -191,7 +192,7 @@ func TestColumnResolve(t *testing.T) {
 	})
 	t.Run("ColumnResolve on non-existant column", func(t *testing.T) {
 		cr := ColumnResolve{Name: "doesnt Exist"}
-		_, err := EvalExpression(&cr, rc)
+		_, err := EvalExpression(context.Background(), &cr, rc)
 		if err == nil {
 			t.Fatalf("expected error for non existant column")
 		}
@@ -214,7 +215,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.FixedWidthTypes.Boolean,
 				Value: true,
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -233,7 +234,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Int8,
 				Value: int8(-5),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -256,7 +257,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Uint8,
 				Value: uint8(7),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -279,7 +280,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Int16,
 				Value: int16(1234),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -301,7 +302,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Uint16,
 				Value: uint16(60000),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -324,7 +325,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Int32,
 				Value: int32(99),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -343,7 +344,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Uint32,
 				Value: uint32(4000000000),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -363,7 +364,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Int64,
 				Value: int64(123456),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -382,7 +383,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Uint64,
 				Value: uint64(9999999999),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -402,7 +403,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Float32,
 				Value: float32(3.14),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -422,7 +423,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.PrimitiveTypes.Float64,
 				Value: float64(3.14),
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -442,7 +443,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.BinaryTypes.String,
 				Value: "hello",
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -463,7 +464,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.BinaryTypes.Binary,
 				Value: bval,
 			}
-			arr, err := EvalLiteral(lit, rc)
+			arr, err := EvalLiteral(context.Background(), lit, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -484,7 +485,7 @@ func TestLiteralResolve(t *testing.T) {
 				Type:  arrow.FixedWidthTypes.Duration_s, // something you did NOT implement
 				Value: int64(10),
 			}
-			_, err := EvalLiteral(lit, rc)
+			_, err := EvalLiteral(context.Background(), lit, rc)
 			if err == nil {
 				t.Fatalf("expected error for unsupported type, got nil")
 			}
@@ -525,7 +526,7 @@ func TestBinaryExpr(t *testing.T) {
 				Right: makeLit(5),
 			}
 
-			arr, err := EvalBinary(b, rc)
+			arr, err := EvalBinary(context.Background(), b, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -546,7 +547,7 @@ func TestBinaryExpr(t *testing.T) {
 				Right: makeLit(3),
 			}
 
-			arr, err := EvalExpression(b, rc)
+			arr, err := EvalExpression(context.Background(), b, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -566,7 +567,7 @@ func TestBinaryExpr(t *testing.T) {
 				Right: makeLit(6),
 			}
 
-			arr, err := EvalExpression(b, rc)
+			arr, err := EvalExpression(context.Background(), b, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -586,7 +587,7 @@ func TestBinaryExpr(t *testing.T) {
 				Right: makeLit(4),
 			}
 
-			arr, err := EvalExpression(b, rc)
+			arr, err := EvalExpression(context.Background(), b, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -606,7 +607,7 @@ func TestBinaryExpr(t *testing.T) {
 				Right: makeLit(1),
 			}
 
-			_, err := EvalExpression(b, rc)
+			_, err := EvalExpression(context.Background(), b, rc)
 			if err == nil {
 				t.Fatalf("expected error for unsupported operator, got nil")
 			}
@@ -665,7 +666,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeStr("hello"),
 			}
 
-			arr, err := EvalExpression(sf, rc)
+			arr, err := EvalExpression(context.Background(), sf, rc)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -687,7 +688,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeStr("HeLLo"),
 			}
 
-			arr, err := EvalExpression(sf, rc)
+			arr, err := EvalExpression(context.Background(), sf, rc)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -709,7 +710,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeInt(-9),
 			}
 
-			arr, err := EvalExpression(sf, rc)
+			arr, err := EvalExpression(context.Background(), sf, rc)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -734,7 +735,7 @@ func TestScalarFunctions(t *testing.T) {
 				},
 			}
 
-			arr, err := EvalExpression(sf, rc)
+			arr, err := EvalExpression(context.Background(), sf, rc)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -756,7 +757,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeInt(99), // not a string
 			}
 
-			_, err := EvalExpression(sf, rc)
+			_, err := EvalExpression(context.Background(), sf, rc)
 			if err == nil {
 				t.Fatalf("expected type error, got nil")
 			}
@@ -767,7 +768,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeInt(99), // not a string
 			}
 
-			_, err := EvalExpression(sf, rc)
+			_, err := EvalExpression(context.Background(), sf, rc)
 			if err == nil {
 				t.Fatalf("expected type error, got nil")
 			}
@@ -783,7 +784,7 @@ func TestScalarFunctions(t *testing.T) {
 				Function:  Upper,
 				Arguments: &cr,
 			}
-			a, err := EvalExpression(sf, &operators.RecordBatch{Schema: schema, Columns: []arrow.Array{nullArr}})
+			a, err := EvalExpression(context.Background(), sf, &operators.RecordBatch{Schema: schema, Columns: []arrow.Array{nullArr}})
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -801,7 +802,7 @@ func TestScalarFunctions(t *testing.T) {
 				Function:  Lower,
 				Arguments: &cr,
 			}
-			a, err := EvalExpression(sf, &operators.RecordBatch{Schema: schema, Columns: []arrow.Array{nullArr}})
+			a, err := EvalExpression(context.Background(), sf, &operators.RecordBatch{Schema: schema, Columns: []arrow.Array{nullArr}})
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -817,7 +818,7 @@ func TestScalarFunctions(t *testing.T) {
 				Arguments: makeStr("hi"),
 			}
 
-			_, err := EvalExpression(sf, rc)
+			_, err := EvalExpression(context.Background(), sf, rc)
 			if err == nil {
 				t.Fatalf("expected unsupported function error, got nil")
 			}
@@ -869,7 +870,7 @@ func TestCastResolve(t *testing.T) {
 				TargetType: arrow.PrimitiveTypes.Float64,
 			}
 
-			arr, err := EvalExpression(ce, rc)
+			arr, err := EvalExpression(context.Background(), ce, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -891,7 +892,7 @@ func TestCastResolve(t *testing.T) {
 				TargetType: arrow.PrimitiveTypes.Int32,
 			}
 
-			_, err := EvalCast(ce, rc)
+			_, err := EvalCast(context.Background(), ce, rc)
 			if err == nil {
 				t.Fatalf("expected cast error, got nil")
 			}
@@ -909,7 +910,7 @@ func TestCastResolve(t *testing.T) {
 				TargetType: arrow.PrimitiveTypes.Float64,
 			}
 
-			arr, err := EvalExpression(ce, rc)
+			arr, err := EvalExpression(context.Background(), ce, rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -938,7 +939,7 @@ func TestCastResolve(t *testing.T) {
 				TargetType: arrow.BinaryTypes.LargeBinary,
 			}
 
-			_, err := EvalExpression(ce, rc)
+			_, err := EvalExpression(context.Background(), ce, rc)
 			if err == nil {
 				t.Fatalf("expected error for invalid cast, got nil")
 			}
@@ -969,7 +970,7 @@ func (ie *InvariantExpr) String() string {
 }
 func TestInvariantExpr(t *testing.T) {
 	t.Run("InvariantExpr", func(t *testing.T) {
-		_, err := EvalExpression(&InvariantExpr{}, nil)
+		_, err := EvalExpression(context.Background(), &InvariantExpr{}, nil)
 		if err == nil {
 			t.Fatalf("expected error for invariant expr eval, got nil")
 		}
@@ -1075,6 +1076,12 @@ func TestInferBinaryType(t *testing.T) {
 			t.Fatalf("expected BOOL for logical op, got %s", got)
 		}
 	})
+	t.Run("Like_ReturnsBoolean", func(t *testing.T) {
+		got := inferBinaryType(arrow.BinaryTypes.String, Like, arrow.BinaryTypes.String)
+		if got.ID() != arrow.BOOL {
+			t.Fatalf("expected BOOL for like, got %s", got)
+		}
+	})
 
 }
 
@@ -1185,7 +1192,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		literal := NewLiteralResolve(arrow.PrimitiveTypes.Int32, (22))
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, Equal, literal)
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1207,7 +1214,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, NotEqual, literal)
 
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1230,7 +1237,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, LessThan, literal)
 
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1253,7 +1260,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, LessThanOrEqual, literal)
 
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1276,7 +1283,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, GreaterThan, literal)
 
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1299,7 +1306,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		col := NewColumnResolve("age")
 		be := NewBinaryExpr(col, GreaterThanOrEqual, literal)
 
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1332,7 +1339,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		)
 
 		be := NewBinaryExpr(left, And, right)
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1362,7 +1369,7 @@ func TestFilterBinaryExpr(t *testing.T) {
 		)
 
 		be := NewBinaryExpr(left, Or, right)
-		arr, err := EvalExpression(be, rc)
+		arr, err := EvalExpression(context.Background(), be, rc)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1390,7 +1397,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid Equal", func(t *testing.T) {
 		be := NewBinaryExpr(left, Equal, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (Equal), got nil")
 		}
@@ -1398,7 +1405,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid NotEqual", func(t *testing.T) {
 		be := NewBinaryExpr(left, NotEqual, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (NotEqual), got nil")
 		}
@@ -1406,7 +1413,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid LessThan", func(t *testing.T) {
 		be := NewBinaryExpr(left, LessThan, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (LessThan), got nil")
 		}
@@ -1414,7 +1421,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid LessThanOrEqual", func(t *testing.T) {
 		be := NewBinaryExpr(left, LessThanOrEqual, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (LessThanOrEqual), got nil")
 		}
@@ -1422,7 +1429,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid GreaterThan", func(t *testing.T) {
 		be := NewBinaryExpr(left, GreaterThan, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (GreaterThan), got nil")
 		}
@@ -1430,7 +1437,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid GreaterThanOrEqual", func(t *testing.T) {
 		be := NewBinaryExpr(left, GreaterThanOrEqual, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (GreaterThanOrEqual), got nil")
 		}
@@ -1438,7 +1445,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid AND", func(t *testing.T) {
 		be := NewBinaryExpr(left, And, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (AND), got nil")
 		}
@@ -1446,7 +1453,7 @@ func TestFilterBinaryExpr_InvalidTypes(t *testing.T) {
 
 	t.Run("invalid OR", func(t *testing.T) {
 		be := NewBinaryExpr(left, Or, right)
-		_, err := EvalExpression(be, rc)
+		_, err := EvalExpression(context.Background(), be, rc)
 		if err == nil {
 			t.Fatalf("expected error for mismatched datatypes (OR), got nil")
 		}
@@ -1550,7 +1557,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 		rc := generateTestColumns()
 		sqlStatment := "A%"
 		whereStatment := NewBinaryExpr(NewColumnResolve("name"), Like, NewLiteralResolve(arrow.BinaryTypes.String, sqlStatment))
-		boolMask, err := EvalExpression(whereStatment, rc)
+		boolMask, err := EvalExpression(context.Background(), whereStatment, rc)
 		if err != nil {
 			t.Fatalf("unexpected error from EvalExpression")
 		}
@@ -1573,7 +1580,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 		sqlStatment := "%li%"
 		whereStatment := NewBinaryExpr(NewColumnResolve("name"), Like, NewLiteralResolve(arrow.BinaryTypes.String, sqlStatment))
 
-		boolMask, err := EvalExpression(whereStatment, rc)
+		boolMask, err := EvalExpression(context.Background(), whereStatment, rc)
 		if err != nil {
 			t.Fatalf("unexpected error from EvalExpression")
 		}
@@ -1599,7 +1606,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 		sqlStatment := "%d"
 		whereStatment := NewBinaryExpr(NewColumnResolve("name"), Like, NewLiteralResolve(arrow.BinaryTypes.String, (sqlStatment)))
 
-		boolMask, err := EvalExpression(whereStatment, rc)
+		boolMask, err := EvalExpression(context.Background(), whereStatment, rc)
 		if err != nil {
 			t.Fatalf("unexpected error from EvalExpression")
 		}
@@ -1625,7 +1632,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 		sqlStatment := "_____"
 		whereStatment := NewBinaryExpr(NewColumnResolve("name"), Like, NewLiteralResolve(arrow.BinaryTypes.String, sqlStatment))
 
-		boolMask, err := EvalExpression(whereStatment, rc)
+		boolMask, err := EvalExpression(context.Background(), whereStatment, rc)
 		if err != nil {
 			t.Fatalf("unexpected error from EvalExpression")
 		}
@@ -1651,7 +1658,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 		sqlStatment := "Ch%"
 		whereStatment := NewBinaryExpr(NewColumnResolve("name"), Like, NewLiteralResolve(arrow.BinaryTypes.String, sqlStatment))
 
-		boolMask, err := EvalExpression(whereStatment, rc)
+		boolMask, err := EvalExpression(context.Background(), whereStatment, rc)
 		if err != nil {
 			t.Fatalf("unexpected error from EvalExpression")
 		}
@@ -1677,7 +1684,7 @@ func TestLikeOperatorSQL(t *testing.T) {
 func TestNullCases(t *testing.T) {
 	t.Run("null Column literal", func(t *testing.T) {
 		v := NewLiteralResolve(arrow.Null, nil)
-		array, err := EvalExpression(v, &operators.RecordBatch{
+		array, err := EvalExpression(context.Background(), v, &operators.RecordBatch{
 			RowCount: 10,
 		})
 		if err != nil {
@@ -1695,6 +1702,47 @@ func makeBatch(schema *arrow.Schema, cols []arrow.Array) *operators.RecordBatch
 	}
 }
 
+func TestNotExpr(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	b := array.NewBooleanBuilder(mem)
+	b.AppendValues([]bool{true, false, false, true}, []bool{true, true, false, true})
+	arr := b.NewArray()
+	b.Release()
+	defer arr.Release()
+	schema := arrow.NewSchema([]arrow.Field{{Name: "col", Type: arrow.FixedWidthTypes.Boolean, Nullable: true}}, nil)
+	batch := makeBatch(schema, []arrow.Array{arr})
+
+	t.Run("negates and keeps nulls", func(t *testing.T) {
+		out, err := EvalExpression(context.Background(), NewNotExpr(NewColumnResolve("col")), batch)
+		if err != nil {
+			t.Fatalf("EvalExpression failed: %v", err)
+		}
+		defer out.Release()
+		got := out.(*array.Boolean)
+		if got.Len() != 4 || got.Value(0) || !got.Value(1) || !got.IsNull(2) || got.Value(3) {
+			t.Fatalf("expected [false true null false], got %v", got)
+		}
+	})
+	t.Run("is null", func(t *testing.T) {
+		out, err := EvalExpression(context.Background(), NewNotExpr(NewNullCheckExpr(NewColumnResolve("col"))), batch)
+		if err != nil {
+			t.Fatalf("EvalExpression failed: %v", err)
+		}
+		defer out.Release()
+		got := out.(*array.Boolean)
+		for i, want := range []bool{false, false, true, false} {
+			if got.IsNull(i) || got.Value(i) != want {
+				t.Fatalf("row %d: expected %v, got %v", i, want, got)
+			}
+		}
+	})
+	t.Run("rejects non booleans", func(t *testing.T) {
+		if _, err := EvalExpression(context.Background(), NewNotExpr(NewLiteralResolve(arrow.PrimitiveTypes.Int64, 1)), batch); err == nil {
+			t.Fatalf("expected an error for NOT over an integer")
+		}
+	})
+}
+
 func TestNullCheckExpr(t *testing.T) {
 
 	t.Run("int32_some_nulls_mask", func(t *testing.T) {
@@ -1719,7 +1767,7 @@ func TestNullCheckExpr(t *testing.T) {
 		t.Logf("%v\n", batch.PrettyPrint())
 		expr := NewColumnResolve("col")
 
-		maskArr, err := EvalNullCheckMask(expr, batch)
+		maskArr, err := EvalNullCheckMask(context.Background(), expr, batch)
 		if err != nil {
 			t.Fatalf("EvalNullCheckMask failed: %v", err)
 		}
@@ -1762,7 +1810,7 @@ func TestNullCheckExpr(t *testing.T) {
 
 		expr := NewColumnResolve("name")
 
-		maskArr, err := EvalNullCheckMask(expr, batch)
+		maskArr, err := EvalNullCheckMask(context.Background(), expr, batch)
 		if err != nil {
 			t.Fatalf("EvalNullCheckMask failed: %v", err)
 		}
@@ -1800,7 +1848,7 @@ func TestNullCheckExpr(t *testing.T) {
 
 		expr := NewColumnResolve("val")
 
-		maskArr, err := EvalNullCheckMask(expr, batch)
+		maskArr, err := EvalNullCheckMask(context.Background(), expr, batch)
 		if err != nil {
 			t.Fatalf("EvalNullCheckMask failed: %v", err)
 		}
@@ -1854,7 +1902,7 @@ func TestLiteralCast(t *testing.T) {
 				RowCount: tt.rowCount,
 			}
 
-			arr, err := EvalExpression(lit, batch)
+			arr, err := EvalExpression(context.Background(), lit, batch)
 			if err != nil {
 				t.Fatalf("EvalExpression failed: %v", err)
 			}
@@ -0,0 +1,280 @@
+package Expr
+
+import (
+	"context"
+	"opti-sql-go/operators"
+	"reflect"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+)
+
+// the simplifier rewrites an expression tree once, before any batch is seen, so the per batch
+// evaluation does less work. it
+//   - folds subtrees that only read literals into a single literal: 2 * 3 -> 6, UPPER('a') -> 'A'
+//   - drops casts to the type the expression already has
+//   - removes boolean identities: x AND true -> x, x OR false -> x, x = true -> x, (x = false) = false -> x
+//   - puts literals on the right of comparisons: 5 < x -> x > 5
+//
+// every rewrite keeps the value of the original expression, including nulls. folded literals take
+// the type the compute kernels actually produce (int32 * int32 stays int32), the same type the
+// unfolded expression evaluates to.
+// anything that can't be folded (e.g. 1 / 0) is left alone so it fails at evaluation time like before
+
+// Simplify returns an equivalent, cheaper to evaluate version of e. schema is the input schema e
+// is evaluated against
+func Simplify(e Expression, schema *arrow.Schema) (Expression, error) {
+	switch ex := e.(type) {
+	case *Alias:
+		inner, err := Simplify(ex.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return NewAlias(inner, ex.Name), nil
+
+	case *BinaryExpr:
+		left, err := Simplify(ex.Left, schema)
+		if err != nil {
+			return nil, err
+		}
+		right, err := Simplify(ex.Right, schema)
+		if err != nil {
+			return nil, err
+		}
+		return simplifyBinary(NewBinaryExpr(left, ex.Op, right)), nil
+
+	case *ScalarFunction:
+		arg, err := Simplify(ex.Arguments, schema)
+		if err != nil {
+			return nil, err
+		}
+		return foldConstant(NewScalarFunction(ex.Function, arg)), nil
+
+	case *CastExpr:
+		inner, err := Simplify(ex.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		dt, err := ExprDataType(inner, schema)
+		if err != nil {
+			return nil, err
+		}
+		if hasExactType(inner) && arrow.TypeEqual(dt, ex.TargetType) {
+			return inner, nil
+		}
+		return foldConstant(NewCastExpr(inner, ex.TargetType)), nil
+
+	case *NullCheckExpr:
+		inner, err := Simplify(ex.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return foldConstant(NewNullCheckExpr(inner)), nil
+
+	case *NotExpr:
+		inner, err := Simplify(ex.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return foldConstant(NewNotExpr(inner)), nil
+
+	default:
+		return e, nil
+	}
+}
+
+// SimplifyPredicate is Simplify for filter predicates, where a null result drops the row just
+// like false does. that allows one extra rewrite: a top level AND chain containing a literal
+// false can never keep a row, so the whole predicate becomes false
+func SimplifyPredicate(pred Expression, schema *arrow.Schema) (Expression, error) {
+	simplified, err := Simplify(pred, schema)
+	if err != nil {
+		return nil, err
+	}
+	if hasFalseConjunct(simplified) {
+		return NewLiteralResolve(arrow.FixedWidthTypes.Boolean, false), nil
+	}
+	return simplified, nil
+}
+
+func hasFalseConjunct(e Expression) bool {
+	if b, ok := e.(*BinaryExpr); ok && b.Op == And {
+		return hasFalseConjunct(b.Left) || hasFalseConjunct(b.Right)
+	}
+	return isBoolLiteral(e, false)
+}
+
+// children of b are already simplified
+func simplifyBinary(b *BinaryExpr) Expression {
+	folded := foldConstant(b)
+	if _, ok := folded.(*LiteralResolve); ok {
+		return folded
+	}
+	if _, ok := b.Left.(*LiteralResolve); ok {
+		if _, ok := b.Right.(*LiteralResolve); !ok {
+			if op, ok := mirroredComparison(b.Op); ok {
+				b = NewBinaryExpr(b.Right, op, b.Left)
+			}
+		}
+	}
+	switch b.Op {
+	case And:
+		switch {
+		case isBoolLiteral(b.Right, true):
+			return b.Left
+		case isBoolLiteral(b.Left, true):
+			return b.Right
+		case reflect.DeepEqual(b.Left, b.Right):
+			return b.Left
+		}
+	case Or:
+		switch {
+		case isBoolLiteral(b.Right, false):
+			return b.Left
+		case isBoolLiteral(b.Left, false):
+			return b.Right
+		case reflect.DeepEqual(b.Left, b.Right):
+			return b.Left
+		}
+	case Equal:
+		if isBoolLiteral(b.Right, true) {
+			return b.Left
+		}
+		// NOT NOT x
+		if inner, ok := b.Left.(*BinaryExpr); ok && inner.Op == Equal && isBoolLiteral(b.Right, false) && isBoolLiteral(inner.Right, false) {
+			return inner.Left
+		}
+	case NotEqual:
+		if isBoolLiteral(b.Right, false) {
+			return b.Left
+		}
+	}
+	return b
+}
+
+// mirroredComparison is the operator that gives the same result with the operands swapped
+func mirroredComparison(op binaryOperator) (binaryOperator, bool) {
+	switch op {
+	case Equal, NotEqual:
+		return op, true
+	case LessThan:
+		return GreaterThan, true
+	case LessThanOrEqual:
+		return GreaterThanOrEqual, true
+	case GreaterThan:
+		return LessThan, true
+	case GreaterThanOrEqual:
+		return LessThanOrEqual, true
+	default:
+		return op, false
+	}
+}
+
+func isBoolLiteral(e Expression, want bool) bool {
+	l, ok := e.(*LiteralResolve)
+	if !ok {
+		return false
+	}
+	v, ok := l.Value.(bool)
+	return ok && v == want
+}
+
+func isConstant(e Expression) bool {
+	switch ex := e.(type) {
+	case *LiteralResolve:
+		return true
+	case *Alias:
+		return isConstant(ex.Expr)
+	case *BinaryExpr:
+		return isConstant(ex.Left) && isConstant(ex.Right)
+	case *ScalarFunction:
+		return isConstant(ex.Arguments)
+	case *CastExpr:
+		return isConstant(ex.Expr)
+	case *NullCheckExpr:
+		return isConstant(ex.Expr)
+	case *NotExpr:
+		return isConstant(ex.Expr)
+	default:
+		return false
+	}
+}
+
+// hasExactType reports whether ExprDataType(e) is the type evaluating e really produces. arithmetic
+// and functions are inferred loosely (int32 + int32 is float64 on paper but int32 at runtime), so
+// a cast over them is never redundant
+func hasExactType(e Expression) bool {
+	switch ex := e.(type) {
+	case *LiteralResolve, *ColumnResolve, *CastExpr, *NullCheckExpr, *NotExpr:
+		return true
+	case *Alias:
+		return hasExactType(ex.Expr)
+	default:
+		return false
+	}
+}
+
+// foldConstant evaluates a literal only expression over a single row and turns the result back
+// into a literal. e is returned untouched if it reads columns, fails to evaluate or produces a
+// value a literal can't hold
+func foldConstant(e Expression) Expression {
+	if _, ok := e.(*LiteralResolve); ok || !isConstant(e) {
+		return e
+	}
+	oneRow := &operators.RecordBatch{Schema: arrow.NewSchema(nil, nil), RowCount: 1}
+	// planning time, outside of any query so nothing to track
+	arr, err := EvalExpression(context.Background(), e, oneRow)
+	if err != nil {
+		return e
+	}
+	defer arr.Release()
+	if arr.Len() != 1 {
+		return e
+	}
+	if lit, ok := literalFromArray(arr); ok {
+		return lit
+	}
+	return e
+}
+
+func literalFromArray(arr arrow.Array) (*LiteralResolve, bool) {
+	if arr.DataType().ID() == arrow.NULL {
+		return NewLiteralResolve(arrow.Null, nil), true
+	}
+	// typed nulls have no literal form, EvalLiteral expects a value
+	if arr.IsNull(0) {
+		return nil, false
+	}
+	var v any
+	switch a := arr.(type) {
+	case *array.Boolean:
+		v = a.Value(0)
+	case *array.Int8:
+		v = a.Value(0)
+	case *array.Int16:
+		v = a.Value(0)
+	case *array.Int32:
+		v = a.Value(0)
+	case *array.Int64:
+		v = a.Value(0)
+	case *array.Uint8:
+		v = a.Value(0)
+	case *array.Uint16:
+		v = a.Value(0)
+	case *array.Uint32:
+		v = a.Value(0)
+	case *array.Uint64:
+		v = a.Value(0)
+	case *array.Float32:
+		v = a.Value(0)
+	case *array.Float64:
+		v = a.Value(0)
+	case *array.String:
+		v = a.Value(0)
+	case *array.Binary:
+		v = append([]byte(nil), a.Value(0)...)
+	default:
+		return nil, false
+	}
+	return &LiteralResolve{Type: arr.DataType(), Value: v}, true
+}
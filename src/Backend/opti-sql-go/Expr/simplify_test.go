@@ -0,0 +1,116 @@
+package Expr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+)
+
+func TestSimplify(t *testing.T) {
+	schema := generateTestColumns().Schema
+	col := NewColumnResolve
+	i32 := func(v int32) Expression { return NewLiteralResolve(arrow.PrimitiveTypes.Int32, v) }
+	f64 := func(v float64) Expression { return NewLiteralResolve(arrow.PrimitiveTypes.Float64, v) }
+	boolLit := func(v bool) Expression { return NewLiteralResolve(arrow.FixedWidthTypes.Boolean, v) }
+	str := func(v string) Expression { return NewLiteralResolve(arrow.BinaryTypes.String, v) }
+	ageOver30 := NewBinaryExpr(col("age"), GreaterThan, i32(30))
+
+	cases := []struct {
+		name string
+		in   Expression
+		want Expression
+	}{
+		{"folds arithmetic", NewBinaryExpr(i32(2), Multiplication, i32(3)), i32(6)},
+		{"folds nested literals", NewBinaryExpr(col("salary"), Addition, NewBinaryExpr(f64(1), Addition, f64(2))),
+			NewBinaryExpr(col("salary"), Addition, f64(3))},
+		{"folds scalar functions", NewScalarFunction(Upper, str("abc")), str("ABC")},
+		{"folds casts of literals", NewCastExpr(i32(30), arrow.PrimitiveTypes.Float64), f64(30)},
+		{"folds comparisons", NewBinaryExpr(i32(1), LessThan, i32(2)), boolLit(true)},
+		{"folds null checks", NewNullCheckExpr(str("x")), boolLit(true)},
+		{"folds not", NewNotExpr(NewNullCheckExpr(str("x"))), boolLit(false)},
+		{"drops redundant casts", NewCastExpr(col("age"), arrow.PrimitiveTypes.Int32), col("age")},
+		{"keeps real casts", NewCastExpr(col("age"), arrow.PrimitiveTypes.Float64), NewCastExpr(col("age"), arrow.PrimitiveTypes.Float64)},
+		{"keeps casts over arithmetic", NewCastExpr(NewBinaryExpr(col("age"), Addition, col("age")), arrow.PrimitiveTypes.Float64),
+			NewCastExpr(NewBinaryExpr(col("age"), Addition, col("age")), arrow.PrimitiveTypes.Float64)},
+		{"x AND true", NewBinaryExpr(ageOver30, And, boolLit(true)), ageOver30},
+		{"true AND x", NewBinaryExpr(boolLit(true), And, ageOver30), ageOver30},
+		{"x OR false", NewBinaryExpr(ageOver30, Or, boolLit(false)), ageOver30},
+		{"x AND x", NewBinaryExpr(ageOver30, And, NewBinaryExpr(col("age"), GreaterThan, i32(30))), ageOver30},
+		{"x = true", NewBinaryExpr(col("is_active"), Equal, boolLit(true)), col("is_active")},
+		{"x != false", NewBinaryExpr(col("is_active"), NotEqual, boolLit(false)), col("is_active")},
+		{"double negation", NewBinaryExpr(NewBinaryExpr(col("is_active"), Equal, boolLit(false)), Equal, boolLit(false)), col("is_active")},
+		{"x AND false keeps nulls", NewBinaryExpr(ageOver30, And, boolLit(false)), NewBinaryExpr(ageOver30, And, boolLit(false))},
+		{"literal moves to the right", NewBinaryExpr(i32(30), LessThan, col("age")), ageOver30},
+		{"equality is symmetric", NewBinaryExpr(str("Bob"), Equal, col("name")), NewBinaryExpr(col("name"), Equal, str("Bob"))},
+		{"arithmetic keeps its operand order", NewBinaryExpr(f64(1), Subtraction, col("salary")), NewBinaryExpr(f64(1), Subtraction, col("salary"))},
+		{"aliases are kept", NewAlias(NewBinaryExpr(f64(1), Addition, f64(1)), "two"), NewAlias(f64(2), "two")},
+		{"failures are left for evaluation", NewBinaryExpr(i32(1), Equal, str("1")), NewBinaryExpr(i32(1), Equal, str("1"))},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Simplify(c.in, schema)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+	t.Run("unknown column in a cast", func(t *testing.T) {
+		if _, err := Simplify(NewCastExpr(col("nope"), arrow.PrimitiveTypes.Int64), schema); err == nil {
+			t.Fatalf("expected error for unknown column")
+		}
+	})
+}
+
+func TestSimplifyPredicate(t *testing.T) {
+	schema := generateTestColumns().Schema
+	ageOver30 := NewBinaryExpr(NewColumnResolve("age"), GreaterThan, NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30))
+	falseLit := NewLiteralResolve(arrow.FixedWidthTypes.Boolean, false)
+
+	got, err := SimplifyPredicate(NewBinaryExpr(NewBinaryExpr(ageOver30, And, falseLit), And, NewColumnResolve("is_active")), schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, falseLit) {
+		t.Fatalf("expected false, got %s", got)
+	}
+	// inside an OR the null from x AND false matters
+	or := NewBinaryExpr(NewBinaryExpr(ageOver30, And, falseLit), Or, NewColumnResolve("is_active"))
+	if got, err = SimplifyPredicate(or, schema); err != nil || !reflect.DeepEqual(got, or) {
+		t.Fatalf("expected %s to stay, got %s (%v)", or, got, err)
+	}
+}
+
+func TestSimplifyKeepsResults(t *testing.T) {
+	batch := generateTestColumns()
+	exprs := []Expression{
+		NewBinaryExpr(NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30), LessThanOrEqual, NewColumnResolve("age")),
+		NewBinaryExpr(NewBinaryExpr(NewColumnResolve("is_active"), Equal, NewLiteralResolve(arrow.FixedWidthTypes.Boolean, true)),
+			And, NewBinaryExpr(NewLiteralResolve(arrow.PrimitiveTypes.Int32, 2), LessThan, NewLiteralResolve(arrow.PrimitiveTypes.Int32, 3))),
+		NewBinaryExpr(NewColumnResolve("salary"), Multiplication,
+			NewBinaryExpr(NewLiteralResolve(arrow.PrimitiveTypes.Float64, 1.0), Addition, NewLiteralResolve(arrow.PrimitiveTypes.Float64, 0.5))),
+		NewCastExpr(NewBinaryExpr(NewColumnResolve("age"), Addition, NewColumnResolve("age")), arrow.PrimitiveTypes.Float64),
+	}
+	for _, e := range exprs {
+		simplified, err := Simplify(e, batch.Schema)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want, err := EvalExpression(context.Background(), e, batch)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := EvalExpression(context.Background(), simplified, batch)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !array.Equal(want, got) {
+			t.Fatalf("%s and %s disagree: %v vs %v", e, simplified, want, got)
+		}
+	}
+}
@@ -30,7 +30,7 @@ type serverConfig struct {
 }
 type batchConfig struct {
 	Size                 int    `yaml:"size"`
-	EnableParallelRead   bool   `yaml:"enable_parallel_read"`
+	EnableParallelRead   bool   `yaml:"enable_parallel_read"` // run filters and projections on every core, see the planner
 	MaxMemoryBeforeSpill uint64 `yaml:"max_memory_before_spill"`
 	MaxFileSizeMB        int    `yaml:"max_file_size_mb"` // max size of a single file
 	ShouldDownload       bool   `yaml:"should_download"`
@@ -39,10 +39,16 @@ type batchConfig struct {
 type queryConfig struct {
 	// should results be cached, server side? if so how long
 	EnableCache     bool `yaml:"enable_cache"`
-	CacheTTLSeconds int  `yaml:"cache_ttl_seconds"`
+	CacheTTLSeconds int  `yaml:"cache_ttl_seconds"` // 0 keeps results until they are evicted or their source changes
+	CacheMaxSizeMB  int  `yaml:"cache_max_size_mb"` // least recently used results are evicted past this
 	// run queries concurrently? if so what the max before blocking
 	EnableConcurrentExecution bool `yaml:"enable_concurrent_execution"`
 	MaxConcurrentQueries      int  `yaml:"max_concurrent_queries"` // blocks after this many concurrent queries until one finishes
+	// queries waiting for a slot, past this many new ones are rejected. a queued query gives up after the timeout
+	MaxQueuedQueries    int `yaml:"max_queued_queries"`
+	QueueTimeoutSeconds int `yaml:"queue_timeout_seconds"` // 0 waits until a slot frees up
+	// hard limit on what a single query may hold in memory, past it the query fails with OUT_OF_MEMORY. 0 for no limit
+	MaxMemoryMB int `yaml:"max_memory_mb"`
 }
 type metricsConfig struct {
 	EnableMetrics      bool   `yaml:"enable_metrics"`
@@ -81,8 +87,12 @@ var configInstance *Config = &Config{
 	Query: queryConfig{
 		EnableCache:               true,
 		CacheTTLSeconds:           600, // 10 minutes
+		CacheMaxSizeMB:            256,
 		EnableConcurrentExecution: true,
 		MaxConcurrentQueries:      2, // 2 concurrent queries
+		MaxQueuedQueries:          16,
+		QueueTimeoutSeconds:       30,
+		MaxMemoryMB:               4096, // 4GB, spilling starts at half of that
 	},
 	Metrics: metricsConfig{
 		EnableMetrics:      true,
@@ -175,12 +185,24 @@ func mergeConfig(dst *Config, src map[string]interface{}) {
 		if v, ok := query["cache_ttl_seconds"].(int); ok {
 			dst.Query.CacheTTLSeconds = v
 		}
+		if v, ok := query["cache_max_size_mb"].(int); ok {
+			dst.Query.CacheMaxSizeMB = v
+		}
 		if v, ok := query["enable_concurrent_execution"].(bool); ok {
 			dst.Query.EnableConcurrentExecution = v
 		}
 		if v, ok := query["max_concurrent_queries"].(int); ok {
 			dst.Query.MaxConcurrentQueries = v
 		}
+		if v, ok := query["max_queued_queries"].(int); ok {
+			dst.Query.MaxQueuedQueries = v
+		}
+		if v, ok := query["queue_timeout_seconds"].(int); ok {
+			dst.Query.QueueTimeoutSeconds = v
+		}
+		if v, ok := query["max_memory_mb"].(int); ok {
+			dst.Query.MaxMemoryMB = v
+		}
 	}
 
 	// =============================
@@ -24,8 +24,12 @@ func resetConfig() {
 		Query: queryConfig{
 			EnableCache:               true,
 			CacheTTLSeconds:           600,
+			CacheMaxSizeMB:            256,
 			EnableConcurrentExecution: true,
 			MaxConcurrentQueries:      2,
+			MaxQueuedQueries:          16,
+			QueueTimeoutSeconds:       30,
+			MaxMemoryMB:               4096,
 		},
 		Metrics: metricsConfig{
 			EnableMetrics:      true,
@@ -153,12 +157,24 @@ func TestDecodeEmptyConfig(t *testing.T) {
 	if config.Query.CacheTTLSeconds != 600 {
 		t.Errorf("Expected cache TTL 600, got %d", config.Query.CacheTTLSeconds)
 	}
+	if config.Query.CacheMaxSizeMB != 256 {
+		t.Errorf("Expected cache max size 256, got %d", config.Query.CacheMaxSizeMB)
+	}
 	if !config.Query.EnableConcurrentExecution {
 		t.Error("Expected enable concurrent execution true")
 	}
 	if config.Query.MaxConcurrentQueries != 2 {
 		t.Errorf("Expected max concurrent queries 2, got %d", config.Query.MaxConcurrentQueries)
 	}
+	if config.Query.MaxQueuedQueries != 16 {
+		t.Errorf("Expected max queued queries 16, got %d", config.Query.MaxQueuedQueries)
+	}
+	if config.Query.QueueTimeoutSeconds != 30 {
+		t.Errorf("Expected queue timeout 30, got %d", config.Query.QueueTimeoutSeconds)
+	}
+	if config.Query.MaxMemoryMB != 4096 {
+		t.Errorf("Expected max memory 4096MB, got %d", config.Query.MaxMemoryMB)
+	}
 
 	if !config.Metrics.EnableMetrics {
 		t.Error("Expected enable metrics true")
@@ -339,6 +355,12 @@ func TestMergeConfigQueryPartial(t *testing.T) {
 	if config.Query.MaxConcurrentQueries != 8 {
 		t.Errorf("Expected max concurrent queries 8, got %d", config.Query.MaxConcurrentQueries)
 	}
+	if config.Query.QueueTimeoutSeconds != 5 {
+		t.Errorf("Expected queue timeout 5, got %d", config.Query.QueueTimeoutSeconds)
+	}
+	if config.Query.MaxMemoryMB != 512 {
+		t.Errorf("Expected max memory 512MB, got %d", config.Query.MaxMemoryMB)
+	}
 
 	// Verify preserved defaults
 	if config.Query.CacheTTLSeconds != 600 {
@@ -0,0 +1,161 @@
+package logicalplan
+
+import (
+	"fmt"
+	"opti-sql-go/Expr"
+	"opti-sql-go/operators/aggr"
+	"strings"
+)
+
+// Format prints the plan as an indented tree, one node per line, children below their parent
+//
+//	Project: id, name
+//	  Filter: (age > 30)
+//	    Scan: source1
+func Format(p Plan) string {
+	var b strings.Builder
+	formatNode(&b, p, 0)
+	return b.String()
+}
+
+func formatNode(b *strings.Builder, p Plan, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(p.String())
+	b.WriteByte('\n')
+	for _, c := range p.Children() {
+		formatNode(b, c, depth+1)
+	}
+}
+
+// FormatExpr prints an expression the way it would look in sql, much easier to read
+// in plans than the Expr String() methods
+func FormatExpr(e Expr.Expression) string {
+	switch ex := e.(type) {
+	case *Expr.ColumnResolve:
+		return ex.Name
+	case *Expr.LiteralResolve:
+		switch v := ex.Value.(type) {
+		case nil:
+			return "NULL"
+		case string:
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		case []byte:
+			return fmt.Sprintf("X'%X'", v)
+		default:
+			return fmt.Sprintf("%v", v)
+		}
+	case *Expr.Alias:
+		return fmt.Sprintf("%s AS %s", FormatExpr(ex.Expr), ex.Name)
+	case *Expr.BinaryExpr:
+		return fmt.Sprintf("(%s %s %s)", FormatExpr(ex.Left), binaryOpSymbol(ex), FormatExpr(ex.Right))
+	case *Expr.ScalarFunction:
+		return fmt.Sprintf("%s(%s)", scalarFunctionName(ex), FormatExpr(ex.Arguments))
+	case *Expr.CastExpr:
+		return fmt.Sprintf("CAST(%s AS %s)", FormatExpr(ex.Expr), ex.TargetType)
+	case *Expr.NullCheckExpr:
+		return fmt.Sprintf("%s IS NOT NULL", FormatExpr(ex.Expr))
+	case *Expr.NotExpr:
+		if check, ok := ex.Expr.(*Expr.NullCheckExpr); ok {
+			return fmt.Sprintf("%s IS NULL", FormatExpr(check.Expr))
+		}
+		return fmt.Sprintf("NOT %s", FormatExpr(ex.Expr))
+	default:
+		return e.String()
+	}
+}
+
+func FormatExprs(exprs []Expr.Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = FormatExpr(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func FormatAggregate(a aggr.AggregateFunctions) string {
+	switch {
+	case a.AggrFunc == aggr.CountStar:
+		return "COUNT(*)"
+	case a.Distinct:
+		return fmt.Sprintf("%s(DISTINCT %s)", aggrFuncName(a.AggrFunc), FormatExpr(a.Child))
+	default:
+		return fmt.Sprintf("%s(%s)", aggrFuncName(a.AggrFunc), FormatExpr(a.Child))
+	}
+}
+
+func FormatSortKey(k aggr.SortKey) string {
+	dir := "DESC"
+	if k.Ascending {
+		dir = "ASC"
+	}
+	nulls := "LAST"
+	if k.NullFirst {
+		nulls = "FIRST"
+	}
+	return fmt.Sprintf("%s %s NULLS %s", FormatExpr(k.Expr), dir, nulls)
+}
+
+func binaryOpSymbol(b *Expr.BinaryExpr) string {
+	switch b.Op {
+	case Expr.Addition:
+		return "+"
+	case Expr.Subtraction:
+		return "-"
+	case Expr.Multiplication:
+		return "*"
+	case Expr.Division:
+		return "/"
+	case Expr.Equal:
+		return "="
+	case Expr.NotEqual:
+		return "!="
+	case Expr.LessThan:
+		return "<"
+	case Expr.LessThanOrEqual:
+		return "<="
+	case Expr.GreaterThan:
+		return ">"
+	case Expr.GreaterThanOrEqual:
+		return ">="
+	case Expr.And:
+		return "AND"
+	case Expr.Or:
+		return "OR"
+	case Expr.Like:
+		return "LIKE"
+	default:
+		return fmt.Sprintf("op(%d)", b.Op)
+	}
+}
+
+func scalarFunctionName(s *Expr.ScalarFunction) string {
+	switch s.Function {
+	case Expr.Upper:
+		return "UPPER"
+	case Expr.Lower:
+		return "LOWER"
+	case Expr.Abs:
+		return "ABS"
+	case Expr.Round:
+		return "ROUND"
+	default:
+		return fmt.Sprintf("func(%d)", s.Function)
+	}
+}
+
+func aggrFuncName(f aggr.AggrFunc) string {
+	switch f {
+	case aggr.Min:
+		return "MIN"
+	case aggr.Max:
+		return "MAX"
+	case aggr.Count, aggr.CountStar:
+		return "COUNT"
+	case aggr.Sum:
+		return "SUM"
+	case aggr.Avg:
+		return "AVG"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,63 @@
+package logicalplan
+
+import (
+	"opti-sql-go/Expr"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func TestFormatExpr(t *testing.T) {
+	col := Expr.NewColumnResolve
+	cases := []struct {
+		expr Expr.Expression
+		want string
+	}{
+		{col("age"), "age"},
+		{Expr.NewLiteralResolve(arrow.BinaryTypes.String, "it's"), "'it''s'"},
+		{Expr.NewLiteralResolve(arrow.Null, nil), "NULL"},
+		{Expr.NewLiteralResolve(arrow.BinaryTypes.Binary, []byte{0xCA, 0xFE}), "X'CAFE'"},
+		{Expr.NewBinaryExpr(col("a"), Expr.LessThanOrEqual, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, 3)), "(a <= 3)"},
+		{Expr.NewAlias(Expr.NewScalarFunction(Expr.Lower, col("name")), "n"), "LOWER(name) AS n"},
+		{Expr.NewCastExpr(col("age"), arrow.PrimitiveTypes.Float64), "CAST(age AS float64)"},
+		{Expr.NewNullCheckExpr(col("email")), "email IS NOT NULL"},
+		{Expr.NewNotExpr(Expr.NewNullCheckExpr(col("email"))), "email IS NULL"},
+		{Expr.NewNotExpr(col("active")), "NOT active"},
+	}
+	for _, c := range cases {
+		if got := FormatExpr(c.expr); got != c.want {
+			t.Fatalf("expected %s, got %s", c.want, got)
+		}
+	}
+}
+
+func TestFormatPlan(t *testing.T) {
+	left := mustScan(t, "people", peopleSchema(), nil)
+	right := mustScan(t, "depts", deptSchema(), []string{"id", "dept"})
+	keys := []Expr.Expression{Expr.NewColumnResolve("id")}
+	j, err := NewJoin(left, right, join.InnerJoin, keys, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	agg, err := NewAggregate(j, []Expr.Expression{Expr.NewColumnResolve("dept")},
+		[]aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Max, Expr.NewColumnResolve("age"))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sorted, err := NewSort(agg, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("max_Column(age)"), false, true)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `Limit: 1
+  Sort: max_Column(age) DESC NULLS FIRST
+    Aggregate: groupBy=[dept] aggr=[MAX(age)]
+      Join: INNER JOIN ON id = id
+        Scan: people
+        Scan: depts projection=[id, dept]
+`
+	if got := Format(NewLimit(sorted, 1)); got != want {
+		t.Fatalf("unexpected plan\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,366 @@
+package logicalplan
+
+import (
+	"errors"
+	"fmt"
+	"opti-sql-go/Expr"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// the logical plan sits between the sql syntax tree and the physical operators. every node has
+// already been resolved against its input schema, so column references are plain Expr.ColumnResolve
+// values naming a field of the child's schema and every expression has a known type.
+// nodes never touch data, the physical planner turns them into operators.
+
+var (
+	ErrTableNotFound = func(name string) error {
+		return fmt.Errorf("table %q not found", name)
+	}
+	ErrColumnNotFound = func(name string, schema *arrow.Schema) error {
+		return fmt.Errorf("column %q not found in schema %v", name, schema)
+	}
+	ErrNonBooleanPredicate = func(pred Expr.Expression, dt arrow.DataType) error {
+		return fmt.Errorf("predicate %s must be boolean, got %s", FormatExpr(pred), dt)
+	}
+	ErrJoinKeyCount = func(l, r int) error {
+		return fmt.Errorf("mismatched number of join keys, left: %d vs right: %d", l, r)
+	}
+	ErrEmptyProjection = errors.New("projection must have at least one expression")
+)
+
+var (
+	_ = (Plan)(&Scan{})
+	_ = (Plan)(&Filter{})
+	_ = (Plan)(&Project{})
+	_ = (Plan)(&Aggregate{})
+	_ = (Plan)(&Having{})
+	_ = (Plan)(&Sort{})
+	_ = (Plan)(&Limit{})
+	_ = (Plan)(&Join{})
+	_ = (Plan)(&Distinct{})
+)
+
+// Plan is a node of the logical plan tree
+type Plan interface {
+	Schema() *arrow.Schema
+	Children() []Plan
+	// one line description of this node only, Format prints the whole tree
+	String() string
+}
+
+// Catalog maps table names used in a query to the schema of the source behind them
+type Catalog interface {
+	TableSchema(name string) (*arrow.Schema, error)
+}
+
+// MapCatalog is the simplest Catalog, handy for tests
+type MapCatalog map[string]*arrow.Schema
+
+func (m MapCatalog) TableSchema(name string) (*arrow.Schema, error) {
+	if s, ok := m[name]; ok {
+		return s, nil
+	}
+	return nil, ErrTableNotFound(name)
+}
+
+// ================
+// Scan
+// ================
+
+// Scan reads a table. Columns is the subset of source columns actually needed,
+// nil means all of them
+type Scan struct {
+	Table   string
+	Source  *arrow.Schema // full schema of the table
+	Columns []string
+	schema  *arrow.Schema
+}
+
+func NewScan(table string, source *arrow.Schema, columns []string) (*Scan, error) {
+	schema := source
+	if columns != nil {
+		fields := make([]arrow.Field, 0, len(columns))
+		for _, c := range columns {
+			idx := source.FieldIndices(c)
+			if len(idx) == 0 {
+				return nil, ErrColumnNotFound(c, source)
+			}
+			fields = append(fields, source.Field(idx[0]))
+		}
+		schema = arrow.NewSchema(fields, nil)
+	}
+	return &Scan{
+		Table:   table,
+		Source:  source,
+		Columns: columns,
+		schema:  schema,
+	}, nil
+}
+
+func (s *Scan) Schema() *arrow.Schema { return s.schema }
+func (s *Scan) Children() []Plan      { return nil }
+func (s *Scan) String() string {
+	if s.Columns == nil {
+		return fmt.Sprintf("Scan: %s", s.Table)
+	}
+	return fmt.Sprintf("Scan: %s projection=[%s]", s.Table, strings.Join(s.Columns, ", "))
+}
+
+// ================
+// Filter
+// ================
+
+// Filter keeps the rows where Predicate is true
+// sql: WHERE predicate
+type Filter struct {
+	Input     Plan
+	Predicate Expr.Expression
+}
+
+func NewFilter(input Plan, predicate Expr.Expression) (*Filter, error) {
+	if err := checkBoolean(predicate, input.Schema()); err != nil {
+		return nil, err
+	}
+	return &Filter{Input: input, Predicate: predicate}, nil
+}
+
+func (f *Filter) Schema() *arrow.Schema { return f.Input.Schema() }
+func (f *Filter) Children() []Plan      { return []Plan{f.Input} }
+func (f *Filter) String() string        { return fmt.Sprintf("Filter: %s", FormatExpr(f.Predicate)) }
+
+// ================
+// Project
+// ================
+
+// Project evaluates Exprs over its input. output names follow project.NewProjectExec:
+// aliases keep their name, bare columns keep theirs and anything else becomes col_<i>
+type Project struct {
+	Input  Plan
+	Exprs  []Expr.Expression
+	schema *arrow.Schema
+}
+
+func NewProject(input Plan, exprs []Expr.Expression) (*Project, error) {
+	if len(exprs) == 0 {
+		return nil, ErrEmptyProjection
+	}
+	fields := make([]arrow.Field, len(exprs))
+	for i, e := range exprs {
+		dt, err := Expr.ExprDataType(e, input.Schema())
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = arrow.Field{Name: ProjectedName(e, i), Type: dt, Nullable: true}
+	}
+	return &Project{
+		Input:  input,
+		Exprs:  exprs,
+		schema: arrow.NewSchema(fields, nil),
+	}, nil
+}
+
+// ProjectedName is the output column name a projection gives expression i
+func ProjectedName(e Expr.Expression, i int) string {
+	switch ex := e.(type) {
+	case *Expr.Alias:
+		return ex.Name
+	case *Expr.ColumnResolve:
+		return ex.Name
+	default:
+		return fmt.Sprintf("col_%d", i)
+	}
+}
+
+func (p *Project) Schema() *arrow.Schema { return p.schema }
+func (p *Project) Children() []Plan      { return []Plan{p.Input} }
+func (p *Project) String() string        { return fmt.Sprintf("Project: %s", FormatExprs(p.Exprs)) }
+
+// ================
+// Aggregate
+// ================
+
+// Aggregate groups its input by GroupBy and computes Aggregates per group. with no group by
+// expressions it is a global aggregation producing a single row.
+// output is the group columns (group_<expr>) followed by the aggregates (<func>_<expr>)
+type Aggregate struct {
+	Input      Plan
+	GroupBy    []Expr.Expression
+	Aggregates []aggr.AggregateFunctions
+	schema     *arrow.Schema
+}
+
+func NewAggregate(input Plan, groupBy []Expr.Expression, aggregates []aggr.AggregateFunctions) (*Aggregate, error) {
+	schema, err := aggr.AggregateSchema(input.Schema(), groupBy, aggregates)
+	if err != nil {
+		return nil, err
+	}
+	return &Aggregate{
+		Input:      input,
+		GroupBy:    groupBy,
+		Aggregates: aggregates,
+		schema:     schema,
+	}, nil
+}
+
+func (a *Aggregate) Schema() *arrow.Schema { return a.schema }
+func (a *Aggregate) Children() []Plan      { return []Plan{a.Input} }
+func (a *Aggregate) String() string {
+	aggs := make([]string, len(a.Aggregates))
+	for i, agg := range a.Aggregates {
+		aggs[i] = FormatAggregate(agg)
+	}
+	return fmt.Sprintf("Aggregate: groupBy=[%s] aggr=[%s]", FormatExprs(a.GroupBy), strings.Join(aggs, ", "))
+}
+
+// ================
+// Having
+// ================
+
+// Having filters the output of an Aggregate
+// sql: HAVING predicate
+type Having struct {
+	Input     Plan
+	Predicate Expr.Expression
+}
+
+func NewHaving(input Plan, predicate Expr.Expression) (*Having, error) {
+	if err := checkBoolean(predicate, input.Schema()); err != nil {
+		return nil, err
+	}
+	return &Having{Input: input, Predicate: predicate}, nil
+}
+
+func (h *Having) Schema() *arrow.Schema { return h.Input.Schema() }
+func (h *Having) Children() []Plan      { return []Plan{h.Input} }
+func (h *Having) String() string        { return fmt.Sprintf("Having: %s", FormatExpr(h.Predicate)) }
+
+// ================
+// Sort
+// ================
+
+// sql: ORDER BY keys
+type Sort struct {
+	Input Plan
+	Keys  []aggr.SortKey
+}
+
+func NewSort(input Plan, keys []aggr.SortKey) (*Sort, error) {
+	for _, k := range keys {
+		if _, err := Expr.ExprDataType(k.Expr, input.Schema()); err != nil {
+			return nil, err
+		}
+	}
+	return &Sort{Input: input, Keys: keys}, nil
+}
+
+func (s *Sort) Schema() *arrow.Schema { return s.Input.Schema() }
+func (s *Sort) Children() []Plan      { return []Plan{s.Input} }
+func (s *Sort) String() string {
+	keys := make([]string, len(s.Keys))
+	for i, k := range s.Keys {
+		keys[i] = FormatSortKey(k)
+	}
+	return fmt.Sprintf("Sort: %s", strings.Join(keys, ", "))
+}
+
+// ================
+// Limit
+// ================
+
+// sql: LIMIT count
+type Limit struct {
+	Input Plan
+	Count uint64
+}
+
+func NewLimit(input Plan, count uint64) *Limit {
+	return &Limit{Input: input, Count: count}
+}
+
+func (l *Limit) Schema() *arrow.Schema { return l.Input.Schema() }
+func (l *Limit) Children() []Plan      { return []Plan{l.Input} }
+func (l *Limit) String() string        { return fmt.Sprintf("Limit: %d", l.Count) }
+
+// ================
+// Join
+// ================
+
+// Join is an equi-join, LeftKeys[i] = RightKeys[i]. keys are resolved against their own side's schema.
+// output columns are left then right, clashing names get a left_/right_ prefix (see join.JoinSchemas)
+type Join struct {
+	Left      Plan
+	Right     Plan
+	Type      join.JoinType
+	LeftKeys  []Expr.Expression
+	RightKeys []Expr.Expression
+	schema    *arrow.Schema
+}
+
+func NewJoin(left, right Plan, joinType join.JoinType, leftKeys, rightKeys []Expr.Expression) (*Join, error) {
+	if len(leftKeys) != len(rightKeys) {
+		return nil, ErrJoinKeyCount(len(leftKeys), len(rightKeys))
+	}
+	for i := range leftKeys {
+		if _, err := Expr.ExprDataType(leftKeys[i], left.Schema()); err != nil {
+			return nil, err
+		}
+		if _, err := Expr.ExprDataType(rightKeys[i], right.Schema()); err != nil {
+			return nil, err
+		}
+	}
+	schema, err := join.JoinSchemas(left.Schema(), right.Schema())
+	if err != nil {
+		return nil, err
+	}
+	return &Join{
+		Left:      left,
+		Right:     right,
+		Type:      joinType,
+		LeftKeys:  leftKeys,
+		RightKeys: rightKeys,
+		schema:    schema,
+	}, nil
+}
+
+func (j *Join) Schema() *arrow.Schema { return j.schema }
+func (j *Join) Children() []Plan      { return []Plan{j.Left, j.Right} }
+func (j *Join) String() string {
+	on := make([]string, len(j.LeftKeys))
+	for i := range j.LeftKeys {
+		on[i] = fmt.Sprintf("%s = %s", FormatExpr(j.LeftKeys[i]), FormatExpr(j.RightKeys[i]))
+	}
+	return fmt.Sprintf("Join: %s ON %s", j.Type, strings.Join(on, " AND "))
+}
+
+// ================
+// Distinct
+// ================
+
+// Distinct removes duplicate rows, comparing every column
+// sql: SELECT DISTINCT
+type Distinct struct {
+	Input Plan
+}
+
+func NewDistinct(input Plan) *Distinct {
+	return &Distinct{Input: input}
+}
+
+func (d *Distinct) Schema() *arrow.Schema { return d.Input.Schema() }
+func (d *Distinct) Children() []Plan      { return []Plan{d.Input} }
+func (d *Distinct) String() string        { return "Distinct" }
+
+func checkBoolean(pred Expr.Expression, schema *arrow.Schema) error {
+	dt, err := Expr.ExprDataType(pred, schema)
+	if err != nil {
+		return err
+	}
+	if dt.ID() != arrow.BOOL {
+		return ErrNonBooleanPredicate(pred, dt)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package logicalplan
+
+import (
+	"opti-sql-go/Expr"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func peopleSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "age", Type: arrow.PrimitiveTypes.Int32},
+	}, nil)
+}
+
+func deptSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "dept", Type: arrow.BinaryTypes.String},
+	}, nil)
+}
+
+func mustScan(t *testing.T, table string, schema *arrow.Schema, columns []string) *Scan {
+	t.Helper()
+	s, err := NewScan(table, schema, columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestScan(t *testing.T) {
+	t.Run("all columns", func(t *testing.T) {
+		s := mustScan(t, "people", peopleSchema(), nil)
+		if !s.Schema().Equal(peopleSchema()) {
+			t.Fatalf("expected full schema, got %v", s.Schema())
+		}
+	})
+	t.Run("projection", func(t *testing.T) {
+		s := mustScan(t, "people", peopleSchema(), []string{"age", "id"})
+		if s.Schema().NumFields() != 2 || s.Schema().Field(0).Name != "age" {
+			t.Fatalf("unexpected projected schema %v", s.Schema())
+		}
+		if s.String() != "Scan: people projection=[age, id]" {
+			t.Fatalf("unexpected description %s", s)
+		}
+	})
+	t.Run("unknown column", func(t *testing.T) {
+		if _, err := NewScan("people", peopleSchema(), []string{"nope"}); err == nil {
+			t.Fatalf("expected error for unknown column")
+		}
+	})
+}
+
+func TestCatalog(t *testing.T) {
+	c := MapCatalog{"people": peopleSchema()}
+	if _, err := c.TableSchema("people"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.TableSchema("missing"); err == nil {
+		t.Fatalf("expected error for missing table")
+	}
+}
+
+func TestFilterAndHaving(t *testing.T) {
+	scan := mustScan(t, "people", peopleSchema(), nil)
+	pred := Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30))
+	f, err := NewFilter(scan, pred)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.Schema().Equal(scan.Schema()) {
+		t.Fatalf("filter must not change the schema")
+	}
+	if _, err := NewFilter(scan, Expr.NewColumnResolve("age")); err == nil {
+		t.Fatalf("expected error for non boolean predicate")
+	}
+	if _, err := NewHaving(scan, Expr.NewColumnResolve("name")); err == nil {
+		t.Fatalf("expected error for non boolean having")
+	}
+}
+
+func TestProject(t *testing.T) {
+	scan := mustScan(t, "people", peopleSchema(), nil)
+	p, err := NewProject(scan, []Expr.Expression{
+		Expr.NewColumnResolve("name"),
+		Expr.NewAlias(Expr.NewColumnResolve("age"), "years"),
+		Expr.NewScalarFunction(Expr.Upper, Expr.NewColumnResolve("name")),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"name", "years", "col_2"}
+	for i, f := range p.Schema().Fields() {
+		if f.Name != want[i] {
+			t.Fatalf("expected %s, got %s", want[i], f.Name)
+		}
+	}
+	if !arrow.TypeEqual(p.Schema().Field(1).Type, arrow.PrimitiveTypes.Int32) {
+		t.Fatalf("alias must keep the type, got %s", p.Schema().Field(1).Type)
+	}
+	if _, err := NewProject(scan, nil); err == nil {
+		t.Fatalf("expected error for empty projection")
+	}
+	if _, err := NewProject(scan, []Expr.Expression{Expr.NewColumnResolve("nope")}); err == nil {
+		t.Fatalf("expected error for unknown column")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	scan := mustScan(t, "people", peopleSchema(), nil)
+	aggs := []aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Avg, Expr.NewColumnResolve("age"))}
+	a, err := NewAggregate(scan, []Expr.Expression{Expr.NewColumnResolve("name")}, aggs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Schema().Field(0).Name != "group_Column(name)" || a.Schema().Field(1).Name != "avg_Column(age)" {
+		t.Fatalf("unexpected aggregate schema %v", a.Schema())
+	}
+	global, err := NewAggregate(scan, nil, aggs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if global.Schema().NumFields() != 1 {
+		t.Fatalf("expected a single column, got %v", global.Schema())
+	}
+	bad := []aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Sum, Expr.NewColumnResolve("name"))}
+	if _, err := NewAggregate(scan, nil, bad); err == nil {
+		t.Fatalf("expected error when summing strings")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	left := mustScan(t, "people", peopleSchema(), nil)
+	right := mustScan(t, "depts", deptSchema(), nil)
+	keys := []Expr.Expression{Expr.NewColumnResolve("id")}
+	j, err := NewJoin(left, right, join.InnerJoin, keys, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"left_id", "name", "age", "right_id", "dept"}
+	for i, f := range j.Schema().Fields() {
+		if f.Name != want[i] {
+			t.Fatalf("expected %s, got %s", want[i], f.Name)
+		}
+	}
+	if len(j.Children()) != 2 {
+		t.Fatalf("expected 2 children")
+	}
+	if _, err := NewJoin(left, right, join.InnerJoin, keys, nil); err == nil {
+		t.Fatalf("expected error for mismatched key count")
+	}
+	if _, err := NewJoin(left, right, join.InnerJoin, []Expr.Expression{Expr.NewColumnResolve("dept")}, keys); err == nil {
+		t.Fatalf("expected error for key missing on its side")
+	}
+}
+
+func TestSortLimitDistinct(t *testing.T) {
+	scan := mustScan(t, "people", peopleSchema(), nil)
+	s, err := NewSort(scan, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("age"), true)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewSort(scan, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("nope")))); err == nil {
+		t.Fatalf("expected error for unknown sort key")
+	}
+	plan := NewLimit(NewDistinct(s), 10)
+	if !plan.Schema().Equal(scan.Schema()) {
+		t.Fatalf("sort, distinct and limit must not change the schema")
+	}
+}
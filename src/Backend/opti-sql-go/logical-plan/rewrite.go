@@ -0,0 +1,116 @@
+package logicalplan
+
+import (
+	"fmt"
+	"opti-sql-go/Expr"
+)
+
+// helpers for code that rewrites plans (the binder and the optimizer rules). plan nodes cache
+// their schema, so a node is never edited in place, it is rebuilt on top of its new children
+
+// WithChildren rebuilds p on top of children, recomputing its schema. children must be in the
+// same order Children() returns them
+func WithChildren(p Plan, children []Plan) (Plan, error) {
+	if len(children) != len(p.Children()) {
+		return nil, fmt.Errorf("%T expects %d children, got %d", p, len(p.Children()), len(children))
+	}
+	switch n := p.(type) {
+	case *Scan:
+		return n, nil
+	case *Filter:
+		return NewFilter(children[0], n.Predicate)
+	case *Project:
+		return NewProject(children[0], n.Exprs)
+	case *Aggregate:
+		return NewAggregate(children[0], n.GroupBy, n.Aggregates)
+	case *Having:
+		return NewHaving(children[0], n.Predicate)
+	case *Sort:
+		return NewSort(children[0], n.Keys)
+	case *Limit:
+		return NewLimit(children[0], n.Count), nil
+	case *Join:
+		return NewJoin(children[0], children[1], n.Type, n.LeftKeys, n.RightKeys)
+	case *Distinct:
+		return NewDistinct(children[0]), nil
+	default:
+		return nil, fmt.Errorf("cannot rebuild plan node %T", p)
+	}
+}
+
+// ColumnRefs returns the names of every column e reads, each name once in order of appearance
+func ColumnRefs(e Expr.Expression) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(e Expr.Expression)
+	walk = func(e Expr.Expression) {
+		switch ex := e.(type) {
+		case *Expr.ColumnResolve:
+			if !seen[ex.Name] {
+				seen[ex.Name] = true
+				names = append(names, ex.Name)
+			}
+		case *Expr.Alias:
+			walk(ex.Expr)
+		case *Expr.BinaryExpr:
+			walk(ex.Left)
+			walk(ex.Right)
+		case *Expr.ScalarFunction:
+			walk(ex.Arguments)
+		case *Expr.CastExpr:
+			walk(ex.Expr)
+		case *Expr.NullCheckExpr:
+			walk(ex.Expr)
+		case *Expr.NotExpr:
+			walk(ex.Expr)
+		}
+	}
+	walk(e)
+	return names
+}
+
+// ReplaceColumns replaces column references by name, used to move an expression written
+// against one schema onto another (e.g. from above a projection to below it)
+func ReplaceColumns(e Expr.Expression, byName map[string]Expr.Expression) Expr.Expression {
+	switch ex := e.(type) {
+	case *Expr.ColumnResolve:
+		if r, ok := byName[ex.Name]; ok {
+			return r
+		}
+		return ex
+	case *Expr.Alias:
+		return Expr.NewAlias(ReplaceColumns(ex.Expr, byName), ex.Name)
+	case *Expr.BinaryExpr:
+		return &Expr.BinaryExpr{Left: ReplaceColumns(ex.Left, byName), Op: ex.Op, Right: ReplaceColumns(ex.Right, byName)}
+	case *Expr.ScalarFunction:
+		return &Expr.ScalarFunction{Function: ex.Function, Arguments: ReplaceColumns(ex.Arguments, byName)}
+	case *Expr.CastExpr:
+		return Expr.NewCastExpr(ReplaceColumns(ex.Expr, byName), ex.TargetType)
+	case *Expr.NullCheckExpr:
+		return Expr.NewNullCheckExpr(ReplaceColumns(ex.Expr, byName))
+	case *Expr.NotExpr:
+		return Expr.NewNotExpr(ReplaceColumns(ex.Expr, byName))
+	default:
+		return e
+	}
+}
+
+// SplitConjunction breaks a AND b AND c into [a, b, c]
+func SplitConjunction(e Expr.Expression) []Expr.Expression {
+	if b, ok := e.(*Expr.BinaryExpr); ok && b.Op == Expr.And {
+		return append(SplitConjunction(b.Left), SplitConjunction(b.Right)...)
+	}
+	return []Expr.Expression{e}
+}
+
+// Conjunction is the inverse of SplitConjunction, nil for an empty list
+func Conjunction(exprs []Expr.Expression) Expr.Expression {
+	if len(exprs) == 0 {
+		return nil
+	}
+	out := exprs[0]
+	for _, e := range exprs[1:] {
+		out = Expr.NewBinaryExpr(out, Expr.And, e)
+	}
+	return out
+}
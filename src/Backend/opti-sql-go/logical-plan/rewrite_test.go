@@ -0,0 +1,88 @@
+package logicalplan
+
+import (
+	"opti-sql-go/Expr"
+	join "opti-sql-go/operators/Join"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func TestWithChildren(t *testing.T) {
+	full := mustScan(t, "people", peopleSchema(), nil)
+	pruned := mustScan(t, "people", peopleSchema(), []string{"age", "id"})
+	pred := Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30))
+	f, err := NewFilter(full, pred)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Run("schema follows the new child", func(t *testing.T) {
+		rebuilt, err := WithChildren(f, []Plan{pruned})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !rebuilt.Schema().Equal(pruned.Schema()) {
+			t.Fatalf("expected %v, got %v", pruned.Schema(), rebuilt.Schema())
+		}
+	})
+	t.Run("validation runs again", func(t *testing.T) {
+		if _, err := WithChildren(f, []Plan{mustScan(t, "people", peopleSchema(), []string{"id"})}); err == nil {
+			t.Fatalf("expected error, age is no longer available")
+		}
+	})
+	t.Run("wrong number of children", func(t *testing.T) {
+		if _, err := WithChildren(f, []Plan{full, full}); err == nil {
+			t.Fatalf("expected error for two children")
+		}
+	})
+	t.Run("join", func(t *testing.T) {
+		keys := []Expr.Expression{Expr.NewColumnResolve("id")}
+		j, err := NewJoin(full, mustScan(t, "depts", deptSchema(), nil), join.InnerJoin, keys, keys)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rebuilt, err := WithChildren(j, []Plan{pruned, mustScan(t, "depts", deptSchema(), []string{"id"})})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rebuilt.Schema().NumFields() != 3 || rebuilt.Schema().Field(2).Name != "right_id" {
+			t.Fatalf("unexpected join schema %v", rebuilt.Schema())
+		}
+	})
+}
+
+func TestColumnRefsAndReplace(t *testing.T) {
+	col := Expr.NewColumnResolve
+	e := Expr.NewBinaryExpr(
+		Expr.NewBinaryExpr(Expr.NewCastExpr(col("age"), arrow.PrimitiveTypes.Float64), Expr.Addition, col("salary")),
+		Expr.GreaterThan,
+		Expr.NewScalarFunction(Expr.Abs, col("age")),
+	)
+	if got := ColumnRefs(e); !reflect.DeepEqual(got, []string{"age", "salary"}) {
+		t.Fatalf("expected [age salary], got %v", got)
+	}
+	replaced := ReplaceColumns(e, map[string]Expr.Expression{"age": col("years")})
+	if got := FormatExpr(replaced); got != "((CAST(years AS float64) + salary) > ABS(years))" {
+		t.Fatalf("unexpected rewrite %s", got)
+	}
+	if got := FormatExpr(e); got != "((CAST(age AS float64) + salary) > ABS(age))" {
+		t.Fatalf("the original expression must not change, got %s", got)
+	}
+}
+
+func TestConjunction(t *testing.T) {
+	col := Expr.NewColumnResolve
+	a, b, c := col("a"), col("b"), col("c")
+	e := Expr.NewBinaryExpr(Expr.NewBinaryExpr(a, Expr.And, b), Expr.And, Expr.NewBinaryExpr(c, Expr.Or, a))
+	parts := SplitConjunction(e)
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 conjuncts, got %d", len(parts))
+	}
+	if got := FormatExpr(Conjunction(parts)); got != "((a AND b) AND (c OR a))" {
+		t.Fatalf("unexpected conjunction %s", got)
+	}
+	if Conjunction(nil) != nil {
+		t.Fatalf("expected nil for no conjuncts")
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+var _ = (memory.Allocator)(&Allocator{})
+
+// Allocator counts the bytes handed out by the allocator it wraps that weren't freed yet. with the go
+// allocator a buffer is only freed once its last reference is released, arrays nobody releases
+// are left to the gc and stay counted
+type Allocator struct {
+	mem   memory.Allocator
+	inUse atomic.Int64
+}
+
+func NewAllocator(mem memory.Allocator) *Allocator {
+	return &Allocator{mem: mem}
+}
+
+func (a *Allocator) Allocate(size int) []byte {
+	a.inUse.Add(int64(size))
+	return a.mem.Allocate(size)
+}
+
+func (a *Allocator) Reallocate(size int, b []byte) []byte {
+	a.inUse.Add(int64(size - len(b)))
+	return a.mem.Reallocate(size, b)
+}
+
+func (a *Allocator) Free(b []byte) {
+	a.inUse.Add(-int64(len(b)))
+	a.mem.Free(b)
+}
+
+// InUse is the number of bytes allocated and not freed yet
+func (a *Allocator) InUse() int64 {
+	return a.inUse.Load()
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+func TestAllocator(t *testing.T) {
+	alloc := NewAllocator(memory.NewGoAllocator())
+	b := array.NewInt64Builder(alloc)
+	b.AppendValues([]int64{1, 2, 3, 4, 5}, nil)
+	arr := b.NewArray()
+	b.Release()
+	if alloc.InUse() <= 0 {
+		t.Fatalf("expected the array's buffers to be counted, got %d", alloc.InUse())
+	}
+	arr.Release()
+	if alloc.InUse() != 0 {
+		t.Fatalf("expected everything freed after the release, got %d", alloc.InUse())
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"opti-sql-go/config"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// the server reports into the collectors below whether or not metrics are enabled, the config only
+// decides what gets registered and therefore exported on /metrics:
+//   - enable_query_stats: queries started, succeeded and failed, latencies, rows and bytes scanned
+//   - enable_memory_stats: go runtime (heap) and arrow allocator bytes in use
+//
+// the admission queue depth and running queries are always exported
+
+const namespace = "optisql"
+
+var (
+	queriesStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_started_total",
+		Help:      "Queries received, per api (execute, stream, flightsql).",
+	}, []string{"api"})
+	queriesSucceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_succeeded_total",
+		Help:      "Queries that returned their whole result, per api.",
+	}, []string{"api"})
+	queriesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_failed_total",
+		Help:      "Queries that failed, per api and the error type the client got back.",
+	}, []string{"api", "error_type"})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Time from receiving a query to its last batch or error, queueing included.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~33s
+	}, []string{"api"})
+	queueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "admission_wait_seconds",
+		Help:      "Time admitted queries waited for a slot.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+	})
+	rowsScanned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "source_rows_scanned_total",
+		Help:      "Rows read from sources, per source kind (csv, parquet).",
+	}, []string{"kind"})
+	bytesScanned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "source_bytes_scanned_total",
+		Help:      "Bytes read from the object store by scans, per source kind (csv, parquet).",
+	}, []string{"kind"})
+)
+
+func QueryStarted(api string) {
+	queriesStarted.WithLabelValues(api).Inc()
+}
+
+func QuerySucceeded(api string, elapsed time.Duration) {
+	queriesSucceeded.WithLabelValues(api).Inc()
+	queryDuration.WithLabelValues(api).Observe(elapsed.Seconds())
+}
+
+func QueryFailed(api, errorType string, elapsed time.Duration) {
+	queriesFailed.WithLabelValues(api, errorType).Inc()
+	queryDuration.WithLabelValues(api).Observe(elapsed.Seconds())
+}
+
+func QueueWait(d time.Duration) {
+	queueWait.Observe(d.Seconds())
+}
+
+func RowsScanned(kind string, n uint64) {
+	rowsScanned.WithLabelValues(kind).Add(float64(n))
+}
+
+func BytesScanned(kind string, n int) {
+	bytesScanned.WithLabelValues(kind).Add(float64(n))
+}
+
+// Gauges are read on every scrape
+type Gauges struct {
+	QueueLength     func() int
+	RunningQueries  func() int
+	ArrowBytesInUse func() int64
+}
+
+// NewRegistry holds the collectors the metrics config asks for
+func NewRegistry(g Gauges) *prometheus.Registry {
+	c := config.GetConfig().Metrics
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "admission_queue_length",
+			Help:      "Queries waiting for a slot, above 0 the backend is saturated.",
+		}, func() float64 { return float64(g.QueueLength()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queries_running",
+			Help:      "Queries admitted and not finished yet.",
+		}, func() float64 { return float64(g.RunningQueries()) }),
+	)
+	if c.EnableQueryStats {
+		reg.MustRegister(queriesStarted, queriesSucceeded, queriesFailed, queryDuration, queueWait, rowsScanned, bytesScanned)
+	}
+	if c.EnableMemoryStats {
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "arrow_allocated_bytes",
+				Help:      "Bytes held by the arrow allocator the server's queries allocate from.",
+			}, func() float64 { return float64(g.ArrowBytesInUse()) }),
+		)
+	}
+	return reg
+}
+
+// Serve exposes reg on /metrics at the configured host and port until the returned server is closed,
+// its Addr is where it ended up listening
+func Serve(reg *prometheus.Registry) (*http.Server, error) {
+	c := config.GetConfig().Metrics
+	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", c.MetricsHost, c.MetricsPort))
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+	srv := &http.Server{Addr: l.Addr().String(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server stopped: %v", err)
+		}
+	}()
+	return srv, nil
+}
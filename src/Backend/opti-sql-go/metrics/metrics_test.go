@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"opti-sql-go/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func gauges() Gauges {
+	return Gauges{
+		QueueLength:     func() int { return 3 },
+		RunningQueries:  func() int { return 2 },
+		ArrowBytesInUse: func() int64 { return 1024 },
+	}
+}
+
+// families returns the names of the metric families reg exports
+func families(t *testing.T, reg *prometheus.Registry) map[string]bool {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	return names
+}
+
+func TestRegistryFollowsConfig(t *testing.T) {
+	c := &config.GetConfig().Metrics
+	queryStats, memoryStats := c.EnableQueryStats, c.EnableMemoryStats
+	t.Cleanup(func() { c.EnableQueryStats, c.EnableMemoryStats = queryStats, memoryStats })
+	QueryStarted("execute")
+	RowsScanned("csv", 10)
+
+	t.Run("everything", func(t *testing.T) {
+		c.EnableQueryStats, c.EnableMemoryStats = true, true
+		names := families(t, NewRegistry(gauges()))
+		for _, want := range []string{"optisql_admission_queue_length", "optisql_queries_started_total", "optisql_source_rows_scanned_total", "optisql_arrow_allocated_bytes", "go_memstats_heap_inuse_bytes"} {
+			if !names[want] {
+				t.Fatalf("expected %s in %v", want, names)
+			}
+		}
+	})
+	t.Run("only the admission gauges", func(t *testing.T) {
+		c.EnableQueryStats, c.EnableMemoryStats = false, false
+		names := families(t, NewRegistry(gauges()))
+		if len(names) != 2 || !names["optisql_admission_queue_length"] || !names["optisql_queries_running"] {
+			t.Fatalf("unexpected metrics %v", names)
+		}
+	})
+}
+
+func TestServe(t *testing.T) {
+	c := &config.GetConfig().Metrics
+	host, port := c.MetricsHost, c.MetricsPort
+	t.Cleanup(func() { c.MetricsHost, c.MetricsPort = host, port })
+	c.MetricsHost, c.MetricsPort = "127.0.0.1", 0
+
+	QueryStarted("stream")
+	QueryFailed("stream", "PARSE_ERROR", 5*time.Millisecond)
+	srv, err := Serve(NewRegistry(gauges()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = srv.Close() }()
+
+	resp, err := http.Get("http://" + srv.Addr + "/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"optisql_admission_queue_length 3",
+		`optisql_queries_failed_total{api="stream",error_type="PARSE_ERROR"} 1`,
+		`optisql_query_duration_seconds_bucket{api="stream",le="0.008"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected %q in\n%s", want, body)
+		}
+	}
+}
@@ -27,6 +27,8 @@ var (
 
 var (
 	_ = (operators.Operator)(&HashJoinExec{})
+	_ = (operators.MemoryReporter)(&HashJoinExec{})
+	_ = (operators.Explainer)(&HashJoinExec{})
 )
 
 type JoinType int
@@ -87,6 +89,12 @@ func NewJoinClause(leftS, rightS []Expr.Expression) JoinClause {
 	}
 }
 
+// JoinSchemas returns the output schema of joining left with right. exported so the planner can
+// resolve column names (left_id, right_id...) before any operator exists
+func JoinSchemas(left, right *arrow.Schema) (*arrow.Schema, error) {
+	return joinSchemas(left, right)
+}
+
 // left schema + right schema, if left and right have same column name, prefix with left_ and right_
 func joinSchemas(left, right *arrow.Schema) (*arrow.Schema, error) {
 	// table1 : id , name , age
@@ -148,7 +156,17 @@ type HashJoinExec struct {
 	done        bool
 	// internalState
 	outputBatch []arrow.Array // intermediate storage for output arrays
-
+	peakMemory  int64
+	// grace hash join, both inputs are held as a single partition until the query is told to spill.
+	// from then on rows are hashed into partitions by their join key and the largest partitions are
+	// written to disk. the partitions are joined pair by pair afterwards, a spilled pair that still
+	// doesn't fit is split again with a different hash
+	consumed   bool
+	whole      *joinPartition   // both inputs, while nothing was partitioned
+	partitions []*joinPartition // the partitions being filled
+	pending    []*joinPartition // partitions left to join, joined from the back
+	fanOut     int              // partitions a side is split into
+	maxDepth   int              // how often a partition can be split again
 }
 type hashEntry struct {
 	row int
@@ -158,6 +176,11 @@ type joinPair struct {
 	rightRow int
 }
 
+const (
+	defaultJoinFanOut   = 8
+	defaultJoinMaxDepth = 3
+)
+
 func NewHashJoinExec(left operators.Operator, right operators.Operator, clause JoinClause, joinType JoinType, filters []Expr.Expression) (*HashJoinExec, error) {
 	schema, err := joinSchemas(left.Schema(), right.Schema())
 	if err != nil {
@@ -174,112 +197,538 @@ func NewHashJoinExec(left operators.Operator, right operators.Operator, clause J
 		filters:     filters,
 		schema:      schema,
 		outputBatch: make([]arrow.Array, schema.NumFields()),
+		fanOut:      defaultJoinFanOut,
+		maxDepth:    defaultJoinMaxDepth,
 	}, nil
 }
 
-func (hj *HashJoinExec) Next(_ uint16) (*operators.RecordBatch, error) {
+// when nothing was spilled the whole join comes back as a single batch, otherwise there is a batch
+// for every pair of partitions that produced rows
+func (hj *HashJoinExec) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
 	if hj.done {
 		return nil, io.EOF
 	}
-	mem := memory.NewGoAllocator()
-	leftArr, err := consumeOperator(hj.leftSource, mem)
+	ctx, mem := operators.Allocator(ctx, hj)
+	if !hj.consumed {
+		if err := hj.consumeInputs(ctx, mem); err != nil {
+			return nil, err
+		}
+	}
+	if hj.whole != nil {
+		whole := hj.whole
+		hj.whole = nil
+		defer whole.close()
+		hj.done = true
+		out, err := hj.joinPartition(ctx, whole, mem)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return &operators.RecordBatch{
+				Schema:   hj.Schema(),
+				Columns:  make([]arrow.Array, hj.schema.NumFields()),
+				RowCount: 0,
+			}, nil
+		}
+		return out, nil
+	}
+	for len(hj.pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := hj.pending[len(hj.pending)-1]
+		hj.pending = hj.pending[:len(hj.pending)-1]
+		out, err := hj.joinSpilled(ctx, p, mem)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			return out, nil
+		}
+	}
+	hj.done = true
+	return nil, io.EOF
+}
+func (hj *HashJoinExec) Schema() *arrow.Schema { return hj.schema }
+func (hj *HashJoinExec) PeakMemory() int64     { return hj.peakMemory }
+func (hj *HashJoinExec) Close() error {
+	if hj.whole != nil {
+		hj.whole.close()
+		hj.whole = nil
+	}
+	closePartitions(hj.partitions)
+	closePartitions(hj.pending)
+	hj.partitions, hj.pending = nil, nil
+	err1 := hj.leftSource.Close()
+	err2 := hj.rightSource.Close()
+	if err1 != nil {
+		return err1
+	}
+	if err2 != nil {
+		return err2
+	}
+	return nil
+}
+func (hj *HashJoinExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "HashJoinExec",
+		Params:   map[string]string{"join_type": hj.joinType.String(), "on": hj.clause.String()},
+		Children: []operators.Operator{hj.leftSource, hj.rightSource},
+	}
+}
+
+// consumeInputs reads both inputs, the left one first
+func (hj *HashJoinExec) consumeInputs(ctx context.Context, mem memory.Allocator) error {
+	hj.whole = newJoinPartition(hj.leftSource.Schema(), hj.rightSource.Schema(), 0)
+	for _, left := range []bool{true, false} {
+		o := hj.rightSource
+		if left {
+			o = hj.leftSource
+		}
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			childRecordBatch, err := operators.NextBatch(ctx, o, math.MaxUint16)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return err
+			}
+			if childRecordBatch.RowCount == 0 {
+				operators.ReleaseArrays(childRecordBatch.Columns)
+				continue
+			}
+			if hj.partitions == nil {
+				if err := hj.whole.side(left).add(childRecordBatch.Columns); err != nil {
+					return err
+				}
+				hj.peakMemory = max(hj.peakMemory, hj.whole.size())
+				if !operators.ShouldSpill(ctx) {
+					continue
+				}
+				if err := hj.startPartitioning(ctx, mem); err != nil {
+					return err
+				}
+			} else if err := hj.addPartitioned(ctx, mem, left, childRecordBatch.Columns); err != nil {
+				return err
+			}
+			if err := hj.spillLargest(ctx, mem); err != nil {
+				return err
+			}
+		}
+	}
+	hj.consumed = true
+	if hj.partitions != nil {
+		// joined from the back, reverse so the first partition comes out first
+		for i := len(hj.partitions) - 1; i >= 0; i-- {
+			hj.pending = append(hj.pending, hj.partitions[i])
+		}
+		hj.partitions = nil
+	}
+	return nil
+}
+
+// startPartitioning hashes everything read so far into partitions, from here on every batch read is
+// partitioned as it comes in
+func (hj *HashJoinExec) startPartitioning(ctx context.Context, mem memory.Allocator) error {
+	whole := hj.whole
+	hj.whole = nil
+	defer whole.close()
+	hj.partitions = hj.newPartitions(0)
+	for _, left := range []bool{true, false} {
+		s := whole.side(left)
+		batches := s.batches
+		s.batches = nil
+		for i, cols := range batches {
+			if err := hj.addPartitioned(ctx, mem, left, cols); err != nil {
+				releaseBatches(batches[i+1:])
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (hj *HashJoinExec) addPartitioned(ctx context.Context, mem memory.Allocator, left bool, cols []arrow.Array) error {
+	depth := 0
+	if len(hj.partitions) > 0 {
+		depth = hj.partitions[0].depth
+	}
+	parts, err := hj.partitionBatch(ctx, mem, left, cols, depth)
+	if err != nil {
+		return err
+	}
+	for i, part := range parts {
+		if part == nil {
+			continue
+		}
+		if err := hj.partitions[i].side(left).add(part); err != nil {
+			releaseBatches(parts[i+1:])
+			return err
+		}
+	}
+	var held int64
+	for _, p := range hj.partitions {
+		held += p.size()
+	}
+	hj.peakMemory = max(hj.peakMemory, held)
+	return nil
+}
+
+// spillLargest writes the partitions holding the most to disk until the query no longer has to spill
+func (hj *HashJoinExec) spillLargest(ctx context.Context, mem memory.Allocator) error {
+	for operators.ShouldSpill(ctx) {
+		var largest *joinPartition
+		for _, p := range hj.partitions {
+			if p.size() > 0 && (largest == nil || p.size() > largest.size()) {
+				largest = p
+			}
+		}
+		if largest == nil {
+			return nil
+		}
+		if err := largest.spill(mem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hj *HashJoinExec) newPartitions(depth int) []*joinPartition {
+	parts := make([]*joinPartition, max(hj.fanOut, 1))
+	for i := range parts {
+		parts[i] = newJoinPartition(hj.leftSource.Schema(), hj.rightSource.Schema(), depth)
+	}
+	return parts
+}
+
+// partitionBatch splits the rows of a batch by the hash of their join key, depth salts the hash so a
+// partition split again spreads over new partitions. cols are released
+func (hj *HashJoinExec) partitionBatch(ctx context.Context, mem memory.Allocator, left bool, cols []arrow.Array, depth int) ([][]arrow.Array, error) {
+	defer operators.ReleaseArrays(cols)
+	exprs, schema := hj.clause.rightS, hj.rightSource.Schema()
+	if left {
+		exprs, schema = hj.clause.leftS, hj.leftSource.Schema()
+	}
+	keys, err := buildComptables(ctx, exprs, cols, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer operators.ReleaseArrays(keys)
+	rows := operators.PartitionRows(keys, cols[0].Len(), max(hj.fanOut, 1), uint64(depth))
+	parts := make([][]arrow.Array, len(rows))
+	for p, idx := range rows {
+		if len(idx) == 0 {
+			continue
+		}
+		parts[p], err = operators.TakeRows(ctx, mem, cols, idx)
+		if err != nil {
+			releaseBatches(parts)
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// joinSpilled joins a pair of partitions, nil when it produced no rows. a spilled pair whose build
+// side still doesn't fit is split again and its parts are joined later
+func (hj *HashJoinExec) joinSpilled(ctx context.Context, p *joinPartition, mem memory.Allocator) (*operators.RecordBatch, error) {
+	if p.left.rows == 0 || p.right.rows == 0 {
+		p.close()
+		return nil, nil
+	}
+	// the build side's spilled rows are measured, not loaded: a partition too big for memory is
+	// split again straight from disk
+	if p.spilled() && p.splittable && p.depth < hj.maxDepth && operators.WouldSpill(ctx, p.right.loadSize()) {
+		return nil, hj.repartition(ctx, p, mem)
+	}
+	defer p.close()
+	return hj.joinPartition(ctx, p, mem)
+}
+
+// repartition splits a pair that doesn't fit in memory into fanOut pairs on disk, the new pairs are
+// pushed onto pending. a part that got every row of the pair (all rows share the join key) isn't
+// split again, hashing can't separate them
+func (hj *HashJoinExec) repartition(ctx context.Context, p *joinPartition, mem memory.Allocator) error {
+	defer p.close()
+	parts := hj.newPartitions(p.depth + 1)
+	for _, part := range parts {
+		if err := part.spill(mem); err != nil {
+			closePartitions(parts)
+			return err
+		}
+	}
+	for _, left := range []bool{true, false} {
+		err := p.side(left).each(func(cols []arrow.Array) error {
+			split, err := hj.partitionBatch(ctx, mem, left, cols, p.depth+1)
+			if err != nil {
+				return err
+			}
+			for i, c := range split {
+				if c == nil {
+					continue
+				}
+				if err := parts[i].side(left).add(c); err != nil {
+					releaseBatches(split[i+1:])
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			closePartitions(parts)
+			return err
+		}
+	}
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := parts[i]
+		if part.left.rows == 0 || part.right.rows == 0 {
+			part.close()
+			continue
+		}
+		part.splittable = part.left.rows < p.left.rows || part.right.rows < p.right.rows
+		hj.pending = append(hj.pending, part)
+	}
+	return nil
+}
+
+// joinPartition joins the two sides of p in memory, nil when no rows match
+func (hj *HashJoinExec) joinPartition(ctx context.Context, p *joinPartition, mem memory.Allocator) (*operators.RecordBatch, error) {
+	leftArr, err := p.left.load(mem)
 	if err != nil {
 		return nil, err
 	}
-	rightArr, err := consumeOperator(hj.rightSource, mem)
+	defer operators.ReleaseArrays(leftArr)
+	rightArr, err := p.right.load(mem)
 	if err != nil {
 		return nil, err
 	}
-	emptyCols := make([]arrow.Array, hj.schema.NumFields())
+	defer operators.ReleaseArrays(rightArr)
+	// both sides are held in full until the output is built
+	hj.peakMemory = max(hj.peakMemory, operators.ArraysSize(leftArr)+operators.ArraysSize(rightArr))
 	if len(leftArr) == 0 || len(rightArr) == 0 {
-		hj.done = true
-		return &operators.RecordBatch{
-			Schema:   hj.Schema(),
-			Columns:  emptyCols,
-			RowCount: uint64(0),
-		}, nil
+		return nil, nil
 	}
 	leftRowCount := leftArr[0].Len()
 	rightRowCount := rightArr[0].Len()
-	leftComp, err := buildComptables(hj.clause.leftS, leftArr, hj.leftSource.Schema())
+	leftComp, err := buildComptables(ctx, hj.clause.leftS, leftArr, hj.leftSource.Schema())
 	if err != nil {
 		return nil, err
 	}
+	defer operators.ReleaseArrays(leftComp)
 
-	rightComp, err := buildComptables(hj.clause.rightS, rightArr, hj.rightSource.Schema())
+	rightComp, err := buildComptables(ctx, hj.clause.rightS, rightArr, hj.rightSource.Schema())
 	if err != nil {
 		return nil, err
 	}
+	defer operators.ReleaseArrays(rightComp)
 	ht := buildRightHashTable(rightComp, rightRowCount)
+	// building and probing can't be interrupted, check in between
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	pairs := probeJoin(leftComp, ht, leftRowCount)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if len(pairs) == 0 {
-		hj.done = true
-		return &operators.RecordBatch{
-			Schema:   hj.Schema(),
-			Columns:  emptyCols,
-			RowCount: 0,
-		}, nil
+		return nil, nil
 	}
 	leftIdxArr, rightIdxArr, err := buildIndexArrays(mem, pairs)
 	if err != nil {
 		return nil, err
 	}
+	defer leftIdxArr.Release()
+	defer rightIdxArr.Release()
 
-	outArr, err := hj.buildOutputArrays(leftArr, rightArr, leftIdxArr, rightIdxArr)
+	outArr, err := hj.buildOutputArrays(ctx, leftArr, rightArr, leftIdxArr, rightIdxArr)
 	if err != nil {
 		return nil, err
 	}
-	hj.done = true
 	return &operators.RecordBatch{
 		Schema:   hj.schema,
 		Columns:  outArr,
 		RowCount: uint64(outArr[0].Len()),
 	}, nil
 }
-func (hj *HashJoinExec) Schema() *arrow.Schema { return hj.schema }
-func (hj *HashJoinExec) Close() error {
-	// do other clean up but for now just pass down to child
-	err1 := hj.leftSource.Close()
-	err2 := hj.rightSource.Close()
-	if err1 != nil {
-		return err1
+
+// joinPartition is the rows of both inputs that hashed to the same partition
+type joinPartition struct {
+	left, right *joinSide
+	depth       int  // how often the rows were split to get here
+	splittable  bool // false once splitting again wouldn't separate the rows
+}
+
+func newJoinPartition(left, right *arrow.Schema, depth int) *joinPartition {
+	return &joinPartition{
+		left:       &joinSide{schema: left},
+		right:      &joinSide{schema: right},
+		depth:      depth,
+		splittable: true,
 	}
-	if err2 != nil {
-		return err2
+}
+
+func (p *joinPartition) side(left bool) *joinSide {
+	if left {
+		return p.left
+	}
+	return p.right
+}
+
+// size is the bytes the partition holds in memory
+func (p *joinPartition) size() int64 { return p.left.size + p.right.size }
+
+func (p *joinPartition) spilled() bool { return p.left.file != nil || p.right.file != nil }
+
+// spill moves both sides to disk, rows added to the partition afterwards go straight to disk as well
+func (p *joinPartition) spill(mem memory.Allocator) error {
+	if err := p.left.spill(mem); err != nil {
+		return err
+	}
+	return p.right.spill(mem)
+}
+
+func (p *joinPartition) close() {
+	p.left.close()
+	p.right.close()
+}
+
+func closePartitions(parts []*joinPartition) {
+	for _, p := range parts {
+		p.close()
 	}
+}
+
+// joinSide is what one input put into a partition, held in memory until the partition is spilled
+type joinSide struct {
+	schema  *arrow.Schema
+	batches [][]arrow.Array
+	size    int64 // bytes held in memory
+	spilled int64 // bytes the rows in file take up once loaded
+	rows    int
+	file    *operators.SpillFile
+}
+
+// add takes ownership of cols
+func (s *joinSide) add(cols []arrow.Array) error {
+	rows := cols[0].Len()
+	s.rows += rows
+	if s.file != nil {
+		defer operators.ReleaseArrays(cols)
+		s.spilled += operators.ArraysSize(cols)
+		return s.file.Write(&operators.RecordBatch{Schema: s.schema, Columns: cols, RowCount: uint64(rows)})
+	}
+	s.batches = append(s.batches, cols)
+	s.size += operators.ArraysSize(cols)
 	return nil
 }
 
-func consumeOperator(o operators.Operator, mem memory.Allocator) ([]arrow.Array, error) {
+func (s *joinSide) spill(mem memory.Allocator) error {
+	if s.file != nil {
+		return nil
+	}
+	file, err := operators.NewSpillFile(s.schema, mem)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	batches := s.batches
+	s.spilled += s.size
+	s.batches, s.size = nil, 0
+	defer releaseBatches(batches)
+	for _, cols := range batches {
+		if err := file.Write(&operators.RecordBatch{Schema: s.schema, Columns: cols, RowCount: uint64(cols[0].Len())}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	AllArrays := make([]arrow.Array, o.Schema().NumFields()) // concated columns
+// each hands every batch of the side to fn, which takes ownership of it. the side is empty afterwards
+func (s *joinSide) each(fn func([]arrow.Array) error) error {
+	batches := s.batches
+	s.batches, s.size = nil, 0
+	for i, cols := range batches {
+		if err := fn(cols); err != nil {
+			releaseBatches(batches[i+1:])
+			return err
+		}
+	}
+	if s.file == nil {
+		return nil
+	}
+	if err := s.file.Rewind(); err != nil {
+		return err
+	}
 	for {
-		childRecordBatch, err := o.Next(math.MaxUint16)
+		batch, err := s.file.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			return nil, err
+			return err
 		}
-		for i := range childRecordBatch.Columns {
-			if AllArrays[i] == nil {
-				AllArrays[i] = childRecordBatch.Columns[i]
-				continue
-			}
-			largerArray, err := array.Concatenate([]arrow.Array{AllArrays[i], childRecordBatch.Columns[i]}, mem)
-			if err != nil {
-				return nil, err
-			}
-			AllArrays[i] = largerArray
+		if err := fn(batch.Columns); err != nil {
+			return err
 		}
 	}
-	return AllArrays, nil
 }
 
-func buildComptables(exprs []Expr.Expression, cols []arrow.Array, schema *arrow.Schema) ([]arrow.Array, error) {
+// loadSize is the bytes load would bring into memory
+func (s *joinSide) loadSize() int64 { return s.size + s.spilled }
+
+// load is every row of the side as one array per column, nil when the side is empty. the side is
+// empty afterwards
+func (s *joinSide) load(mem memory.Allocator) ([]arrow.Array, error) {
+	var batches [][]arrow.Array
+	err := s.each(func(cols []arrow.Array) error {
+		batches = append(batches, cols)
+		return nil
+	})
+	if err != nil || len(batches) == 0 {
+		releaseBatches(batches)
+		return nil, err
+	}
+	if len(batches) == 1 {
+		return batches[0], nil
+	}
+	defer releaseBatches(batches)
+	out := make([]arrow.Array, len(batches[0]))
+	parts := make([]arrow.Array, len(batches))
+	for i := range out {
+		for b := range batches {
+			parts[b] = batches[b][i]
+		}
+		out[i], err = array.Concatenate(parts, mem)
+		if err != nil {
+			operators.ReleaseArrays(out)
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *joinSide) close() {
+	releaseBatches(s.batches)
+	s.batches, s.size = nil, 0
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+}
+
+func releaseBatches(batches [][]arrow.Array) {
+	for _, cols := range batches {
+		operators.ReleaseArrays(cols)
+	}
+}
+
+func buildComptables(ctx context.Context, exprs []Expr.Expression, cols []arrow.Array, schema *arrow.Schema) ([]arrow.Array, error) {
 	compArr := make([]arrow.Array, len(exprs))
 	for i, expr := range exprs {
-		arr, err := Expr.EvalExpression(expr, &operators.RecordBatch{
+		arr, err := Expr.EvalExpression(ctx, expr, &operators.RecordBatch{
 			Schema:   schema,
 			Columns:  cols,
 			RowCount: uint64(cols[0].Len()),
@@ -387,13 +836,12 @@ func buildIndexArrays(
 }
 
 func (hj *HashJoinExec) buildOutputArrays(
+	ctx context.Context,
 	leftCols []arrow.Array,
 	rightCols []arrow.Array,
 	leftIdxArr arrow.Array,
 	rightIdxArr arrow.Array,
 ) ([]arrow.Array, error) {
-	ctx := context.Background()
-
 	output := make([]arrow.Array, hj.schema.NumFields())
 	for i := range len(leftCols) {
 		col := leftCols[i]
@@ -1,11 +1,14 @@
 package join
 
 import (
+	"context"
 	"errors"
 	"io"
 	"opti-sql-go/Expr"
 	"opti-sql-go/operators"
 	"opti-sql-go/operators/project"
+	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -198,7 +201,7 @@ func collectAllRows(t *testing.T, op operators.Operator) []*operators.RecordBatc
 
 	var batches []*operators.RecordBatch
 	for {
-		b, err := op.Next(1024)
+		b, err := op.Next(context.Background(), 1024)
 		if errors.Is(err, io.EOF) {
 			break
 		}
@@ -226,7 +229,7 @@ func flattenRowCount(batches []*operators.RecordBatch) int {
 func evalInt32Slice(t *testing.T, expr Expr.Expression, batch *operators.RecordBatch) ([]int32, []bool) {
 	t.Helper()
 
-	arr, err := Expr.EvalExpression(expr, batch)
+	arr, err := Expr.EvalExpression(context.Background(), expr, batch)
 	if err != nil {
 		t.Fatalf("EvalExpression failed: %v", err)
 	}
@@ -477,7 +480,7 @@ func TestHashJoin_MultiAttributeKey(t *testing.T) {
 	first := batches[0]
 
 	deptExpr := Expr.NewColumnResolve("department")
-	arr, err := Expr.EvalExpression(deptExpr, first)
+	arr, err := Expr.EvalExpression(context.Background(), deptExpr, first)
 	if err != nil {
 		t.Fatalf("EvalExpression department failed: %v", err)
 	}
@@ -537,13 +540,13 @@ func TestHashJoin_ComputedKeySimulation(t *testing.T) {
 	leftEmailExpr := Expr.NewColumnResolve("left_email_lower")
 	rightEmailExpr := Expr.NewColumnResolve("right_email_lower")
 
-	leftArr, err := Expr.EvalExpression(leftEmailExpr, first)
+	leftArr, err := Expr.EvalExpression(context.Background(), leftEmailExpr, first)
 	if err != nil {
 		t.Fatalf("EvalExpression left_email_lower failed: %v", err)
 	}
 	defer leftArr.Release()
 
-	rightArr, err := Expr.EvalExpression(rightEmailExpr, first)
+	rightArr, err := Expr.EvalExpression(context.Background(), rightEmailExpr, first)
 	if err != nil {
 		t.Fatalf("EvalExpression right_email_lower failed: %v", err)
 	}
@@ -562,3 +565,261 @@ func TestHashJoin_ComputedKeySimulation(t *testing.T) {
 		}
 	}
 }
+
+//
+// ---------- (4) grace hash join ----------
+//
+
+// smallBatches hands out its child's rows a few at a time, copied with its own allocator like a
+// scan would so the query's memory grows with every batch
+type smallBatches struct {
+	input operators.Operator
+	size  uint16
+}
+
+func (s *smallBatches) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
+	batch, err := s.input.Next(ctx, s.size)
+	if err != nil {
+		return nil, err
+	}
+	_, mem := operators.Allocator(ctx, s)
+	cols := make([]arrow.Array, len(batch.Columns))
+	for i, c := range batch.Columns {
+		cols[i], err = array.Concatenate([]arrow.Array{c}, mem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	operators.ReleaseArrays(batch.Columns)
+	return &operators.RecordBatch{Schema: batch.Schema, Columns: cols, RowCount: batch.RowCount}, nil
+}
+func (s *smallBatches) Schema() *arrow.Schema { return s.input.Schema() }
+func (s *smallBatches) Close() error          { return s.input.Close() }
+
+// keyedSources is a left side of 120 rows over 40 keys and a right side of 60 rows over 30 keys,
+// the right keys are every other number so half of the left keys find a match
+func keyedSources(sameKey bool) (*project.InMemorySource, *project.InMemorySource) {
+	leftIDs, leftNames := make([]int, 120), make([]string, 120)
+	for i := range leftIDs {
+		leftIDs[i] = i % 40
+		leftNames[i] = "l" + strings.Repeat("x", i%7)
+	}
+	rightIDs, rightDepts := make([]int, 60), make([]string, 60)
+	for i := range rightIDs {
+		rightIDs[i] = (i % 30) * 2
+		rightDepts[i] = "d" + strings.Repeat("y", i%5)
+	}
+	if sameKey {
+		for i := range leftIDs {
+			leftIDs[i] = 4
+		}
+		for i := range rightIDs {
+			rightIDs[i] = 4
+		}
+	}
+	left, _ := project.NewInMemoryProjectExec([]string{"id", "name"}, []any{leftIDs, leftNames})
+	right, _ := project.NewInMemoryProjectExec([]string{"id", "dept"}, []any{rightIDs, rightDepts})
+	return left, right
+}
+
+// joinedRows runs the join and returns its rows formatted and sorted, so runs that emit them in a
+// different order can be compared
+func joinedRows(t *testing.T, ctx context.Context, hj *HashJoinExec) []string {
+	t.Helper()
+	var rows []string
+	for {
+		b, err := hj.Next(ctx, 1024)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error from Next: %v", err)
+		}
+		for r := 0; r < int(b.RowCount); r++ {
+			var row []string
+			for _, c := range b.Columns {
+				row = append(row, c.ValueStr(r))
+			}
+			rows = append(rows, strings.Join(row, "|"))
+		}
+		operators.ReleaseArrays(b.Columns)
+	}
+	sort.Strings(rows)
+	return rows
+}
+
+func newKeyedJoin(t *testing.T, sameKey bool, batchSize uint16) *HashJoinExec {
+	t.Helper()
+	left, right := keyedSources(sameKey)
+	clause := NewJoinClause(Expr.NewExpressions(Expr.NewColumnResolve("id")), Expr.NewExpressions(Expr.NewColumnResolve("id")))
+	var l, r operators.Operator = left, right
+	if batchSize > 0 {
+		l, r = &smallBatches{input: left, size: batchSize}, &smallBatches{input: right, size: batchSize}
+	}
+	hj, err := NewHashJoinExec(l, r, clause, InnerJoin, nil)
+	if err != nil {
+		t.Fatalf("NewHashJoinExec failed: %v", err)
+	}
+	return hj
+}
+
+func TestGraceHashJoin(t *testing.T) {
+	inMemory := func(t *testing.T, sameKey bool) []string {
+		hj := newKeyedJoin(t, sameKey, 0)
+		defer func() { _ = hj.Close() }()
+		return joinedRows(t, context.Background(), hj)
+	}
+	sameRows := func(t *testing.T, want, got []string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("expected %d rows, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("row %d: expected %s, got %s", i, want[i], got[i])
+			}
+		}
+	}
+
+	t.Run("spilled partitions give the in memory result", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		want := inMemory(t, false)
+		// 20 keys match, 3 left rows and 2 right rows each
+		if len(want) != 120 {
+			t.Fatalf("expected 120 joined rows in memory, got %d", len(want))
+		}
+
+		q := operators.NewQueryMemory(nil, 0, 1)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		hj := newKeyedJoin(t, false, 16)
+		hj.fanOut, hj.maxDepth = 4, 0
+		sameRows(t, want, joinedRows(t, ctx, hj))
+		if err := hj.Close(); err != nil {
+			t.Fatalf("HashJoinExec Close failed: %v", err)
+		}
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+		if entries, _ := os.ReadDir(os.TempDir()); len(entries) != 0 {
+			t.Fatalf("expected the spill files to be removed, found %d", len(entries))
+		}
+	})
+	t.Run("pairs that don't fit are split again", func(t *testing.T) {
+		want := inMemory(t, false)
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		hj := newKeyedJoin(t, false, 16)
+		defer func() { _ = hj.Close() }()
+		hj.fanOut, hj.maxDepth = 2, 3
+		var batches int
+		var rows []string
+		for {
+			b, err := hj.Next(ctx, 1024)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error from Next: %v", err)
+			}
+			batches++
+			for r := 0; r < int(b.RowCount); r++ {
+				rows = append(rows, b.Columns[0].ValueStr(r)+"|"+b.Columns[1].ValueStr(r)+"|"+b.Columns[2].ValueStr(r)+"|"+b.Columns[3].ValueStr(r))
+			}
+			operators.ReleaseArrays(b.Columns)
+		}
+		sort.Strings(rows)
+		sameRows(t, want, rows)
+		// 2 partitions split 3 more times is up to 16 pairs
+		if batches <= 2 {
+			t.Fatalf("expected the pairs to be split again, got %d batches", batches)
+		}
+	})
+	t.Run("a single key is not split forever", func(t *testing.T) {
+		want := inMemory(t, true)
+		if len(want) != 120*60 {
+			t.Fatalf("expected every row to match, got %d", len(want))
+		}
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		hj := newKeyedJoin(t, true, 32)
+		defer func() { _ = hj.Close() }()
+		hj.fanOut, hj.maxDepth = 4, 100
+		sameRows(t, want, joinedRows(t, ctx, hj))
+	})
+	t.Run("a pair that doesn't fit is split again without loading it", func(t *testing.T) {
+		// the keyed inputs written to disk as a single pair batch by batch, the right input 20 times over
+		// so its size dwarfs a batch. the inputs are read untracked, what the query holds at its peak is
+		// only what reading the pair back took
+		spilledPair := func(hj *HashJoinExec, mem memory.Allocator) *joinPartition {
+			p := hj.newPartitions(0)[0]
+			if err := p.spill(mem); err != nil {
+				t.Fatalf("spill failed: %v", err)
+			}
+			add := func(left bool, source operators.Operator) {
+				defer func() { _ = source.Close() }()
+				for {
+					b, err := source.Next(context.Background(), 8)
+					if errors.Is(err, io.EOF) {
+						return
+					}
+					if err != nil {
+						t.Fatalf("unexpected error from Next: %v", err)
+					}
+					if err := p.side(left).add(b.Columns); err != nil {
+						t.Fatalf("add failed: %v", err)
+					}
+				}
+			}
+			for i := 0; i < 20; i++ {
+				left, right := keyedSources(false)
+				if i == 0 {
+					add(true, left)
+				}
+				add(false, right)
+			}
+			return p
+		}
+		measure := newKeyedJoin(t, false, 0)
+		p := spilledPair(measure, memory.DefaultAllocator)
+		buildSize := p.right.loadSize()
+		p.close()
+		_ = measure.Close()
+
+		q := operators.NewQueryMemory(nil, 0, buildSize/2)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		hj := newKeyedJoin(t, false, 0)
+		defer func() { _ = hj.Close() }()
+		hj.fanOut, hj.maxDepth = 4, 3
+		ctx, mem := operators.Allocator(ctx, hj)
+		p = spilledPair(hj, mem)
+		if p.right.loadSize() != buildSize {
+			t.Fatalf("expected the build side to take %d bytes, got %d", buildSize, p.right.loadSize())
+		}
+		b, err := hj.joinSpilled(ctx, p, mem)
+		if err != nil {
+			t.Fatalf("joinSpilled failed: %v", err)
+		}
+		if b != nil {
+			t.Fatalf("expected the pair to be split again instead of joined")
+		}
+		if len(hj.pending) == 0 {
+			t.Fatalf("expected the parts of the pair to be pending")
+		}
+		if q.Peak() >= buildSize {
+			t.Fatalf("expected the %d byte build side to never be in memory at once, peak was %d", buildSize, q.Peak())
+		}
+	})
+	t.Run("under the threshold everything stays in memory", func(t *testing.T) {
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1<<30))
+		hj := newKeyedJoin(t, false, 16)
+		defer func() { _ = hj.Close() }()
+		b, err := hj.Next(ctx, 1024)
+		if err != nil {
+			t.Fatalf("unexpected error from Next: %v", err)
+		}
+		if b.RowCount != 120 {
+			t.Fatalf("expected the whole join in one batch, got %d rows", b.RowCount)
+		}
+		if _, err := hj.Next(ctx, 1024); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	})
+}
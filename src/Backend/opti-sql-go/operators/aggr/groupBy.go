@@ -1,8 +1,10 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
 	"opti-sql-go/Expr"
 	"opti-sql-go/operators"
@@ -21,24 +23,72 @@ rules for group by:
 */
 var (
 	_ = (operators.Operator)(&GroupByExec{})
+	_ = (operators.MemoryReporter)(&GroupByExec{})
+	_ = (operators.Explainer)(&GroupByExec{})
 )
 
-// place all unique elements of the group by column into a hash table, each element gets their own Accumulator instance
+// rough size of one group in the hash table besides its key: a boxed group value or an accumulator
+const groupEntrySize = 16
+
+// number of partitions groups are spilled to
+const groupByFanOut = 16
+
+// place all unique elements of the group by column into a hash table, each element gets their own Accumulator instance.
+// when the query goes over its spill threshold the partial state of every group is written to the partition its key
+// hashes to and the hash table starts over, once the input is consumed each partition is merged back and finalized on
+// its own. a key only ever lands in one partition so every group is finished by a single merge
 type GroupByExec struct {
 	input       operators.Operator
 	schema      *arrow.Schema
 	groupExpr   []AggregateFunctions
 	groupByExpr []Expr.Expression // column names
-
-	groups map[string][]accumulator // maps group by key to its accumulator
-	keys   map[string][]string      // key → original values for output
-	done   bool
+	types       []arrow.DataType  // type of the values each aggregate reads, see aggrInputTypes
+	mode        AggrMode
+
+	groups   map[string][]accumulator // maps group by key to its accumulator
+	keys     map[string][]any         // key → original values for output, nil for null
+	consumed bool
+	done     bool
+	mem      memory.Allocator // what memory is counted against, from Next
+	memory   int64            // estimate of what groups and keys hold, counted against the query with operators.Grow
+	peak     int64
+
+	seed        maphash.Seed
+	fanOut      int
+	stateSchema *arrow.Schema          // group columns followed by the state of every accumulator, see buildStateSchema
+	partitions  []*operators.SpillFile // by hash of the group key, nil until something hashed there
+	merged      int                    // partitions merged back so far
 }
 
 func NewGroupByExec(child operators.Operator, groupExpr []AggregateFunctions, groupBy []Expr.Expression) (*GroupByExec, error) {
-	s, err := buildGroupBySchema(child.Schema(), groupBy, groupExpr)
-	if err != nil {
-		return nil, err
+	return NewGroupByExecWithMode(child, groupExpr, groupBy, Complete)
+}
+
+// NewGroupByExecWithMode is NewGroupByExec running one phase of a two phase aggregation. in Merge and
+// Final mode child returns what a Partial (or Merge) GroupByExec over the same expressions does, the
+// group columns come first and groupBy only names them
+func NewGroupByExecWithMode(child operators.Operator, groupExpr []AggregateFunctions, groupBy []Expr.Expression, mode AggrMode) (*GroupByExec, error) {
+	var s *arrow.Schema
+	var types []arrow.DataType
+	var err error
+	if mode.readsState() {
+		if child.Schema().NumFields() < len(groupBy) {
+			return nil, ErrInvalidStateInput(child.Schema())
+		}
+		if types, err = stateInputTypes(child.Schema(), len(groupBy), groupExpr); err != nil {
+			return nil, err
+		}
+		fields := append([]arrow.Field(nil), child.Schema().Fields()[:len(groupBy)]...)
+		s = arrow.NewSchema(append(fields, resultFields(groupExpr, types)...), nil)
+	} else {
+		if s, err = buildGroupBySchema(child.Schema(), groupBy, groupExpr); err != nil {
+			return nil, err
+		}
+		types, _ = aggrInputTypes(child.Schema(), groupExpr)
+	}
+	state := buildStateSchema(s.Fields()[:len(groupBy)], groupExpr, types)
+	if mode.returnsState() {
+		s = state
 	}
 
 	return &GroupByExec{
@@ -46,112 +96,302 @@ func NewGroupByExec(child operators.Operator, groupExpr []AggregateFunctions, gr
 		schema:      s,
 		groupExpr:   groupExpr,
 		groupByExpr: groupBy,
-		keys:        make(map[string][]string),
+		types:       types,
+		mode:        mode,
+		keys:        make(map[string][]any),
 		groups:      make(map[string][]accumulator),
+		fanOut:      groupByFanOut,
+		stateSchema: state,
 	}, nil
 }
 
 /*
 grab child rows
 */
-func (g *GroupByExec) Next(batchSize uint16) (*operators.RecordBatch, error) {
+func (g *GroupByExec) Next(ctx context.Context, batchSize uint16) (*operators.RecordBatch, error) {
 	if g.done {
 		return nil, io.EOF
 	}
+	ctx, mem := operators.Allocator(ctx, g)
+	g.mem = mem
+	if !g.consumed {
+		if err := g.consumeInput(ctx, batchSize); err != nil {
+			return nil, err
+		}
+		g.consumed = true
+		if g.partitions == nil {
+			g.done = true
+			return g.output(mem), nil
+		}
+	}
+	// spilled, every partition comes out as its own batch
+	for g.merged < len(g.partitions) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := g.merged
+		g.merged++
+		if g.partitions[p] == nil {
+			continue
+		}
+		err := g.mergePartition(g.partitions[p])
+		_ = g.partitions[p].Close()
+		g.partitions[p] = nil
+		if err != nil {
+			return nil, err
+		}
+		batch := g.output(mem)
+		g.resetGroups()
+		return batch, nil
+	}
+	g.done = true
+	return nil, io.EOF
+}
 
+func (g *GroupByExec) consumeInput(ctx context.Context, batchSize uint16) error {
 	for {
-		childBatch, err := g.input.Next(batchSize)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		childBatch, err := operators.NextBatch(ctx, g.input, batchSize)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			return nil, err
+			return err
 		}
 
-		rowCount := int(childBatch.RowCount)
-
-		// 1. evaluate all group-by expressions into arrays
-		groupArrays := make([]arrow.Array, len(g.groupByExpr))
-		for i, expr := range g.groupByExpr {
-			arr, err := Expr.EvalExpression(expr, childBatch)
+		if g.mode.readsState() {
+			err := g.mergeState(childBatch)
+			operators.ReleaseArrays(childBatch.Columns)
 			if err != nil {
-				operators.ReleaseArrays(groupArrays)
-				operators.ReleaseArrays(childBatch.Columns)
-				return nil, err
+				return err
 			}
-			groupArrays[i] = arr
+		} else if err := g.update(ctx, childBatch); err != nil {
+			return err
 		}
-
-		// 2. evaluate all aggregation child expressions
-		aggrArrays := make([]arrow.Array, len(g.groupExpr))
-		for i, agg := range g.groupExpr {
-			arr, err := Expr.EvalExpression(agg.Child, childBatch)
-			if err != nil {
-				operators.ReleaseArrays(aggrArrays)
-				operators.ReleaseArrays(groupArrays)
-				operators.ReleaseArrays(childBatch.Columns)
-				return nil, err
-			}
-			arr, err = castArrayToFloat64(arr)
-			if err != nil {
-				operators.ReleaseArrays(aggrArrays)
-				operators.ReleaseArrays(groupArrays)
-				operators.ReleaseArrays(childBatch.Columns)
-				return nil, err
+		if operators.ShouldSpill(ctx) && len(g.groups) > 0 {
+			if err := g.spillGroups(); err != nil {
+				return err
 			}
-			aggrArrays[i] = arr
 		}
+	}
+	// once anything was spilled the rest has to go to the partitions too, so each key is merged in one place
+	if g.partitions != nil && len(g.groups) > 0 {
+		return g.spillGroups()
+	}
+	return nil
+}
 
-		// 3. process rows
-		for row := 0; row < rowCount; row++ {
+// update adds the rows of childBatch to their groups, it releases childBatch
+func (g *GroupByExec) update(ctx context.Context, childBatch *operators.RecordBatch) error {
+	rowCount := int(childBatch.RowCount)
 
-			// Build group key
-			keyParts := make([]string, len(groupArrays))
-			for j, arr := range groupArrays {
-				if arr.IsNull(row) {
-					keyParts[j] = "NULL"
-				} else {
-					keyParts[j] = fmt.Sprintf("%v", getValue(arr, row))
-				}
+	// 1. evaluate all group-by expressions into arrays
+	groupArrays := make([]arrow.Array, len(g.groupByExpr))
+	for i, expr := range g.groupByExpr {
+		arr, err := Expr.EvalExpression(ctx, expr, childBatch)
+		if err != nil {
+			operators.ReleaseArrays(groupArrays)
+			operators.ReleaseArrays(childBatch.Columns)
+			return err
+		}
+		groupArrays[i] = arr
+	}
+
+	// 2. evaluate all aggregation child expressions
+	aggrArrays := make([]arrow.Array, len(g.groupExpr))
+	for i, agg := range g.groupExpr {
+		cast, err := aggrInput(ctx, agg, g.types[i], childBatch)
+		if err != nil {
+			operators.ReleaseArrays(aggrArrays)
+			operators.ReleaseArrays(groupArrays)
+			operators.ReleaseArrays(childBatch.Columns)
+			return err
+		}
+		aggrArrays[i] = cast
+	}
+
+	// 3. process rows
+	var added int64
+	var err error
+rows:
+	for row := 0; row < rowCount; row++ {
+		accs, size := g.group(groupKey(groupArrays, row))
+		added += size
+
+		// UPDATE accumulators
+		for i, arr := range aggrArrays {
+			if arr != nil && arr.IsNull(row) {
+				continue
 			}
-			key := strings.Join(keyParts, "|")
-			// Allocate accumulator list if new group
-			if _, exists := g.groups[key]; !exists {
-				g.groups[key] = make([]accumulator, len(g.groupExpr))
-				for i, agg := range g.groupExpr {
-					g.groups[key][i] = createAccumulator(agg.AggrFunc)
-				}
-				g.keys[key] = keyParts // store original values
+			if err = accs[i].Update(arr, row); err != nil {
+				err = fmt.Errorf("%s: %w", g.groupExpr[i], err)
+				break rows
 			}
+		}
+	}
+	// 4. release temp arrays
+	operators.ReleaseArrays(aggrArrays)
+	operators.ReleaseArrays(groupArrays)
+	operators.ReleaseArrays(childBatch.Columns)
+
+	if err != nil {
+		return err
+	}
+	return g.grow(added)
+}
+
+// output is a batch of every group in the hash table, their results or in Partial and Merge mode their state
+func (g *GroupByExec) output(mem memory.Allocator) *operators.RecordBatch {
+	if !g.mode.returnsState() {
+		return buildGroupByOutput(g, mem)
+	}
+	keys := make([]string, 0, len(g.groups))
+	for key := range g.groups {
+		keys = append(keys, key)
+	}
+	return buildGroupStateBatch(g, keys, mem)
+}
+
+// groupKey is the hash table key of row along with the values it was built from, nil for null
+func groupKey(groupArrays []arrow.Array, row int) (string, []any) {
+	keyParts := make([]string, len(groupArrays))
+	values := make([]any, len(groupArrays))
+	for j, arr := range groupArrays {
+		if arr.IsNull(row) {
+			keyParts[j] = "NULL"
+		} else {
+			values[j] = getValue(arr, row)
+			keyParts[j] = fmt.Sprintf("%v", values[j])
+		}
+	}
+	return strings.Join(keyParts, "|"), values
+}
+
+// group is the accumulators of key, allocated if it is a new group. size is what a new group added to the hash table
+func (g *GroupByExec) group(key string, values []any) (accs []accumulator, size int64) {
+	if accs, exists := g.groups[key]; exists {
+		return accs, 0
+	}
+	accs = make([]accumulator, len(g.groupExpr))
+	for i, agg := range g.groupExpr {
+		accs[i] = newAccumulator(agg, g.types[i])
+	}
+	g.groups[key] = accs
+	g.keys[key] = values // store original values
+	return accs, int64(len(key)) + groupEntrySize*int64(len(values)+len(g.groupExpr))
+}
+
+func (g *GroupByExec) grow(n int64) error {
+	if err := operators.Grow(g.mem, n); err != nil {
+		return err
+	}
+	g.memory += n
+	g.peak = max(g.peak, g.memory)
+	return nil
+}
+
+// resetGroups empties the hash table and gives back what it was counted for
+func (g *GroupByExec) resetGroups() {
+	operators.Shrink(g.mem, g.memory)
+	g.memory = 0
+	g.groups = make(map[string][]accumulator)
+	g.keys = make(map[string][]any)
+}
 
-			// UPDATE accumulators
-			for i, arr := range aggrArrays {
-				if arr.IsNull(row) {
-					continue
-				}
-				val := arr.(*array.Float64).Value(row)
-				g.groups[key][i].Update(val)
+// spillGroups writes the partial state of every group to the partition its key hashes to and empties the hash table
+func (g *GroupByExec) spillGroups() error {
+	if g.partitions == nil {
+		g.seed = maphash.MakeSeed()
+		g.partitions = make([]*operators.SpillFile, g.fanOut)
+	}
+	byPartition := make([][]string, g.fanOut)
+	for key := range g.groups {
+		p := maphash.String(g.seed, key) % uint64(g.fanOut)
+		byPartition[p] = append(byPartition[p], key)
+	}
+	for p, keys := range byPartition {
+		if len(keys) == 0 {
+			continue
+		}
+		if g.partitions[p] == nil {
+			spill, err := operators.NewSpillFile(g.stateSchema, g.mem)
+			if err != nil {
+				return err
 			}
+			g.partitions[p] = spill
+		}
+		batch := buildGroupStateBatch(g, keys, g.mem)
+		err := g.partitions[p].Write(batch)
+		operators.ReleaseArrays(batch.Columns)
+		if err != nil {
+			return err
 		}
-		// 4. release temp arrays
-		operators.ReleaseArrays(aggrArrays)
-		operators.ReleaseArrays(groupArrays)
-		operators.ReleaseArrays(childBatch.Columns)
 	}
+	g.resetGroups()
+	return nil
+}
 
-	// 4. Build output RecordBatch
-	batch := buildGroupByOutput(g)
+// mergePartition reads the partial states spilled to a partition back into the hash table
+func (g *GroupByExec) mergePartition(spill *operators.SpillFile) error {
+	if err := spill.Rewind(); err != nil {
+		return err
+	}
+	for {
+		batch, err := spill.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		err = g.mergeState(batch)
+		operators.ReleaseArrays(batch.Columns)
+		if err != nil {
+			return err
+		}
+	}
+}
 
-	g.done = true
-	return batch, nil
+// mergeState folds a batch laid out like the state schema into the hash table
+func (g *GroupByExec) mergeState(batch *operators.RecordBatch) error {
+	keyCount := len(g.groupByExpr)
+	var added int64
+	for row := 0; row < int(batch.RowCount); row++ {
+		accs, size := g.group(groupKey(batch.Columns[:keyCount], row))
+		added += size
+		if err := mergeRow(g.groupExpr, accs, batch.Columns[keyCount:], row); err != nil {
+			return err
+		}
+	}
+	return g.grow(added)
 }
 
 func (g *GroupByExec) Schema() *arrow.Schema {
 	return g.schema
 }
 func (g *GroupByExec) Close() error {
+	g.resetGroups()
+	for i, p := range g.partitions {
+		if p != nil {
+			_ = p.Close()
+			g.partitions[i] = nil
+		}
+	}
 	return g.input.Close()
 }
+func (g *GroupByExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type: "GroupByExec",
+		Params: explainMode(map[string]string{
+			"group_by":   operators.ExplainList(g.groupByExpr),
+			"aggregates": operators.ExplainList(g.groupExpr),
+		}, g.mode),
+		Children: []operators.Operator{g.input},
+	}
+}
+func (g *GroupByExec) PeakMemory() int64 { return g.peak }
 
 // handles validation and building of schema for group by
 func buildGroupBySchema(childSchema *arrow.Schema, groupByExpr []Expr.Expression, aggrExprs []AggregateFunctions) (*arrow.Schema, error) {
@@ -173,29 +413,29 @@ func buildGroupBySchema(childSchema *arrow.Schema, groupByExpr []Expr.Expression
 	}
 
 	// 2. Add aggregate columns
-	for _, agg := range aggrExprs {
-		dt, err := Expr.ExprDataType(agg.Child, childSchema)
-		if err != nil || !validAggrType(dt) {
-			return nil, ErrInvalidAggrColumnType(dt)
-		}
-		// All aggregates produce float64
-		fieldName := fmt.Sprintf("%s_%s",
-			strings.ToLower(aggrToString(int(agg.AggrFunc))),
-			agg.Child.String(),
-		)
-
-		fields = append(fields, arrow.Field{
-			Name:     fieldName,
-			Type:     arrow.PrimitiveTypes.Float64,
-			Nullable: false,
-		})
+	types, err := aggrInputTypes(childSchema, aggrExprs)
+	if err != nil {
+		return nil, err
 	}
+	fields = append(fields, resultFields(aggrExprs, types)...)
 
 	return arrow.NewSchema(fields, nil), nil
 }
 
 func getValue(arr arrow.Array, row int) any {
 	switch col := arr.(type) {
+	case *array.Int8:
+		return col.Value(row)
+	case *array.Int16:
+		return col.Value(row)
+	case *array.Uint8:
+		return col.Value(row)
+	case *array.Uint16:
+		return col.Value(row)
+	case *array.Uint32:
+		return col.Value(row)
+	case *array.Uint64:
+		return col.Value(row)
 	case *array.Int32:
 		return col.Value(row)
 	case *array.Int64:
@@ -213,15 +453,15 @@ func getValue(arr arrow.Array, row int) any {
 		return fmt.Sprintf("%v", col)
 	}
 }
-func createAccumulator(fn AggrFunc) accumulator {
+
+// createAccumulator is an accumulator of fn over values of type dt (the type before inputType)
+func createAccumulator(fn AggrFunc, dt arrow.DataType) accumulator {
 	switch fn {
-	case Min:
-		return newMinAggr()
-	case Max:
-		return newMaxAggr()
+	case Min, Max:
+		return newMinMaxAggr(fn, dt)
 	case Sum:
-		return newSumAggr()
-	case Count:
+		return newSumAggr(dt)
+	case Count, CountStar:
 		return newCountAggr()
 	case Avg:
 		return newAvgAggr()
@@ -230,9 +470,16 @@ func createAccumulator(fn AggrFunc) accumulator {
 	}
 }
 
-func buildGroupByOutput(g *GroupByExec) *operators.RecordBatch {
-	alloc := memory.NewGoAllocator()
+// newAccumulator is the accumulator of agg over values of type dt, see createAccumulator
+func newAccumulator(agg AggregateFunctions, dt arrow.DataType) accumulator {
+	acc := createAccumulator(agg.AggrFunc, dt)
+	if agg.Distinct {
+		return newDistinctAggr(acc, distinctType(agg.AggrFunc, dt))
+	}
+	return acc
+}
 
+func buildGroupByOutput(g *GroupByExec, alloc memory.Allocator) *operators.RecordBatch {
 	rowCount := len(g.groups)
 	if rowCount == 0 {
 		return &operators.RecordBatch{
@@ -246,15 +493,12 @@ func buildGroupByOutput(g *GroupByExec) *operators.RecordBatch {
 	colBuilders := make([]arrow.Array, len(g.schema.Fields()))
 
 	// Temporary storage for columns
-	groupCols := make([][]any, len(g.groupByExpr))  // group columns
-	aggrCols := make([][]float64, len(g.groupExpr)) // aggregate columns
+	groupCols := make([][]any, len(g.groupByExpr))                           // group columns
+	aggrCols := fieldBuilders(alloc, g.schema.Fields()[len(g.groupByExpr):]) // aggregate columns
 
 	for i := range groupCols {
 		groupCols[i] = make([]any, 0, rowCount)
 	}
-	for i := range aggrCols {
-		aggrCols[i] = make([]float64, 0, rowCount)
-	}
 
 	for key, accs := range g.groups {
 		// Add group-by (dimension) values
@@ -265,7 +509,7 @@ func buildGroupByOutput(g *GroupByExec) *operators.RecordBatch {
 
 		// Add aggregated values
 		for j, acc := range accs {
-			aggrCols[j] = append(aggrCols[j], acc.Finalize())
+			acc.Finalize(aggrCols[j])
 		}
 
 	}
@@ -280,10 +524,7 @@ func buildGroupByOutput(g *GroupByExec) *operators.RecordBatch {
 	}
 
 	// Build aggregate columns
-	for j := range g.groupExpr {
-		colBuilders[fieldIndex] = buildFloatArray(alloc, aggrCols[j])
-		fieldIndex++
-	}
+	copy(colBuilders[fieldIndex:], finishBuilders(aggrCols))
 
 	return &operators.RecordBatch{
 		Schema:   g.schema,
@@ -291,6 +532,34 @@ func buildGroupByOutput(g *GroupByExec) *operators.RecordBatch {
 		RowCount: uint64(rowCount),
 	}
 }
+
+// buildGroupStateBatch is the state of the given groups, laid out like the state schema
+func buildGroupStateBatch(g *GroupByExec, keys []string, mem memory.Allocator) *operators.RecordBatch {
+	groupCols := make([][]any, len(g.groupByExpr))
+	builders := fieldBuilders(mem, g.stateSchema.Fields()[len(g.groupByExpr):])
+	for _, key := range keys {
+		for j, v := range g.keys[key] {
+			groupCols[j] = append(groupCols[j], v)
+		}
+		col := 0
+		for i, acc := range g.groups[key] {
+			width := aggrStateWidth(g.groupExpr[i])
+			acc.State(builders[col : col+width])
+			col += width
+		}
+	}
+	columns := make([]arrow.Array, 0, len(g.stateSchema.Fields()))
+	for j := range groupCols {
+		columns = append(columns, buildDynamicArray(mem, g.stateSchema.Field(j).Type, groupCols[j]))
+	}
+	columns = append(columns, finishBuilders(builders)...)
+	return &operators.RecordBatch{
+		Schema:   g.stateSchema,
+		Columns:  columns,
+		RowCount: uint64(len(keys)),
+	}
+}
+
 func buildDynamicArray(mem memory.Allocator, dt arrow.DataType, values []any) arrow.Array {
 	switch dt.ID() {
 
@@ -445,11 +714,6 @@ func buildDynamicArray(mem memory.Allocator, dt arrow.DataType, values []any) ar
 	}
 }
 
-func buildFloatArray(mem memory.Allocator, values []float64) arrow.Array {
-	b := array.NewFloat64Builder(mem)
-	b.AppendValues(values, nil)
-	return b.NewArray()
-}
 func castToBool(v any) bool {
 	if v == "true" || v == true {
 		return true
@@ -1,11 +1,15 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
 	"opti-sql-go/operators/project"
+	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -124,7 +128,7 @@ func groupByProject() *project.InMemorySource {
 
 func TestGroupByInit(t *testing.T) {
 	p := groupByProject()
-	_, _ = p.Next(12)
+	_, _ = p.Next(context.Background(), 12)
 }
 
 func TestNewGroupByExecAndSchema(t *testing.T) {
@@ -391,7 +395,7 @@ func TestBasicOperatorCasesGroupBy(t *testing.T) {
 			t.Fatalf("unexpected err: %v", err)
 		}
 		gb.done = true
-		_, err = gb.Next(100)
+		_, err = gb.Next(context.Background(), 100)
 		if err == nil || !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF but received %v", err)
 		}
@@ -413,7 +417,7 @@ func TestGroupByNext_SingleColumnCount(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	batch, _ := gb.Next(1000)
+	batch, _ := gb.Next(context.Background(), 1000)
 
 	if batch == nil || batch.RowCount == 0 {
 		t.Fatalf("expected non-empty grouped result")
@@ -455,7 +459,7 @@ func TestGroupByNext_MultipleGroupBy_MultipleAggs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	batch, _ := gb.Next(50)
+	batch, _ := gb.Next(context.Background(), 50)
 
 	if batch.RowCount == 0 {
 		t.Fatalf("expected non-zero grouped rows")
@@ -466,6 +470,35 @@ func TestGroupByNext_MultipleGroupBy_MultipleAggs(t *testing.T) {
 	}
 }
 
+func TestGroupByNext_NonStringKeys(t *testing.T) {
+	child := groupByProject()
+	gb, err := NewGroupByExec(child,
+		[]AggregateFunctions{{AggrFunc: Count, Child: col("id")}},
+		[]Expr.Expression{col("age")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	batch, err := gb.Next(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ages, ok := batch.Columns[0].(*array.Int32)
+	if !ok {
+		t.Fatalf("expected int32 group column, got %s", batch.Columns[0].DataType())
+	}
+	counts := batch.Columns[1].(*array.Int64)
+	total := int64(0)
+	for i := 0; i < ages.Len(); i++ {
+		if ages.Value(i) == 0 {
+			t.Fatalf("unexpected zero age at row %d", i)
+		}
+		total += counts.Value(i)
+	}
+	if total != 40 {
+		t.Fatalf("expected counts to add up to 40, got %v", total)
+	}
+}
+
 func TestGroupByNext_MultipleNextCalls(t *testing.T) {
 	col := func(n string) Expr.Expression { return Expr.NewColumnResolve(n) }
 
@@ -482,8 +515,8 @@ func TestGroupByNext_MultipleNextCalls(t *testing.T) {
 	}
 
 	// First call returns batch + EOF
-	_, _ = gb.Next(100)
-	_, err = gb.Next(100)
+	_, _ = gb.Next(context.Background(), 100)
+	_, err = gb.Next(context.Background(), 100)
 	if !errors.Is(err, io.EOF) {
 		t.Fatalf("expected EOF on second return, got %v", err)
 	}
@@ -632,7 +665,7 @@ func TestCreateAccumulator_AllCases(t *testing.T) {
 	funcs := []AggrFunc{Min, Max, Sum, Count, Avg}
 
 	for _, fn := range funcs {
-		acc := createAccumulator(fn)
+		acc := createAccumulator(fn, arrow.PrimitiveTypes.Int64)
 		if acc == nil {
 			t.Fatalf("expected accumulator for fn=%v", fn)
 		}
@@ -646,7 +679,7 @@ func TestCreateAccumulator_PanicOnInvalid(t *testing.T) {
 		}
 	}()
 
-	createAccumulator(AggrFunc(9999)) // invalid
+	createAccumulator(AggrFunc(9999), arrow.PrimitiveTypes.Int64) // invalid
 }
 
 func TestBuildGroupByOutput_Basic(t *testing.T) {
@@ -664,9 +697,9 @@ func TestBuildGroupByOutput_Basic(t *testing.T) {
 	}
 
 	// invoke Next (fills accumulators)
-	_, _ = gb.Next(100)
+	_, _ = gb.Next(context.Background(), 100)
 
-	batch := buildGroupByOutput(gb)
+	batch := buildGroupByOutput(gb, memory.NewGoAllocator())
 
 	if batch.RowCount == 0 {
 		t.Fatalf("expected grouped rows")
@@ -676,3 +709,324 @@ func TestBuildGroupByOutput_Basic(t *testing.T) {
 		t.Fatalf("expected 2 columns, got %d", len(batch.Columns))
 	}
 }
+
+// groupRows reads every group as one formatted string per row, sorted since groups come out in no particular order
+func groupRows(t *testing.T, ctx context.Context, g *GroupByExec) (rows []string, batches int) {
+	t.Helper()
+	for {
+		batch, err := g.Next(ctx, 5)
+		if errors.Is(err, io.EOF) {
+			sort.Strings(rows)
+			return rows, batches
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batches++
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := ""
+			for _, c := range batch.Columns {
+				row += c.ValueStr(r) + "|"
+			}
+			rows = append(rows, row)
+		}
+		operators.ReleaseArrays(batch.Columns)
+	}
+}
+
+func TestSpillingGroupBy(t *testing.T) {
+	groupBy := []Expr.Expression{Expr.NewColumnResolve("department"), Expr.NewColumnResolve("region")}
+	aggs := []AggregateFunctions{
+		{AggrFunc: Min, Child: Expr.NewColumnResolve("salary")},
+		{AggrFunc: Max, Child: Expr.NewColumnResolve("age")},
+		{AggrFunc: Count, Child: Expr.NewColumnResolve("id")},
+		{AggrFunc: Sum, Child: Expr.NewColumnResolve("salary")},
+		{AggrFunc: Avg, Child: Expr.NewColumnResolve("age")},
+	}
+	inMemory := func(t *testing.T, child operators.Operator, groupBy []Expr.Expression, aggs []AggregateFunctions) []string {
+		gb, err := NewGroupByExec(child, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rows, _ := groupRows(t, context.Background(), gb)
+		return rows
+	}
+
+	t.Run("spilled groups merge back to the in-memory result", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		want := inMemory(t, groupByProject(), groupBy, aggs)
+		q := operators.NewQueryMemory(nil, 0, 1)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		gb, err := NewGroupByExec(&smallBatches{input: groupByProject(), size: 3}, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gb.fanOut = 4
+		got, batches := groupRows(t, ctx, gb)
+		if batches < 2 || batches > gb.fanOut {
+			t.Fatalf("expected a batch per partition, got %d batches", batches)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+		if err := gb.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+		if entries, _ := os.ReadDir(os.TempDir()); len(entries) != 0 {
+			t.Fatalf("expected the spill files to be removed, found %d files", len(entries))
+		}
+	})
+	t.Run("null keys and values", func(t *testing.T) {
+		groupBy := []Expr.Expression{Expr.NewColumnResolve("name")}
+		aggs := []AggregateFunctions{
+			{AggrFunc: Avg, Child: Expr.NewColumnResolve("age")},
+			{AggrFunc: Min, Child: Expr.NewColumnResolve("salary")},
+		}
+		want := inMemory(t, aggProjectNull(), groupBy, aggs)
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		gb, err := NewGroupByExec(&smallBatches{input: aggProjectNull(), size: 2}, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = gb.Close() }()
+		got, _ := groupRows(t, ctx, gb)
+		if gb.partitions == nil {
+			t.Fatalf("expected the groups to be spilled")
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	})
+	t.Run("under the threshold nothing is spilled", func(t *testing.T) {
+		want := inMemory(t, groupByProject(), groupBy, aggs)
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1<<30))
+		gb, err := NewGroupByExec(&smallBatches{input: groupByProject(), size: 3}, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = gb.Close() }()
+		got, batches := groupRows(t, ctx, gb)
+		if gb.partitions != nil || batches != 1 {
+			t.Fatalf("expected a single in-memory batch, got %d batches", batches)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	})
+	t.Run("having skips partitions it empties", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		// group g has g%4+1 rows so only every fourth group passes the having
+		var gs []int64
+		var want []string
+		for g := int64(0); g < 32; g++ {
+			for range g%4 + 1 {
+				gs = append(gs, g)
+			}
+			if g%4 == 3 {
+				want = append(want, fmt.Sprint(g))
+			}
+		}
+		src, err := project.NewInMemoryProjectExec([]string{"g"}, []any{gs})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		gb, err := NewGroupByExec(&smallBatches{input: src, size: 7}, []AggregateFunctions{{AggrFunc: CountStar}}, []Expr.Expression{Expr.NewColumnResolve("g")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gb.fanOut = 8
+		having, err := NewHavingExec(gb, Expr.NewBinaryExpr(Expr.NewColumnResolve("count_*"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int64(3))))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		proj, err := project.NewProjectExec(having, []Expr.Expression{Expr.NewColumnResolve(gb.Schema().Field(0).Name)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = proj.Close() }()
+		var got []string
+		for {
+			batch, err := proj.Next(ctx, 5)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for r := 0; r < int(batch.RowCount); r++ {
+				got = append(got, batch.Columns[0].ValueStr(r))
+			}
+			operators.ReleaseArrays(batch.Columns)
+		}
+		if gb.partitions == nil {
+			t.Fatalf("expected the groups to be spilled")
+		}
+		sort.Slice(got, func(i, j int) bool { return len(got[i]) < len(got[j]) || len(got[i]) == len(got[j]) && got[i] < got[j] })
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("expected groups %v, got %v", want, got)
+		}
+	})
+	t.Run("hash table counts against the limit", func(t *testing.T) {
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 2048, 0))
+		gb, err := NewGroupByExec(groupByProject(), aggs, []Expr.Expression{Expr.NewColumnResolve("name")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = gb.Close() }()
+		if _, err := gb.Next(ctx, 100); !errors.Is(err, operators.ErrOutOfMemory) {
+			t.Fatalf("expected an out of memory error, got %v", err)
+		}
+	})
+}
+
+// unionOp returns the batches of every input one input after the other
+type unionOp struct {
+	inputs []operators.Operator
+	next   int
+}
+
+func (u *unionOp) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	for u.next < len(u.inputs) {
+		batch, err := u.inputs[u.next].Next(ctx, n)
+		if errors.Is(err, io.EOF) {
+			u.next++
+			continue
+		}
+		return batch, err
+	}
+	return nil, io.EOF
+}
+func (u *unionOp) Schema() *arrow.Schema { return u.inputs[0].Schema() }
+func (u *unionOp) Close() error {
+	for _, in := range u.inputs {
+		_ = in.Close()
+	}
+	return nil
+}
+
+// drainRows reads every row of op as one formatted string per row, sorted
+func drainRows(t *testing.T, ctx context.Context, op operators.Operator) []string {
+	t.Helper()
+	var rows []string
+	for {
+		batch, err := op.Next(ctx, 5)
+		if errors.Is(err, io.EOF) {
+			sort.Strings(rows)
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := ""
+			for _, c := range batch.Columns {
+				row += c.ValueStr(r) + "|"
+			}
+			rows = append(rows, row)
+		}
+		operators.ReleaseArrays(batch.Columns)
+	}
+}
+
+func TestTwoPhaseAggregation(t *testing.T) {
+	aggs := []AggregateFunctions{
+		{AggrFunc: Min, Child: Expr.NewColumnResolve("salary")},
+		{AggrFunc: Max, Child: Expr.NewColumnResolve("age")},
+		{AggrFunc: Count, Child: Expr.NewColumnResolve("id")},
+		{AggrFunc: Sum, Child: Expr.NewColumnResolve("salary")},
+		{AggrFunc: Avg, Child: Expr.NewColumnResolve("age")},
+		{AggrFunc: CountStar},
+		{AggrFunc: Count, Child: Expr.NewColumnResolve("age"), Distinct: true},
+		{AggrFunc: Sum, Child: Expr.NewColumnResolve("salary"), Distinct: true},
+	}
+	groupBy := []Expr.Expression{Expr.NewColumnResolve("department"), Expr.NewColumnResolve("region")}
+	builders := map[string]func(child operators.Operator, mode AggrMode) (operators.Operator, error){
+		"group by": func(child operators.Operator, mode AggrMode) (operators.Operator, error) {
+			return NewGroupByExecWithMode(child, aggs, groupBy, mode)
+		},
+		"global": func(child operators.Operator, mode AggrMode) (operators.Operator, error) {
+			return NewGlobalAggrExecWithMode(child, aggs, mode)
+		},
+	}
+	// three copies of the table, each in small batches
+	tables := func() []operators.Operator {
+		return []operators.Operator{
+			&smallBatches{input: groupByProject(), size: 4},
+			&smallBatches{input: groupByProject(), size: 3},
+			&smallBatches{input: groupByProject(), size: 5},
+		}
+	}
+
+	for name, build := range builders {
+		t.Run(name+" partials combine to the complete result", func(t *testing.T) {
+			complete, err := build(&unionOp{inputs: tables()}, Complete)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := drainRows(t, context.Background(), complete)
+			t.Setenv("TMPDIR", t.TempDir())
+			for _, upstream := range [][]AggrMode{{Final}, {Merge, Final}, {Merge, Merge, Final}} {
+				// every group by phase spills, their partitions hold state the same way their output does
+				q := operators.NewQueryMemory(nil, 0, 1)
+				ctx := operators.WithQueryMemory(context.Background(), q)
+				// a partial aggregate per table, combined by the upstream phases
+				var partials []operators.Operator
+				for _, table := range tables() {
+					p, err := build(table, Partial)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					partials = append(partials, p)
+				}
+				var op operators.Operator = &unionOp{inputs: partials}
+				for _, mode := range upstream {
+					if op, err = build(op, mode); err != nil {
+						t.Fatalf("%v: unexpected error: %v", mode, err)
+					}
+				}
+				if !op.Schema().Equal(complete.Schema()) {
+					t.Fatalf("%v: expected the final schema to match the complete one, got %v", upstream, op.Schema())
+				}
+				got := drainRows(t, ctx, op)
+				if strings.Join(got, "\n") != strings.Join(want, "\n") {
+					t.Fatalf("%v: expected\n%s\ngot\n%s", upstream, strings.Join(want, "\n"), strings.Join(got, "\n"))
+				}
+				_ = op.Close()
+				if q.InUse() != 0 {
+					t.Fatalf("%v: expected all memory to be released, %d bytes still in use", upstream, q.InUse())
+				}
+			}
+		})
+	}
+	t.Run("partials return the state columns", func(t *testing.T) {
+		gb, err := NewGroupByExecWithMode(groupByProject(), aggs, groupBy, Partial)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"group_Column(department)", "group_Column(region)", "min_Column(salary)", "max_Column(age)", "count_Column(id)", "sum_Column(salary)", "avg_Column(age)_sum", "avg_Column(age)_count", "count_*", "count_distinct_Column(age)", "sum_distinct_Column(salary)"}
+		fields := gb.Schema().Fields()
+		if len(fields) != len(want) {
+			t.Fatalf("expected %d columns, got %v", len(want), gb.Schema())
+		}
+		for i, f := range fields {
+			if f.Name != want[i] {
+				t.Fatalf("expected column %d to be %s, got %s", i, want[i], f.Name)
+			}
+		}
+		if text := operators.ExplainText(gb); !strings.Contains(text, "mode=partial") {
+			t.Fatalf("expected the mode in the plan\n%s", text)
+		}
+	})
+	t.Run("merging input that isn't state fails", func(t *testing.T) {
+		if _, err := NewGroupByExecWithMode(groupByProject(), aggs, groupBy, Final); err == nil {
+			t.Fatalf("expected a final group by over raw rows to fail")
+		}
+		if _, err := NewGlobalAggrExecWithMode(groupByProject(), aggs, Merge); err == nil {
+			t.Fatalf("expected a merging aggregate over raw rows to fail")
+		}
+	})
+}
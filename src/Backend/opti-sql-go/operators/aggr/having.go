@@ -1,6 +1,7 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"io"
 	"opti-sql-go/Expr"
@@ -14,6 +15,7 @@ import (
 // carbon copy of filter.go with minor changes to fit having semantics
 var (
 	_ = (operators.Operator)(&HavingExec{})
+	_ = (operators.Explainer)(&HavingExec{})
 )
 
 type HavingExec struct {
@@ -33,35 +35,57 @@ func NewHavingExec(input operators.Operator, havingFilter Expr.Expression) (*Hav
 	}, nil
 }
 
-func (h *HavingExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (h *HavingExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if h.done {
 		return nil, io.EOF
 	}
-	childBatch, err := h.input.Next(n)
-	if err != nil {
-		if errors.Is(err, io.EOF) {
-			h.done = true
+	ctx, _ = operators.Allocator(ctx, h)
+	// a spilled GroupByExec hands out a batch per partition, one the predicate empties is skipped
+	// instead of ending the stream
+	for {
+		childBatch, err := h.input.Next(ctx, n)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				h.done = true
+			}
+			return nil, err
 		}
-		return nil, err
+		batch, err := h.filterBatch(ctx, childBatch)
+		if err != nil || batch.RowCount > 0 {
+			return batch, err
+		}
+		operators.ReleaseArrays(batch.Columns)
 	}
-	booleanMask, err := Expr.EvalExpression(h.havingExpr, childBatch)
+}
+
+// filterBatch keeps the rows of childBatch the predicate is true for
+func (h *HavingExec) filterBatch(ctx context.Context, childBatch *operators.RecordBatch) (*operators.RecordBatch, error) {
+	booleanMask, err := Expr.EvalExpression(ctx, h.havingExpr, childBatch)
 	if err != nil {
+		operators.ReleaseArrays(childBatch.Columns)
 		return nil, err
 	}
+	defer booleanMask.Release()
 	boolArr, ok := booleanMask.(*array.Boolean) // impossible for this to not be a boolean array,assuming validPredicates works as it should
 	if !ok {
+		operators.ReleaseArrays(childBatch.Columns)
 		return nil, errors.New("having predicate did not evaluate to boolean array")
 	}
 	filteredCol := make([]arrow.Array, len(childBatch.Columns))
 	for i, col := range childBatch.Columns {
-		filteredCol[i], err = filter.ApplyBooleanMask(col, boolArr)
+		filteredCol[i], err = filter.ApplyBooleanMask(ctx, col, boolArr)
 		if err != nil {
+			operators.ReleaseArrays(filteredCol)
+			operators.ReleaseArrays(childBatch.Columns)
 			return nil, err
 		}
 	}
 	// release old columns
 	operators.ReleaseArrays(childBatch.Columns)
-	size := uint64(filteredCol[0].Len())
+	size := uint64(0)
+	if len(filteredCol) > 0 {
+		size = uint64(filteredCol[0].Len())
+	}
 
 	return &operators.RecordBatch{
 		Schema:   childBatch.Schema,
@@ -75,3 +99,10 @@ func (h *HavingExec) Schema() *arrow.Schema {
 func (h *HavingExec) Close() error {
 	return h.input.Close()
 }
+func (h *HavingExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "HavingExec",
+		Params:   map[string]string{"predicate": h.havingExpr.String()},
+		Children: []operators.Operator{h.input},
+	}
+}
@@ -1,6 +1,7 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"io"
 	"strings"
@@ -45,7 +46,7 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 			t.Fatalf("unexpected HavingExec init error: %v", err)
 		}
 
-		batch, err := having.Next(1024)
+		batch, err := having.Next(context.Background(), 1024)
 		if err != nil {
 			t.Fatalf("unexpected error running Next: %v", err)
 		}
@@ -81,7 +82,7 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 		havingExpr := Expr.NewBinaryExpr(
 			Expr.NewColumnResolve(countCol),
 			Expr.GreaterThanOrEqual,
-			Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float64, 10.0),
+			Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int64(10)),
 		)
 
 		having, err := NewHavingExec(gb, havingExpr)
@@ -89,7 +90,7 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 			t.Fatalf("unexpected err: %v", err)
 		}
 
-		batch, err := having.Next(200)
+		batch, err := having.Next(context.Background(), 200)
 		if err != nil {
 			t.Fatalf("unexpected Next error: %v", err)
 		}
@@ -124,13 +125,10 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 
 		having, _ := NewHavingExec(gb, havingExpr)
 
-		batch, err := having.Next(1024)
-		if err != nil {
-			t.Fatalf("unexpected err: %v", err)
-		}
-
-		if batch.RowCount != 0 {
-			t.Fatalf("expected all rows to be filtered out, got %d", batch.RowCount)
+		// batches the predicate empties are skipped, with every group filtered out that is all of them
+		batch, err := having.Next(context.Background(), 1024)
+		if !errors.Is(err, io.EOF) {
+			t.Fatalf("expected all rows to be filtered out, got %v (%v)", batch, err)
 		}
 	})
 
@@ -152,7 +150,7 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 
 		having, _ := NewHavingExec(gb, invalidExpr)
 
-		_, err := having.Next(100)
+		_, err := having.Next(context.Background(), 100)
 		if err == nil {
 			t.Fatalf("expected non-boolean error, got nil")
 		}
@@ -186,7 +184,7 @@ func TestHavingExec_OnGroupBy(t *testing.T) {
 		h, _ := NewHavingExec(gb, havingExpr)
 		h.done = true
 
-		_, err := h.Next(10)
+		_, err := h.Next(context.Background(), 10)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF, got: %v", err)
 		}
@@ -1,16 +1,20 @@
 package aggr
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"opti-sql-go/Expr"
 	"opti-sql-go/operators"
+	"strings"
 
 	"github.com/apache/arrow/go/v17/arrow"
 	"github.com/apache/arrow/go/v17/arrow/array"
 	"github.com/apache/arrow/go/v17/arrow/compute"
+	"github.com/apache/arrow/go/v17/arrow/float16"
+	"github.com/apache/arrow/go/v17/arrow/memory"
 )
 
 var (
@@ -18,7 +22,12 @@ var (
 		return fmt.Errorf("%d is an unsupported aggregate function", aggr)
 	}
 	ErrInvalidAggrColumnType = func(value any) error {
-		return fmt.Errorf("%v of type %T cannot be cast to float64 so it is not a valid column type to aggregate on", value, value)
+		return fmt.Errorf("%v of type %T is not a valid column type to aggregate on", value, value)
+	}
+	ErrSumOverflow       = errors.New("sum is out of the range of its type")
+	ErrDistinctCountStar = errors.New("COUNT(*) counts rows, it can't be DISTINCT")
+	ErrInvalidStateInput = func(schema *arrow.Schema) error {
+		return fmt.Errorf("input %v is not the state of the aggregates being merged", schema)
 	}
 )
 
@@ -31,15 +40,17 @@ const (
 	Count
 	Sum
 	Avg
+	CountStar // COUNT(*), counts rows and reads no column
 )
 
 var (
-	_ = (accumulator)(&minAggrAccumulator{})
-	_ = (accumulator)(&maxAggrAccumulator{})
+	_ = (accumulator)(&minMaxAggrAccumulator[int64]{})
 	_ = (accumulator)(&countAggrAccumulator{})
-	_ = (accumulator)(&sumAggrAccumulator{})
+	_ = (accumulator)(&sumAggrAccumulator[int64]{})
 	_ = (accumulator)(&avgAggrAccumulator{})
+	_ = (accumulator)(&distinctAggrAccumulator[int64]{})
 	_ = (operators.Operator)(&AggrExec{})
+	_ = (operators.Explainer)(&AggrExec{})
 )
 
 func NewAggregateFunctions(aggrFunc AggrFunc, child Expr.Expression) AggregateFunctions {
@@ -51,97 +62,464 @@ func NewAggregateFunctions(aggrFunc AggrFunc, child Expr.Expression) AggregateFu
 
 type AggregateFunctions struct {
 	AggrFunc AggrFunc        // switch to deal with separate aggregate functions
-	Child    Expr.Expression // resolves to a column generally
+	Child    Expr.Expression // resolves to a column generally, nil for CountStar
+	Distinct bool            // aggregate every distinct value once, ie COUNT(DISTINCT x)
+}
+
+func (a AggregateFunctions) String() string {
+	switch {
+	case a.AggrFunc == CountStar:
+		return "COUNT(*)"
+	case a.Distinct:
+		return fmt.Sprintf("%s(DISTINCT %s)", aggrToString(int(a.AggrFunc)), a.Child)
+	default:
+		return fmt.Sprintf("%s(%s)", aggrToString(int(a.AggrFunc)), a.Child)
+	}
+}
+
+// AggrMode is the phase of a two phase aggregation an operator runs. Partial aggregates run close to
+// the data (per worker, per file) and return the state of their accumulators instead of results,
+// Merge combines such states into fewer and Final turns them into the results. Complete does all of
+// it in one operator
+type AggrMode int
+
+const (
+	Complete AggrMode = iota // input rows in, results out
+	Partial                  // input rows in, state columns out
+	Merge                    // state columns in, state columns out
+	Final                    // state columns in, results out
+)
+
+func (m AggrMode) String() string {
+	switch m {
+	case Complete:
+		return "complete"
+	case Partial:
+		return "partial"
+	case Merge:
+		return "merge"
+	case Final:
+		return "final"
+	default:
+		return "unknown"
+	}
 }
+
+// readsState is true for the modes whose input is the state columns of another aggregate
+func (m AggrMode) readsState() bool { return m == Merge || m == Final }
+
+// returnsState is true for the modes that output state columns instead of results
+func (m AggrMode) returnsState() bool { return m == Partial || m == Merge }
+
+// resultType is the type fn returns over values of type dt, following SQL: COUNT is an int64, SUM an
+// int64 over signed integers (uint64 over unsigned ones, float64 over floats), MIN and MAX keep the
+// type of their input (float16 is widened to float32) and AVG is a float64. everything but COUNT is
+// null when it saw no values
+func resultType(fn AggrFunc, dt arrow.DataType) arrow.DataType {
+	switch fn {
+	case Count, CountStar:
+		return arrow.PrimitiveTypes.Int64
+	case Sum:
+		switch dt.ID() {
+		case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64:
+			return arrow.PrimitiveTypes.Int64
+		case arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+			return arrow.PrimitiveTypes.Uint64
+		default:
+			return arrow.PrimitiveTypes.Float64
+		}
+	case Min, Max:
+		if dt.ID() == arrow.FLOAT16 {
+			return arrow.PrimitiveTypes.Float32
+		}
+		return dt
+	default:
+		return arrow.PrimitiveTypes.Float64
+	}
+}
+
+// inputType is the type values are cast to before they are handed to the accumulator of fn, nil
+// when they are handed over as they are
+func inputType(fn AggrFunc, dt arrow.DataType) arrow.DataType {
+	switch fn {
+	case Count, CountStar:
+		return nil
+	case Avg:
+		return arrow.PrimitiveTypes.Float64
+	default:
+		return resultType(fn, dt)
+	}
+}
+
 type accumulator interface {
-	Update(value float64)
-	Finalize() float64
+	// Update adds row of values, values was cast to the aggregate's inputType and row isn't null.
+	// values is nil for CountStar, every row counts
+	Update(values arrow.Array, row int) error
+	// Finalize appends the result to b, a builder of the aggregate's resultType
+	Finalize(b array.Builder)
+	// State appends the partial result so far to the builders of the aggregate's state columns (see
+	// stateFields), Merge folds the state in row of those columns into the accumulator. this is how
+	// partial aggregates are combined and how group by reads back groups it spilled to disk
+	State(builders []array.Builder)
+	Merge(cols []arrow.Array, row int) error
 }
 
-func newMinAggr() accumulator {
-	return &minAggrAccumulator{}
+// stateFields are the columns the state of fn over values of type dt is kept in, name is the
+// aggregate's output column. sum, min and max are null until they saw a value so an empty state
+// stays null once merged. their state has the result type so it reads back the same
+func stateFields(fn AggrFunc, name string, dt arrow.DataType) []arrow.Field {
+	switch fn {
+	case Avg:
+		return []arrow.Field{
+			{Name: name + "_sum", Type: arrow.PrimitiveTypes.Float64},
+			{Name: name + "_count", Type: arrow.PrimitiveTypes.Int64},
+		}
+	case Min, Max, Sum:
+		return []arrow.Field{{Name: name, Type: resultType(fn, dt), Nullable: true}}
+	default:
+		return []arrow.Field{{Name: name, Type: resultType(fn, dt)}}
+	}
 }
 
-type minAggrAccumulator struct {
-	minV       float64
-	firstValue bool
+// stateWidth is the number of state columns of fn
+func stateWidth(fn AggrFunc) int {
+	if fn == Avg {
+		return 2
+	}
+	return 1
 }
 
-func (m *minAggrAccumulator) Update(value float64) {
-	if !m.firstValue {
-		m.minV = value
-		m.firstValue = true
-		return
+// aggrStateFields are the state columns of agg over values of type dt. a DISTINCT aggregate keeps
+// the distinct values it saw instead, as a list of its distinctType
+func aggrStateFields(agg AggregateFunctions, dt arrow.DataType) []arrow.Field {
+	if agg.Distinct {
+		return []arrow.Field{{Name: aggrFieldName(agg), Type: arrow.ListOf(distinctType(agg.AggrFunc, dt))}}
 	}
-	m.minV = min(m.minV, value)
+	return stateFields(agg.AggrFunc, aggrFieldName(agg), dt)
+}
 
+// aggrStateWidth is the number of state columns of agg
+func aggrStateWidth(agg AggregateFunctions) int {
+	if agg.Distinct {
+		return 1
+	}
+	return stateWidth(agg.AggrFunc)
 }
-func (m *minAggrAccumulator) Finalize() float64 { return m.minV }
-func newMaxAggr() accumulator {
-	return &maxAggrAccumulator{}
+
+// distinctType is the type the values of a DISTINCT fn over dt are kept in, what its accumulator is handed
+func distinctType(fn AggrFunc, dt arrow.DataType) arrow.DataType {
+	if t := inputType(fn, dt); t != nil {
+		return t
+	}
+	return dt
 }
 
-type maxAggrAccumulator struct {
-	maxV       float64
-	firstValue bool
+// valuer and appender are the typed arrays and builders of arrow, ie *array.Int64 and *array.Int64Builder
+type valuer[T any] interface{ Value(int) T }
+type appender[T any] interface{ Append(T) }
+
+// min and max keep the value that compares lowest (keep -1) or highest (keep 1), compared like the
+// sort compares them. clone copies strings and bytes out of the array they were read from, nil for
+// values that don't point into one
+type minMaxAggrAccumulator[T any] struct {
+	value   T
+	seen    bool
+	keep    int
+	compare func(a, b T) int
+	clone   func(T) T
+}
+
+func newMinMaxAggr(fn AggrFunc, dt arrow.DataType) accumulator {
+	keep := -1
+	if fn == Max {
+		keep = 1
+	}
+	switch resultType(fn, dt).ID() {
+	case arrow.INT8:
+		return &minMaxAggrAccumulator[int8]{keep: keep, compare: compareNumeric[int8]}
+	case arrow.INT16:
+		return &minMaxAggrAccumulator[int16]{keep: keep, compare: compareNumeric[int16]}
+	case arrow.INT32:
+		return &minMaxAggrAccumulator[int32]{keep: keep, compare: compareNumeric[int32]}
+	case arrow.INT64:
+		return &minMaxAggrAccumulator[int64]{keep: keep, compare: compareNumeric[int64]}
+	case arrow.UINT8:
+		return &minMaxAggrAccumulator[uint8]{keep: keep, compare: compareNumeric[uint8]}
+	case arrow.UINT16:
+		return &minMaxAggrAccumulator[uint16]{keep: keep, compare: compareNumeric[uint16]}
+	case arrow.UINT32:
+		return &minMaxAggrAccumulator[uint32]{keep: keep, compare: compareNumeric[uint32]}
+	case arrow.UINT64:
+		return &minMaxAggrAccumulator[uint64]{keep: keep, compare: compareNumeric[uint64]}
+	case arrow.FLOAT32:
+		return &minMaxAggrAccumulator[float32]{keep: keep, compare: compareFloat[float32]}
+	case arrow.FLOAT64:
+		return &minMaxAggrAccumulator[float64]{keep: keep, compare: compareFloat[float64]}
+	case arrow.STRING:
+		return &minMaxAggrAccumulator[string]{keep: keep, compare: strings.Compare, clone: strings.Clone}
+	case arrow.BINARY:
+		return &minMaxAggrAccumulator[[]byte]{keep: keep, compare: bytes.Compare, clone: bytes.Clone}
+	case arrow.BOOL:
+		return &minMaxAggrAccumulator[bool]{keep: keep, compare: compareBool}
+	case arrow.DATE32:
+		return &minMaxAggrAccumulator[arrow.Date32]{keep: keep, compare: compareNumeric[arrow.Date32]}
+	case arrow.DATE64:
+		return &minMaxAggrAccumulator[arrow.Date64]{keep: keep, compare: compareNumeric[arrow.Date64]}
+	case arrow.TIMESTAMP:
+		return &minMaxAggrAccumulator[arrow.Timestamp]{keep: keep, compare: compareNumeric[arrow.Timestamp]}
+	default:
+		panic(fmt.Sprintf("unsupported %s type: %v", aggrToString(int(fn)), dt))
+	}
 }
 
-func (m *maxAggrAccumulator) Update(value float64) {
-	if !m.firstValue {
-		m.maxV = value
-		m.firstValue = true
+func (m *minMaxAggrAccumulator[T]) Update(values arrow.Array, row int) error {
+	m.update(values.(valuer[T]).Value(row))
+	return nil
+}
+func (m *minMaxAggrAccumulator[T]) update(v T) {
+	if !m.seen || m.compare(v, m.value) == m.keep {
+		if m.clone != nil {
+			v = m.clone(v)
+		}
+		m.value = v
+		m.seen = true
+	}
+}
+func (m *minMaxAggrAccumulator[T]) Finalize(b array.Builder) {
+	if !m.seen {
+		b.AppendNull()
 		return
 	}
-	m.maxV = max(m.maxV, value)
+	b.(appender[T]).Append(m.value)
+}
+func (m *minMaxAggrAccumulator[T]) State(builders []array.Builder) { m.Finalize(builders[0]) }
+func (m *minMaxAggrAccumulator[T]) Merge(cols []arrow.Array, row int) error {
+	if !cols[0].IsNull(row) {
+		m.update(cols[0].(valuer[T]).Value(row))
+	}
+	return nil
 }
-func (m *maxAggrAccumulator) Finalize() float64 { return m.maxV }
 
 func newCountAggr() accumulator {
 	return &countAggrAccumulator{}
 }
 
 type countAggrAccumulator struct {
-	count float64
+	count int64
 }
 
-func (c *countAggrAccumulator) Update(_ float64) {
+func (c *countAggrAccumulator) Update(_ arrow.Array, _ int) error {
 	c.count++
+	return nil
+}
+func (c *countAggrAccumulator) Finalize(b array.Builder) { b.(*array.Int64Builder).Append(c.count) }
+func (c *countAggrAccumulator) State(builders []array.Builder) {
+	builders[0].(*array.Int64Builder).Append(c.count)
+}
+func (c *countAggrAccumulator) Merge(cols []arrow.Array, row int) error {
+	c.count += cols[0].(*array.Int64).Value(row)
+	return nil
 }
-func (c *countAggrAccumulator) Finalize() float64 { return c.count }
 
-func newSumAggr() accumulator {
-	return &sumAggrAccumulator{}
+// sums add up in the type they return, add reports false when the result overflowed
+type sumAggrAccumulator[T int64 | uint64 | float64] struct {
+	summation T
+	seen      bool
+	add       func(a, b T) (T, bool)
 }
 
-type sumAggrAccumulator struct {
-	summation float64
+func newSumAggr(dt arrow.DataType) accumulator {
+	switch resultType(Sum, dt).ID() {
+	case arrow.INT64:
+		return &sumAggrAccumulator[int64]{add: addInt64}
+	case arrow.UINT64:
+		return &sumAggrAccumulator[uint64]{add: addUint64}
+	default:
+		return &sumAggrAccumulator[float64]{add: func(a, b float64) (float64, bool) { return a + b, true }}
+	}
 }
 
-func (s *sumAggrAccumulator) Update(value float64) {
-	s.summation += value
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+func addUint64(a, b uint64) (uint64, bool) {
+	c := a + b
+	return c, c >= a
+}
+
+func (s *sumAggrAccumulator[T]) Update(values arrow.Array, row int) error {
+	return s.update(values.(valuer[T]).Value(row))
+}
+func (s *sumAggrAccumulator[T]) update(v T) error {
+	sum, ok := s.add(s.summation, v)
+	if !ok {
+		return ErrSumOverflow
+	}
+	s.summation = sum
+	s.seen = true
+	return nil
+}
+func (s *sumAggrAccumulator[T]) Finalize(b array.Builder) {
+	if !s.seen {
+		b.AppendNull()
+		return
+	}
+	b.(appender[T]).Append(s.summation)
 }
-func (s *sumAggrAccumulator) Finalize() float64 { return s.summation }
+func (s *sumAggrAccumulator[T]) State(builders []array.Builder) { s.Finalize(builders[0]) }
+func (s *sumAggrAccumulator[T]) Merge(cols []arrow.Array, row int) error {
+	if cols[0].IsNull(row) {
+		return nil
+	}
+	return s.update(cols[0].(valuer[T]).Value(row))
+}
+
 func newAvgAggr() accumulator {
 	return &avgAggrAccumulator{}
 }
 
 type avgAggrAccumulator struct {
-	used   bool
 	values float64
-	count  float64
+	count  int64
 }
 
-func (a *avgAggrAccumulator) Update(value float64) {
-	a.used = true
-	a.values += value
+func (a *avgAggrAccumulator) Update(values arrow.Array, row int) error {
+	a.values += values.(*array.Float64).Value(row)
 	a.count++
+	return nil
+}
+func (a *avgAggrAccumulator) Finalize(b array.Builder) {
+	// the average of no values is null, not a divide by zero
+	if a.count == 0 {
+		b.AppendNull()
+		return
+	}
+	b.(*array.Float64Builder).Append(a.values / float64(a.count))
+}
+func (a *avgAggrAccumulator) State(builders []array.Builder) {
+	builders[0].(*array.Float64Builder).Append(a.values)
+	builders[1].(*array.Int64Builder).Append(a.count)
+}
+func (a *avgAggrAccumulator) Merge(cols []arrow.Array, row int) error {
+	a.values += cols[0].(*array.Float64).Value(row)
+	a.count += cols[1].(*array.Int64).Value(row)
+	return nil
+}
+
+// distinctAggrAccumulator hands acc every value the first time it sees it. the distinct values are
+// its state, merging one hands on the values this accumulator didn't see yet. key is what a value is
+// looked up by when T isn't comparable, clone copies strings and bytes out of the array they were
+// read from like minMaxAggrAccumulator does
+type distinctAggrAccumulator[T any] struct {
+	acc    accumulator
+	seen   map[any]struct{}
+	values []T
+	key    func(T) any
+	clone  func(T) T
+}
+
+// newDistinctAggr makes acc a DISTINCT aggregate over values of type dt, see distinctType
+func newDistinctAggr(acc accumulator, dt arrow.DataType) accumulator {
+	switch dt.ID() {
+	case arrow.INT8:
+		return &distinctAggrAccumulator[int8]{acc: acc}
+	case arrow.INT16:
+		return &distinctAggrAccumulator[int16]{acc: acc}
+	case arrow.INT32:
+		return &distinctAggrAccumulator[int32]{acc: acc}
+	case arrow.INT64:
+		return &distinctAggrAccumulator[int64]{acc: acc}
+	case arrow.UINT8:
+		return &distinctAggrAccumulator[uint8]{acc: acc}
+	case arrow.UINT16:
+		return &distinctAggrAccumulator[uint16]{acc: acc}
+	case arrow.UINT32:
+		return &distinctAggrAccumulator[uint32]{acc: acc}
+	case arrow.UINT64:
+		return &distinctAggrAccumulator[uint64]{acc: acc}
+	case arrow.FLOAT16:
+		return &distinctAggrAccumulator[float16.Num]{acc: acc}
+	case arrow.FLOAT32:
+		return &distinctAggrAccumulator[float32]{acc: acc}
+	case arrow.FLOAT64:
+		return &distinctAggrAccumulator[float64]{acc: acc}
+	case arrow.STRING:
+		return &distinctAggrAccumulator[string]{acc: acc, clone: strings.Clone}
+	case arrow.BINARY:
+		return &distinctAggrAccumulator[[]byte]{acc: acc, key: func(v []byte) any { return string(v) }, clone: bytes.Clone}
+	case arrow.BOOL:
+		return &distinctAggrAccumulator[bool]{acc: acc}
+	case arrow.DATE32:
+		return &distinctAggrAccumulator[arrow.Date32]{acc: acc}
+	case arrow.DATE64:
+		return &distinctAggrAccumulator[arrow.Date64]{acc: acc}
+	case arrow.TIMESTAMP:
+		return &distinctAggrAccumulator[arrow.Timestamp]{acc: acc}
+	default:
+		panic(fmt.Sprintf("unsupported DISTINCT type: %v", dt))
+	}
 }
-func (a *avgAggrAccumulator) Finalize() float64 {
-	// handles divide by zero
-	if !a.used {
-		return 0.0
+
+func (d *distinctAggrAccumulator[T]) Update(values arrow.Array, row int) error {
+	v := values.(valuer[T]).Value(row)
+	if _, ok := d.seen[d.keyOf(v)]; ok {
+		return nil
+	}
+	if d.clone != nil {
+		v = d.clone(v)
+	}
+	if d.seen == nil {
+		d.seen = make(map[any]struct{})
+	}
+	d.seen[d.keyOf(v)] = struct{}{}
+	d.values = append(d.values, v)
+	return d.acc.Update(values, row)
+}
+func (d *distinctAggrAccumulator[T]) keyOf(v T) any {
+	if d.key != nil {
+		return d.key(v)
+	}
+	return v
+}
+func (d *distinctAggrAccumulator[T]) Finalize(b array.Builder) { d.acc.Finalize(b) }
+func (d *distinctAggrAccumulator[T]) State(builders []array.Builder) {
+	list := builders[0].(*array.ListBuilder)
+	list.Append(true)
+	values := list.ValueBuilder().(appender[T])
+	for _, v := range d.values {
+		values.Append(v)
+	}
+}
+func (d *distinctAggrAccumulator[T]) Merge(cols []arrow.Array, row int) error {
+	list := cols[0].(*array.List)
+	start, end := list.ValueOffsets(row)
+	for i := int(start); i < int(end); i++ {
+		if err := d.Update(list.ListValues(), i); err != nil {
+			return err
+		}
 	}
-	return a.values / a.count
+	return nil
+}
+
+// fieldBuilders are builders for the columns of fields
+func fieldBuilders(mem memory.Allocator, fields []arrow.Field) []array.Builder {
+	builders := make([]array.Builder, len(fields))
+	for i, f := range fields {
+		builders[i] = array.NewBuilder(mem, f.Type)
+	}
+	return builders
+}
+
+// finishBuilders turns builders into arrays and releases them
+func finishBuilders(builders []array.Builder) []arrow.Array {
+	cols := make([]arrow.Array, len(builders))
+	for i, b := range builders {
+		cols[i] = b.NewArray()
+		b.Release()
+	}
+	return cols
 }
 
 // ===================
@@ -152,127 +530,329 @@ type AggrExec struct {
 	input          operators.Operator   // child operator
 	schema         *arrow.Schema        // output schema
 	aggExpressions []AggregateFunctions // list of wanted aggregate expressions
+	types          []arrow.DataType     // type of the values each aggregate reads, see aggrInputTypes
 	accumulators   []accumulator        // list of accumulators corresponding to aggExpressions, these will actually work to compute the aggregation
-	done           bool                 // know when to return io.EOF
+	mode           AggrMode
+	done           bool // know when to return io.EOF
 }
 
 func NewGlobalAggrExec(child operators.Operator, aggExprs []AggregateFunctions) (*AggrExec, error) {
+	return NewGlobalAggrExecWithMode(child, aggExprs, Complete)
+}
+
+// NewGlobalAggrExecWithMode is NewGlobalAggrExec running one phase of a two phase aggregation. in
+// Merge and Final mode child returns the state columns of a Partial (or Merge) aggregate over the
+// same aggExprs
+func NewGlobalAggrExecWithMode(child operators.Operator, aggExprs []AggregateFunctions, mode AggrMode) (*AggrExec, error) {
+	var types []arrow.DataType
+	var err error
+	if mode.readsState() {
+		types, err = stateInputTypes(child.Schema(), 0, aggExprs)
+	} else {
+		types, err = aggrInputTypes(child.Schema(), aggExprs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema := arrow.NewSchema(resultFields(aggExprs, types), nil)
+	if mode.returnsState() {
+		schema = buildStateSchema(nil, aggExprs, types)
+	}
 	accs := make([]accumulator, len(aggExprs))
-	fields := make([]arrow.Field, len(aggExprs))
 	for i, agg := range aggExprs {
-		dt, err := Expr.ExprDataType(agg.Child, child.Schema())
-		if err != nil || !validAggrType(dt) {
-			return nil, ErrInvalidAggrColumnType(dt)
-		}
-		var fieldName string
-		switch agg.AggrFunc {
-		case Min:
-			fieldName = fmt.Sprintf("min_%s", agg.Child.String())
-			accs[i] = newMinAggr()
-		case Max:
-			fieldName = fmt.Sprintf("max_%s", agg.Child.String())
-			accs[i] = newMaxAggr()
-		case Count:
-			fieldName = fmt.Sprintf("count_%s", agg.Child.String())
-			accs[i] = newCountAggr()
-		case Sum:
-			fieldName = fmt.Sprintf("sum_%s", agg.Child.String())
-			accs[i] = newSumAggr()
-		case Avg:
-			fieldName = fmt.Sprintf("avg_%s", agg.Child.String())
-			accs[i] = newAvgAggr()
+		accs[i] = newAccumulator(agg, types[i])
+	}
+	return &AggrExec{
+		input:          child,
+		schema:         schema,
+		aggExpressions: aggExprs,
+		types:          types,
+		accumulators:   accs,
+		mode:           mode,
+	}, nil
+}
+
+// handles validation and building of schema for global aggregations
+func buildGlobalAggrSchema(childSchema *arrow.Schema, aggExprs []AggregateFunctions) (*arrow.Schema, error) {
+	types, err := aggrInputTypes(childSchema, aggExprs)
+	if err != nil {
+		return nil, err
+	}
+	return arrow.NewSchema(resultFields(aggExprs, types), nil), nil
+}
 
+// aggrInputTypes validates aggExprs over childSchema and returns the type of the values each reads,
+// null for CountStar which reads none
+func aggrInputTypes(childSchema *arrow.Schema, aggExprs []AggregateFunctions) ([]arrow.DataType, error) {
+	types := make([]arrow.DataType, len(aggExprs))
+	for i, agg := range aggExprs {
+		switch agg.AggrFunc {
+		case Min, Max, Count, Sum, Avg:
+		case CountStar:
+			if agg.Distinct {
+				return nil, ErrDistinctCountStar
+			}
+			types[i] = arrow.Null
+			continue
 		default:
 			return nil, ErrUnsupportedAggrFunc(int(agg.AggrFunc))
 		}
+		dt, err := Expr.ExprDataType(agg.Child, childSchema)
+		// DISTINCT keeps its values in the types MIN and MAX take
+		if err != nil || !validAggrType(agg.AggrFunc, dt) || agg.Distinct && !validAggrType(Min, dt) {
+			return nil, ErrInvalidAggrColumnType(dt)
+		}
+		types[i] = dt
+	}
+	return types, nil
+}
+
+// resultFields are the output columns of aggExprs over values of types, all but the counts are null
+// when they saw no values
+func resultFields(aggExprs []AggregateFunctions, types []arrow.DataType) []arrow.Field {
+	fields := make([]arrow.Field, len(aggExprs))
+	for i, agg := range aggExprs {
 		fields[i] = arrow.Field{
-			Name:     fieldName,
-			Type:     arrow.PrimitiveTypes.Float64,
-			Nullable: true,
+			Name:     aggrFieldName(agg),
+			Type:     resultType(agg.AggrFunc, types[i]),
+			Nullable: agg.AggrFunc != Count && agg.AggrFunc != CountStar,
 		}
 	}
-	return &AggrExec{
-		input:          child,
-		schema:         arrow.NewSchema(fields, nil),
-		aggExpressions: aggExprs,
-		accumulators:   accs,
-	}, nil
+	return fields
+}
+
+// aggrFieldName is the name of the output column of agg, ie sum_Column(salary), count_distinct_Column(id) or count_*
+func aggrFieldName(agg AggregateFunctions) string {
+	name := strings.ToLower(aggrToString(int(agg.AggrFunc)))
+	switch {
+	case agg.AggrFunc == CountStar:
+		return name + "_*"
+	case agg.Distinct:
+		name += "_distinct"
+	}
+	return fmt.Sprintf("%s_%s", name, agg.Child.String())
+}
+
+// buildStateSchema is what Partial and Merge aggregates return: the group columns followed by the
+// state columns of every aggregate, see stateFields
+func buildStateSchema(groupFields []arrow.Field, aggExprs []AggregateFunctions, types []arrow.DataType) *arrow.Schema {
+	fields := append([]arrow.Field(nil), groupFields...)
+	for i, agg := range aggExprs {
+		fields = append(fields, aggrStateFields(agg, types[i])...)
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// stateInputTypes checks the input of a Merge or Final aggregate is laid out like buildStateSchema,
+// after its first groupCount group columns, and returns the type each aggregate reads. that is the
+// type of its first state column (of its values for DISTINCT), a state merges the same as the values
+// it was built from
+func stateInputTypes(childSchema *arrow.Schema, groupCount int, aggExprs []AggregateFunctions) ([]arrow.DataType, error) {
+	types := make([]arrow.DataType, len(aggExprs))
+	col := groupCount
+	for i, agg := range aggExprs {
+		if col >= childSchema.NumFields() {
+			return nil, ErrInvalidStateInput(childSchema)
+		}
+		dt := childSchema.Field(col).Type
+		if list, ok := dt.(*arrow.ListType); ok && agg.Distinct {
+			dt = list.Elem()
+		}
+		if !validAggrType(agg.AggrFunc, dt) {
+			return nil, ErrInvalidStateInput(childSchema)
+		}
+		types[i] = dt
+		for _, f := range aggrStateFields(agg, types[i]) {
+			if col >= childSchema.NumFields() || !arrow.TypeEqual(f.Type, childSchema.Field(col).Type) {
+				return nil, ErrInvalidStateInput(childSchema)
+			}
+			col++
+		}
+	}
+	if col != childSchema.NumFields() {
+		return nil, ErrInvalidStateInput(childSchema)
+	}
+	return types, nil
+}
+
+// AggregateSchema returns the schema an aggregation over childSchema would produce, without building the operator.
+// with no group by expressions this matches NewGlobalAggrExec, otherwise NewGroupByExec
+func AggregateSchema(childSchema *arrow.Schema, groupBy []Expr.Expression, aggExprs []AggregateFunctions) (*arrow.Schema, error) {
+	if len(groupBy) == 0 {
+		return buildGlobalAggrSchema(childSchema, aggExprs)
+	}
+	return buildGroupBySchema(childSchema, groupBy, aggExprs)
 }
 
 // Next consumes all batches from the child operator, evaluates the aggregate expressions,
 // updates the accumulators for each value, and returns a single output batch containing
-// the final aggregation results. It returns io.EOF after producing the result batch.
-func (a *AggrExec) Next(n uint16) (*operators.RecordBatch, error) {
+// the final aggregation results (or their state in Partial and Merge mode). It returns io.EOF
+// after producing the result batch.
+func (a *AggrExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if a.done {
 		return nil, io.EOF
 	}
+	ctx, mem := operators.Allocator(ctx, a)
 	for {
-		childBatch, err := a.input.Next(n)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		childBatch, err := operators.NextBatch(ctx, a.input, n)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			return nil, err
 		}
-		for i, aggExpr := range a.aggExpressions {
-			agrArray, err := Expr.EvalExpression(aggExpr.Child, childBatch)
-			if err != nil {
-				return nil, err
-			}
-			agrArray, err = castArrayToFloat64(agrArray)
-			if err != nil {
-				return nil, err
-			}
-			valueArray := agrArray.(*array.Float64)
-			accumulator := a.accumulators[i]
-			for j := 0; j < valueArray.Len(); j++ {
-				if valueArray.IsNull(j) {
-					continue
-				}
-				accumulator.Update(valueArray.Value(j))
-			}
-
+		if a.mode.readsState() {
+			err = a.mergeState(childBatch)
+		} else {
+			err = a.update(ctx, childBatch)
 		}
 		operators.ReleaseArrays(childBatch.Columns)
-	}
-	// build array with just the result of the column
-	resultColumns := make([]arrow.Array, len(a.accumulators))
-	for i := range a.accumulators {
-		resultColumns[i] = operators.NewRecordBatchBuilder().GenFloatArray(a.accumulators[i].Finalize())
+		if err != nil {
+			return nil, err
+		}
 	}
 	a.done = true
+	// build array with just the result (or the state) of every aggregate
+	builders := fieldBuilders(mem, a.schema.Fields())
+	col := 0
+	for i, acc := range a.accumulators {
+		if a.mode.returnsState() {
+			width := aggrStateWidth(a.aggExpressions[i])
+			acc.State(builders[col : col+width])
+			col += width
+			continue
+		}
+		acc.Finalize(builders[i])
+	}
 	return &operators.RecordBatch{
 		Schema:   a.schema,
-		Columns:  resultColumns,
+		Columns:  finishBuilders(builders),
 		RowCount: 1,
 	}, nil
 }
 
+// update feeds the values of every aggregate expression in batch to its accumulator
+func (a *AggrExec) update(ctx context.Context, batch *operators.RecordBatch) error {
+	for i, aggExpr := range a.aggExpressions {
+		values, err := aggrInput(ctx, aggExpr, a.types[i], batch)
+		if err != nil {
+			return err
+		}
+		accumulator := a.accumulators[i]
+		for j := 0; j < int(batch.RowCount); j++ {
+			if values != nil && values.IsNull(j) {
+				continue
+			}
+			if err := accumulator.Update(values, j); err != nil {
+				operators.ReleaseArrays([]arrow.Array{values})
+				return fmt.Errorf("%s: %w", aggExpr, err)
+			}
+		}
+		operators.ReleaseArrays([]arrow.Array{values})
+	}
+	return nil
+}
+
+// aggrInput evaluates the values agg reads from batch, cast to its inputType. nil for CountStar which
+// reads no column, every row counts
+func aggrInput(ctx context.Context, agg AggregateFunctions, dt arrow.DataType, batch *operators.RecordBatch) (arrow.Array, error) {
+	if agg.AggrFunc == CountStar {
+		return nil, nil
+	}
+	arr, err := Expr.EvalExpression(ctx, agg.Child, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer arr.Release()
+	return castAggrInput(ctx, arr, inputType(agg.AggrFunc, dt))
+}
+
+// mergeState folds every row of state columns in batch into the accumulators
+func (a *AggrExec) mergeState(batch *operators.RecordBatch) error {
+	for row := 0; row < int(batch.RowCount); row++ {
+		if err := mergeRow(a.aggExpressions, a.accumulators, batch.Columns, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRow merges row of the state columns in cols into accs, one accumulator after the other
+func mergeRow(aggExprs []AggregateFunctions, accs []accumulator, cols []arrow.Array, row int) error {
+	col := 0
+	for i, agg := range aggExprs {
+		width := aggrStateWidth(agg)
+		if err := accs[i].Merge(cols[col:col+width], row); err != nil {
+			return fmt.Errorf("%s: %w", agg, err)
+		}
+		col += width
+	}
+	return nil
+}
+
 func (a *AggrExec) Schema() *arrow.Schema {
 	return a.schema
 }
 func (a *AggrExec) Close() error {
 	return a.input.Close()
 }
+func (a *AggrExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "AggrExec",
+		Params:   explainMode(map[string]string{"aggregates": operators.ExplainList(a.aggExpressions)}, a.mode),
+		Children: []operators.Operator{a.input},
+	}
+}
 
-func validAggrType(dt arrow.DataType) bool {
+// explainMode adds the mode to the params of aggregates that only run part of the aggregation
+func explainMode(params map[string]string, mode AggrMode) map[string]string {
+	if mode != Complete {
+		params["mode"] = mode.String()
+	}
+	return params
+}
+
+// validAggrType is whether fn can aggregate values of type dt. COUNT takes anything, MIN and MAX
+// anything the sort can order and SUM and AVG numbers
+func validAggrType(fn AggrFunc, dt arrow.DataType) bool {
+	if dt == nil {
+		return false
+	}
 	switch dt.ID() {
 	case arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64,
 		arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64, arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
 		return true
+	case arrow.STRING, arrow.BINARY, arrow.BOOL, arrow.DATE32, arrow.DATE64, arrow.TIMESTAMP:
+		return fn == Min || fn == Max || fn == Count
 	default:
-		return false
+		return fn == Count
 	}
 }
 
-func castArrayToFloat64(arr arrow.Array) (arrow.Array, error) {
-	outDatum, err := compute.CastArray(context.Background(), arr, compute.NewCastOptions(&arrow.Float64Type{}, true))
+func castArrayToFloat64(ctx context.Context, arr arrow.Array) (arrow.Array, error) {
+	return castArray(ctx, arr, arrow.PrimitiveTypes.Float64)
+}
+
+func castArray(ctx context.Context, arr arrow.Array, to arrow.DataType) (arrow.Array, error) {
+	outDatum, err := compute.CastArray(ctx, arr, compute.NewCastOptions(to, true))
 	if err != nil {
 		return nil, err
 	}
 
 	return outDatum, nil
 }
+
+// castAggrInput is arr as the input type of an aggregate, a new reference either way
+func castAggrInput(ctx context.Context, arr arrow.Array, to arrow.DataType) (arrow.Array, error) {
+	if to == nil || arrow.TypeEqual(arr.DataType(), to) {
+		arr.Retain()
+		return arr, nil
+	}
+	return castArray(ctx, arr, to)
+}
 func aggrToString(t int) string {
 	switch AggrFunc(t) {
 	case Min:
@@ -285,6 +865,8 @@ func aggrToString(t int) string {
 		return "SUM"
 	case Avg:
 		return "AVG"
+	case CountStar:
+		return "COUNT"
 	default:
 		return "UNKNOWN_AGGREGATE_FUNCTION"
 	}
@@ -1,12 +1,15 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
 	"opti-sql-go/operators/project"
+	"strings"
 	"testing"
 
 	"github.com/apache/arrow/go/v15/arrow/memory"
@@ -201,7 +204,7 @@ func TestNewAggrExec(t *testing.T) {
 		child := aggProject()
 
 		agg := []AggregateFunctions{
-			{AggrFunc: Min, Child: col("name")}, // "name" is string → invalid
+			{AggrFunc: Sum, Child: col("name")}, // "name" is string → invalid
 		}
 
 		_, err := NewGlobalAggrExec(child, agg)
@@ -226,7 +229,7 @@ func TestNewAggrExec(t *testing.T) {
 	})
 
 	// -----------------------------------------------------------------
-	t.Run("schema_type_float64_for_all_numeric_aggs", func(t *testing.T) {
+	t.Run("schema_types_follow_sql_for_numeric_aggs", func(t *testing.T) {
 		child := aggProject()
 
 		agg := []AggregateFunctions{
@@ -242,9 +245,11 @@ func TestNewAggrExec(t *testing.T) {
 			t.Fatalf("unexpected: %v", err)
 		}
 
-		for _, f := range exec.Schema().Fields() {
-			if f.Type.ID() != arrow.FLOAT64 {
-				t.Fatalf("expected float64 output type, got %s", f.Type)
+		// min and max keep their input type, sum of ints and count are int64, avg is float64
+		want := []arrow.Type{arrow.INT32, arrow.FLOAT64, arrow.INT64, arrow.FLOAT64, arrow.INT64}
+		for i, f := range exec.Schema().Fields() {
+			if f.Type.ID() != want[i] {
+				t.Fatalf("expected %s to be %s, got %s", f.Name, want[i], f.Type)
 			}
 		}
 		if err := exec.Close(); err != nil {
@@ -318,6 +323,47 @@ func TestNewAggrExec(t *testing.T) {
 	})
 }
 
+func TestAggregateSchema(t *testing.T) {
+	child := aggProject()
+	aggs := []AggregateFunctions{
+		{AggrFunc: Sum, Child: col("salary")},
+		{AggrFunc: Count, Child: col("id")},
+	}
+	t.Run("global_matches_exec", func(t *testing.T) {
+		schema, err := AggregateSchema(child.Schema(), nil, aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		exec, err := NewGlobalAggrExec(child, aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !schema.Equal(exec.Schema()) {
+			t.Fatalf("expected %v, got %v", exec.Schema(), schema)
+		}
+	})
+	t.Run("group_by_matches_exec", func(t *testing.T) {
+		groupBy := []Expr.Expression{col("name")}
+		schema, err := AggregateSchema(child.Schema(), groupBy, aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		exec, err := NewGroupByExec(child, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !schema.Equal(exec.Schema()) {
+			t.Fatalf("expected %v, got %v", exec.Schema(), schema)
+		}
+	})
+	t.Run("invalid_column", func(t *testing.T) {
+		_, err := AggregateSchema(child.Schema(), nil, []AggregateFunctions{{AggrFunc: Sum, Child: col("nope")}})
+		if err == nil {
+			t.Fatalf("expected error for unknown column")
+		}
+	})
+}
+
 func TestCastArrayToFloat64(t *testing.T) {
 
 	alloc := memory.NewGoAllocator
@@ -328,7 +374,7 @@ func TestCastArrayToFloat64(t *testing.T) {
 		b.AppendValues([]int32{1, 2, 3, 4}, nil)
 		arr := b.NewArray()
 
-		out, err := castArrayToFloat64(arr)
+		out, err := castArrayToFloat64(context.Background(), arr)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -352,7 +398,7 @@ func TestCastArrayToFloat64(t *testing.T) {
 		b.AppendValues([]float32{10.5, 20.5, 30.5}, nil)
 		arr := b.NewArray()
 
-		out, err := castArrayToFloat64(arr)
+		out, err := castArrayToFloat64(context.Background(), arr)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -376,7 +422,7 @@ func TestCastArrayToFloat64(t *testing.T) {
 		b.AppendValues([]string{"a", "b", "c"}, nil)
 		arr := b.NewArray()
 
-		_, err := castArrayToFloat64(arr)
+		_, err := castArrayToFloat64(context.Background(), arr)
 		if err == nil {
 			t.Fatalf("expected error when casting string array to float64")
 		}
@@ -388,7 +434,7 @@ func TestCastArrayToFloat64(t *testing.T) {
 		// no values appended
 		arr := b.NewArray()
 
-		out, err := castArrayToFloat64(arr)
+		out, err := castArrayToFloat64(context.Background(), arr)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -415,7 +461,7 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		aggrExec.done = true
-		_, err = aggrExec.Next(10)
+		_, err = aggrExec.Next(context.Background(), 10)
 		if err == nil || !errors.Is(err, io.EOF) {
 			t.Fatalf("expected io.EOF error, got nil")
 		}
@@ -428,10 +474,10 @@ func TestAggregateExecNext(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		resultBatch, _ := aggrExec.Next(100)
+		resultBatch, _ := aggrExec.Next(context.Background(), 100)
 		t.Logf("record batch: %v\n", resultBatch)
-		if resultBatch.Columns[0].(*array.Float64).Value(0) != 22 {
-			t.Fatalf("expected minimum age 22, got %v", resultBatch.Columns[0].(*array.Float64).Value(0))
+		if resultBatch.Columns[0].(*array.Int32).Value(0) != 22 {
+			t.Fatalf("expected minimum age 22, got %v", resultBatch.Columns[0].(*array.Int32).Value(0))
 		}
 
 	})
@@ -446,7 +492,7 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		resultBatch, _ := aggrExec.Next(100)
+		resultBatch, _ := aggrExec.Next(context.Background(), 100)
 
 		maxSalary := resultBatch.Columns[0].(*array.Float64).Value(0)
 		if maxSalary != 99000.0 && maxSalary != 94000.0 && maxSalary != 93000.0 {
@@ -465,10 +511,10 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		resultBatch, _ := aggrExec.Next(200)
+		resultBatch, _ := aggrExec.Next(context.Background(), 200)
 
-		sumIDs := resultBatch.Columns[0].(*array.Float64).Value(0)
-		expected := float64((25 * 26) / 2) // sum(1..25) = 325
+		sumIDs := resultBatch.Columns[0].(*array.Int64).Value(0)
+		expected := int64((25 * 26) / 2) // sum(1..25) = 325
 		if sumIDs != expected {
 			t.Fatalf("expected sum 325, got %v", sumIDs)
 		}
@@ -484,9 +530,9 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		resultBatch, _ := aggrExec.Next(300)
+		resultBatch, _ := aggrExec.Next(context.Background(), 300)
 
-		count := resultBatch.Columns[0].(*array.Float64).Value(0)
+		count := resultBatch.Columns[0].(*array.Int64).Value(0)
 		if count != 25 {
 			t.Fatalf("expected count 25, got %v", count)
 		}
@@ -503,7 +549,7 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		resultBatch, _ := aggrExec.Next(500)
+		resultBatch, _ := aggrExec.Next(context.Background(), 500)
 
 		avg := resultBatch.Columns[0].(*array.Float64).Value(0)
 		expected := 75740.02
@@ -527,11 +573,11 @@ func TestAggregateExecNext(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		resultBatch, _ := aggrExec.Next(1000)
+		resultBatch, _ := aggrExec.Next(context.Background(), 1000)
 
-		minAge := resultBatch.Columns[0].(*array.Float64).Value(0)
+		minAge := resultBatch.Columns[0].(*array.Int32).Value(0)
 		maxSalary := resultBatch.Columns[1].(*array.Float64).Value(0)
-		countIDs := resultBatch.Columns[2].(*array.Float64).Value(0)
+		countIDs := resultBatch.Columns[2].(*array.Int64).Value(0)
 
 		if minAge != 22 {
 			t.Fatalf("expected min age 22, got %v", minAge)
@@ -566,13 +612,14 @@ func TestAggregateExecNext(t *testing.T) {
 			"sum_Column(age)",
 			"count_Column(salary)",
 		}
+		expectedTypes := []arrow.Type{arrow.INT32, arrow.INT64, arrow.INT64}
 
 		for i, f := range s.Fields() {
 			if f.Name != expectedNames[i] {
 				t.Fatalf("expected field %s, got %s", expectedNames[i], f.Name)
 			}
-			if f.Type.ID() != arrow.FLOAT64 {
-				t.Fatalf("expected float64 fields only")
+			if f.Type.ID() != expectedTypes[i] {
+				t.Fatalf("expected %s to be %s, got %s", f.Name, expectedTypes[i], f.Type)
 			}
 		}
 	})
@@ -591,16 +638,373 @@ func TestAggregateExecNull(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		resultBatch, _ := aggrExec.Next(100)
+		resultBatch, _ := aggrExec.Next(context.Background(), 100)
 		t.Logf("rb:%v\n", resultBatch)
-		count := resultBatch.Columns[0].(*array.Float64).Value(0)
+		count := resultBatch.Columns[0].(*array.Int64).Value(0)
 		if count != 8 {
 			t.Fatalf("expected count 7, got %v", count)
 		}
-		sumIDs := resultBatch.Columns[1].(*array.Float64).Value(0)
-		expectedSum := float64(1 + 2 + 4 + 5 + 7 + 8 + 9) // only non-null ids
+		sumIDs := resultBatch.Columns[1].(*array.Int64).Value(0)
+		expectedSum := int64(1 + 2 + 4 + 5 + 7 + 8 + 9) // only non-null ids
 		if sumIDs != expectedSum {
 			t.Fatalf("expected sum %v, got %v", expectedSum, sumIDs)
 		}
 	})
 }
+
+// stateOf is the state columns of acc over int64 values, one row
+func stateOf(fn AggrFunc, acc accumulator) []arrow.Array {
+	builders := fieldBuilders(memory.NewGoAllocator(), stateFields(fn, "x", arrow.PrimitiveTypes.Int64))
+	acc.State(builders)
+	return finishBuilders(builders)
+}
+
+// finalOf is the result of acc over int64 values, formatted
+func finalOf(fn AggrFunc, acc accumulator) string {
+	b := array.NewBuilder(memory.NewGoAllocator(), resultType(fn, arrow.PrimitiveTypes.Int64))
+	defer b.Release()
+	acc.Finalize(b)
+	arr := b.NewArray()
+	defer arr.Release()
+	return arr.ValueStr(0)
+}
+
+// updateWith adds values to acc the way the operators do, cast to the input type of fn
+func updateWith(t *testing.T, fn AggrFunc, acc accumulator, values []int64) {
+	t.Helper()
+	b := array.NewInt64Builder(memory.NewGoAllocator())
+	b.AppendValues(values, nil)
+	arr := b.NewArray()
+	defer arr.Release()
+	cast, err := castAggrInput(context.Background(), arr, inputType(fn, arrow.PrimitiveTypes.Int64))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cast.Release()
+	for row := 0; row < cast.Len(); row++ {
+		if err := acc.Update(cast, row); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestAccumulatorState(t *testing.T) {
+	parts := [][]int64{{4, 9}, {}, {-3, 7, 7}}
+	for _, fn := range []AggrFunc{Min, Max, Count, Sum, Avg} {
+		t.Run(aggrToString(int(fn)), func(t *testing.T) {
+			whole := createAccumulator(fn, arrow.PrimitiveTypes.Int64)
+			merged := createAccumulator(fn, arrow.PrimitiveTypes.Int64)
+			for _, part := range parts {
+				acc := createAccumulator(fn, arrow.PrimitiveTypes.Int64)
+				updateWith(t, fn, acc, part)
+				updateWith(t, fn, whole, part)
+				state := stateOf(fn, acc)
+				if len(state) != stateWidth(fn) || state[0].Len() != 1 {
+					t.Fatalf("expected one row of %d state columns, got %v", stateWidth(fn), state)
+				}
+				if err := merged.Merge(state, 0); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if finalOf(fn, merged) != finalOf(fn, whole) {
+				t.Fatalf("expected %v after merging, got %v", finalOf(fn, whole), finalOf(fn, merged))
+			}
+			// merging only empty states is the same as never seeing a value
+			empty := createAccumulator(fn, arrow.PrimitiveTypes.Int64)
+			if err := empty.Merge(stateOf(fn, createAccumulator(fn, arrow.PrimitiveTypes.Int64)), 0); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finalOf(fn, empty) != finalOf(fn, createAccumulator(fn, arrow.PrimitiveTypes.Int64)) {
+				t.Fatalf("expected an empty state to change nothing, got %v", finalOf(fn, empty))
+			}
+		})
+	}
+}
+
+func TestTypedAggregates(t *testing.T) {
+	const big = int64(1) << 53
+	source := func(names []string, columns []any) *project.InMemorySource {
+		p, err := project.NewInMemoryProjectExec(names, columns)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return p
+	}
+	run := func(child *project.InMemorySource, aggs []AggregateFunctions) (*operators.RecordBatch, error) {
+		exec, err := NewGlobalAggrExec(child, aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return exec.Next(context.Background(), 100)
+	}
+
+	t.Run("integer sums are exact past 2^53", func(t *testing.T) {
+		child := source([]string{"id"}, []any{[]int64{big, 1, 1}})
+		batch, err := run(child, []AggregateFunctions{{AggrFunc: Sum, Child: col("id")}, {AggrFunc: Count, Child: col("id")}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := batch.Columns[0].(*array.Int64).Value(0); got != big+2 {
+			t.Fatalf("expected %d, got %d", big+2, got)
+		}
+		if got := batch.Columns[1].(*array.Int64).Value(0); got != 3 {
+			t.Fatalf("expected a count of 3, got %d", got)
+		}
+	})
+	t.Run("overflowing sums fail", func(t *testing.T) {
+		child := source([]string{"id"}, []any{[]int64{math.MaxInt64, 1}})
+		if _, err := run(child, []AggregateFunctions{{AggrFunc: Sum, Child: col("id")}}); !errors.Is(err, ErrSumOverflow) {
+			t.Fatalf("expected an overflow, got %v", err)
+		}
+		child = source([]string{"id"}, []any{[]int64{math.MinInt64, -1}})
+		if _, err := run(child, []AggregateFunctions{{AggrFunc: Sum, Child: col("id")}}); !errors.Is(err, ErrSumOverflow) {
+			t.Fatalf("expected an underflow, got %v", err)
+		}
+		child = source([]string{"n"}, []any{[]uint64{math.MaxUint64, 1}})
+		if _, err := run(child, []AggregateFunctions{{AggrFunc: Sum, Child: col("n")}}); !errors.Is(err, ErrSumOverflow) {
+			t.Fatalf("expected an unsigned overflow, got %v", err)
+		}
+	})
+	t.Run("result types", func(t *testing.T) {
+		child := source([]string{"small", "n", "f"}, []any{[]int8{-3, 100, 100}, []uint16{1, 2, 3}, []float32{1.5, -2, 4}})
+		aggs := []AggregateFunctions{
+			{AggrFunc: Sum, Child: col("small")},
+			{AggrFunc: Min, Child: col("small")},
+			{AggrFunc: Sum, Child: col("n")},
+			{AggrFunc: Max, Child: col("n")},
+			{AggrFunc: Sum, Child: col("f")},
+			{AggrFunc: Min, Child: col("f")},
+			{AggrFunc: Avg, Child: col("small")},
+		}
+		batch, err := run(child, aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"int64 197", "int8 -3", "uint64 6", "uint16 3", "float64 3.5", "float32 -2", "float64 65.66666666666667"}
+		for i, c := range batch.Columns {
+			if got := fmt.Sprintf("%s %s", c.DataType(), c.ValueStr(0)); got != want[i] {
+				t.Fatalf("%s: expected %s, got %s", aggs[i], want[i], got)
+			}
+		}
+	})
+}
+
+// nonNumericSource has a key column and a column of every non numeric type MIN/MAX understand, with a null in each
+func nonNumericSource(t *testing.T) *project.InMemorySource {
+	mem := memory.NewGoAllocator()
+	key := array.NewStringBuilder(mem)
+	name := array.NewStringBuilder(mem)
+	flag := array.NewBooleanBuilder(mem)
+	raw := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
+	day := array.NewDate32Builder(mem)
+	at := array.NewTimestampBuilder(mem, &arrow.TimestampType{Unit: arrow.Millisecond})
+	took := array.NewDurationBuilder(mem, arrow.FixedWidthTypes.Duration_s.(*arrow.DurationType))
+	for i, n := range []string{"bob", "Zed", "", "amy", "bob", "carl"} {
+		key.Append([]string{"x", "y"}[i%2])
+		name.Append(n)
+		flag.Append(i%3 == 1)
+		raw.Append([]byte(n))
+		day.Append(arrow.Date32(100 - i*10))
+		at.Append(arrow.Timestamp(1000 * (i%4 + 1)))
+		took.Append(arrow.Duration(i))
+	}
+	builders := []array.Builder{key, name, flag, raw, day, at, took}
+	arrays := make([]arrow.Array, len(builders))
+	for i, b := range builders {
+		if i > 0 {
+			b.AppendNull()
+		} else {
+			key.Append("x")
+		}
+		arrays[i] = b.NewArray()
+		b.Release()
+	}
+	p, err := project.NewInMemoryProjectExecFromArrays([]string{"key", "name", "flag", "raw", "day", "at", "took"}, arrays)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+func TestNonNumericAggregates(t *testing.T) {
+	var aggs []AggregateFunctions
+	for _, c := range []string{"name", "flag", "raw", "day", "at"} {
+		aggs = append(aggs, AggregateFunctions{AggrFunc: Min, Child: col(c)}, AggregateFunctions{AggrFunc: Max, Child: col(c)})
+	}
+	aggs = append(aggs, AggregateFunctions{AggrFunc: Count, Child: col("name")}, AggregateFunctions{AggrFunc: Count, Child: col("took")})
+
+	t.Run("min and max follow the sort order", func(t *testing.T) {
+		exec, err := NewGlobalAggrExec(nonNumericSource(t), aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, err := exec.Next(context.Background(), 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// strings and bytes compare bytewise, false sorts before true and nulls are skipped
+		want := []string{
+			"utf8 ", "utf8 carl",
+			"bool false", "bool true",
+			"binary ", "binary Y2FybA==",
+			"date32 1970-02-20", "date32 1970-04-11",
+			"timestamp[ms] 1970-01-01 00:00:01Z", "timestamp[ms] 1970-01-01 00:00:04Z",
+			"int64 6", "int64 6",
+		}
+		for i, c := range batch.Columns {
+			got := fmt.Sprintf("%s %s", c.DataType(), c.ValueStr(0))
+			if got != want[i] {
+				t.Fatalf("%s: expected %s, got %s", aggs[i], want[i], got)
+			}
+			if i < 10 && c.DataType().ID() != exec.Schema().Field(i).Type.ID() {
+				t.Fatalf("%s: expected the schema type %s, got %s", aggs[i], exec.Schema().Field(i).Type, c.DataType())
+			}
+		}
+		operators.ReleaseArrays(batch.Columns)
+	})
+	t.Run("grouped with spilling matches in memory", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		groupBy := []Expr.Expression{col("key")}
+		gb, err := NewGroupByExec(nonNumericSource(t), aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want, _ := groupRows(t, context.Background(), gb)
+		q := operators.NewQueryMemory(nil, 0, 1)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		gb, err = NewGroupByExec(&smallBatches{input: nonNumericSource(t), size: 2}, aggs, groupBy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := groupRows(t, ctx, gb)
+		if gb.partitions == nil {
+			t.Fatalf("expected the groups to be spilled")
+		}
+		if len(want) != 2 || !strings.HasPrefix(want[0], "x||bob|false|true|") || !strings.HasPrefix(want[1], "y|Zed|carl|") {
+			t.Fatalf("unexpected groups\n%s", strings.Join(want, "\n"))
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+		if err := gb.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+	})
+	t.Run("only count takes any type", func(t *testing.T) {
+		for _, agg := range []AggregateFunctions{
+			{AggrFunc: Sum, Child: col("name")},
+			{AggrFunc: Avg, Child: col("flag")},
+			{AggrFunc: Sum, Child: col("day")},
+			{AggrFunc: Min, Child: col("took")},
+		} {
+			if _, err := NewGlobalAggrExec(nonNumericSource(t), []AggregateFunctions{agg}); err == nil {
+				t.Fatalf("%s: expected a type error", agg)
+			}
+		}
+	})
+}
+
+// nullableSource has a key column k and an int64 column v with nulls, every v of group b is null
+func nullableSource(t *testing.T, rows int) *project.InMemorySource {
+	mem := memory.NewGoAllocator()
+	keys := array.NewStringBuilder(mem)
+	values := array.NewInt64Builder(mem)
+	for i, v := range []int64{3, 3, 0, 5, 0, 0}[:rows] {
+		keys.Append([]string{"a", "a", "b", "a", "b", "a"}[i])
+		if v == 0 {
+			values.AppendNull()
+			continue
+		}
+		values.Append(v)
+	}
+	arrays := []arrow.Array{keys.NewArray(), values.NewArray()}
+	keys.Release()
+	values.Release()
+	p, err := project.NewInMemoryProjectExecFromArrays([]string{"k", "v"}, arrays)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+func TestCountStarDistinctAndNulls(t *testing.T) {
+	aggs := []AggregateFunctions{
+		{AggrFunc: CountStar},
+		{AggrFunc: Count, Child: col("v")},
+		{AggrFunc: Count, Child: col("v"), Distinct: true},
+		{AggrFunc: Sum, Child: col("v")},
+		{AggrFunc: Sum, Child: col("v"), Distinct: true},
+		{AggrFunc: Avg, Child: col("v"), Distinct: true},
+		{AggrFunc: Min, Child: col("v")},
+	}
+	global := func(t *testing.T, rows int) []string {
+		exec, err := NewGlobalAggrExec(nullableSource(t, rows), aggs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, err := exec.Next(context.Background(), 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer operators.ReleaseArrays(batch.Columns)
+		got := make([]string, len(batch.Columns))
+		for i, c := range batch.Columns {
+			got[i] = c.ValueStr(0)
+		}
+		return got
+	}
+
+	t.Run("count star counts rows, distinct counts values once", func(t *testing.T) {
+		want := "6 3 2 11 8 4 3"
+		if got := strings.Join(global(t, 6), " "); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	})
+	t.Run("no rows count as 0 and aggregate to null", func(t *testing.T) {
+		want := "0 0 0 (null) (null) (null) (null)"
+		if got := strings.Join(global(t, 0), " "); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	})
+	t.Run("groups of only nulls aggregate to null", func(t *testing.T) {
+		gb, err := NewGroupByExec(nullableSource(t, 6), aggs, []Expr.Expression{col("k")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := groupRows(t, context.Background(), gb)
+		want := "a|4|3|2|11|8|4|3|\nb|2|0|0|(null)|(null)|(null)|(null)|"
+		if strings.Join(got, "\n") != want {
+			t.Fatalf("expected\n%s\ngot\n%s", want, strings.Join(got, "\n"))
+		}
+		for _, f := range gb.Schema().Fields()[1:] {
+			if f.Nullable == strings.HasPrefix(f.Name, "count_") {
+				t.Fatalf("expected only the counts to be non nullable, got %v", f)
+			}
+		}
+	})
+	t.Run("names", func(t *testing.T) {
+		if got := fmt.Sprint(aggs[0], aggs[2]); got != "COUNT(*) COUNT(DISTINCT Column(v))" {
+			t.Fatalf("unexpected aggregates %s", got)
+		}
+		schema, err := AggregateSchema(nullableSource(t, 6).Schema(), nil, aggs[:3])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schema.Field(0).Name != "count_*" || schema.Field(2).Name != "count_distinct_Column(v)" {
+			t.Fatalf("unexpected schema %v", schema)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewGlobalAggrExec(nullableSource(t, 6), []AggregateFunctions{{AggrFunc: CountStar, Distinct: true}}); !errors.Is(err, ErrDistinctCountStar) {
+			t.Fatalf("expected ErrDistinctCountStar, got %v", err)
+		}
+		// count takes any type, but DISTINCT only keeps the ones MIN and MAX take
+		distinct := []AggregateFunctions{{AggrFunc: Count, Child: col("took"), Distinct: true}}
+		if _, err := NewGlobalAggrExec(nonNumericSource(t), distinct); err == nil {
+			t.Fatalf("expected a type error")
+		}
+	})
+}
@@ -1,14 +1,18 @@
 package aggr
 
 import (
+	"bytes"
+	"container/heap"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"opti-sql-go/Expr"
+	"opti-sql-go/config"
 	"opti-sql-go/operators"
 	"sort"
+	"strconv"
 
 	"github.com/apache/arrow/go/v17/arrow"
 	"github.com/apache/arrow/go/v17/arrow/array"
@@ -20,6 +24,10 @@ import (
 var (
 	_ = (operators.Operator)(&SortExec{})
 	_ = (operators.Operator)(&TopKSortExec{})
+	_ = (operators.MemoryReporter)(&SortExec{})
+	_ = (operators.MemoryReporter)(&TopKSortExec{})
+	_ = (operators.Explainer)(&SortExec{})
+	_ = (operators.Explainer)(&TopKSortExec{})
 )
 
 type SortKey struct {
@@ -43,6 +51,17 @@ func NewSortKey(expr Expr.Expression, options ...bool) *SortKey {
 		NullFirst: nullF,
 	}
 }
+func (s SortKey) String() string {
+	dir := "DESC"
+	if s.Ascending {
+		dir = "ASC"
+	}
+	nulls := "LAST"
+	if s.NullFirst {
+		nulls = "FIRST"
+	}
+	return fmt.Sprintf("%s %s NULLS %s", s.Expr, dir, nulls)
+}
 func CombineSortKeys(sk ...*SortKey) []SortKey {
 	var res []SortKey
 	for _, s := range sk {
@@ -61,72 +80,50 @@ type SortExec struct {
 	totalRows      uint64
 	consumed       bool // did we finish reading all of the child record batches?
 	done           bool // have we already produced all the sorted record batches?
+	peakMemory     int64
+	// external sort, when the query is told to spill the rows read so far are sorted into a run and
+	// written to disk. once the input is exhausted the runs are merged back together
+	runs         []*operators.SpillFile
+	runBatchRows int   // rows per batch written to a run
+	minRunSize   int64 // bytes buffered before a run is spilled, however early the query asks to
+	maxFanIn     int   // most runs merged at once, more are merged into longer runs first
+	merger       *runMerger
 }
 
+const (
+	defaultSortMinRunSize = 4 << 20
+	defaultSortMaxFanIn   = 64
+)
+
 func NewSortExec(child operators.Operator, sortKeys []SortKey) (*SortExec, error) {
 	return &SortExec{
-		input:    child,
-		schema:   child.Schema(),
-		sortKeys: sortKeys,
+		input:        child,
+		schema:       child.Schema(),
+		sortKeys:     sortKeys,
+		runBatchRows: config.GetConfig().Batch.Size,
+		minRunSize:   defaultSortMinRunSize,
+		maxFanIn:     defaultSortMaxFanIn,
 	}, nil
 }
 
-// for now read everything into memory and sort -- next steps will be to do external merge
-
 // n is the number of records we will return,sortExec will read in 2^16-1 column entries from its child, this is more efficient that trusting the caller to pass in a reasonable
 // n so that we avoid small/frequent IO operations
-func (s *SortExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (s *SortExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if s.done {
 		return nil, io.EOF
 	}
+	ctx, mem := operators.Allocator(ctx, s)
 	if !s.consumed {
-		allColumns := make([]arrow.Array, len(s.schema.Fields())) // concated columns
-		mem := memory.NewGoAllocator()
-		var count uint64
-		for {
-			childBatch, err := s.input.Next(math.MaxUint16)
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
-				}
-				return nil, err
-			}
-			for i := range childBatch.Columns {
-				if allColumns[i] == nil {
-					allColumns[i] = childBatch.Columns[i]
-					continue
-				}
-				largerArray, err := array.Concatenate([]arrow.Array{allColumns[i], childBatch.Columns[i]}, mem)
-				if err != nil {
-					return nil, err
-				}
-				allColumns[i] = largerArray
-			}
-		}
-		s.consumed = true
-		if len(allColumns) > 0 {
-			count = uint64(allColumns[0].Len())
-		}
-		idx, err := sortBatches(&operators.RecordBatch{
-			Schema:   s.schema,
-			Columns:  allColumns,
-			RowCount: count,
-		}, s.sortKeys)
-		if err != nil {
+		if err := s.consumeInput(ctx, mem); err != nil {
 			return nil, err
 		}
-		// now update all mappings
-		idxArray := idxToArrowArray(idx, mem)
-		defer idxArray.Release()
-		for i := range len(allColumns) {
-			arr, err := compute.TakeArray(context.Background(), allColumns[i], idxArray)
-			if err != nil {
-				return nil, err
-			}
-			allColumns[i] = arr
+	}
+	if s.merger != nil {
+		batch, err := s.merger.next(ctx, n, mem)
+		if errors.Is(err, io.EOF) {
+			s.done = true
 		}
-		s.totalColumns = allColumns
-		s.totalRows = count
+		return batch, err
 	}
 	var readSize uint64
 	remaining := s.totalRows - s.consumedOffset
@@ -141,8 +138,7 @@ func (s *SortExec) Next(n uint16) (*operators.RecordBatch, error) {
 		// remaining > n or remaining = n then just read n and return
 		readSize = uint64(n)
 	}
-	mem := memory.NewGoAllocator()
-	sortedColumns, err := s.consumeSortedBatch(readSize, mem)
+	sortedColumns, err := s.consumeSortedBatch(ctx, readSize, mem)
 	if err != nil {
 		return nil, err
 	}
@@ -153,14 +149,213 @@ func (s *SortExec) Next(n uint16) (*operators.RecordBatch, error) {
 		RowCount: readSize,
 	}, nil
 }
+
+// consumeInput reads the whole input. everything is sorted in memory unless the query asks to spill
+// on the way, then the rows buffered so far are sorted and written to disk as a run once they make
+// up at least minRunSize bytes. the runs are merged afterwards
+func (s *SortExec) consumeInput(ctx context.Context, mem memory.Allocator) error {
+	var buffered [][]arrow.Array // the columns of every batch read since the last run was spilled
+	var bufferedSize int64
+	for {
+		if err := ctx.Err(); err != nil {
+			releaseBuffered(buffered)
+			return err
+		}
+		childBatch, err := operators.NextBatch(ctx, s.input, math.MaxUint16)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			releaseBuffered(buffered)
+			return err
+		}
+		if childBatch.RowCount == 0 {
+			operators.ReleaseArrays(childBatch.Columns)
+			continue
+		}
+		buffered = append(buffered, childBatch.Columns)
+		bufferedSize += operators.ArraysSize(childBatch.Columns)
+		// the input and its sorted copy are both alive until the take in sortRun is done
+		s.peakMemory = max(s.peakMemory, 2*bufferedSize)
+		if bufferedSize >= s.minRunSize && operators.ShouldSpill(ctx) {
+			if err := s.spillRun(ctx, buffered, mem); err != nil {
+				return err
+			}
+			buffered, bufferedSize = nil, 0
+		}
+	}
+	s.consumed = true
+	if len(s.runs) > 0 {
+		if len(buffered) > 0 {
+			if err := s.spillRun(ctx, buffered, mem); err != nil {
+				return err
+			}
+		}
+		if err := s.reduceRuns(ctx, mem); err != nil {
+			return err
+		}
+		merger, err := newRunMerger(ctx, s.schema, s.sortKeys, s.runs)
+		if err != nil {
+			return err
+		}
+		s.merger = merger
+		return nil
+	}
+	if len(buffered) == 0 {
+		return nil
+	}
+	sorted, err := s.sortRun(ctx, buffered, mem)
+	if err != nil {
+		return err
+	}
+	s.totalColumns = sorted
+	s.totalRows = uint64(sorted[0].Len())
+	return nil
+}
+
+// sortRun concatenates the buffered batches and sorts them, the buffered columns are released
+func (s *SortExec) sortRun(ctx context.Context, buffered [][]arrow.Array, mem memory.Allocator) ([]arrow.Array, error) {
+	allColumns, err := concatColumns(buffered, mem)
+	if err != nil {
+		return nil, err
+	}
+	defer operators.ReleaseArrays(allColumns)
+	count := uint64(allColumns[0].Len())
+	idx, err := sortBatches(ctx, &operators.RecordBatch{
+		Schema:   s.schema,
+		Columns:  allColumns,
+		RowCount: count,
+	}, s.sortKeys)
+	if err != nil {
+		return nil, err
+	}
+	idxArray := idxToArrowArray(idx, mem)
+	defer idxArray.Release()
+	sorted := make([]arrow.Array, len(allColumns))
+	for i := range allColumns {
+		arr, err := compute.TakeArray(ctx, allColumns[i], idxArray)
+		if err != nil {
+			operators.ReleaseArrays(sorted)
+			return nil, err
+		}
+		sorted[i] = arr
+	}
+	return sorted, nil
+}
+
+// spillRun sorts the buffered batches and writes them to a new run on disk
+func (s *SortExec) spillRun(ctx context.Context, buffered [][]arrow.Array, mem memory.Allocator) error {
+	sorted, err := s.sortRun(ctx, buffered, mem)
+	if err != nil {
+		return err
+	}
+	defer operators.ReleaseArrays(sorted)
+	run, err := operators.NewSpillFile(s.schema, mem)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	rows := sorted[0].Len()
+	step := max(s.runBatchRows, 1)
+	for offset := 0; offset < rows; offset += step {
+		end := min(offset+step, rows)
+		cols := make([]arrow.Array, len(sorted))
+		for i, col := range sorted {
+			cols[i] = array.NewSlice(col, int64(offset), int64(end))
+		}
+		err := run.Write(&operators.RecordBatch{Schema: s.schema, Columns: cols, RowCount: uint64(end - offset)})
+		operators.ReleaseArrays(cols)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reduceRuns merges the runs maxFanIn at a time until a single merge can take all of them. the
+// runs are merged in order, so rows that compare equal keep the order they were read in
+func (s *SortExec) reduceRuns(ctx context.Context, mem memory.Allocator) error {
+	fanIn := max(s.maxFanIn, 2)
+	for len(s.runs) > fanIn {
+		var merged []*operators.SpillFile
+		for start := 0; start < len(s.runs); start += fanIn {
+			group := s.runs[start:min(start+fanIn, len(s.runs))]
+			if len(group) == 1 {
+				merged = append(merged, group[0])
+				continue
+			}
+			run, err := s.mergeRuns(ctx, group, mem)
+			if err != nil {
+				// whatever is left is closed with the sort
+				s.runs = append(merged, s.runs[start:]...)
+				return err
+			}
+			merged = append(merged, run)
+		}
+		s.runs = merged
+	}
+	return nil
+}
+
+// mergeRuns merges runs into a single new run, the merged runs are closed
+func (s *SortExec) mergeRuns(ctx context.Context, runs []*operators.SpillFile, mem memory.Allocator) (*operators.SpillFile, error) {
+	merger, err := newRunMerger(ctx, s.schema, s.sortKeys, runs)
+	if err != nil {
+		return nil, err
+	}
+	defer merger.release()
+	run, err := operators.NewSpillFile(s.schema, mem)
+	if err != nil {
+		return nil, err
+	}
+	step := uint16(min(max(s.runBatchRows, 1), math.MaxUint16))
+	for {
+		batch, err := merger.next(ctx, step, mem)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = run.Write(batch)
+			operators.ReleaseArrays(batch.Columns)
+		}
+		if err == nil {
+			err = operators.MemoryError(ctx)
+		}
+		if err != nil {
+			_ = run.Close()
+			return nil, err
+		}
+	}
+	for _, r := range runs {
+		_ = r.Close()
+	}
+	return run, nil
+}
+
 func (s *SortExec) Schema() *arrow.Schema {
 	return s.schema
 }
 func (s *SortExec) Close() error {
+	operators.ReleaseArrays(s.totalColumns)
+	s.totalColumns = nil
+	if s.merger != nil {
+		s.merger.release()
+	}
+	for _, run := range s.runs {
+		_ = run.Close()
+	}
+	s.runs = nil
 	return s.input.Close()
 }
-func (s *SortExec) consumeSortedBatch(readsize uint64, mem memory.Allocator) ([]arrow.Array, error) {
-	ctx := context.Background()
+func (s *SortExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "SortExec",
+		Params:   map[string]string{"keys": operators.ExplainList(s.sortKeys)},
+		Children: []operators.Operator{s.input},
+	}
+}
+func (s *SortExec) PeakMemory() int64 { return s.peakMemory }
+func (s *SortExec) consumeSortedBatch(ctx context.Context, readsize uint64, mem memory.Allocator) ([]arrow.Array, error) {
 	resultColumns := make([]arrow.Array, len(s.schema.Fields()))
 	offsetArray := genoffsetTakeIdx(s.consumedOffset, readsize, mem)
 	defer offsetArray.Release()
@@ -177,6 +372,230 @@ func (s *SortExec) consumeSortedBatch(readsize uint64, mem memory.Allocator) ([]
 	return resultColumns, nil
 }
 
+// concatColumns joins the columns of several batches into one array per column, the inputs are released
+func concatColumns(batches [][]arrow.Array, mem memory.Allocator) ([]arrow.Array, error) {
+	if len(batches) == 1 {
+		return batches[0], nil
+	}
+	defer releaseBuffered(batches)
+	out := make([]arrow.Array, len(batches[0]))
+	parts := make([]arrow.Array, len(batches))
+	for i := range out {
+		for b := range batches {
+			parts[b] = batches[b][i]
+		}
+		arr, err := array.Concatenate(parts, mem)
+		if err != nil {
+			operators.ReleaseArrays(out)
+			return nil, err
+		}
+		out[i] = arr
+	}
+	return out, nil
+}
+
+func releaseBuffered(batches [][]arrow.Array) {
+	for _, cols := range batches {
+		operators.ReleaseArrays(cols)
+	}
+}
+
+/*
+k-way merge of the sorted runs. every run has one batch in memory at a time, a heap of the runs
+ordered by their current row says which run the next row comes from
+*/
+type runMerger struct {
+	schema   *arrow.Schema
+	sortKeys []SortKey
+	heap     runHeap
+}
+
+// runChunk is the batch of a run currently in memory
+type runChunk struct {
+	cols []arrow.Array
+	keys []arrow.Array // the sort keys evaluated over cols
+	rows int
+	base int  // where the chunk starts in the batch being merged, -1 if nothing was taken from it yet
+	used bool // the run moved past this chunk, it is released once the batch being merged is built
+}
+
+func (c *runChunk) release() {
+	operators.ReleaseArrays(c.cols)
+	operators.ReleaseArrays(c.keys)
+}
+
+type runCursor struct {
+	run   int // position of the run, rows that compare equal come from the earlier run first
+	file  *operators.SpillFile
+	chunk *runChunk
+	row   int
+}
+
+// load reads the next batch of the run, io.EOF once the run is exhausted
+func (c *runCursor) load(ctx context.Context, sortKeys []SortKey) error {
+	for {
+		batch, err := c.file.Read()
+		if err != nil {
+			return err
+		}
+		if batch.RowCount == 0 {
+			operators.ReleaseArrays(batch.Columns)
+			continue
+		}
+		keys := make([]arrow.Array, len(sortKeys))
+		for i, sk := range sortKeys {
+			arr, err := Expr.EvalExpression(ctx, sk.Expr, batch)
+			if err != nil {
+				operators.ReleaseArrays(keys)
+				operators.ReleaseArrays(batch.Columns)
+				return fmt.Errorf("sort merge: failed to eval sort expression: %v", err)
+			}
+			keys[i] = arr
+		}
+		c.chunk = &runChunk{cols: batch.Columns, keys: keys, rows: int(batch.RowCount), base: -1}
+		c.row = 0
+		return nil
+	}
+}
+
+type runHeap struct {
+	cursors  []*runCursor
+	sortKeys []SortKey
+}
+
+func (h *runHeap) Len() int { return len(h.cursors) }
+func (h *runHeap) Less(a, b int) bool {
+	ca, cb := h.cursors[a], h.cursors[b]
+	for k, sk := range h.sortKeys {
+		cmp := compareArrowArrays(ca.chunk.keys[k], uint64(ca.row), cb.chunk.keys[k], uint64(cb.row))
+		if cmp == 0 {
+			continue
+		}
+		if sk.Ascending {
+			return cmp < 0
+		}
+		return cmp > 0
+	}
+	return ca.run < cb.run
+}
+func (h *runHeap) Swap(a, b int) { h.cursors[a], h.cursors[b] = h.cursors[b], h.cursors[a] }
+func (h *runHeap) Push(x any)    { h.cursors = append(h.cursors, x.(*runCursor)) }
+func (h *runHeap) Pop() any {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
+
+func newRunMerger(ctx context.Context, schema *arrow.Schema, sortKeys []SortKey, runs []*operators.SpillFile) (*runMerger, error) {
+	m := &runMerger{schema: schema, sortKeys: sortKeys, heap: runHeap{sortKeys: sortKeys}}
+	for i, run := range runs {
+		if err := run.Rewind(); err != nil {
+			m.release()
+			return nil, err
+		}
+		c := &runCursor{run: i, file: run}
+		if err := c.load(ctx, sortKeys); err != nil {
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+			m.release()
+			return nil, err
+		}
+		m.heap.cursors = append(m.heap.cursors, c)
+	}
+	heap.Init(&m.heap)
+	return m, nil
+}
+
+// next merges up to n rows. the rows are picked from the runs' current chunks and gathered with a
+// single take over the chunks they came from
+func (m *runMerger) next(ctx context.Context, n uint16, mem memory.Allocator) (*operators.RecordBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var parts []*runChunk
+	idx := make([]uint64, 0, n)
+	total := 0
+	for len(idx) < int(n) && m.heap.Len() > 0 {
+		c := m.heap.cursors[0]
+		chunk := c.chunk
+		if chunk.base < 0 {
+			chunk.base = total
+			total += chunk.rows
+			parts = append(parts, chunk)
+		}
+		idx = append(idx, uint64(chunk.base+c.row))
+		c.row++
+		if c.row < chunk.rows {
+			heap.Fix(&m.heap, 0)
+			continue
+		}
+		chunk.used = true
+		if err := c.load(ctx, m.sortKeys); err != nil {
+			if !errors.Is(err, io.EOF) {
+				releaseChunks(parts)
+				return nil, err
+			}
+			heap.Pop(&m.heap)
+			continue
+		}
+		heap.Fix(&m.heap, 0)
+	}
+	defer releaseChunks(parts)
+	if len(idx) == 0 {
+		return nil, io.EOF
+	}
+	idxArray := idxToArrowArray(idx, mem)
+	defer idxArray.Release()
+	columns := make([]arrow.Array, len(m.schema.Fields()))
+	for i := range columns {
+		cols := make([]arrow.Array, len(parts))
+		for p, chunk := range parts {
+			cols[p] = chunk.cols[i]
+		}
+		src := cols[0]
+		if len(cols) > 1 {
+			combined, err := array.Concatenate(cols, mem)
+			if err != nil {
+				operators.ReleaseArrays(columns)
+				return nil, err
+			}
+			src = combined
+		}
+		arr, err := compute.TakeArray(ctx, src, idxArray)
+		if len(cols) > 1 {
+			src.Release()
+		}
+		if err != nil {
+			operators.ReleaseArrays(columns)
+			return nil, err
+		}
+		columns[i] = arr
+	}
+	return &operators.RecordBatch{
+		Schema:   m.schema,
+		Columns:  columns,
+		RowCount: uint64(len(idx)),
+	}, nil
+}
+
+// releaseChunks frees the chunks their run moved past, the others are still being merged
+func releaseChunks(parts []*runChunk) {
+	for _, chunk := range parts {
+		chunk.base = -1
+		if chunk.used {
+			chunk.release()
+		}
+	}
+}
+
+func (m *runMerger) release() {
+	for _, c := range m.heap.cursors {
+		c.chunk.release()
+	}
+	m.heap.cursors = nil
+}
+
 /*
 only sort and keep the top k elements in memory
 */
@@ -192,6 +611,7 @@ type TopKSortExec struct {
 	consumedOffset uint64
 	consumed       bool // did we finish reading all of the input record batches?
 	done           bool
+	peakMemory     int64
 }
 
 func NewTopKSortExec(child operators.Operator, sortKeys []SortKey, k uint16) (*TopKSortExec, error) {
@@ -208,18 +628,21 @@ func NewTopKSortExec(child operators.Operator, sortKeys []SortKey, k uint16) (*T
 }
 
 // for now read everything into memory and sort -- next steps will be to do external merge
-func (t *TopKSortExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (t *TopKSortExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if t.done {
 		return nil, io.EOF
 	}
-	mem := memory.NewGoAllocator()
+	ctx, mem := operators.Allocator(ctx, t)
 	if !t.consumed {
 		for {
-			childBatch, err := t.input.Next(math.MaxUint16)
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			childBatch, err := operators.NextBatch(ctx, t.input, math.MaxUint16)
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					t.consumed = true
-					if len(t.sortedColumns) != 0 {
+					if len(t.sortedColumns) != 0 && t.sortedColumns[0] != nil {
 						t.totalRows = uint64(t.sortedColumns[0].Len())
 					}
 					break
@@ -228,7 +651,7 @@ func (t *TopKSortExec) Next(n uint16) (*operators.RecordBatch, error) {
 			}
 			// after the update, run take, and then update the sorted columns we store internally
 			// handle input validation here
-			err = t.UpdateTopKSorted(childBatch, t.sortKeys, mem)
+			err = t.UpdateTopKSorted(ctx, childBatch, t.sortKeys, mem)
 			if err != nil {
 				return nil, err
 			}
@@ -244,7 +667,7 @@ func (t *TopKSortExec) Next(n uint16) (*operators.RecordBatch, error) {
 		// remaining > n or remaining = n then just read n and return
 		readSize = uint64(n)
 	}
-	sortedArr, err := t.consumeSortedBatch(readSize, memory.NewGoAllocator())
+	sortedArr, err := t.consumeSortedBatch(ctx, readSize, mem)
 	if err != nil {
 		return nil, err
 	}
@@ -259,8 +682,18 @@ func (t *TopKSortExec) Schema() *arrow.Schema {
 	return t.schema
 }
 func (t *TopKSortExec) Close() error {
+	operators.ReleaseArrays(t.sortedColumns)
+	clear(t.sortedColumns)
 	return t.input.Close()
 }
+func (t *TopKSortExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "TopKSortExec",
+		Params:   map[string]string{"keys": operators.ExplainList(t.sortKeys), "k": strconv.Itoa(int(t.k))},
+		Children: []operators.Operator{t.input},
+	}
+}
+func (t *TopKSortExec) PeakMemory() int64 { return t.peakMemory }
 
 type heapRow struct {
 	rowIdx uint64
@@ -271,22 +704,30 @@ type heapRow struct {
 evaluate key cols
 then iterate through all of the key columns and generate their key represenation
 */
-func (t *TopKSortExec) UpdateTopKSorted(newBatch *operators.RecordBatch, sortKeys []SortKey, mem memory.Allocator) error {
+func (t *TopKSortExec) UpdateTopKSorted(ctx context.Context, newBatch *operators.RecordBatch, sortKeys []SortKey, mem memory.Allocator) error {
+	// the new batch and the k rows kept so far are ranked together, both are released once the
+	// new top k is taken out of them
+	allColumns, err := joinArrays(newBatch.Columns, t.sortedColumns, mem)
+	operators.ReleaseArrays(newBatch.Columns)
+	if err != nil {
+		return err
+	}
+	defer operators.ReleaseArrays(allColumns)
+	// the new batch on top of the k rows kept so far is the most this operator ever holds
+	t.peakMemory = max(t.peakMemory, operators.ArraysSize(allColumns))
+
+	rowCount := int(allColumns[0].Len())
+	all := &operators.RecordBatch{Schema: newBatch.Schema, Columns: allColumns, RowCount: uint64(rowCount)}
 	// 1. Evaluate key columns
 	keyCols := make([]arrow.Array, len(sortKeys))
+	defer operators.ReleaseArrays(keyCols)
 	for i, sk := range sortKeys {
-		arr, err := Expr.EvalExpression(sk.Expr, newBatch)
+		arr, err := Expr.EvalExpression(ctx, sk.Expr, all)
 		if err != nil {
 			return err
 		}
 		keyCols[i] = arr
 	}
-	allColumns, err := joinArrays(newBatch.Columns, t.sortedColumns, mem)
-	if err != nil {
-		return err
-	}
-
-	rowCount := int(allColumns[0].Len())
 	tmpBuff := make([]heapRow, 0, rowCount)
 	for i := 0; i < rowCount; i++ {
 		keys := make([]interface{}, len(sortKeys))
@@ -311,29 +752,37 @@ func (t *TopKSortExec) UpdateTopKSorted(newBatch *operators.RecordBatch, sortKey
 	defer takeArray.Release()
 	count := newBatch.Schema.NumFields()
 	for i := range count {
-		sc, err := compute.TakeArray(context.Background(), allColumns[i], takeArray)
+		sc, err := compute.TakeArray(ctx, allColumns[i], takeArray)
 		if err != nil {
 			return err
 		}
+		if t.sortedColumns[i] != nil {
+			t.sortedColumns[i].Release()
+		}
 		t.sortedColumns[i] = sc
 	}
 	return nil
 }
 
+// joinArrays concatenates existing and newarrs column by column, every returned array is a
+// reference of its own
 func joinArrays(existing, newarrs []arrow.Array, mem memory.Allocator) ([]arrow.Array, error) {
 	if len(existing) == 0 {
-		return newarrs, nil
-	}
-	if len(newarrs) == 0 {
-		return existing, nil
+		existing, newarrs = newarrs, existing
 	}
 	result := make([]arrow.Array, len(existing))
 	for i := range existing {
-		v1, v2 := existing[i], newarrs[i]
-		if v1 == nil {
-			result[i] = v2
-			continue
-		} else if v2 == nil {
+		var v1, v2 arrow.Array = existing[i], nil
+		if i < len(newarrs) {
+			v2 = newarrs[i]
+		}
+		if v1 == nil || v2 == nil {
+			if v1 == nil {
+				v1 = v2
+			}
+			if v1 != nil {
+				v1.Retain()
+			}
 			result[i] = v1
 			continue
 		}
@@ -346,8 +795,7 @@ func joinArrays(existing, newarrs []arrow.Array, mem memory.Allocator) ([]arrow.
 	return result, nil
 }
 
-func (t *TopKSortExec) consumeSortedBatch(readsize uint64, mem memory.Allocator) ([]arrow.Array, error) {
-	ctx := context.Background()
+func (t *TopKSortExec) consumeSortedBatch(ctx context.Context, readsize uint64, mem memory.Allocator) ([]arrow.Array, error) {
 	resultColumns := make([]arrow.Array, len(t.schema.Fields()))
 	offsetArray := genoffsetTakeIdx(t.consumedOffset, readsize, mem)
 	defer offsetArray.Release()
@@ -367,10 +815,11 @@ func (t *TopKSortExec) consumeSortedBatch(readsize uint64, mem memory.Allocator)
 /*
 shared functions
 */
-func sortBatches(fullRC *operators.RecordBatch, sortKeys []SortKey) ([]uint64, error) {
+func sortBatches(ctx context.Context, fullRC *operators.RecordBatch, sortKeys []SortKey) ([]uint64, error) {
 	keyColumns := make([]arrow.Array, len(sortKeys))
+	defer operators.ReleaseArrays(keyColumns)
 	for i, sk := range sortKeys {
-		arr, err := Expr.EvalExpression(sk.Expr, fullRC)
+		arr, err := Expr.EvalExpression(ctx, sk.Expr, fullRC)
 		if err != nil {
 			return nil, fmt.Errorf("sort batches: failed to eval sort expression: %v", err)
 		}
@@ -413,22 +862,27 @@ func sortIndexVector(idVec []uint64, keyColumns []arrow.Array, sortKeys []SortKe
 }
 
 func compareArrowValues(col arrow.Array, i, j uint64) int {
+	return compareArrowArrays(col, i, col, j)
+}
+
+// compareArrowArrays compares row i of a with row j of b, a and b are of the same type
+func compareArrowArrays(a arrow.Array, i uint64, b arrow.Array, j uint64) int {
 	// Handle nulls (treat as lowest value for now)
-	if col.IsNull(int(i)) && col.IsNull(int(j)) {
+	if a.IsNull(int(i)) && b.IsNull(int(j)) {
 		return 0
 	}
-	if col.IsNull(int(i)) {
+	if a.IsNull(int(i)) {
 		return -1
 	}
-	if col.IsNull(int(j)) {
+	if b.IsNull(int(j)) {
 		return 1
 	}
 
-	switch arr := col.(type) {
+	switch arr := a.(type) {
 
 	case *array.String:
 		vi := arr.Value(int(i))
-		vj := arr.Value(int(j))
+		vj := b.(*array.String).Value(int(j))
 		switch {
 		case vi < vj:
 			return -1
@@ -439,61 +893,70 @@ func compareArrowValues(col arrow.Array, i, j uint64) int {
 		}
 
 	case *array.Int8:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Int8).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Int16:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Int16).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Int32:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Int32).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Int64:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Int64).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Uint8:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Uint8).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Uint16:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Uint16).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Uint32:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Uint32).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Uint64:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Uint64).Value(int(j))
 		return compareNumeric(vi, vj)
 
 	case *array.Float32:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Float32).Value(int(j))
 		return compareFloat(vi, vj)
 
 	case *array.Float64:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
+		vi, vj := arr.Value(int(i)), b.(*array.Float64).Value(int(j))
 		return compareFloat(vi, vj)
 
 	case *array.Boolean:
-		vi, vj := arr.Value(int(i)), arr.Value(int(j))
-		if vi == vj {
-			return 0
-		}
-		if !vi && vj {
-			return -1
-		}
-		return 1
+		vi, vj := arr.Value(int(i)), b.(*array.Boolean).Value(int(j))
+		return compareBool(vi, vj)
+
+	case *array.Binary:
+		return bytes.Compare(arr.Value(int(i)), b.(*array.Binary).Value(int(j)))
+
+	case *array.Date32:
+		vi, vj := arr.Value(int(i)), b.(*array.Date32).Value(int(j))
+		return compareNumeric(vi, vj)
+
+	case *array.Date64:
+		vi, vj := arr.Value(int(i)), b.(*array.Date64).Value(int(j))
+		return compareNumeric(vi, vj)
+
+	case *array.Timestamp:
+		vi, vj := arr.Value(int(i)), b.(*array.Timestamp).Value(int(j))
+		return compareNumeric(vi, vj)
 
 	default:
 		panic("unsupported Arrow type in compareArrowValues")
 	}
 }
 
-func compareNumeric[T int64 | int32 | int16 | int8 | uint64 | uint32 | uint16 | uint8](a, b T) int {
+func compareNumeric[T ~int64 | ~int32 | ~int16 | ~int8 | ~uint64 | ~uint32 | ~uint16 | ~uint8](a, b T) int {
 	switch {
 	case a < b:
 		return -1
@@ -514,6 +977,17 @@ func compareFloat[T float32 | float64](a, b T) int {
 		return 0
 	}
 }
+
+// false sorts before true
+func compareBool(a, b bool) int {
+	if a == b {
+		return 0
+	}
+	if !a && b {
+		return -1
+	}
+	return 1
+}
 func extractValue(col arrow.Array, idx int) interface{} {
 	switch arr := col.(type) {
 
@@ -1,10 +1,14 @@
 package aggr
 
 import (
+	"context"
 	"errors"
 	"io"
+	"math"
 	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
 	"opti-sql-go/operators/project"
+	"os"
 	"testing"
 
 	"github.com/apache/arrow/go/v17/arrow"
@@ -25,7 +29,7 @@ func TestSortInit(t *testing.T) {
 			t.Fatalf("expected schema %v, got %v", proj.Schema(), sortExec.schema)
 		}
 		sortExec.done = true
-		_, err = sortExec.Next(100)
+		_, err = sortExec.Next(context.Background(), 100)
 		if err != io.EOF {
 			t.Fatalf("expected io.EOF error on done sortExec but got %v", err)
 		}
@@ -56,7 +60,7 @@ func TestSortInit(t *testing.T) {
 			t.Fatalf("expected %v for top k but got %v", topKVal, topK.k)
 		}
 		topK.done = true
-		_, err = topK.Next(100)
+		_, err = topK.Next(context.Background(), 100)
 		if err != io.EOF {
 			t.Fatalf("expected io.EOF error on done topK but got %v", err)
 		}
@@ -91,7 +95,7 @@ func TestBasicSortExpr(t *testing.T) {
 			t.Fatalf("unexpected error from NewSortExec : %v\n", err)
 		}
 		for {
-			sortedBatch, err := sortExec.Next(5)
+			sortedBatch, err := sortExec.Next(context.Background(), 5)
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					break
@@ -119,7 +123,7 @@ func TestFullSortOverNetwork(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error %v\n", err)
 		}
-		rc, err := sortExec.Next(10)
+		rc, err := sortExec.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("unexpected error %v\n", err)
 		}
@@ -141,7 +145,7 @@ func TestFullSortExec_Next(t *testing.T) {
 		sortExec, err := NewSortExec(proj, CombineSortKeys(ageSK))
 		require.NoError(t, err)
 
-		batch, err := sortExec.Next(5)
+		batch, err := sortExec.Next(context.Background(), 5)
 		require.NoError(t, err)
 		require.Equal(t, uint64(5), batch.RowCount)
 
@@ -171,7 +175,7 @@ func TestFullSortExec_Next(t *testing.T) {
 		sortExec, err := NewSortExec(proj, CombineSortKeys(nameSK))
 		require.NoError(t, err)
 
-		batch, err := sortExec.Next(3)
+		batch, err := sortExec.Next(context.Background(), 3)
 		require.NoError(t, err)
 
 		names := batch.Columns[1].(*array.String)
@@ -379,6 +383,38 @@ func TestCompareArrowValues_AllTypes(t *testing.T) {
 	assert("bool eq", compareArrowValues(boolArr, 1, 1), 0)
 	boolArr.Release()
 
+	// ---- BINARY ----
+	binB := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
+	binB.Append([]byte{1, 2})
+	binB.Append([]byte{1, 2, 0})
+	binArr := binB.NewArray()
+	assert("binary lt", compareArrowValues(binArr, 0, 1), -1)
+	assert("binary eq", compareArrowValues(binArr, 1, 1), 0)
+	binArr.Release()
+	binB.Release()
+
+	// ---- TEMPORAL ----
+	dateB := array.NewDate32Builder(mem)
+	dateB.AppendValues([]arrow.Date32{20000, 19000}, nil)
+	dateArr := dateB.NewArray()
+	assert("date32 gt", compareArrowValues(dateArr, 0, 1), 1)
+	dateArr.Release()
+	dateB.Release()
+
+	date64B := array.NewDate64Builder(mem)
+	date64B.AppendValues([]arrow.Date64{-86400000, 0}, nil)
+	date64Arr := date64B.NewArray()
+	assert("date64 lt", compareArrowValues(date64Arr, 0, 1), -1)
+	date64Arr.Release()
+	date64B.Release()
+
+	tsB := array.NewTimestampBuilder(mem, &arrow.TimestampType{Unit: arrow.Microsecond})
+	tsB.AppendValues([]arrow.Timestamp{5, 5}, nil)
+	tsArr := tsB.NewArray()
+	assert("timestamp eq", compareArrowValues(tsArr, 0, 1), 0)
+	tsArr.Release()
+	tsB.Release()
+
 	// ---- NULL CASES ----
 	nullB := array.NewInt32Builder(mem)
 	nullB.AppendNull()
@@ -538,7 +574,7 @@ func TestTopKSort_BasicAndValues(t *testing.T) {
 		if err != nil {
 			t.Fatalf("NewTopKSortExec error: %v", err)
 		}
-		rb, err := sortExec.Next(5)
+		rb, err := sortExec.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -568,7 +604,7 @@ func TestTopKSort_BasicAndValues(t *testing.T) {
 		if err != nil {
 			t.Fatalf("NewTopKSortExec error: %v", err)
 		}
-		rb, err := sortExec.Next(1000)
+		rb, err := sortExec.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("Next error: %v", err)
 		}
@@ -597,7 +633,7 @@ func TestTopKSort_CombinedAndPagination(t *testing.T) {
 		}
 		total := uint64(0)
 		for _, sz := range []uint16{3, 3, 3} {
-			rb, err := sortExec.Next(sz)
+			rb, err := sortExec.Next(context.Background(), sz)
 			if err != nil && !errors.Is(err, io.EOF) {
 				t.Fatalf("Next error: %v", err)
 			}
@@ -617,3 +653,188 @@ func TestTopKSort_CombinedAndPagination(t *testing.T) {
 		}
 	})
 }
+
+// smallBatches hands out its child's rows a few at a time, copied with its own allocator like a
+// scan would so the query's memory grows with every batch
+type smallBatches struct {
+	input operators.Operator
+	size  uint16
+}
+
+func (s *smallBatches) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
+	batch, err := s.input.Next(ctx, s.size)
+	if err != nil {
+		return nil, err
+	}
+	_, mem := operators.Allocator(ctx, s)
+	cols := make([]arrow.Array, len(batch.Columns))
+	for i, c := range batch.Columns {
+		cols[i], err = array.Concatenate([]arrow.Array{c}, mem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	operators.ReleaseArrays(batch.Columns)
+	return &operators.RecordBatch{Schema: batch.Schema, Columns: cols, RowCount: batch.RowCount}, nil
+}
+func (s *smallBatches) Schema() *arrow.Schema { return s.input.Schema() }
+func (s *smallBatches) Close() error          { return s.input.Close() }
+
+// drainSort reads every row of the sort as one formatted string per row
+func drainSort(t *testing.T, ctx context.Context, s *SortExec, n uint16) []string {
+	t.Helper()
+	var rows []string
+	for {
+		batch, err := s.Next(ctx, n)
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := ""
+			for _, c := range batch.Columns {
+				row += c.ValueStr(r) + "|"
+			}
+			rows = append(rows, row)
+		}
+		operators.ReleaseArrays(batch.Columns)
+	}
+}
+
+func sameRows(t *testing.T, want, got []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestExternalSort(t *testing.T) {
+	keys := CombineSortKeys(NewSortKey(col("age"), false), NewSortKey(col("id"), true))
+	inMemory := func(t *testing.T, src operators.Operator, keys []SortKey) []string {
+		s, err := NewSortExec(src, keys)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		return drainSort(t, context.Background(), s, 7)
+	}
+
+	// inputSize is the bytes the sort buffers reading all of src
+	inputSize := func(t *testing.T, src operators.Operator) int64 {
+		defer func() { _ = src.Close() }()
+		var size int64
+		for {
+			b, err := src.Next(context.Background(), math.MaxUint16)
+			if errors.Is(err, io.EOF) {
+				return size
+			}
+			require.NoError(t, err)
+			size += operators.ArraysSize(b.Columns)
+			operators.ReleaseArrays(b.Columns)
+		}
+	}
+
+	t.Run("runs hold several batches and give the in memory order", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		want := inMemory(t, aggProject(), keys)
+
+		q := operators.NewQueryMemory(nil, 0, 1)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		s, err := NewSortExec(&smallBatches{input: aggProject(), size: 4}, keys)
+		require.NoError(t, err)
+		s.runBatchRows = 3 // several batches per run so the merge has to move through them
+		s.minRunSize = inputSize(t, &smallBatches{input: aggProject(), size: 4}) / 3
+		got := drainSort(t, ctx, s, 7)
+		// 7 input batches, a run is spilled once it holds a third of them
+		if len(s.runs) < 2 || len(s.runs) > 4 {
+			t.Fatalf("expected 2 to 4 runs of several batches, got %d runs", len(s.runs))
+		}
+		sameRows(t, want, got)
+		require.NoError(t, s.Close())
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+		if entries, _ := os.ReadDir(os.TempDir()); len(entries) != 0 {
+			t.Fatalf("expected the runs to be removed on close, found %d files", len(entries))
+		}
+	})
+	t.Run("small inputs are not spilled however early the query asks", func(t *testing.T) {
+		want := inMemory(t, aggProject(), keys)
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		s, err := NewSortExec(&smallBatches{input: aggProject(), size: 4}, keys)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		got := drainSort(t, ctx, s, 7)
+		if len(s.runs) != 0 {
+			t.Fatalf("expected the input to stay under the minimum run size, got %d runs", len(s.runs))
+		}
+		sameRows(t, want, got)
+	})
+	t.Run("runs past the fan in are merged in passes", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		want := inMemory(t, aggProject(), keys)
+
+		q := operators.NewQueryMemory(nil, 0, 1)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		s, err := NewSortExec(&smallBatches{input: aggProject(), size: 2}, keys)
+		require.NoError(t, err)
+		s.runBatchRows = 3
+		s.minRunSize, s.maxFanIn = 0, 2
+		got := drainSort(t, ctx, s, 7)
+		// a run per input batch, merged 2 at a time until a single merge can take them
+		if len(s.runs) != 2 {
+			t.Fatalf("expected the runs to be merged down to the fan in, got %d runs", len(s.runs))
+		}
+		sameRows(t, want, got)
+		require.NoError(t, s.Close())
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+		if entries, _ := os.ReadDir(os.TempDir()); len(entries) != 0 {
+			t.Fatalf("expected the runs to be removed, found %d files", len(entries))
+		}
+	})
+	t.Run("nulls and ascending keys", func(t *testing.T) {
+		keys := CombineSortKeys(NewSortKey(col("salary"), true), NewSortKey(col("id"), true))
+		want := inMemory(t, aggProjectNull(), keys)
+
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1))
+		s, err := NewSortExec(&smallBatches{input: aggProjectNull(), size: 2}, keys)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		s.minRunSize = 0 // a run per batch
+		got := drainSort(t, ctx, s, 3)
+		if len(s.runs) < 2 {
+			t.Fatalf("expected the sort to spill, got %d runs", len(s.runs))
+		}
+		sameRows(t, want, got)
+	})
+	t.Run("under the threshold nothing is spilled", func(t *testing.T) {
+		want := inMemory(t, aggProject(), keys)
+		ctx := operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1<<30))
+		s, err := NewSortExec(&smallBatches{input: aggProject(), size: 4}, keys)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		got := drainSort(t, ctx, s, 7)
+		if len(s.runs) != 0 {
+			t.Fatalf("expected no runs, got %d", len(s.runs))
+		}
+		sameRows(t, want, got)
+	})
+	t.Run("cancelled while merging", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(operators.WithQueryMemory(context.Background(), operators.NewQueryMemory(nil, 0, 1)))
+		s, err := NewSortExec(&smallBatches{input: aggProject(), size: 5}, keys)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		s.minRunSize = 0
+		_, err = s.Next(ctx, 5)
+		require.NoError(t, err)
+		cancel()
+		if _, err := s.Next(ctx, 5); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,429 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
+	"strconv"
+	"sync"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// exchange (repartition): an ExchangeExec reads its child on one goroutine and fans the batches out
+// to N copies of a worker pipeline (filters, projections, partial aggregates...) that each run on
+// their own goroutine, whatever the workers return is merged back into a single stream. batches go
+// to whichever worker is free (round robin) or to the worker their key hashes to, so every row of
+// a key is seen by the same worker. the merged stream has no particular order, the deterministic
+// mode runs the workers one after another on the caller's goroutine instead so tests get the same
+// output every run
+
+var (
+	_ = (operators.Operator)(&ExchangeExec{})
+	_ = (operators.Explainer)(&ExchangeExec{})
+	_ = (operators.Operator)(&workerSource{})
+	_ = (operators.Explainer)(&workerSource{})
+
+	ErrInvalidWorkers = func(n int) error {
+		return fmt.Errorf("exchange: need at least one worker, got %d", n)
+	}
+	ErrWorkerSchema = func(worker int) error {
+		return fmt.Errorf("exchange: pipeline of worker %d has a different schema than worker 0", worker)
+	}
+)
+
+// Partitioning says which worker a row is sent to
+type Partitioning struct {
+	Keys []Expr.Expression // rows with equal keys go to the same worker, round robin when empty
+}
+
+// RoundRobin hands each batch to the next free worker
+func RoundRobin() Partitioning { return Partitioning{} }
+
+// HashPartitioning sends every row to the worker its keys hash to
+func HashPartitioning(keys ...Expr.Expression) Partitioning { return Partitioning{Keys: keys} }
+
+func (p Partitioning) String() string {
+	if len(p.Keys) == 0 {
+		return "round_robin"
+	}
+	return "hash" + operators.ExplainList(p.Keys)
+}
+
+// Options configures an ExchangeExec
+type Options struct {
+	Workers       int // copies of the pipeline
+	Partitioning  Partitioning
+	Deterministic bool // run the workers in order on the caller's goroutine, for tests
+}
+
+// Pipeline builds one worker's pipeline on top of input, it is called once per worker. the pipeline
+// owns input and closing it has to be safe
+type Pipeline func(input operators.Operator) (operators.Operator, error)
+
+type ExchangeExec struct {
+	input   operators.Operator
+	opts    Options
+	schema  *arrow.Schema
+	workers []operators.Operator // pipeline roots
+	sources []*workerSource      // leaves of the pipelines, by worker
+
+	started bool
+	done    bool
+	cancel  context.CancelFunc
+	out     chan *operators.RecordBatch
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+
+	// deterministic mode
+	sent      int // batches dispatched so far
+	inputDone bool
+	current   int // worker being drained
+}
+
+// NewExchangeExec builds opts.Workers copies of pipeline, each reading the part of child the
+// partitioning sends it
+func NewExchangeExec(child operators.Operator, opts Options, pipeline Pipeline) (*ExchangeExec, error) {
+	if opts.Workers < 1 {
+		return nil, ErrInvalidWorkers(opts.Workers)
+	}
+	for _, key := range opts.Partitioning.Keys {
+		if _, err := Expr.ExprDataType(key, child.Schema()); err != nil {
+			return nil, err
+		}
+	}
+	e := &ExchangeExec{input: child, opts: opts}
+	var shared chan *operators.RecordBatch
+	if len(opts.Partitioning.Keys) == 0 {
+		// round robin is morsel driven, every worker takes the next batch from the same channel
+		shared = make(chan *operators.RecordBatch, opts.Workers)
+	}
+	for i := 0; i < opts.Workers; i++ {
+		src := &workerSource{exchange: e, id: i, in: shared}
+		if src.in == nil {
+			src.in = make(chan *operators.RecordBatch, 1)
+		}
+		op, err := pipeline(src)
+		if err != nil {
+			_ = e.closeWorkers()
+			return nil, err
+		}
+		if i > 0 && !op.Schema().Equal(e.schema) {
+			_ = op.Close()
+			_ = e.closeWorkers()
+			return nil, ErrWorkerSchema(i)
+		}
+		e.schema = op.Schema()
+		e.sources = append(e.sources, src)
+		e.workers = append(e.workers, op)
+	}
+	return e, nil
+}
+
+func (e *ExchangeExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	if e.done {
+		return nil, io.EOF
+	}
+	if e.opts.Deterministic {
+		return e.nextInOrder(ctx, n)
+	}
+	if !e.started {
+		e.start(ctx, n)
+	}
+	select {
+	case batch, ok := <-e.out:
+		if !ok {
+			e.done = true
+			if e.err != nil {
+				return nil, e.err
+			}
+			return nil, io.EOF
+		}
+		return batch, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start runs the child on one goroutine and every worker on their own, the workers' output ends up
+// in e.out which is closed once all of them are done
+func (e *ExchangeExec) start(ctx context.Context, n uint16) {
+	e.started = true
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.out = make(chan *operators.RecordBatch, len(e.workers))
+	e.wg.Add(1 + len(e.workers))
+	go e.produce(ctx, n)
+	for _, w := range e.workers {
+		go e.work(ctx, w, n)
+	}
+	go func() {
+		e.wg.Wait()
+		close(e.out)
+	}()
+}
+
+// fail records the first error of any goroutine and stops the others
+func (e *ExchangeExec) fail(err error) {
+	e.errOnce.Do(func() {
+		e.err = err
+		e.cancel()
+	})
+}
+
+func (e *ExchangeExec) produce(ctx context.Context, n uint16) {
+	defer e.wg.Done()
+	defer e.closeInputs()
+	err := func() error {
+		for {
+			batch, err := operators.NextBatch(ctx, e.input, n)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			parts, err := e.partition(ctx, batch)
+			if err != nil {
+				return err
+			}
+			for i, part := range parts {
+				if part == nil {
+					continue
+				}
+				select {
+				case e.sources[i].in <- part:
+				case <-ctx.Done():
+					for _, p := range parts[i:] {
+						if p != nil {
+							operators.ReleaseArrays(p.Columns)
+						}
+					}
+					return ctx.Err()
+				}
+			}
+		}
+	}()
+	if err != nil {
+		e.fail(err)
+	}
+}
+
+func (e *ExchangeExec) work(ctx context.Context, w operators.Operator, n uint16) {
+	defer e.wg.Done()
+	err := func() error {
+		for {
+			batch, err := operators.NextBatch(ctx, w, n)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			select {
+			case e.out <- batch:
+			case <-ctx.Done():
+				operators.ReleaseArrays(batch.Columns)
+				return ctx.Err()
+			}
+		}
+	}()
+	if err != nil {
+		e.fail(err)
+	}
+}
+
+// closeInputs tells the workers no more batches are coming
+func (e *ExchangeExec) closeInputs() {
+	closed := make(map[chan *operators.RecordBatch]bool)
+	for _, src := range e.sources {
+		if !closed[src.in] {
+			closed[src.in] = true
+			close(src.in)
+		}
+	}
+}
+
+// nextInOrder is Next in deterministic mode: worker 0 is drained first, then worker 1 and so on.
+// whenever a worker runs out of batches the child is read on the spot and its batches are queued
+// for whichever worker they belong to
+func (e *ExchangeExec) nextInOrder(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	for e.current < len(e.workers) {
+		batch, err := e.workers[e.current].Next(ctx, n)
+		if err == nil {
+			return batch, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		e.current++
+	}
+	e.done = true
+	return nil, io.EOF
+}
+
+// dispatch reads one batch of the child and queues its parts for their workers, false once the child is exhausted
+func (e *ExchangeExec) dispatch(ctx context.Context, n uint16) (bool, error) {
+	if e.inputDone {
+		return false, nil
+	}
+	batch, err := e.input.Next(ctx, n)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			e.inputDone = true
+			return false, nil
+		}
+		return false, err
+	}
+	parts, err := e.partition(ctx, batch)
+	if err != nil {
+		return false, err
+	}
+	for i, part := range parts {
+		if part != nil {
+			e.sources[i].queue = append(e.sources[i].queue, part)
+		}
+	}
+	return true, nil
+}
+
+// partition splits batch into one batch per worker, nil for workers that get nothing. round robin
+// hands the whole batch to the next worker. batch is taken over
+func (e *ExchangeExec) partition(ctx context.Context, batch *operators.RecordBatch) ([]*operators.RecordBatch, error) {
+	parts := make([]*operators.RecordBatch, len(e.workers))
+	if len(e.opts.Partitioning.Keys) == 0 {
+		parts[e.sent%len(e.workers)] = batch
+		e.sent++
+		return parts, nil
+	}
+	defer operators.ReleaseArrays(batch.Columns)
+	ctx, mem := operators.Allocator(ctx, e)
+	keys := make([]arrow.Array, len(e.opts.Partitioning.Keys))
+	defer operators.ReleaseArrays(keys)
+	for i, key := range e.opts.Partitioning.Keys {
+		arr, err := Expr.EvalExpression(ctx, key, batch)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = arr
+	}
+	rows := operators.PartitionRows(keys, int(batch.RowCount), len(e.workers), 0)
+	for w, idx := range rows {
+		if len(idx) == 0 {
+			continue
+		}
+		cols, err := operators.TakeRows(ctx, mem, batch.Columns, idx)
+		if err != nil {
+			for _, p := range parts {
+				if p != nil {
+					operators.ReleaseArrays(p.Columns)
+				}
+			}
+			return nil, err
+		}
+		parts[w] = &operators.RecordBatch{Schema: batch.Schema, Columns: cols, RowCount: uint64(len(idx))}
+	}
+	return parts, nil
+}
+
+func (e *ExchangeExec) Schema() *arrow.Schema { return e.schema }
+
+// Close stops the workers, drops whatever they didn't hand over yet and closes the pipelines and the child
+func (e *ExchangeExec) Close() error {
+	if e.started {
+		e.cancel()
+		for batch := range e.out {
+			operators.ReleaseArrays(batch.Columns)
+		}
+		// every goroutine is done, what is left in the inputs was never read by a worker
+		for _, src := range e.sources {
+			for batch := range src.in {
+				operators.ReleaseArrays(batch.Columns)
+			}
+		}
+		e.started = false
+	}
+	err := e.closeWorkers()
+	if cerr := e.input.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (e *ExchangeExec) closeWorkers() error {
+	var err error
+	for _, w := range e.workers {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	for _, src := range e.sources {
+		for _, batch := range src.queue {
+			operators.ReleaseArrays(batch.Columns)
+		}
+		src.queue = nil
+	}
+	return err
+}
+
+func (e *ExchangeExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type: "ExchangeExec",
+		Params: map[string]string{
+			"partitioning": e.opts.Partitioning.String(),
+			"workers":      strconv.Itoa(len(e.workers)),
+		},
+		Children: []operators.Operator{e.workers[0]},
+	}
+}
+
+// workerSource is the leaf of a worker's pipeline, it hands out the batches the exchange sent to
+// that worker
+type workerSource struct {
+	exchange *ExchangeExec
+	id       int
+	in       chan *operators.RecordBatch
+	queue    []*operators.RecordBatch // deterministic mode
+}
+
+func (s *workerSource) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	if s.exchange.opts.Deterministic {
+		for len(s.queue) == 0 {
+			more, err := s.exchange.dispatch(ctx, n)
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return nil, io.EOF
+			}
+		}
+		batch := s.queue[0]
+		s.queue = s.queue[1:]
+		return batch, nil
+	}
+	select {
+	case batch, ok := <-s.in:
+		if !ok {
+			return nil, io.EOF
+		}
+		return batch, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *workerSource) Schema() *arrow.Schema { return s.exchange.input.Schema() }
+
+// Close is a no-op, the exchange owns the child
+func (s *workerSource) Close() error { return nil }
+
+func (s *workerSource) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "ExchangeSource",
+		Params:   map[string]string{"worker": strconv.Itoa(s.id)},
+		Children: []operators.Operator{s.exchange.input},
+	}
+}
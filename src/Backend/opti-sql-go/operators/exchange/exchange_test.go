@@ -0,0 +1,274 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"io"
+	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/filter"
+	"opti-sql-go/operators/project"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func exchangeSource() *project.InMemorySource {
+	ids := make([]int32, 50)
+	groups := make([]string, 50)
+	for i := range ids {
+		ids[i] = int32(i)
+		groups[i] = []string{"a", "b", "c", "d", "e", "f", "g"}[i%7]
+	}
+	p, _ := project.NewInMemoryProjectExec([]string{"id", "grp"}, []any{ids, groups})
+	return p
+}
+
+// smallIDs is a worker pipeline keeping the rows with an id below 30
+func smallIDs(input operators.Operator) (operators.Operator, error) {
+	return filter.NewFilterExec(input, Expr.NewBinaryExpr(Expr.NewColumnResolve("id"), Expr.LessThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, int32(30))))
+}
+
+// passThrough is a worker pipeline that hands on what the worker was sent
+func passThrough(input operators.Operator) (operators.Operator, error) { return input, nil }
+
+// drain reads every row of op as one formatted string per row, in the order they came out
+func drain(t *testing.T, ctx context.Context, op operators.Operator) []string {
+	t.Helper()
+	var rows []string
+	for {
+		batch, err := op.Next(ctx, 3)
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := ""
+			for _, c := range batch.Columns {
+				row += c.ValueStr(r) + "|"
+			}
+			rows = append(rows, row)
+		}
+		operators.ReleaseArrays(batch.Columns)
+	}
+}
+
+func sorted(rows []string) string {
+	out := append([]string(nil), rows...)
+	sort.Strings(out)
+	return strings.Join(out, "\n")
+}
+
+// seenKeys records the groups every worker was handed
+type seenKeys struct {
+	operators.Operator
+	mu     *sync.Mutex
+	worker int
+	keys   map[string]int
+}
+
+func (s *seenKeys) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	batch, err := s.Operator.Next(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for r := 0; r < int(batch.RowCount); r++ {
+		s.keys[batch.Columns[1].ValueStr(r)] |= 1 << s.worker
+	}
+	return batch, nil
+}
+
+// failing hands out a batch and then fails
+type failing struct {
+	operators.Operator
+	calls int
+}
+
+func (f *failing) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	f.calls++
+	if f.calls > 1 {
+		return nil, errors.New("worker failed")
+	}
+	return f.Operator.Next(ctx, n)
+}
+
+func TestExchange(t *testing.T) {
+	serial, err := smallIDs(exchangeSource())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := drain(t, context.Background(), serial)
+
+	t.Run("workers together return what a single pipeline does", func(t *testing.T) {
+		for _, p := range []Partitioning{RoundRobin(), HashPartitioning(Expr.NewColumnResolve("grp"))} {
+			e, err := NewExchangeExec(exchangeSource(), Options{Workers: 4, Partitioning: p}, smallIDs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := drain(t, context.Background(), e); sorted(got) != sorted(want) {
+				t.Fatalf("%s: expected\n%s\ngot\n%s", p, sorted(want), sorted(got))
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+	t.Run("partitioned batches are released", func(t *testing.T) {
+		q := operators.NewQueryMemory(nil, 0, 0)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		e, err := NewExchangeExec(exchangeSource(), Options{Workers: 4, Partitioning: HashPartitioning(Expr.NewColumnResolve("grp"))}, passThrough)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rows := drain(t, ctx, e); len(rows) != 50 {
+			t.Fatalf("expected every row, got %d", len(rows))
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.Peak() == 0 || q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+	})
+	t.Run("deterministic mode returns the same order every run", func(t *testing.T) {
+		var first []string
+		for run := 0; run < 3; run++ {
+			e, err := NewExchangeExec(exchangeSource(), Options{Workers: 3, Deterministic: true}, smallIDs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := drain(t, context.Background(), e)
+			_ = e.Close()
+			if sorted(got) != sorted(want) {
+				t.Fatalf("expected\n%s\ngot\n%s", sorted(want), sorted(got))
+			}
+			if run == 0 {
+				first = got
+				// worker 0 gets batches 0, 3, 6... and is drained first
+				if first[0] != "0|a|" || first[2] != "2|c|" || first[3] != "9|c|" {
+					t.Fatalf("unexpected order %v", first)
+				}
+				continue
+			}
+			if strings.Join(got, "\n") != strings.Join(first, "\n") {
+				t.Fatalf("run %d came out in a different order\n%s", run, strings.Join(got, "\n"))
+			}
+		}
+	})
+	t.Run("hash partitioning sends a key to one worker", func(t *testing.T) {
+		for _, deterministic := range []bool{false, true} {
+			var mu sync.Mutex
+			keys := make(map[string]int)
+			worker := 0
+			pipeline := func(input operators.Operator) (operators.Operator, error) {
+				worker++
+				return &seenKeys{Operator: input, mu: &mu, worker: worker - 1, keys: keys}, nil
+			}
+			opts := Options{Workers: 3, Partitioning: HashPartitioning(Expr.NewColumnResolve("grp")), Deterministic: deterministic}
+			e, err := NewExchangeExec(exchangeSource(), opts, pipeline)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rows := drain(t, context.Background(), e); len(rows) != 50 {
+				t.Fatalf("expected every row, got %d", len(rows))
+			}
+			_ = e.Close()
+			used := 0
+			for key, workers := range keys {
+				if workers&(workers-1) != 0 {
+					t.Fatalf("key %s went to more than one worker (%b)", key, workers)
+				}
+				used |= workers
+			}
+			if len(keys) != 7 || used == 1 {
+				t.Fatalf("expected the 7 keys spread over the workers, got %v", keys)
+			}
+		}
+	})
+	t.Run("a failing worker fails the exchange", func(t *testing.T) {
+		q := operators.NewQueryMemory(nil, 0, 0)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		pipeline := func(input operators.Operator) (operators.Operator, error) {
+			return &failing{Operator: input}, nil
+		}
+		e, err := NewExchangeExec(exchangeSource(), Options{Workers: 4, Partitioning: HashPartitioning(Expr.NewColumnResolve("grp"))}, pipeline)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for {
+			batch, err := e.Next(ctx, 3)
+			if err == nil {
+				operators.ReleaseArrays(batch.Columns)
+				continue
+			}
+			if err.Error() != "worker failed" {
+				t.Fatalf("expected the worker's error, got %v", err)
+			}
+			break
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+	})
+	t.Run("closing early stops the workers", func(t *testing.T) {
+		q := operators.NewQueryMemory(nil, 0, 0)
+		ctx := operators.WithQueryMemory(context.Background(), q)
+		e, err := NewExchangeExec(exchangeSource(), Options{Workers: 2, Partitioning: HashPartitioning(Expr.NewColumnResolve("grp"))}, passThrough)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, err := e.Next(ctx, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		operators.ReleaseArrays(batch.Columns)
+		if err := e.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.InUse() != 0 {
+			t.Fatalf("expected all memory to be released, %d bytes still in use", q.InUse())
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		e, err := NewExchangeExec(exchangeSource(), Options{Workers: 2}, smallIDs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = e.Close() }()
+		if _, err := e.Next(ctx, 3); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		if _, err := NewExchangeExec(exchangeSource(), Options{}, smallIDs); err == nil {
+			t.Fatalf("expected zero workers to fail")
+		}
+		opts := Options{Workers: 2, Partitioning: HashPartitioning(Expr.NewColumnResolve("missing"))}
+		if _, err := NewExchangeExec(exchangeSource(), opts, smallIDs); err == nil {
+			t.Fatalf("expected an unknown key to fail")
+		}
+	})
+	t.Run("explain", func(t *testing.T) {
+		e, err := NewExchangeExec(exchangeSource(), Options{Workers: 2, Partitioning: HashPartitioning(Expr.NewColumnResolve("grp"))}, smallIDs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = e.Close() }()
+		text := operators.ExplainText(e)
+		if !strings.HasPrefix(text, "ExchangeExec partitioning=hash[Column(grp)] workers=2") || !strings.Contains(text, "    ExchangeSource worker=0") {
+			t.Fatalf("unexpected plan\n%s", text)
+		}
+	})
+}
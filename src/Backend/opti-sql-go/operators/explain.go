@@ -0,0 +1,117 @@
+package operators
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EXPLAIN: every operator describes itself (Explainer), ExplainPlan walks a built pipeline from the
+// root and collects those descriptions together with each operator's output schema. the tree prints
+// as indented text or marshals to JSON for the frontend
+
+// Explainer is implemented by operators that can describe themselves
+type Explainer interface {
+	Explain() Explanation
+}
+
+// Explanation is what an operator tells EXPLAIN about itself
+type Explanation struct {
+	Type     string            // FilterExec, SortExec...
+	Params   map[string]string // key parameters: predicate, sort keys, limit...
+	Children []Operator        // inputs, in the order the operator reads them
+}
+
+// PlanNode is one operator of an explained pipeline
+type PlanNode struct {
+	Type     string            `json:"type"`
+	Params   map[string]string `json:"params,omitempty"`
+	Schema   []PlanField       `json:"schema"`
+	Children []*PlanNode       `json:"children,omitempty"`
+}
+
+// PlanField is a column of an operator's output schema
+type PlanField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// ExplainPlan describes the pipeline rooted at op. operators that don't implement Explainer show
+// up by their go type, without children
+func ExplainPlan(op Operator) *PlanNode {
+	ex := ExplainOperator(op)
+	node := &PlanNode{Type: ex.Type, Params: ex.Params}
+	for _, c := range ex.Children {
+		node.Children = append(node.Children, ExplainPlan(c))
+	}
+	if schema := op.Schema(); schema != nil {
+		for _, f := range schema.Fields() {
+			node.Schema = append(node.Schema, PlanField{Name: f.Name, Type: f.Type.String(), Nullable: f.Nullable})
+		}
+	}
+	return node
+}
+
+// ExplainOperator is op.Explain(), or just the go type of op when it can't explain itself.
+// wrappers that don't change what an operator does (stats, metrics) hand back the wrapped operator's
+// explanation through this so they don't show up in the tree
+func ExplainOperator(op Operator) Explanation {
+	if e, ok := op.(Explainer); ok {
+		return e.Explain()
+	}
+	return Explanation{Type: strings.TrimPrefix(fmt.Sprintf("%T", op), "*")}
+}
+
+// String prints the tree one operator per line, children indented below their parent. parameters
+// are sorted by name and the output schema comes last
+//
+//	LimitExec limit=10 schema=[name: utf8]
+//	  FilterExec predicate=BinaryExpr(Column(age) 10 Literal(30)) schema=[name: utf8]
+//	    CSVSource schema=[name: utf8]
+func (n *PlanNode) String() string {
+	var b strings.Builder
+	formatPlanNode(&b, n, 0)
+	return b.String()
+}
+
+func formatPlanNode(b *strings.Builder, n *PlanNode, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Type)
+	keys := make([]string, 0, len(n.Params))
+	for k := range n.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s=%s", k, n.Params[k])
+	}
+	fields := make([]string, len(n.Schema))
+	for i, f := range n.Schema {
+		fields[i] = f.Name + ": " + f.Type
+	}
+	fmt.Fprintf(b, " schema=[%s]\n", strings.Join(fields, ", "))
+	for _, c := range n.Children {
+		formatPlanNode(b, c, depth+1)
+	}
+}
+
+// ExplainText is ExplainPlan(op) printed as indented text
+func ExplainText(op Operator) string {
+	return ExplainPlan(op).String()
+}
+
+// ExplainJSON is ExplainPlan(op) as indented JSON
+func ExplainJSON(op Operator) ([]byte, error) {
+	return json.MarshalIndent(ExplainPlan(op), "", "  ")
+}
+
+// ExplainList joins the String() of each item, for list parameters like sort keys or projections
+func ExplainList[T fmt.Stringer](items []T) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = item.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,59 @@
+package operators
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// explainedOp describes itself with the given children
+type explainedOp struct {
+	batchesOp
+	kind     string
+	children []Operator
+}
+
+func (e *explainedOp) Explain() Explanation {
+	return Explanation{Type: e.kind, Params: map[string]string{"b": "2", "a": "1"}, Children: e.children}
+}
+
+func TestExplainPlan(t *testing.T) {
+	rbb := NewRecordBatchBuilder()
+	rbb.SchemaBuilder.WithField("id", arrow.PrimitiveTypes.Int32, false).WithField("name", arrow.BinaryTypes.String, true)
+	batch := &RecordBatch{Schema: rbb.Schema()}
+
+	leaf := &batchesOp{batch: batch}
+	parent := &explainedOp{batchesOp: batchesOp{batch: batch}, kind: "ParentExec", children: []Operator{leaf}}
+
+	t.Run("text", func(t *testing.T) {
+		want := "ParentExec a=1 b=2 schema=[id: int32, name: utf8]\n" +
+			"  operators.batchesOp schema=[id: int32, name: utf8]\n"
+		if got := ExplainText(parent); got != want {
+			t.Fatalf("expected\n%s\ngot\n%s", want, got)
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		data, err := ExplainJSON(parent)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var node PlanNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if node.Type != "ParentExec" || node.Params["a"] != "1" || len(node.Children) != 1 || node.Children[0].Type != "operators.batchesOp" {
+			t.Fatalf("unexpected plan %s", data)
+		}
+		if !strings.Contains(string(data), `"schema": [`) || !node.Schema[1].Nullable || node.Schema[0].Nullable {
+			t.Fatalf("unexpected schema in %s", data)
+		}
+	})
+	t.Run("stats wrappers are transparent", func(t *testing.T) {
+		analyzed := NewAnalyzedExec(parent, "Parent", NewAnalyzedExec(leaf, "Leaf"))
+		if got, want := ExplainText(analyzed), ExplainText(parent); got != want {
+			t.Fatalf("expected\n%s\ngot\n%s", want, got)
+		}
+	})
+}
@@ -16,6 +16,7 @@ import (
 
 var (
 	_ = (operators.Operator)(&FilterExec{})
+	_ = (operators.Explainer)(&FilterExec{})
 )
 
 // FilterExec is an operator that filters input records according to a predicate expression.
@@ -33,6 +34,14 @@ func NewFilterExec(input operators.Operator, pred Expr.Expression) (*FilterExec,
 	if !validPredicates(pred, input.Schema()) {
 		return nil, errors.New("predicates passed to FilterExec are invalid")
 	}
+	if dt, err := Expr.ExprDataType(pred, input.Schema()); err != nil || dt.ID() != arrow.BOOL {
+		return nil, errors.New("predicate passed to FilterExec does not evaluate to a boolean")
+	}
+	// fold constants once here instead of materializing literal columns for every batch
+	pred, err := Expr.SimplifyPredicate(pred, input.Schema())
+	if err != nil {
+		return nil, err
+	}
 	return &FilterExec{
 		input:        input,
 		predicate:    pred,
@@ -40,13 +49,13 @@ func NewFilterExec(input operators.Operator, pred Expr.Expression) (*FilterExec,
 		bufferedCols: make([]arrow.Array, input.Schema().NumFields()),
 	}, nil
 }
-func (f *FilterExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (f *FilterExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if f.done && f.bufferedSize == 0 {
 		return nil, io.EOF
 	}
-	mem := memory.NewGoAllocator()
+	ctx, mem := operators.Allocator(ctx, f)
 	for f.bufferedSize < int64(n) && !f.done {
-		childBatch, err := f.input.Next(n)
+		childBatch, err := f.input.Next(ctx, n)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				f.done = true
@@ -54,22 +63,15 @@ func (f *FilterExec) Next(n uint16) (*operators.RecordBatch, error) {
 			}
 			return nil, err
 		}
-		booleanMask, err := Expr.EvalExpression(f.predicate, childBatch)
+		filteredCol, err := f.filterBatch(ctx, childBatch)
+		// the filtered columns are copies, the child's batch is done with either way
+		operators.ReleaseArrays(childBatch.Columns)
 		if err != nil {
 			return nil, err
 		}
-		boolArr, ok := booleanMask.(*array.Boolean) // impossible for this to not be a boolean array,assuming validPredicates works as it should
-		if !ok {
-			return nil, errors.New("predicate did not evaluate to boolean array")
-		}
-		filteredCol := make([]arrow.Array, len(childBatch.Columns))
-		for i, col := range childBatch.Columns {
-			filteredCol[i], err = ApplyBooleanMask(col, boolArr)
-			if err != nil {
-				return nil, err
-			}
+		if len(filteredCol) > 0 {
+			f.bufferedSize += int64(filteredCol[0].Len())
 		}
-		booleanMask.Release()
 		// combine with buffered columns
 		for i, col := range f.bufferedCols {
 			if col == nil {
@@ -84,19 +86,16 @@ func (f *FilterExec) Next(n uint16) (*operators.RecordBatch, error) {
 
 			// Release old buffer column
 			col.Release()
+			filteredCol[i].Release()
 
 			f.bufferedCols[i] = combined
 		}
-		if len(childBatch.Columns) > 0 {
-			size := int64(filteredCol[0].Len())
-			f.bufferedSize += int64(size)
-		}
 	}
 	if f.bufferedSize == 0 {
 		return nil, io.EOF
 	}
 	toEmit := min(int64(n), f.bufferedSize)
-	out, err := f.sliceFilterCols(toEmit, mem)
+	out, err := f.sliceFilterCols(ctx, toEmit, mem)
 	if err != nil {
 		return nil, err
 	}
@@ -117,20 +116,53 @@ func (f *FilterExec) Schema() *arrow.Schema {
 }
 
 func (f *FilterExec) Close() error {
+	operators.ReleaseArrays(f.bufferedCols)
+	clear(f.bufferedCols)
+	f.bufferedSize = 0
 	return f.input.Close()
 }
 
-func ApplyBooleanMask(col arrow.Array, mask *array.Boolean) (arrow.Array, error) {
+// filterBatch applies the predicate to every column of batch
+func (f *FilterExec) filterBatch(ctx context.Context, batch *operators.RecordBatch) ([]arrow.Array, error) {
+	booleanMask, err := Expr.EvalExpression(ctx, f.predicate, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer booleanMask.Release()
+	boolArr, ok := booleanMask.(*array.Boolean) // impossible for this to not be a boolean array,assuming validPredicates works as it should
+	if !ok {
+		return nil, errors.New("predicate did not evaluate to boolean array")
+	}
+	filteredCol := make([]arrow.Array, len(batch.Columns))
+	for i, col := range batch.Columns {
+		filteredCol[i], err = ApplyBooleanMask(ctx, col, boolArr)
+		if err != nil {
+			operators.ReleaseArrays(filteredCol)
+			return nil, err
+		}
+	}
+	return filteredCol, nil
+}
+func (f *FilterExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "FilterExec",
+		Params:   map[string]string{"predicate": f.predicate.String()},
+		Children: []operators.Operator{f.input},
+	}
+}
+
+func ApplyBooleanMask(ctx context.Context, col arrow.Array, mask *array.Boolean) (arrow.Array, error) {
 	datum, err := compute.Filter(
-		context.Background(),
-		compute.NewDatum(col),
-		compute.NewDatum(mask),
+		ctx,
+		compute.NewDatumWithoutOwning(col),
+		compute.NewDatumWithoutOwning(mask),
 		*compute.DefaultFilterOptions(),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	defer datum.Release()
 	arr := datum.(*compute.ArrayDatum).MakeArray()
 	return arr, nil
 }
@@ -150,8 +182,11 @@ func validPredicates(pred Expr.Expression, schema *arrow.Schema) bool {
 		case Expr.Equal, Expr.NotEqual,
 			Expr.GreaterThan, Expr.GreaterThanOrEqual,
 			Expr.LessThan, Expr.LessThanOrEqual,
-			Expr.And, Expr.Or:
+			Expr.And, Expr.Or, Expr.Like:
 			// supported
+		case Expr.Addition, Expr.Subtraction,
+			Expr.Multiplication, Expr.Division:
+			// only as operands of a comparison, the boolean check in NewFilterExec rejects them at the top
 		default:
 			return false
 		}
@@ -176,6 +211,12 @@ func validPredicates(pred Expr.Expression, schema *arrow.Schema) bool {
 
 	case *Expr.NullCheckExpr:
 		return validPredicates(p.Expr, schema)
+	case *Expr.NotExpr:
+		return validPredicates(p.Expr, schema)
+	case *Expr.CastExpr:
+		return validPredicates(p.Expr, schema)
+	case *Expr.Alias:
+		return validPredicates(p.Expr, schema)
 	case *Expr.ScalarFunction:
 		return true
 	default:
@@ -183,7 +224,7 @@ func validPredicates(pred Expr.Expression, schema *arrow.Schema) bool {
 	}
 }
 
-func (f *FilterExec) sliceFilterCols(n int64, mem memory.Allocator) ([]arrow.Array, error) {
+func (f *FilterExec) sliceFilterCols(ctx context.Context, n int64, mem memory.Allocator) ([]arrow.Array, error) {
 	out := make([]arrow.Array, len(f.bufferedCols))
 
 	// Build index arrays for:
@@ -216,7 +257,6 @@ func (f *FilterExec) sliceFilterCols(n int64, mem memory.Allocator) ([]arrow.Arr
 	defer keepArr.Release()
 
 	// For each column: materialize output slice + update buffer
-	ctx := context.Background()
 	for i, col := range f.bufferedCols {
 		// emit slice
 		sliceOut, err := compute.TakeArray(ctx, col, emitArr)
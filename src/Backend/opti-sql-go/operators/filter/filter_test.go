@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"errors"
 	"io"
 	"opti-sql-go/Expr"
@@ -80,7 +81,7 @@ func TestFilterExec_BasicPredicates(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		rb, err := f.Next(10)
+		rb, err := f.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -112,7 +113,7 @@ func TestFilterExec_BasicPredicates(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		rb, err := f.Next(20)
+		rb, err := f.Next(context.Background(), 20)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -141,7 +142,7 @@ func TestFilterExec_BasicPredicates(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		rb, err := f.Next(10)
+		rb, err := f.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -172,7 +173,7 @@ func TestFilterExec_BasicPredicates(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		rb, err := f.Next(10)
+		rb, err := f.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -204,7 +205,7 @@ func TestFilterExec_EdgeCases(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		_, err := f.Next(0)
+		_, err := f.Next(context.Background(), 0)
 		if err == nil {
 			t.Fatalf("expected error but got %v", err)
 		}
@@ -221,8 +222,8 @@ func TestFilterExec_EdgeCases(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		_, _ = f.Next(50)
-		_, err := f.Next(10)
+		_, _ = f.Next(context.Background(), 50)
+		_, err := f.Next(context.Background(), 10)
 
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF, got %v", err)
@@ -240,7 +241,7 @@ func TestFilterExec_EdgeCases(t *testing.T) {
 
 		f, _ := NewFilterExec(proj, pred)
 
-		_, err := f.Next(20)
+		_, err := f.Next(context.Background(), 20)
 		if err == nil {
 			t.Fatalf("expected EOF error but got nil")
 		}
@@ -270,12 +271,12 @@ func TestFilterExecVariantCase(t *testing.T) {
 		proj := basicProject()
 		predicate := Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30))
 		f, _ := NewFilterExec(proj, predicate)
-		_, err := f.Next(1)
+		_, err := f.Next(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		f.done = true
-		_, err = f.Next(1)
+		_, err = f.Next(context.Background(), 1)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF error, got %v", err)
 		}
@@ -332,14 +333,57 @@ func TestFilterBuffer(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create filter exec: %v", err)
 		}
-		_, err = f.Next(5)
+		_, err = f.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		_, err = f.Next(5)
+		_, err = f.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
 	})
 }
+
+func TestFilterExec_SimplifiesPredicate(t *testing.T) {
+	age30 := Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, 30)
+	written := Expr.NewBinaryExpr(
+		Expr.NewBinaryExpr(age30, Expr.LessThan, Expr.NewColumnResolve("age")),
+		Expr.And,
+		Expr.NewLiteralResolve(arrow.FixedWidthTypes.Boolean, true),
+	)
+	f, err := NewFilterExec(basicProject(), written)
+	if err != nil {
+		t.Fatalf("failed to create filter exec: %v", err)
+	}
+	want := Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, age30)
+	if f.predicate.String() != want.String() {
+		t.Fatalf("expected predicate %s, got %s", want, f.predicate)
+	}
+	plain, err := NewFilterExec(basicProject(), want)
+	if err != nil {
+		t.Fatalf("failed to create filter exec: %v", err)
+	}
+	got, err := f.Next(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected, err := plain.Next(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.RowCount != expected.RowCount || got.RowCount == 0 {
+		t.Fatalf("expected %d rows, got %d", expected.RowCount, got.RowCount)
+	}
+
+	t.Run("contradiction", func(t *testing.T) {
+		never := Expr.NewBinaryExpr(want, Expr.And, Expr.NewLiteralResolve(arrow.FixedWidthTypes.Boolean, false))
+		f, err := NewFilterExec(basicProject(), never)
+		if err != nil {
+			t.Fatalf("failed to create filter exec: %v", err)
+		}
+		if _, err := f.Next(context.Background(), 100); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	})
+}
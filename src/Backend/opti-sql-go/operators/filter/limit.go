@@ -7,6 +7,7 @@ import (
 	"math"
 	"opti-sql-go/Expr"
 	"opti-sql-go/operators"
+	"strconv"
 	"strings"
 
 	"github.com/apache/arrow/go/v17/arrow"
@@ -18,24 +19,31 @@ import (
 var (
 	_ = (operators.Operator)(&LimitExec{})
 	_ = (operators.Operator)(&DistinctExec{})
+	_ = (operators.MemoryReporter)(&DistinctExec{})
+	_ = (operators.Explainer)(&LimitExec{})
+	_ = (operators.Explainer)(&DistinctExec{})
 )
 
 type LimitExec struct {
 	input     operators.Operator
 	schema    *arrow.Schema
-	remaining uint16
+	limit     uint64
+	remaining uint64
 	done      bool
 }
 
-func NewLimitExec(input operators.Operator, count uint16) (*LimitExec, error) {
+// NewLimitExec passes on the first count rows of input. count isn't bound by the size of a batch,
+// the rows are handed out over as many batches as the caller asks for
+func NewLimitExec(input operators.Operator, count uint64) (*LimitExec, error) {
 	return &LimitExec{
 		input:     input,
 		schema:    input.Schema(),
+		limit:     count,
 		remaining: count,
 	}, nil
 }
 
-func (l *LimitExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (l *LimitExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if n == 0 {
 		return &operators.RecordBatch{
 			Schema:   l.schema,
@@ -46,29 +54,24 @@ func (l *LimitExec) Next(n uint16) (*operators.RecordBatch, error) {
 	if l.remaining == 0 {
 		return nil, io.EOF
 	}
-	var childN uint16
-	switch {
-	case n < l.remaining:
-		// We can fulfill the request fully
-		childN = n
-		l.remaining -= n
-
-	case n == l.remaining:
-		// Exact request - done afterwards
-		childN = n
-		l.remaining = 0
-		l.done = true
-
-	case n > l.remaining:
-		// Only have l.remaining left
-		childN = l.remaining
-		l.remaining = 0
-		l.done = true
-	}
-	childBatch, err := l.input.Next(childN)
+	// never ask for more than we still have left to give
+	childN := uint16(min(uint64(n), l.remaining))
+	childBatch, err := l.input.Next(ctx, childN)
 	if err != nil {
 		return nil, err
 	}
+	// pipeline breakers like joins and group by hand back everything in one batch regardless of n
+	if childBatch.RowCount > uint64(childN) {
+		for i, col := range childBatch.Columns {
+			childBatch.Columns[i] = array.NewSlice(col, 0, int64(childN))
+			col.Release()
+		}
+		childBatch.RowCount = uint64(childN)
+	}
+	l.remaining -= childBatch.RowCount
+	if l.remaining == 0 {
+		l.done = true
+	}
 	return childBatch, nil
 }
 func (l *LimitExec) Schema() *arrow.Schema {
@@ -78,6 +81,13 @@ func (l *LimitExec) Schema() *arrow.Schema {
 func (l *LimitExec) Close() error {
 	return l.input.Close()
 }
+func (l *LimitExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "LimitExec",
+		Params:   map[string]string{"limit": strconv.FormatUint(l.limit, 10)},
+		Children: []operators.Operator{l.input},
+	}
+}
 
 type DistinctExec struct {
 	input               operators.Operator
@@ -89,6 +99,7 @@ type DistinctExec struct {
 	consumedInput       bool                // did we consume all the input record batches?
 	totalRows           uint64
 	done                bool
+	seenBytes           int64 // size of the keys in seenValues
 }
 
 func NewDistinctExec(input operators.Operator, colExpr []Expr.Expression) (*DistinctExec, error) {
@@ -105,15 +116,17 @@ func NewDistinctExec(input operators.Operator, colExpr []Expr.Expression) (*Dist
 }
 
 // pipeline breaker. consume all, if row combonation is already seen, dont include in output
-func (d *DistinctExec) Next(n uint16) (*operators.RecordBatch, error) {
+func (d *DistinctExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
 	if d.done {
 		return nil, io.EOF
 	}
-	mem := memory.NewGoAllocator()
-	ctx := context.Background()
+	ctx, mem := operators.Allocator(ctx, d)
 	if !d.consumedInput {
 		for {
-			childBatch, err := d.input.Next(math.MaxUint16)
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			childBatch, err := operators.NextBatch(ctx, d.input, math.MaxUint16)
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					d.consumedInput = true
@@ -127,8 +140,10 @@ func (d *DistinctExec) Next(n uint16) (*operators.RecordBatch, error) {
 			// resolve the columns we care about
 			evaluatedArrays := make([]arrow.Array, len(d.colExpr))
 			for i := range d.colExpr {
-				arr, err := Expr.EvalExpression(d.colExpr[i], childBatch)
+				arr, err := Expr.EvalExpression(ctx, d.colExpr[i], childBatch)
 				if err != nil {
+					operators.ReleaseArrays(evaluatedArrays)
+					operators.ReleaseArrays(childBatch.Columns)
 					return nil, err
 				}
 				evaluatedArrays[i] = arr
@@ -154,24 +169,17 @@ func (d *DistinctExec) Next(n uint16) (*operators.RecordBatch, error) {
 				key := keyBuilder.String()
 				if _, seen := d.seenValues[key]; !seen {
 					d.seenValues[key] = struct{}{}
+					d.seenBytes += int64(len(key))
 					idxTracker = append(idxTracker, int32(rowIdx))
 					// check if its been seen, if it hasnt been add it to the table,
 					// and keep track of the index so we can grab the value from the array
 				}
 			}
-			takeArray := idxToArrowArray(idxTracker, mem)
-			for i := range len(childBatch.Columns) {
-				largeArray := childBatch.Columns[i]
-				uniqueElements, err := compute.TakeArray(ctx, largeArray, takeArray)
-				if err != nil {
-					return nil, err
-				}
-				joinedArray, err := joinArrays(d.distinctValuesArray[i], uniqueElements, mem)
-				if err != nil {
-					return nil, err
-				}
-				//	uniqueElements.Release()
-				d.distinctValuesArray[i] = joinedArray
+			operators.ReleaseArrays(evaluatedArrays)
+			err = d.keepRows(ctx, childBatch, idxTracker, mem)
+			operators.ReleaseArrays(childBatch.Columns)
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -187,7 +195,7 @@ func (d *DistinctExec) Next(n uint16) (*operators.RecordBatch, error) {
 	} else {
 		readsize = remaining
 	}
-	distinctArraySlice, err := d.consumeDistinctArrays(readsize, mem)
+	distinctArraySlice, err := d.consumeDistinctArrays(ctx, readsize, mem)
 	if err != nil {
 		return nil, err
 	}
@@ -205,12 +213,42 @@ func (d *DistinctExec) Next(n uint16) (*operators.RecordBatch, error) {
 	}, nil
 }
 func (d *DistinctExec) Schema() *arrow.Schema { return d.schema }
+
+// everything kept only grows until the input is consumed, so what is held now is the peak
+func (d *DistinctExec) PeakMemory() int64 {
+	return d.seenBytes + operators.ArraysSize(d.distinctValuesArray)
+}
 func (d *DistinctExec) Close() error {
 	operators.ReleaseArrays(d.distinctValuesArray)
 	return d.input.Close()
 }
-func (d *DistinctExec) consumeDistinctArrays(readSize uint64, mem memory.Allocator) ([]arrow.Array, error) {
-	ctx := context.Background()
+func (d *DistinctExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "DistinctExec",
+		Params:   map[string]string{"columns": operators.ExplainList(d.colExpr)},
+		Children: []operators.Operator{d.input},
+	}
+}
+
+// keepRows appends the rows at idx of batch to the distinct values found so far
+func (d *DistinctExec) keepRows(ctx context.Context, batch *operators.RecordBatch, idx []int32, mem memory.Allocator) error {
+	takeArray := idxToArrowArray(idx, mem)
+	defer takeArray.Release()
+	for i, largeArray := range batch.Columns {
+		uniqueElements, err := compute.TakeArray(ctx, largeArray, takeArray)
+		if err != nil {
+			return err
+		}
+		joinedArray, err := joinArrays(d.distinctValuesArray[i], uniqueElements, mem)
+		if err != nil {
+			uniqueElements.Release()
+			return err
+		}
+		d.distinctValuesArray[i] = joinedArray
+	}
+	return nil
+}
+func (d *DistinctExec) consumeDistinctArrays(ctx context.Context, readSize uint64, mem memory.Allocator) ([]arrow.Array, error) {
 	resultColumns := make([]arrow.Array, len(d.schema.Fields()))
 	offsetArray := genoffsetTakeIdx(d.consumedOffset, readSize, mem)
 	defer offsetArray.Release()
@@ -236,14 +274,29 @@ func idxToArrowArray(v []int32, mem memory.Allocator) arrow.Array {
 	arr := b.NewArray()
 	return arr
 }
+
+// joinArrays concatenates a1 and a2 and takes over the references to both, on success they are
+// released or handed back as the result
 func joinArrays(a1, a2 arrow.Array, mem memory.Allocator) (arrow.Array, error) {
 	if a1 == nil || a1.Len() == 0 {
+		if a1 != nil {
+			a1.Release()
+		}
 		return a2, nil
 	}
 	if a2 == nil || a2.Len() == 0 {
+		if a2 != nil {
+			a2.Release()
+		}
 		return a1, nil
 	}
-	return array.Concatenate([]arrow.Array{a1, a2}, mem)
+	joined, err := array.Concatenate([]arrow.Array{a1, a2}, mem)
+	if err != nil {
+		return nil, err
+	}
+	a1.Release()
+	a2.Release()
+	return joined, nil
 }
 func genoffsetTakeIdx(offset, size uint64, mem memory.Allocator) arrow.Array {
 	b := array.NewUint64Builder(mem)
@@ -1,9 +1,12 @@
 package filter
 
 import (
+	"context"
 	"errors"
 	"io"
+	"math"
 	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
 	"opti-sql-go/operators/project"
 	"testing"
 
@@ -88,7 +91,7 @@ func maskAny(t *testing.T, src *project.InMemorySource, expr Expr.Expression, ex
 	t.Helper()
 
 	// 1. Pull the record batch from the project source
-	batch, err := src.Next(10)
+	batch, err := src.Next(context.Background(), 10)
 	if err != nil {
 		t.Fatalf("failed to fetch record batch: %v", err)
 	}
@@ -97,7 +100,7 @@ func maskAny(t *testing.T, src *project.InMemorySource, expr Expr.Expression, ex
 	}
 
 	// 2. Evaluate expression against the batch
-	out, err := Expr.EvalExpression(expr, batch)
+	out, err := Expr.EvalExpression(context.Background(), expr, batch)
 	if err != nil {
 		t.Fatalf("EvalExpression error: %v", err)
 	}
@@ -149,7 +152,7 @@ func TestLimitExec_InitAndSchema(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		_, err = lim.Next(3)
+		_, err = lim.Next(context.Background(), 3)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF for zero limit, got %v", err)
 		}
@@ -175,7 +178,7 @@ func TestLimitExec_NextBehavior(t *testing.T) {
 
 	t.Run("n < remaining", func(t *testing.T) {
 		lim, _ := NewLimitExec(memSrc, 5)
-		rb, err := lim.Next(3)
+		rb, err := lim.Next(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -187,14 +190,14 @@ func TestLimitExec_NextBehavior(t *testing.T) {
 	t.Run("n == remaining", func(t *testing.T) {
 		memSrc2, _ := project.NewInMemoryProjectExec(names, cols)
 		lim, _ := NewLimitExec(memSrc2, 4)
-		rb, err := lim.Next(4)
+		rb, err := lim.Next(context.Background(), 4)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		if rb.RowCount != 4 {
 			t.Fatalf("expected 4 rows, got %d", rb.RowCount)
 		}
-		_, err = lim.Next(2)
+		_, err = lim.Next(context.Background(), 2)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF after exact match, got %v", err)
 		}
@@ -203,14 +206,14 @@ func TestLimitExec_NextBehavior(t *testing.T) {
 	t.Run("n > remaining", func(t *testing.T) {
 		memSrc3, _ := project.NewInMemoryProjectExec(names, cols)
 		lim, _ := NewLimitExec(memSrc3, 3)
-		rb, err := lim.Next(10)
+		rb, err := lim.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
 		if rb.RowCount != 3 {
 			t.Fatalf("expected 3 rows, got %d", rb.RowCount)
 		}
-		_, err = lim.Next(10)
+		_, err = lim.Next(context.Background(), 10)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("was expecting io.EOF but received %v", err)
 		}
@@ -225,7 +228,7 @@ func TestLimitExec_IterationUntilEOF(t *testing.T) {
 
 		total := 0
 		for {
-			rb, err := lim.Next(3)
+			rb, err := lim.Next(context.Background(), 3)
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					break
@@ -251,7 +254,7 @@ func TestLimitExec_IterationUntilEOF(t *testing.T) {
 		memSrc2, _ := project.NewInMemoryProjectExec(names, cols)
 		lim, _ := NewLimitExec(memSrc2, 5)
 
-		rb, err := lim.Next(0)
+		rb, err := lim.Next(context.Background(), 0)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -259,7 +262,7 @@ func TestLimitExec_IterationUntilEOF(t *testing.T) {
 			t.Fatalf("expected zero rowcount, got %d", rb.RowCount)
 		}
 
-		rb2, err := lim.Next(2)
+		rb2, err := lim.Next(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -275,9 +278,9 @@ func TestLimitExec_IterationUntilEOF(t *testing.T) {
 		memSrc3, _ := project.NewInMemoryProjectExec(names, cols)
 		lim, _ := NewLimitExec(memSrc3, 2)
 
-		_, _ = lim.Next(3) // exhaust
+		_, _ = lim.Next(context.Background(), 3) // exhaust
 
-		_, err := lim.Next(1)
+		_, err := lim.Next(context.Background(), 1)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF, got %v", err)
 		}
@@ -285,6 +288,37 @@ func TestLimitExec_IterationUntilEOF(t *testing.T) {
 	})
 }
 
+// wholeBatchSource hands back everything on the first call no matter what n is, like joins and group by do
+type wholeBatchSource struct {
+	*project.InMemorySource
+}
+
+func (w *wholeBatchSource) Next(_ context.Context, _ uint16) (*operators.RecordBatch, error) {
+	return w.InMemorySource.Next(context.Background(), math.MaxUint16)
+}
+
+func TestLimitExec_ChildIgnoresBatchSize(t *testing.T) {
+	names, cols := generateTestColumns()
+	memSrc, _ := project.NewInMemoryProjectExec(names, cols)
+	lim, _ := NewLimitExec(&wholeBatchSource{memSrc}, 4)
+
+	rb, err := lim.Next(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rb.RowCount != 4 {
+		t.Fatalf("expected 4 rows, got %d", rb.RowCount)
+	}
+	for i, c := range rb.Columns {
+		if c.Len() != 4 {
+			t.Fatalf("column %d: expected 4 values, got %d", i, c.Len())
+		}
+	}
+	if _, err := lim.Next(context.Background(), 100); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF once the limit is reached, got %v", err)
+	}
+}
+
 /*
 ==============================================
 //            Wild Card Test
@@ -601,7 +635,7 @@ func TestDistinctInit(t *testing.T) {
 			t.Fatalf("unexpected error occured closing operator %v\n", err)
 		}
 		distinctExec.done = true
-		_, err = distinctExec.Next(3)
+		_, err = distinctExec.Next(context.Background(), 3)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected io.EOF but got %v\n", err)
 		}
@@ -615,7 +649,7 @@ func TestDistinctInit(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error creating new distinct operator")
 		}
-		rc, err := distinctExec.Next(5)
+		rc, err := distinctExec.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("error occured grabbing next values from distinct operator %v", err)
 		}
@@ -632,7 +666,7 @@ func TestDistinctInit(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error creating new distinct operator")
 		}
-		rc, err := distinctExec.Next(5)
+		rc, err := distinctExec.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("error occured grabbing next values from distinct operator %v", err)
 		}
@@ -654,7 +688,7 @@ func TestDistinctNext(t *testing.T) {
 		batchsize := 1
 		count := 0
 		for {
-			rc, err := distinctExec.Next(uint16(batchsize))
+			rc, err := distinctExec.Next(context.Background(), uint16(batchsize))
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					break
@@ -683,7 +717,7 @@ func TestDistinctNext(t *testing.T) {
 			t.Fatalf("unexpected error creating new distinct operator")
 		}
 		// request all in one go
-		rc, err := distinctExec.Next(10)
+		rc, err := distinctExec.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -714,13 +748,13 @@ func TestDistinctNext(t *testing.T) {
 			t.Fatalf("unexpected error creating new distinct operator")
 		}
 		// consume all
-		_, err = distinctExec.Next(10)
+		_, err = distinctExec.Next(context.Background(), 10)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error while consuming distinct results: %v", err)
 			// it's ok if we got results; call Next again until EOF
 		}
 		// subsequent Next should return EOF
-		_, err = distinctExec.Next(1)
+		_, err = distinctExec.Next(context.Background(), 1)
 		if !errors.Is(err, io.EOF) {
 			t.Fatalf("expected EOF after consuming distinct results, got %v", err)
 		}
@@ -826,3 +860,50 @@ func TestJoinArrays(t *testing.T) {
 		}
 	})
 }
+
+// sequenceSource counts from 0 to total-1 in batches of the size asked for
+type sequenceSource struct {
+	next, total int64
+}
+
+func (s *sequenceSource) Next(_ context.Context, n uint16) (*operators.RecordBatch, error) {
+	if s.next >= s.total {
+		return nil, io.EOF
+	}
+	b := array.NewInt64Builder(memory.NewGoAllocator())
+	defer b.Release()
+	for ; s.next < s.total && b.Len() < int(n); s.next++ {
+		b.Append(s.next)
+	}
+	arr := b.NewArray()
+	return &operators.RecordBatch{Schema: s.Schema(), Columns: []arrow.Array{arr}, RowCount: uint64(arr.Len())}, nil
+}
+func (s *sequenceSource) Schema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil)
+}
+func (s *sequenceSource) Close() error { return nil }
+
+func TestLimitExec_PastBatchSize(t *testing.T) {
+	lim, _ := NewLimitExec(&sequenceSource{total: 70000}, 66000)
+	defer func() { _ = lim.Close() }()
+	var total uint64
+	var batches int
+	for {
+		rb, err := lim.Next(context.Background(), math.MaxUint16)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if last := rb.Columns[0].(*array.Int64).Value(int(rb.RowCount) - 1); last != int64(total+rb.RowCount-1) {
+			t.Fatalf("expected the rows in order, batch ends with %d", last)
+		}
+		total += rb.RowCount
+		batches++
+		operators.ReleaseArrays(rb.Columns)
+	}
+	if total != 66000 || batches != 2 {
+		t.Fatalf("expected 66000 rows over 2 batches, got %d rows over %d", total, batches)
+	}
+}
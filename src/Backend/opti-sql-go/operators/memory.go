@@ -0,0 +1,312 @@
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v17/arrow/compute"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// memory accounting: every query gets a QueryMemory that hands out one tracked allocator per
+// operator. the allocators count the bytes that are in use (allocated and not freed yet) for their
+// operator and for the whole query. going past the hard limit fails the query with an
+// OutOfMemoryError at the next operator boundary (NextBatch), going past the spill threshold is how blocking operators know they should
+// start writing to disk. the query's QueryMemory travels in the ctx handed to Next, operators get
+// their allocator with Allocator(ctx, op) and pass the returned ctx on to Expr and compute so
+// everything they evaluate is counted against them as well
+
+var (
+	_ = (memory.Allocator)(&TrackedAllocator{})
+
+	ErrOutOfMemory = errors.New("query ran out of memory")
+)
+
+// OutOfMemoryError is returned when an allocation would take a query past its limit
+type OutOfMemoryError struct {
+	Operator  string // the operator that asked for the memory
+	Requested int64
+	InUse     int64 // bytes the query had in use when it asked
+	Limit     int64
+}
+
+func (e *OutOfMemoryError) Error() string {
+	return fmt.Sprintf("query ran out of memory: %s asked for %s with %s of %s already in use",
+		e.Operator, formatBytes(e.Requested), formatBytes(e.InUse), formatBytes(e.Limit))
+}
+
+func (e *OutOfMemoryError) Is(target error) bool { return target == ErrOutOfMemory }
+
+// QueryMemory tracks the memory of a single query
+type QueryMemory struct {
+	parent  memory.Allocator
+	limit   int64 // hard limit, 0 for none
+	spillAt int64 // blocking operators should spill past this, 0 to never spill
+
+	inUse  atomic.Int64
+	peak   atomic.Int64
+	failed atomic.Pointer[OutOfMemoryError] // the first allocation that went past the limit
+
+	mu  sync.Mutex
+	ops map[Operator]*TrackedAllocator
+}
+
+// NewQueryMemory tracks memory allocated from parent. limit is the most bytes the query may have in
+// use at once and spillAt the point from which ShouldSpill says yes, 0 turns either off
+func NewQueryMemory(parent memory.Allocator, limit, spillAt int64) *QueryMemory {
+	if parent == nil {
+		parent = memory.DefaultAllocator
+	}
+	return &QueryMemory{
+		parent:  parent,
+		limit:   limit,
+		spillAt: spillAt,
+		ops:     make(map[Operator]*TrackedAllocator),
+	}
+}
+
+func (q *QueryMemory) InUse() int64 { return q.inUse.Load() }
+func (q *QueryMemory) Peak() int64  { return q.peak.Load() }
+func (q *QueryMemory) Limit() int64 { return q.limit }
+
+// Err is the OutOfMemoryError of the first allocation that took the query past its limit, nil
+// while it is within it
+func (q *QueryMemory) Err() error {
+	if oom := q.failed.Load(); oom != nil {
+		return oom
+	}
+	return nil
+}
+
+// ShouldSpill is true once the query holds more than the spill threshold
+func (q *QueryMemory) ShouldSpill() bool {
+	return q.spillAt > 0 && q.inUse.Load() >= q.spillAt
+}
+
+// WouldSpill is true when n more bytes would take the query past the spill threshold
+func (q *QueryMemory) WouldSpill(n int64) bool {
+	return q.spillAt > 0 && q.inUse.Load()+n >= q.spillAt
+}
+
+// Operator is op's tracked allocator, created on first use
+func (q *QueryMemory) Operator(op Operator) *TrackedAllocator {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.ops[op]
+	if !ok {
+		t = &TrackedAllocator{query: q, name: ExplainOperator(op).Type}
+		q.ops[op] = t
+	}
+	return t
+}
+
+// OperatorMemory is what one operator of the query allocated
+type OperatorMemory struct {
+	Operator string
+	InUse    int64
+	Peak     int64
+}
+
+// Operators lists the memory of every operator that allocated something, largest peak first
+func (q *QueryMemory) Operators() []OperatorMemory {
+	q.mu.Lock()
+	out := make([]OperatorMemory, 0, len(q.ops))
+	for _, t := range q.ops {
+		out = append(out, OperatorMemory{Operator: t.name, InUse: t.InUse(), Peak: t.Peak()})
+	}
+	q.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Peak != out[j].Peak {
+			return out[i].Peak > out[j].Peak
+		}
+		return out[i].Operator < out[j].Operator
+	})
+	return out
+}
+
+// reserve counts n more bytes against the query, failing when that takes it past the limit or
+// once an earlier allocation did
+func (q *QueryMemory) reserve(name string, n int64) error {
+	if err := q.Err(); err != nil {
+		return err
+	}
+	inUse := q.inUse.Add(n)
+	if q.limit > 0 && inUse > q.limit {
+		q.inUse.Add(-n)
+		return &OutOfMemoryError{Operator: name, Requested: n, InUse: inUse - n, Limit: q.limit}
+	}
+	storeMax(&q.peak, inUse)
+	return nil
+}
+
+// overdraw counts n bytes the query had to hand out past its limit and records oom as the reason
+// the query fails
+func (q *QueryMemory) overdraw(n int64, oom *OutOfMemoryError) {
+	q.failed.CompareAndSwap(nil, oom)
+	storeMax(&q.peak, q.inUse.Add(n))
+}
+
+func (q *QueryMemory) release(n int64) { q.inUse.Add(-n) }
+
+// TrackedAllocator is the allocator of a single operator of a query. arrow's allocator interface
+// has no way to return an error and compute kernels allocate on goroutines of their own, so going
+// past the limit still hands out the memory but records an *OutOfMemoryError that NextBatch
+// fails the query with once the operator returns
+type TrackedAllocator struct {
+	query *QueryMemory
+	name  string
+	inUse atomic.Int64
+	peak  atomic.Int64
+}
+
+func (t *TrackedAllocator) Allocate(size int) []byte {
+	t.reserve(int64(size))
+	return t.query.parent.Allocate(size)
+}
+
+func (t *TrackedAllocator) Reallocate(size int, b []byte) []byte {
+	diff := int64(size - len(b))
+	if diff > 0 {
+		t.reserve(diff)
+	}
+	out := t.query.parent.Reallocate(size, b)
+	if diff < 0 {
+		t.release(-diff)
+	}
+	return out
+}
+
+func (t *TrackedAllocator) Free(b []byte) {
+	size := int64(len(b))
+	t.query.parent.Free(b)
+	t.release(size)
+}
+
+func (t *TrackedAllocator) InUse() int64 { return t.inUse.Load() }
+func (t *TrackedAllocator) Peak() int64  { return t.peak.Load() }
+
+// ShouldSpill is true once the query this operator belongs to holds more than the spill threshold
+func (t *TrackedAllocator) ShouldSpill() bool { return t.query.ShouldSpill() }
+
+// Grow counts n bytes the operator holds outside of arrow buffers (hash tables, boxed values), they
+// go towards the spill threshold and the limit like its arrays do. unlike Allocate it returns going
+// past the limit as an error right away, as well as any earlier allocation of the query that did
+func (t *TrackedAllocator) Grow(n int64) error {
+	if err := t.query.reserve(t.name, n); err != nil {
+		return err
+	}
+	storeMax(&t.peak, t.inUse.Add(n))
+	return nil
+}
+
+// Shrink gives back n bytes counted with Grow
+func (t *TrackedAllocator) Shrink(n int64) { t.release(n) }
+
+func (t *TrackedAllocator) reserve(n int64) {
+	if err := t.query.reserve(t.name, n); err != nil {
+		oom, _ := err.(*OutOfMemoryError)
+		t.query.overdraw(n, oom)
+	}
+	storeMax(&t.peak, t.inUse.Add(n))
+}
+
+func (t *TrackedAllocator) release(n int64) {
+	t.inUse.Add(-n)
+	t.query.release(n)
+}
+
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+type queryMemoryKey struct{}
+
+// WithQueryMemory attaches q to ctx, every operator run with the returned ctx allocates through q
+func WithQueryMemory(ctx context.Context, q *QueryMemory) context.Context {
+	return context.WithValue(ctx, queryMemoryKey{}, q)
+}
+
+// QueryMemoryFrom is the query's memory tracker, nil when the query isn't tracked
+func QueryMemoryFrom(ctx context.Context) *QueryMemory {
+	q, _ := ctx.Value(queryMemoryKey{}).(*QueryMemory)
+	return q
+}
+
+// Allocator is the allocator op should build its arrays with, together with a ctx that makes
+// Expr and compute kernels allocate through it too. without a QueryMemory in ctx (tests, planning)
+// it is the default allocator
+func Allocator(ctx context.Context, op Operator) (context.Context, memory.Allocator) {
+	q := QueryMemoryFrom(ctx)
+	if q == nil {
+		return ctx, memory.DefaultAllocator
+	}
+	mem := q.Operator(op)
+	return compute.WithAllocator(ctx, mem), mem
+}
+
+// ShouldSpill is asked by blocking operators (sorts, hash tables) before they hold on to another
+// batch. it is always false when the query isn't tracked
+func ShouldSpill(ctx context.Context) bool {
+	q := QueryMemoryFrom(ctx)
+	return q != nil && q.ShouldSpill()
+}
+
+// WouldSpill is asked before reading n bytes back from disk, so something too big to hold can be
+// split again without loading it first. always false when the query isn't tracked
+func WouldSpill(ctx context.Context, n int64) bool {
+	q := QueryMemoryFrom(ctx)
+	return q != nil && q.WouldSpill(n)
+}
+
+// Grow counts n bytes of Go memory against mem when it is a tracked allocator (see
+// TrackedAllocator.Grow), so operators can hand it the allocator they got from Allocator either way
+func Grow(mem memory.Allocator, n int64) error {
+	t, ok := mem.(*TrackedAllocator)
+	if !ok || n == 0 {
+		return nil
+	}
+	return t.Grow(n)
+}
+
+// Shrink gives back n bytes counted with Grow
+func Shrink(mem memory.Allocator, n int64) {
+	if t, ok := mem.(*TrackedAllocator); ok && n != 0 {
+		t.Shrink(n)
+	}
+}
+
+// MemoryError is the error the query in ctx has to fail with because it went past its memory
+// limit, nil while it is within it or isn't tracked
+func MemoryError(ctx context.Context) error {
+	if q := QueryMemoryFrom(ctx); q != nil {
+		return q.Err()
+	}
+	return nil
+}
+
+// NextBatch is op.Next that fails with the query's OutOfMemoryError once anything op ran went past
+// the memory limit. it is used by whatever drives the root of a query and by operators draining
+// their input, so a query stops at the first batch boundary after running out
+func NextBatch(ctx context.Context, op Operator, n uint16) (*RecordBatch, error) {
+	batch, err := op.Next(ctx, n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if oom := MemoryError(ctx); oom != nil {
+		if batch != nil {
+			ReleaseArrays(batch.Columns)
+		}
+		return nil, oom
+	}
+	return batch, err
+}
@@ -0,0 +1,163 @@
+package operators
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// buildingOp builds a fresh int64 column of size rows with its allocator on every call
+type buildingOp struct {
+	schema *arrow.Schema
+	size   int
+	left   int
+}
+
+func (b *buildingOp) Next(ctx context.Context, _ uint16) (*RecordBatch, error) {
+	if b.left == 0 {
+		return nil, io.EOF
+	}
+	b.left--
+	_, mem := Allocator(ctx, b)
+	ib := array.NewInt64Builder(mem)
+	defer ib.Release()
+	for i := 0; i < b.size; i++ {
+		ib.Append(int64(i))
+	}
+	return &RecordBatch{Schema: b.schema, Columns: []arrow.Array{ib.NewArray()}, RowCount: uint64(b.size)}, nil
+}
+func (b *buildingOp) Schema() *arrow.Schema { return b.schema }
+func (b *buildingOp) Close() error          { return nil }
+
+func TestQueryMemory(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil)
+
+	t.Run("bytes are counted per operator and per query", func(t *testing.T) {
+		checked := memory.NewCheckedAllocator(memory.NewGoAllocator())
+		q := NewQueryMemory(checked, 0, 0)
+		ctx := WithQueryMemory(context.Background(), q)
+		a := &buildingOp{schema: schema, size: 100, left: 1}
+		b := &buildingOp{schema: schema, size: 10, left: 1}
+		batchA, err := NextBatch(ctx, a, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batchB, err := NextBatch(ctx, b, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q.InUse() != int64(checked.CurrentAlloc()) || q.InUse() == 0 {
+			t.Fatalf("expected the query to hold %d bytes, got %d", checked.CurrentAlloc(), q.InUse())
+		}
+		ops := q.Operators()
+		if len(ops) != 2 || ops[0].Operator != "operators.buildingOp" || ops[0].InUse+ops[1].InUse != q.InUse() || ops[0].Peak <= ops[1].Peak {
+			t.Fatalf("unexpected operator memory %+v", ops)
+		}
+		held := q.InUse()
+		ReleaseArrays(batchA.Columns)
+		ReleaseArrays(batchB.Columns)
+		if q.InUse() != 0 || checked.CurrentAlloc() != 0 {
+			t.Fatalf("expected everything to be freed, query holds %d", q.InUse())
+		}
+		if q.Peak() < held {
+			t.Fatalf("expected the peak to cover both batches (%d), got %d", held, q.Peak())
+		}
+	})
+	t.Run("going past the limit fails the query", func(t *testing.T) {
+		q := NewQueryMemory(memory.NewGoAllocator(), 512, 0)
+		ctx := WithQueryMemory(context.Background(), q)
+		_, err := NextBatch(ctx, &buildingOp{schema: schema, size: 1000, left: 1}, 10)
+		var oom *OutOfMemoryError
+		if !errors.Is(err, ErrOutOfMemory) || !errors.As(err, &oom) {
+			t.Fatalf("expected an out of memory error, got %v", err)
+		}
+		if oom.Operator != "operators.buildingOp" || oom.Limit != 512 {
+			t.Fatalf("unexpected error %+v", oom)
+		}
+		// the batch that went past the limit is released and every later allocation fails too
+		if q.InUse() != 0 || q.Peak() <= q.Limit() {
+			t.Fatalf("expected the batch to be released, %d in use with a peak of %d", q.InUse(), q.Peak())
+		}
+		if err := q.Err(); !errors.Is(err, ErrOutOfMemory) {
+			t.Fatalf("expected the query to stay failed, got %v", err)
+		}
+		_, mem := Allocator(ctx, &buildingOp{})
+		if err := Grow(mem, 1); !errors.Is(err, ErrOutOfMemory) {
+			t.Fatalf("expected growing a failed query to fail, got %v", err)
+		}
+	})
+	t.Run("spill threshold", func(t *testing.T) {
+		q := NewQueryMemory(nil, 0, 256)
+		ctx := WithQueryMemory(context.Background(), q)
+		if ShouldSpill(ctx) {
+			t.Fatalf("nothing allocated yet")
+		}
+		batch, err := NextBatch(ctx, &buildingOp{schema: schema, size: 100, left: 1}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ShouldSpill(ctx) {
+			t.Fatalf("expected to spill with %d bytes in use", q.InUse())
+		}
+		ReleaseArrays(batch.Columns)
+		if ShouldSpill(ctx) {
+			t.Fatalf("expected not to spill once freed")
+		}
+		if ShouldSpill(context.Background()) {
+			t.Fatalf("untracked queries never spill")
+		}
+	})
+	t.Run("go memory counts once grown", func(t *testing.T) {
+		q := NewQueryMemory(nil, 1024, 256)
+		ctx := WithQueryMemory(context.Background(), q)
+		_, mem := Allocator(ctx, &buildingOp{})
+		if err := Grow(mem, 300); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ShouldSpill(ctx) || mem.(*TrackedAllocator).InUse() != 300 {
+			t.Fatalf("expected 300 bytes to count towards spilling, query holds %d", q.InUse())
+		}
+		if err := Grow(mem, 1000); !errors.Is(err, ErrOutOfMemory) {
+			t.Fatalf("expected an out of memory error, got %v", err)
+		}
+		Shrink(mem, 300)
+		if q.InUse() != 0 || q.Peak() != 300 {
+			t.Fatalf("expected everything to be given back, query holds %d with a peak of %d", q.InUse(), q.Peak())
+		}
+		if err := Grow(memory.DefaultAllocator, 1<<40); err != nil {
+			t.Fatalf("untracked queries never run out of memory, got %v", err)
+		}
+	})
+	t.Run("untracked queries use the default allocator", func(t *testing.T) {
+		ctx := context.Background()
+		got, mem := Allocator(ctx, &buildingOp{})
+		if got != ctx || mem != memory.DefaultAllocator {
+			t.Fatalf("expected ctx and the default allocator back")
+		}
+	})
+	t.Run("allocations on other goroutines fail the query", func(t *testing.T) {
+		q := NewQueryMemory(nil, 512, 0)
+		ctx := WithQueryMemory(context.Background(), q)
+		op := &buildingOp{schema: schema}
+		_, mem := Allocator(ctx, op)
+		// compute kernels allocate on goroutines of their own, nothing may panic there
+		done := make(chan []byte)
+		go func() { done <- mem.Allocate(1024) }()
+		buf := <-done
+		if err := MemoryError(ctx); !errors.Is(err, ErrOutOfMemory) {
+			t.Fatalf("expected the query to have run out of memory, got %v", err)
+		}
+		if _, err := NextBatch(ctx, op, 10); !errors.Is(err, ErrOutOfMemory) {
+			t.Fatalf("expected the next batch to fail, got %v", err)
+		}
+		mem.Free(buf)
+		if q.InUse() != 0 {
+			t.Fatalf("expected the overdrawn bytes to be given back, %d in use", q.InUse())
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package operators
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/compute"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// hash partitioning, shared by everything that splits rows by key: the exchange spreading rows over
+// its workers and the hash join spilling its inputs. rows with equal keys always land in the same
+// partition, no matter which batch they came in with
+
+// RowKey is the key of row made of the values of keys, nulls hash alike
+func RowKey(keys []arrow.Array, row int) string {
+	var b strings.Builder
+	for i, col := range keys {
+		if i > 0 {
+			b.WriteByte('|')
+		}
+		if col.IsNull(row) {
+			b.WriteString("NULL")
+			continue
+		}
+		b.WriteString(col.ValueStr(row))
+	}
+	return b.String()
+}
+
+// PartitionRows splits rows 0..rows-1 of keys over n partitions by the hash of their RowKey and
+// returns the row indices of every partition. seed salts the hash, a partition split again with
+// another seed spreads over all of the new partitions
+func PartitionRows(keys []arrow.Array, rows, n int, seed uint64) [][]int32 {
+	parts := make([][]int32, n)
+	h := fnv.New64a()
+	for r := 0; r < rows; r++ {
+		h.Reset()
+		_, _ = h.Write([]byte(RowKey(keys, r)))
+		p := mixHash(h.Sum64(), seed) % uint64(n)
+		parts[p] = append(parts[p], int32(r))
+	}
+	return parts
+}
+
+// mixHash spreads h over all 64 bits (murmur3's finalizer) after salting it with seed. fnv alone
+// barely changes its low bits, neither between keys nor between seeds
+func mixHash(h, seed uint64) uint64 {
+	h ^= seed * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// TakeRows copies the rows at idx out of every column of cols
+func TakeRows(ctx context.Context, mem memory.Allocator, cols []arrow.Array, idx []int32) ([]arrow.Array, error) {
+	b := array.NewInt32Builder(mem)
+	b.AppendValues(idx, nil)
+	idxArr := b.NewArray()
+	b.Release()
+	defer idxArr.Release()
+	out := make([]arrow.Array, len(cols))
+	for i, col := range cols {
+		taken, err := compute.TakeArray(ctx, col, idxArr)
+		if err != nil {
+			ReleaseArrays(out)
+			return nil, err
+		}
+		out[i] = taken
+	}
+	return out, nil
+}
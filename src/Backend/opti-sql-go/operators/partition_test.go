@@ -0,0 +1,92 @@
+package operators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+func TestPartitionRows(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	b := array.NewInt64Builder(mem)
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			b.AppendNull()
+			continue
+		}
+		b.Append(int64(i % 37))
+	}
+	keys := []arrow.Array{b.NewArray()}
+	defer ReleaseArrays(keys)
+
+	partitionOf := func(parts [][]int32) map[int32]int {
+		out := make(map[int32]int)
+		for p, rows := range parts {
+			for _, r := range rows {
+				out[r] = p
+			}
+		}
+		return out
+	}
+
+	t.Run("equal keys share a partition", func(t *testing.T) {
+		parts := PartitionRows(keys, 1000, 8, 0)
+		of := partitionOf(parts)
+		if len(of) != 1000 {
+			t.Fatalf("expected every row in exactly one partition, got %d", len(of))
+		}
+		byKey := make(map[string]int)
+		for r := int32(0); r < 1000; r++ {
+			key := RowKey(keys, int(r))
+			if p, ok := byKey[key]; ok && p != of[r] {
+				t.Fatalf("key %s is in partitions %d and %d", key, p, of[r])
+			}
+			byKey[key] = of[r]
+		}
+		used := 0
+		for _, rows := range parts {
+			if len(rows) > 0 {
+				used++
+			}
+		}
+		if used < 6 {
+			t.Fatalf("expected 38 keys to spread over the partitions, only %d got rows", used)
+		}
+	})
+	t.Run("another seed splits a partition again", func(t *testing.T) {
+		first := PartitionRows(keys, 1000, 4, 0)[0]
+		sub := make([][]int32, 4)
+		for p, rows := range PartitionRows(keys, 1000, 4, 1) {
+			for _, r := range rows {
+				for _, f := range first {
+					if f == r {
+						sub[p] = append(sub[p], r)
+					}
+				}
+			}
+		}
+		used := 0
+		for _, rows := range sub {
+			if len(rows) > 0 {
+				used++
+			}
+		}
+		if used < 2 {
+			t.Fatalf("expected the rows of a partition to spread with a new seed")
+		}
+	})
+	t.Run("take rows", func(t *testing.T) {
+		cols, err := TakeRows(context.Background(), mem, keys, []int32{1, 10, 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer ReleaseArrays(cols)
+		got := cols[0].(*array.Int64)
+		if got.Len() != 3 || got.Value(0) != 1 || !got.IsNull(1) || got.Value(2) != 2 {
+			t.Fatalf("unexpected rows %v", got)
+		}
+	})
+}
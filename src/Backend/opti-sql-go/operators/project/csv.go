@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 
 var (
 	_ = (operators.Operator)(&CSVSource{})
+	_ = (operators.Explainer)(&CSVSource{})
 )
 
 type CSVSource struct {
@@ -38,13 +40,17 @@ func NewProjectCSVLeaf(source io.Reader) (*CSVSource, error) {
 	return proj, err
 }
 
-func (csvS *CSVSource) Next(n uint16) (*operators.RecordBatch, error) {
+func (csvS *CSVSource) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if csvS.done {
 		return nil, io.EOF
 	}
 
 	// 1. Create builders
-	builders := csvS.initBuilders()
+	_, mem := operators.Allocator(ctx, csvS)
+	builders := csvS.initBuilders(mem)
 
 	rowsRead := uint16(0)
 
@@ -93,16 +99,19 @@ func (csvS *CSVSource) Close() error {
 	csvS.done = true
 	return nil
 }
+func (csvS *CSVSource) Explain() operators.Explanation {
+	return operators.Explanation{Type: "CSVSource"}
+}
 
 func (csvS *CSVSource) Schema() *arrow.Schema {
 	return csvS.schema
 }
-func (csvS *CSVSource) initBuilders() []array.Builder {
+func (csvS *CSVSource) initBuilders(mem memory.Allocator) []array.Builder {
 	fields := csvS.schema.Fields()
 	builders := make([]array.Builder, len(fields))
 
 	for i, f := range fields {
-		builders[i] = array.NewBuilder(memory.DefaultAllocator, f.Type)
+		builders[i] = array.NewBuilder(mem, f.Type)
 	}
 
 	return builders
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -65,7 +66,7 @@ func TestCsvNext(t *testing.T) {
 	if err != nil {
 		t.Errorf("Failed to create ProjectCSVLeaf: %v", err)
 	}
-	rBatch, err := csvLeaf.Next(10)
+	rBatch, err := csvLeaf.Next(context.Background(), 10)
 	if err != nil {
 		t.Errorf("Failed to read next batch from CSV: %v", err)
 	}
@@ -388,7 +389,7 @@ func TestNextFunction(t *testing.T) {
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -466,7 +467,7 @@ func TestNextFunction(t *testing.T) {
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -506,7 +507,7 @@ NULL
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -532,7 +533,7 @@ NULL
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -559,7 +560,7 @@ false
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -590,7 +591,7 @@ false
 		}
 
 		// First batch of 2
-		batch1, err := proj.Next(2)
+		batch1, err := proj.Next(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("First Next failed: %v", err)
 		}
@@ -599,7 +600,7 @@ false
 		}
 
 		// Second batch of 3
-		batch2, err := proj.Next(3)
+		batch2, err := proj.Next(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("Second Next failed: %v", err)
 		}
@@ -608,7 +609,7 @@ false
 		}
 
 		// Third batch - should get remaining 1 row
-		batch3, err := proj.Next(10)
+		batch3, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Third Next failed: %v", err)
 		}
@@ -617,7 +618,7 @@ false
 		}
 
 		// Fourth batch - should return EOF
-		_, err = proj.Next(10)
+		_, err = proj.Next(context.Background(), 10)
 		if err != io.EOF {
 			t.Errorf("Expected EOF, got: %v", err)
 		}
@@ -634,7 +635,7 @@ false
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(3)
+		batch, err := proj.Next(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -643,7 +644,7 @@ false
 		}
 
 		// Next call should return EOF
-		_, err = proj.Next(1)
+		_, err = proj.Next(context.Background(), 1)
 		if err != io.EOF {
 			t.Errorf("Expected EOF after reading all data, got: %v", err)
 		}
@@ -659,13 +660,13 @@ val1`
 		}
 
 		// Read the only row
-		_, err = proj.Next(10)
+		_, err = proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("First Next failed: %v", err)
 		}
 
 		// Second call when no data remains and rowsRead == 0
-		_, err = proj.Next(10)
+		_, err = proj.Next(context.Background(), 10)
 		if err != io.EOF {
 			t.Errorf("Expected EOF when no data left, got: %v", err)
 		}
@@ -686,16 +687,16 @@ val1`
 		}
 
 		// Read all data
-		_, _ = proj.Next(10)
+		_, _ = proj.Next(context.Background(), 10)
 
 		// Hit EOF and set done
-		_, err = proj.Next(10)
+		_, err = proj.Next(context.Background(), 10)
 		if err != io.EOF {
 			t.Fatalf("Expected EOF, got: %v", err)
 		}
 
 		// Call again - should immediately return EOF due to done flag
-		_, err = proj.Next(10)
+		_, err = proj.Next(context.Background(), 10)
 		if err != io.EOF {
 			t.Errorf("Expected EOF on subsequent call when done=true, got: %v", err)
 		}
@@ -714,7 +715,7 @@ c`
 
 		// Read one row at a time
 		for i := 0; i < 3; i++ {
-			batch, err := proj.Next(1)
+			batch, err := proj.Next(context.Background(), 1)
 			if err != nil {
 				t.Fatalf("Next call %d failed: %v", i+1, err)
 			}
@@ -734,7 +735,7 @@ c`
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(1000)
+		batch, err := proj.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -755,7 +756,7 @@ c`
 		}
 
 		// Request 10 rows, but only 3 exist
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -777,7 +778,7 @@ false`
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -809,7 +810,7 @@ false`
 			t.Fatalf("NewProjectCSVLeaf failed: %v", err)
 		}
 
-		batch, err := proj.Next(10)
+		batch, err := proj.Next(context.Background(), 10)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -853,7 +854,7 @@ func TestIntegrationWithRealFile(t *testing.T) {
 		batchCount := 0
 
 		for {
-			batch, err := proj.Next(10)
+			batch, err := proj.Next(context.Background(), 10)
 			if err == io.EOF {
 				break
 			}
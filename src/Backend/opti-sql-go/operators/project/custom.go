@@ -1,9 +1,11 @@
 package project
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"opti-sql-go/operators"
+	"strconv"
 
 	"github.com/apache/arrow/go/v15/arrow/memory"
 	"github.com/apache/arrow/go/v17/arrow"
@@ -12,6 +14,7 @@ import (
 
 var (
 	_ = (operators.Operator)(&InMemorySource{})
+	_ = (operators.Explainer)(&InMemorySource{})
 )
 
 // in memory format just for the ease of testing
@@ -102,7 +105,10 @@ func NewInMemoryProjectExecFromArrays(names []string, arrays []arrow.Array) (*In
 	}, nil
 }
 
-func (ms *InMemorySource) Next(n uint16) (*operators.RecordBatch, error) {
+func (ms *InMemorySource) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if len(ms.columns) == 0 || ms.pos >= uint16(ms.columns[0].Len()) {
 		return nil, io.EOF // EOF
 	}
@@ -135,6 +141,16 @@ func (ms *InMemorySource) Close() error {
 	}
 	return nil
 }
+func (ms *InMemorySource) Explain() operators.Explanation {
+	rows := 0
+	if len(ms.columns) > 0 {
+		rows = ms.columns[0].Len()
+	}
+	return operators.Explanation{
+		Type:   "InMemorySource",
+		Params: map[string]string{"rows": strconv.Itoa(rows)},
+	}
+}
 func (ms *InMemorySource) Schema() *arrow.Schema {
 	return ms.schema
 }
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"io"
 	"testing"
 
@@ -748,7 +749,7 @@ func TestNext(t *testing.T) {
 		}
 
 		// Read all 10 rows in one batch
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -766,7 +767,7 @@ func TestNext(t *testing.T) {
 		}
 
 		// Next call should return EOF
-		_, err = proj.Next(1)
+		_, err = proj.Next(context.Background(), 1)
 		if err != io.EOF {
 			t.Errorf("Expected EOF after reading all data, got: %v", err)
 		}
@@ -789,7 +790,7 @@ func TestNext(t *testing.T) {
 
 		// Iterate until EOF
 		for {
-			batch, err := proj.Next(3)
+			batch, err := proj.Next(context.Background(), 3)
 			if err == io.EOF {
 				break
 			}
@@ -840,7 +841,7 @@ func TestNext(t *testing.T) {
 		}
 
 		// Read 2 rows at a time
-		batch1, err := proj.Next(2)
+		batch1, err := proj.Next(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("First Next failed: %v", err)
 		}
@@ -848,7 +849,7 @@ func TestNext(t *testing.T) {
 			t.Errorf("First batch: expected 2 rows, got %d", batch1.Columns[0].Len())
 		}
 
-		batch2, err := proj.Next(2)
+		batch2, err := proj.Next(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("Second Next failed: %v", err)
 		}
@@ -859,7 +860,7 @@ func TestNext(t *testing.T) {
 		// Continue reading until EOF
 		rowsRemaining := 0
 		for {
-			batch, err := proj.Next(2)
+			batch, err := proj.Next(context.Background(), 2)
 			if err == io.EOF {
 				break
 			}
@@ -893,7 +894,7 @@ func TestNext(t *testing.T) {
 
 		totalRows := 0
 		for {
-			batch, err := proj.Next(5)
+			batch, err := proj.Next(context.Background(), 5)
 			if err == io.EOF {
 				break
 			}
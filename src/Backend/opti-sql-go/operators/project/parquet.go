@@ -7,6 +7,7 @@ import (
 	"io"
 	"opti-sql-go/config"
 	"opti-sql-go/operators"
+	"strings"
 
 	"github.com/apache/arrow/go/v17/arrow"
 	"github.com/apache/arrow/go/v17/arrow/array"
@@ -18,6 +19,7 @@ import (
 
 var (
 	_      = (operators.Operator)(&ParquetSource{})
+	_      = (operators.Explainer)(&ParquetSource{})
 	Config = config.GetConfig()
 )
 
@@ -111,10 +113,15 @@ func NewParquetSourcePushDown(r parquet.ReaderAtSeeker, columns []string) (*Parq
 }
 
 // double check that this return exactly n rows in a column.
-func (ps *ParquetSource) Next(n uint16) (*operators.RecordBatch, error) {
-	if ps.reader == nil || ps.done || !ps.reader.Next() {
+func (ps *ParquetSource) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ps.reader == nil || ps.done {
 		return nil, io.EOF
 	}
+	// the reader decodes pages on its own goroutines so only the combined columns are counted
+	_, mem := operators.Allocator(ctx, ps)
 	columns := make([]arrow.Array, len(ps.schema.Fields()))
 	curRow := 0
 	for curRow < int(n) && ps.reader.Next() {
@@ -138,7 +145,7 @@ func (ps *ParquetSource) Next(n uint16) (*operators.RecordBatch, error) {
 			}
 
 			// Otherwise combine existing + new batch column
-			combined := CombineArray(existing, batchCol)
+			combined := CombineArray(existing, batchCol, mem)
 
 			// Replace
 			columns[colIdx] = combined
@@ -150,6 +157,14 @@ func (ps *ParquetSource) Next(n uint16) (*operators.RecordBatch, error) {
 
 		curRow += numRows
 	}
+	if curRow == 0 {
+		// reader is exhausted, surface a read error instead of a silent EOF
+		ps.done = true
+		if err := ps.reader.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
 	return &operators.RecordBatch{
 		Schema:   ps.schema,
 		Columns:  columns,
@@ -161,12 +176,19 @@ func (ps *ParquetSource) Close() error {
 	ps.reader = nil
 	return nil
 }
+func (ps *ParquetSource) Explain() operators.Explanation {
+	ex := operators.Explanation{Type: "ParquetSource"}
+	if len(ps.projectionPushDown) > 0 {
+		ex.Params = map[string]string{"projection": "[" + strings.Join(ps.projectionPushDown, ", ") + "]"}
+	}
+	return ex
+}
 func (ps *ParquetSource) Schema() *arrow.Schema {
 	return ps.schema
 }
 
 // append arr2 to arr1 so (arr1 + arr2) = arr1-arr2
-func CombineArray(a1, a2 arrow.Array) arrow.Array {
+func CombineArray(a1, a2 arrow.Array, mem memory.Allocator) arrow.Array {
 	if a1 == nil {
 		return a2
 	}
@@ -174,7 +196,6 @@ func CombineArray(a1, a2 arrow.Array) arrow.Array {
 		return a1
 	}
 
-	mem := memory.NewGoAllocator()
 	dt := a1.DataType()
 
 	switch dt.ID() {
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -134,7 +135,7 @@ func TestParquetClose(t *testing.T) {
 	if source.reader != nil {
 		t.Errorf("Expected reader to be nil after Close, but it is not")
 	}
-	_, err = source.Next(1)
+	_, err = source.Next(context.Background(), 1)
 	if err != io.EOF {
 		t.Error("expected reader to return io.EOF")
 	}
@@ -148,7 +149,7 @@ func TestRunToEnd(t *testing.T) {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 	for {
-		rc, err := source.Next(1024 * 8)
+		rc, err := source.Next(context.Background(), 1024*8)
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -167,7 +168,7 @@ func TestParquetRead(t *testing.T) {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 	//	batchSize := uint16(10)
-	rc, err := source.Next(uint16(15))
+	rc, err := source.Next(context.Background(), uint16(15))
 	if err != nil {
 		t.Fatalf("Unexpected error on Next: %v", err)
 	}
@@ -197,7 +198,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		ib2.Append(2)
 		ib2.Append(3)
 		a2 := ib2.NewArray().(*array.Int8)
-		comb := CombineArray(a1, a2).(*array.Int8)
+		comb := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Int8)
 		if comb.Len() != a1.Len()+a2.Len() {
 			t.Fatalf("int8 combined length wrong")
 		}
@@ -217,7 +218,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		i16b2 := array.NewInt16Builder(mem)
 		i16b2.Append(30)
 		ia2 := i16b2.NewArray().(*array.Int16)
-		i16c := CombineArray(ia1, ia2).(*array.Int16)
+		i16c := CombineArray(ia1, ia2, memory.NewGoAllocator()).(*array.Int16)
 		if i16c.Len() != ia1.Len()+ia2.Len() {
 			t.Fatalf("int16 combined length")
 		}
@@ -233,7 +234,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		i32b2 := array.NewInt32Builder(mem)
 		i32b2.Append(2)
 		ia32_2 := i32b2.NewArray().(*array.Int32)
-		i32c := CombineArray(ia32_1, ia32_2).(*array.Int32)
+		i32c := CombineArray(ia32_1, ia32_2, memory.NewGoAllocator()).(*array.Int32)
 		if i32c.Len() != 2 {
 			t.Fatalf("int32 combined length")
 		}
@@ -249,7 +250,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		i64b2 := array.NewInt64Builder(mem)
 		i64b2.Append(200)
 		ia64_2 := i64b2.NewArray().(*array.Int64)
-		i64c := CombineArray(ia64_1, ia64_2).(*array.Int64)
+		i64c := CombineArray(ia64_1, ia64_2, memory.NewGoAllocator()).(*array.Int64)
 		if i64c.Len() != 2 {
 			t.Fatalf("int64 combined length")
 		}
@@ -265,7 +266,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		u8b2 := array.NewUint8Builder(mem)
 		u8b2.Append(9)
 		ua8_2 := u8b2.NewArray().(*array.Uint8)
-		u8c := CombineArray(ua8_1, ua8_2).(*array.Uint8)
+		u8c := CombineArray(ua8_1, ua8_2, memory.NewGoAllocator()).(*array.Uint8)
 		if u8c.Len() != 2 {
 			t.Fatalf("uint8 combined length")
 		}
@@ -281,7 +282,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		u16b2 := array.NewUint16Builder(mem)
 		u16b2.Append(32)
 		ua16_2 := u16b2.NewArray().(*array.Uint16)
-		u16c := CombineArray(ua16_1, ua16_2).(*array.Uint16)
+		u16c := CombineArray(ua16_1, ua16_2, memory.NewGoAllocator()).(*array.Uint16)
 		if u16c.Len() != 2 {
 			t.Fatalf("uint16 combined length")
 		}
@@ -297,7 +298,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		u32b2 := array.NewUint32Builder(mem)
 		u32b2.Append(2000)
 		ua32_2 := u32b2.NewArray().(*array.Uint32)
-		u32c := CombineArray(ua32_1, ua32_2).(*array.Uint32)
+		u32c := CombineArray(ua32_1, ua32_2, memory.NewGoAllocator()).(*array.Uint32)
 		if u32c.Len() != 2 {
 			t.Fatalf("uint32 combined length")
 		}
@@ -313,7 +314,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		u64b2 := array.NewUint64Builder(mem)
 		u64b2.Append(20000)
 		ua64_2 := u64b2.NewArray().(*array.Uint64)
-		u64c := CombineArray(ua64_1, ua64_2).(*array.Uint64)
+		u64c := CombineArray(ua64_1, ua64_2, memory.NewGoAllocator()).(*array.Uint64)
 		if u64c.Len() != 2 {
 			t.Fatalf("uint64 combined length")
 		}
@@ -329,7 +330,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		f32b2 := array.NewFloat32Builder(mem)
 		f32b2.Append(2.5)
 		fa32_2 := f32b2.NewArray().(*array.Float32)
-		f32c := CombineArray(fa32_1, fa32_2).(*array.Float32)
+		f32c := CombineArray(fa32_1, fa32_2, memory.NewGoAllocator()).(*array.Float32)
 		if f32c.Len() != 2 {
 			t.Fatalf("float32 combined length")
 		}
@@ -345,7 +346,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		f64b2 := array.NewFloat64Builder(mem)
 		f64b2.Append(6.28)
 		fa64_2 := f64b2.NewArray().(*array.Float64)
-		f64c := CombineArray(fa64_1, fa64_2).(*array.Float64)
+		f64c := CombineArray(fa64_1, fa64_2, memory.NewGoAllocator()).(*array.Float64)
 		if f64c.Len() != 2 {
 			t.Fatalf("float64 combined length")
 		}
@@ -362,7 +363,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		bb2 := array.NewBooleanBuilder(mem)
 		bb2.Append(false)
 		ba2 := bb2.NewArray().(*array.Boolean)
-		bc := CombineArray(ba1, ba2).(*array.Boolean)
+		bc := CombineArray(ba1, ba2, memory.NewGoAllocator()).(*array.Boolean)
 		if bc.Len() != ba1.Len()+ba2.Len() {
 			t.Fatalf("bool combined length")
 		}
@@ -379,7 +380,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		sb2 := array.NewStringBuilder(mem)
 		sb2.Append("two")
 		sa2 := sb2.NewArray().(*array.String)
-		sc := CombineArray(sa1, sa2).(*array.String)
+		sc := CombineArray(sa1, sa2, memory.NewGoAllocator()).(*array.String)
 		if sc.Len() != sa1.Len()+sa2.Len() {
 			t.Fatalf("string combined length")
 		}
@@ -395,7 +396,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		lsb2 := array.NewLargeStringBuilder(mem)
 		lsb2.Append("big2")
 		la2 := lsb2.NewArray().(*array.LargeString)
-		lc := CombineArray(la1, la2).(*array.LargeString)
+		lc := CombineArray(la1, la2, memory.NewGoAllocator()).(*array.LargeString)
 		if lc.Len() != la1.Len()+la2.Len() {
 			t.Fatalf("large string combined length")
 		}
@@ -411,7 +412,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		bbld2 := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
 		bbld2.Append([]byte("b"))
 		baBb2 := bbld2.NewArray().(*array.Binary)
-		bcbin := CombineArray(baBb1, baBb2).(*array.Binary)
+		bcbin := CombineArray(baBb1, baBb2, memory.NewGoAllocator()).(*array.Binary)
 		if bcbin.Len() != baBb1.Len()+baBb2.Len() {
 			t.Fatalf("binary combined length")
 		}
@@ -425,7 +426,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		bbld := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
 		bbld.Append([]byte("z"))
 		sec := bbld.NewArray().(*array.Binary)
-		got := CombineArray(nil, sec)
+		got := CombineArray(nil, sec, memory.NewGoAllocator())
 		if got == nil {
 			t.Fatalf("expected non-nil when a1 is nil")
 		}
@@ -439,7 +440,7 @@ func TestCombineArray_Cases(t *testing.T) {
 		bbld := array.NewBinaryBuilder(mem, arrow.BinaryTypes.Binary)
 		bbld.Append([]byte("y"))
 		first := bbld.NewArray().(*array.Binary)
-		got := CombineArray(first, nil)
+		got := CombineArray(first, nil, memory.NewGoAllocator())
 		if got == nil {
 			t.Fatalf("expected non-nil when a2 is nil")
 		}
@@ -466,7 +467,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(15)
 		a2 := b2.NewArray().(*array.Uint16)
 
-		out := CombineArray(a1, a2).(*array.Uint16)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Uint16)
 		if out.Len() != 5 {
 			t.Fatalf("uint16 expected len 5 got %d", out.Len())
 		}
@@ -487,7 +488,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.AppendNull()
 		b2.Append(23)
 		a2 := b2.NewArray().(*array.Int16)
-		out := CombineArray(a1, a2).(*array.Int16)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Int16)
 		if out.Len() != 4 {
 			t.Fatalf("int16 expected len 4 got %d", out.Len())
 		}
@@ -508,7 +509,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.AppendNull()
 		b2.Append(33)
 		a2 := b2.NewArray().(*array.Int32)
-		out := CombineArray(a1, a2).(*array.Int32)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Int32)
 		if !out.IsNull(1) || !out.IsNull(2) {
 			t.Fatalf("int32 nulls not present")
 		}
@@ -525,7 +526,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(23)
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Uint32)
-		out := CombineArray(a1, a2).(*array.Uint32)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Uint32)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("uint32 nulls not present")
 		}
@@ -543,7 +544,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(42)
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Int64)
-		out := CombineArray(a1, a2).(*array.Int64)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Int64)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("int64 nulls not present")
 		}
@@ -561,7 +562,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(42)
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Uint64)
-		out := CombineArray(a1, a2).(*array.Uint64)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Uint64)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("Uint64 nulls not present")
 		}
@@ -580,7 +581,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(3)
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Uint8)
-		out := CombineArray(a1, a2).(*array.Uint8)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Uint8)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("uint8 nulls not present")
 		}
@@ -598,7 +599,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.AppendNull()
 		b2.Append(2.5)
 		a2 := b2.NewArray().(*array.Float32)
-		out := CombineArray(a1, a2).(*array.Float32)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Float32)
 		if !out.IsNull(1) || !out.IsNull(2) {
 			t.Fatalf("float32 nulls not present")
 		}
@@ -616,7 +617,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append(4.14)
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Float64)
-		out := CombineArray(a1, a2).(*array.Float64)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Float64)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("float64 nulls not present")
 		}
@@ -634,7 +635,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.AppendNull()
 		b2.Append(false)
 		a2 := b2.NewArray().(*array.Boolean)
-		out := CombineArray(a1, a2).(*array.Boolean)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Boolean)
 		if !out.IsNull(1) || !out.IsNull(2) {
 			t.Fatalf("bool nulls not present")
 		}
@@ -652,7 +653,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.AppendNull()
 		b2.Append("s2")
 		a2 := b2.NewArray().(*array.String)
-		out := CombineArray(a1, a2).(*array.String)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.String)
 		if !out.IsNull(1) || !out.IsNull(2) {
 			t.Fatalf("string nulls not present")
 		}
@@ -670,7 +671,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append("L2")
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.LargeString)
-		out := CombineArray(a1, a2).(*array.LargeString)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.LargeString)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("large string nulls not present")
 		}
@@ -688,7 +689,7 @@ func TestCombineArray_PerTypeNulls(t *testing.T) {
 		b2.Append([]byte("bb2"))
 		b2.AppendNull()
 		a2 := b2.NewArray().(*array.Binary)
-		out := CombineArray(a1, a2).(*array.Binary)
+		out := CombineArray(a1, a2, memory.NewGoAllocator()).(*array.Binary)
 		if !out.IsNull(0) || !out.IsNull(3) {
 			t.Fatalf("binary nulls not present")
 		}
@@ -719,5 +720,26 @@ func TestCombineArray_UnsupportedType(t *testing.T) {
 	}()
 
 	// Call CombineArray with unsupported type
-	_ = CombineArray(arr, arr)
+	_ = CombineArray(arr, arr, memory.NewGoAllocator())
+}
+
+func TestParquetReturnsFirstRecord(t *testing.T) {
+	source, err := NewParquetSourcePushDown(getTestParquetFile(), []string{"country", "lat"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var rows uint64
+	for {
+		rc, err := source.Next(context.Background(), 50)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error on Next: %v", err)
+		}
+		rows += rc.RowCount
+	}
+	if rows == 0 {
+		t.Fatalf("expected rows from the first record batch, got none")
+	}
 }
@@ -1,9 +1,9 @@
 package project
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"opti-sql-go/Expr"
 	"opti-sql-go/operators"
 
@@ -12,6 +12,7 @@ import (
 
 var (
 	_ = (operators.Operator)(&ProjectExec{})
+	_ = (operators.Explainer)(&ProjectExec{})
 )
 
 var (
@@ -23,7 +24,6 @@ type ProjectExec struct {
 	input        operators.Operator
 	outputschema arrow.Schema
 	expr         []Expr.Expression
-	done         bool
 }
 
 // columns to keep and existing schema
@@ -69,41 +69,46 @@ func NewProjectExec(input operators.Operator, exprs []Expr.Expression) (*Project
 	// This ensures every projected column has a name in the output schema.
 
 	outputschema := arrow.NewSchema(fields, nil)
+	// the schema above comes from the expressions as written, evaluation uses the simplified ones
+	simplified := make([]Expr.Expression, len(exprs))
+	for i, e := range exprs {
+		s, err := Expr.Simplify(e, input.Schema())
+		if err != nil {
+			return nil, fmt.Errorf("project exec: failed to simplify expr %d: %w", i, err)
+		}
+		simplified[i] = s
+	}
 	// return new exec
 	return &ProjectExec{
 		input:        input,
 		outputschema: *outputschema,
-		expr:         exprs,
+		expr:         simplified,
 	}, nil
 }
 
 // pretty simple, read from child operator and prune columns
 // pass through error && handles EOF alike
-func (p *ProjectExec) Next(n uint16) (*operators.RecordBatch, error) {
-	if p.done {
-		return nil, io.EOF
+func (p *ProjectExec) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	childBatch, err := p.input.Next(ctx, n)
+	// an empty batch is not the end of the input, only EOF is
+	for err == nil && childBatch.RowCount == 0 {
+		operators.ReleaseArrays(childBatch.Columns)
+		childBatch, err = p.input.Next(ctx, n)
 	}
-
-	childBatch, err := p.input.Next(n)
 	if err != nil {
 		return nil, err
 	}
-	if childBatch.RowCount == 0 {
-		p.done = true
-		return &operators.RecordBatch{
-			Schema:   &p.outputschema,
-			Columns:  []arrow.Array{},
-			RowCount: 0,
-		}, nil
-	}
+	ctx, _ = operators.Allocator(ctx, p)
 	outPutCols := make([]arrow.Array, len(p.expr))
 	for i, e := range p.expr {
-		arr, err := Expr.EvalExpression(e, childBatch)
+		arr, err := Expr.EvalExpression(ctx, e, childBatch)
 		if err != nil {
+			operators.ReleaseArrays(outPutCols)
+			operators.ReleaseArrays(childBatch.Columns)
 			return nil, fmt.Errorf("project eval expression failed for expr %d: %w", i, err)
 		}
+		// EvalExpression hands back an array of our own, columns passed through are retained
 		outPutCols[i] = arr
-		arr.Retain()
 	}
 	operators.ReleaseArrays(childBatch.Columns)
 	return &operators.RecordBatch{
@@ -115,6 +120,13 @@ func (p *ProjectExec) Next(n uint16) (*operators.RecordBatch, error) {
 func (p *ProjectExec) Close() error {
 	return p.input.Close()
 }
+func (p *ProjectExec) Explain() operators.Explanation {
+	return operators.Explanation{
+		Type:     "ProjectExec",
+		Params:   map[string]string{"exprs": operators.ExplainList(p.expr)},
+		Children: []operators.Operator{p.input},
+	}
+}
 func (p *ProjectExec) Schema() *arrow.Schema {
 	return &p.outputschema
 }
@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"math"
 	"opti-sql-go/Expr"
 	"strings"
@@ -36,7 +37,7 @@ func TestProjectExec_Column_sql(t *testing.T) {
 			&Expr.ColumnResolve{Name: "id"},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -63,7 +64,7 @@ func TestProjectExec_Column_sql(t *testing.T) {
 			&Expr.ColumnResolve{Name: "name"},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -89,7 +90,7 @@ func TestProjectExec_Column_sql(t *testing.T) {
 			&Expr.ColumnResolve{Name: "age"},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -122,7 +123,7 @@ func TestProjectExec_Literal_sql(t *testing.T) {
 			&Expr.LiteralResolve{Type: arrow.PrimitiveTypes.Int64, Value: int64(4)},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(1)
+		rc, err := proj.Next(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -141,7 +142,7 @@ func TestProjectExec_Literal_sql(t *testing.T) {
 			&Expr.LiteralResolve{Type: arrow.BinaryTypes.String, Value: string("hello")},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(1)
+		rc, err := proj.Next(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -161,7 +162,7 @@ func TestProjectExec_Literal_sql(t *testing.T) {
 			&Expr.LiteralResolve{Type: arrow.PrimitiveTypes.Float32, Value: float32(3.14)},
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(2)
+		rc, err := proj.Next(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("err:%v\n", err)
 		}
@@ -198,7 +199,7 @@ func TestProjectExec_Literal_Literal(t *testing.T) {
 			t.Log(e.String())
 		}
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(4)
+		rc, err := proj.Next(context.Background(), 4)
 		if err != nil {
 			t.Fatalf("unexpected error: %v\n", err)
 		}
@@ -229,7 +230,7 @@ func TestProjectExec_Literal_Literal(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(4)
+		rc, err := proj.Next(context.Background(), 4)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -270,7 +271,7 @@ func TestProjectExec_Literal_Literal(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -318,7 +319,7 @@ func TestProjectExec_Literal_Literal(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(4)
+		rc, err := proj.Next(context.Background(), 4)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -358,7 +359,7 @@ func TestProjectExec_CastLiteral_Column(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("error: %v", err)
 		}
@@ -383,7 +384,7 @@ func TestProjectExec_CastLiteral_Column(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("error: %v", err)
 		}
@@ -409,7 +410,7 @@ func TestProjectExec_CastLiteral_Column(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		_, err := proj.Next(6)
+		_, err := proj.Next(context.Background(), 6)
 
 		if err == nil {
 			t.Fatalf("expected cast error but got nil")
@@ -426,7 +427,7 @@ func TestProjectExec_CastLiteral_Column(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("error: %v", err)
 		}
@@ -455,7 +456,7 @@ func TestProjectExec_CastLiteral_Column(t *testing.T) {
 		}
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(3)
+		rc, err := proj.Next(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("error: %v", err)
 		}
@@ -491,7 +492,7 @@ func TestProjectExec_Column_Literal(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("unexpected err: %v", err)
 		}
@@ -516,7 +517,7 @@ func TestProjectExec_Column_Literal(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, err := proj.Next(6)
+		rc, err := proj.Next(context.Background(), 6)
 		if err != nil {
 			t.Fatalf("err: %v", err)
 		}
@@ -541,7 +542,7 @@ func TestProjectExec_Column_Literal(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, _ := proj.Next(6)
+		rc, _ := proj.Next(context.Background(), 6)
 		out := rc.Columns[0].(*array.Int64)
 
 		expected := []int64{2, 4, 6, 8, 10, 12}
@@ -563,7 +564,7 @@ func TestProjectExec_Column_Literal(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, _ := proj.Next(6)
+		rc, _ := proj.Next(context.Background(), 6)
 		out := rc.Columns[0].(*array.Float32)
 
 		expected := []float32{49.3, 37.7, 44.05, 46.15, 39.75, 42.5}
@@ -614,7 +615,7 @@ func TestProjectExec_AliasExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, _ := proj.Next(3)
+		rc, _ := proj.Next(context.Background(), 3)
 
 		if proj.Schema().Field(0).Name != "boosted_age" {
 			t.Fatalf("alias not applied")
@@ -641,7 +642,7 @@ func TestProjectExec_AliasExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, _ := proj.Next(3)
+		rc, _ := proj.Next(context.Background(), 3)
 
 		if proj.Schema().Field(0).Name != "constant_value" {
 			t.Fatalf("alias not applied")
@@ -676,7 +677,7 @@ func TestProjectExec_AliasExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rc, _ := proj.Next(3)
+		rc, _ := proj.Next(context.Background(), 3)
 
 		if proj.Schema().Field(0).Name != "final_score" {
 			t.Fatalf("alias not applied")
@@ -719,7 +720,7 @@ func TestProjectExec_FunctionExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rb, err := proj.Next(3)
+		rb, err := proj.Next(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -751,7 +752,7 @@ func TestProjectExec_FunctionExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, exprs)
-		rb, _ := proj.Next(2)
+		rb, _ := proj.Next(context.Background(), 2)
 
 		out := rb.Columns[0].(*array.String)
 		t.Logf("columns: %v\n", out)
@@ -784,7 +785,7 @@ func TestProjectExec_FunctionExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, Expr.NewExpressions(expr))
-		rb, _ := proj.Next(3)
+		rb, _ := proj.Next(context.Background(), 3)
 
 		out := rb.Columns[0].(*array.Float32)
 
@@ -818,7 +819,7 @@ func TestProjectExec_FunctionExpr(t *testing.T) {
 		)
 
 		proj, _ := NewProjectExec(memSrc, Expr.NewExpressions(expr))
-		rb, _ := proj.Next(3)
+		rb, _ := proj.Next(context.Background(), 3)
 
 		out := rb.Columns[0].(*array.Float64)
 		expected := []float64{
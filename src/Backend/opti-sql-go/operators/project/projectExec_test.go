@@ -1,13 +1,17 @@
 package project
 
 import (
+	"context"
 	"errors"
 	"io"
+	"math"
 	"opti-sql-go/Expr"
+	"opti-sql-go/operators"
 	"testing"
 
 	"github.com/apache/arrow/go/v17/arrow"
 	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
 )
 
 func TestProjectExec_Init(t *testing.T) {
@@ -47,7 +51,7 @@ func TestProjectExec_BasicColumns(t *testing.T) {
 		t.Fatalf("failed to create project exec: %v", err)
 	}
 
-	rb, err := projExec.Next(3)
+	rb, err := projExec.Next(context.Background(), 3)
 	if err != nil {
 		t.Fatalf("Next failed: %v", err)
 	}
@@ -106,7 +110,7 @@ func TestProjectExec_Literal(t *testing.T) {
 		t.Fatalf("failed to init project exec: %v", err)
 	}
 
-	rb, err := projExec.Next(5)
+	rb, err := projExec.Next(context.Background(), 5)
 	if err != nil {
 		t.Fatalf("Next failed: %v", err)
 	}
@@ -138,7 +142,7 @@ func TestProjectExec_BinaryAdd(t *testing.T) {
 		t.Fatalf("failed: %v", err)
 	}
 
-	rb, err := projExec.Next(3)
+	rb, err := projExec.Next(context.Background(), 3)
 	if err != nil {
 		t.Fatalf("Next failed: %v", err)
 	}
@@ -165,7 +169,7 @@ func TestProjectExec_IterateEOF(t *testing.T) {
 
 	count := 0
 	for {
-		rb, err := projExec.Next(2)
+		rb, err := projExec.Next(context.Background(), 2)
 		if errors.Is(err, io.EOF) {
 			break
 		}
@@ -209,7 +213,7 @@ func TestEOFBehavior(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create project exec: %v", err)
 	}
-	rc, err := proj.Next(1)
+	rc, err := proj.Next(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("unexpected error on first Next: %v", err)
 	}
@@ -221,7 +225,7 @@ func TestEOFBehavior(t *testing.T) {
 		t.Fatalf("expected 'richard', got '%s'", nameCol.Value(0))
 	}
 
-	_, err = proj.Next(10)
+	_, err = proj.Next(context.Background(), 10)
 	if !errors.Is(err, io.EOF) {
 		t.Fatalf("expected EOF error on second Next, got %v", err)
 	}
@@ -280,3 +284,59 @@ func TestPruneSchema_cvg(t *testing.T) {
 	})
 
 }
+
+func TestProjectExec_SimplifiesExpressions(t *testing.T) {
+	names, cols := generateTestColumns()
+	memSrc, _ := NewInMemoryProjectExec(names, cols)
+	folded := Expr.NewAlias(Expr.NewBinaryExpr(
+		Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, 2),
+		Expr.Multiplication,
+		Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, 3),
+	), "six")
+	proj, err := NewProjectExec(memSrc, []Expr.Expression{folded, Expr.NewCastExpr(Expr.NewColumnResolve("id"), arrow.PrimitiveTypes.Int32)})
+	if err != nil {
+		t.Fatalf("failed to create project exec: %v", err)
+	}
+	if proj.Schema().Field(0).Name != "six" || proj.Schema().Field(1).Name != "col_1" {
+		t.Fatalf("simplifying must not rename outputs, got %v", proj.Schema())
+	}
+	if _, ok := proj.expr[0].(*Expr.Alias).Expr.(*Expr.LiteralResolve); !ok {
+		t.Fatalf("expected the literal product to be folded, got %s", proj.expr[0])
+	}
+	rb, err := proj.Next(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	six, ok := rb.Columns[0].(*array.Int64)
+	if !ok || six.Len() != 3 || six.Value(2) != 6 {
+		t.Fatalf("expected a column of 6, got %v", rb.Columns[0])
+	}
+}
+
+func TestProjectExec_OutOfMemoryInComputeKernel(t *testing.T) {
+	b := array.NewInt64Builder(memory.NewGoAllocator())
+	for i := 0; i < 60000; i++ {
+		b.Append(int64(i))
+	}
+	memSrc, err := NewInMemoryProjectExecFromArrays([]string{"a"}, []arrow.Array{b.NewArray()})
+	if err != nil {
+		t.Fatalf("failed to create in memory source: %v", err)
+	}
+	plusOne := Expr.NewAlias(Expr.NewBinaryExpr(Expr.NewColumnResolve("a"), Expr.Addition, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, 1)), "b")
+	proj, err := NewProjectExec(memSrc, []Expr.Expression{plusOne})
+	if err != nil {
+		t.Fatalf("failed to create project exec: %v", err)
+	}
+	// the addition's output alone is ~480KB, allocated by the arithmetic kernel
+	q := operators.NewQueryMemory(nil, 256<<10, 0)
+	ctx := operators.WithQueryMemory(context.Background(), q)
+	if _, err := operators.NextBatch(ctx, proj, math.MaxUint16); !errors.Is(err, operators.ErrOutOfMemory) {
+		t.Fatalf("expected an out of memory error, got %v", err)
+	}
+	if err := proj.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.InUse() != 0 {
+		t.Fatalf("expected the failed batch to be released, %d bytes still in use", q.InUse())
+	}
+}
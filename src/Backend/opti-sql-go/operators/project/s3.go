@@ -5,6 +5,7 @@ import (
 	"io"
 	"opti-sql-go/config"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/minio/minio-go"
@@ -34,8 +35,13 @@ func NewStreamReader(fileName string) (*NetworkResource, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewStreamReaderFromClient(client, bucket, fileName)
+}
 
-	obj, err := client.GetObject(bucket, fileName, minio.GetObjectOptions{})
+// NewStreamReaderFromClient opens bucket/key with an already configured client, this is how
+// sources are read from object stores other than the one in the config (and from local stand-ins in tests)
+func NewStreamReaderFromClient(client *minio.Client, bucket, key string) (*NetworkResource, error) {
+	obj, err := client.GetObject(bucket, key, minio.GetObjectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -43,8 +49,8 @@ func NewStreamReader(fileName string) (*NetworkResource, error) {
 	return &NetworkResource{
 		client:   client,
 		bucket:   bucket,
-		key:      fileName,
-		fileName: fileName,
+		key:      key,
+		fileName: key,
 		stream:   obj, // CSV reads this directly
 	}, nil
 }
@@ -80,8 +86,15 @@ func (n *NetworkResource) Seek(offset int64, whence int) (int64, error) {
 		return 0, fmt.Errorf("unsupported seek mode for S3: %d", whence)
 	}
 }
-func (n *NetworkResource) DownloadLocally() (*os.File, error) {
-	f, err := os.Create(fmt.Sprintf("%s-%d", n.key, time.Now().UnixNano()))
+
+// Close releases the underlying object stream
+func (n *NetworkResource) Close() error {
+	return n.stream.Close()
+}
+
+// DownloadLocally copies the whole object into a new file under dir, the caller removes it when done
+func (n *NetworkResource) DownloadLocally(dir string) (*os.File, error) {
+	f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%s-%d", filepath.Base(n.key), time.Now().UnixNano())))
 	if err != nil {
 		return nil, err
 	}
@@ -89,15 +102,21 @@ func (n *NetworkResource) DownloadLocally() (*os.File, error) {
 	// Read entire stream once
 	content, err := io.ReadAll(n.stream)
 	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
 		return nil, err
 	}
 
 	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
 		return nil, err
 	}
 
 	// Rewind so CSV readers can start from beginning
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
 		return nil, err
 	}
 
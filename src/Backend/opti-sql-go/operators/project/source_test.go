@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -82,7 +83,7 @@ func TestS3Download(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create s3 object: %v", err)
 		}
-		newFile, err := nr.DownloadLocally()
+		newFile, err := nr.DownloadLocally(t.TempDir())
 		if err != nil {
 			t.Fatalf("failed to download file locally %v", err)
 		}
@@ -115,7 +116,7 @@ func TestS3Download(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create s3 object: %v", err)
 		}
-		newFile, err := nr.DownloadLocally()
+		newFile, err := nr.DownloadLocally(t.TempDir())
 		if err != nil {
 			t.Fatalf("failed to download file locally %v", err)
 		}
@@ -148,7 +149,7 @@ func TestS3Download(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create s3 object: %v", err)
 		}
-		newFile, err := nr.DownloadLocally()
+		newFile, err := nr.DownloadLocally(t.TempDir())
 		if err != nil {
 			t.Fatalf("failed to download file locally %v", err)
 		}
@@ -189,7 +190,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create csv project source from s3 object: %v", err)
 		}
-		rc, err := pj.Next(5)
+		rc, err := pj.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("failed to read record batch from s3 csv source: %v", err)
 		}
@@ -205,7 +206,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create parquet project source from s3 object: %v", err)
 		}
-		rc, err := pj.Next(5)
+		rc, err := pj.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("failed to read record batch from s3 csv source: %v", err)
 		}
@@ -217,7 +218,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create s3 object: %v", err)
 		}
-		f, err := nr.DownloadLocally()
+		f, err := nr.DownloadLocally(t.TempDir())
 		if err != nil {
 			t.Fatalf("failed to download s3 object locally: %v", err)
 		}
@@ -232,7 +233,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create csv project source from s3 object: %v", err)
 		}
-		rc, err := pj.Next(5)
+		rc, err := pj.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("failed to read record batch from s3 csv source: %v", err)
 		}
@@ -248,7 +249,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create s3 object: %v", err)
 		}
-		f, err := nr.DownloadLocally()
+		f, err := nr.DownloadLocally(t.TempDir())
 		if err != nil {
 			t.Fatalf("failed to download s3 object locally: %v", err)
 		}
@@ -263,7 +264,7 @@ func TestS3ForSource(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create csv project source from s3 object: %v", err)
 		}
-		rc, err := pj.Next(5)
+		rc, err := pj.Next(context.Background(), 5)
 		if err != nil {
 			t.Fatalf("failed to read record batch from s3 csv source: %v", err)
 		}
@@ -1,6 +1,7 @@
 package operators
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -16,7 +17,7 @@ var (
 )
 
 type Operator interface {
-	Next(uint16) (*RecordBatch, error)
+	Next(context.Context, uint16) (*RecordBatch, error)
 	Schema() *arrow.Schema
 	// Call Operator.Close() after Next returns an io.EOF to clean up resources
 	Close() error
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/apache/arrow/go/v17/arrow"
 	"github.com/apache/arrow/go/v17/arrow/array"
@@ -143,7 +144,8 @@ Accept that we're writing more data - it's worth it for correctness
 */
 
 type serializer struct {
-	schema *arrow.Schema // schema is always attached to the serializer
+	schema *arrow.Schema    // schema is always attached to the serializer
+	mem    memory.Allocator // buffers read back are allocated from mem, nil keeps them on the go heap
 }
 
 func NewSerializer(schema *arrow.Schema) (*serializer, error) {
@@ -211,42 +213,55 @@ func (ss *serializer) columnsTodisk(columns []arrow.Array) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
 	for _, col := range columns {
-		data := col.Data()
-
-		// Write array length (number of rows)
-		if err := binary.Write(buf, binary.LittleEndian, int64(data.Len())); err != nil {
+		if err := writeArrayData(buf, col.Data()); err != nil {
 			return nil, err
 		}
+	}
 
-		// Number of buffers for this column
-		buffers := data.Buffers()
-		if err := binary.Write(buf, binary.LittleEndian, uint32(len(buffers))); err != nil {
-			return nil, err
-		}
+	return buf.Bytes(), nil
+}
 
-		// Write each buffer
-		for _, b := range buffers {
-			if b == nil || b.Len() == 0 {
-				// Write 0 length
-				if err := binary.Write(buf, binary.LittleEndian, uint64(0)); err != nil {
-					return nil, err
-				}
-				continue
-			}
+// writeArrayData writes the length and buffers of data followed by its children, ie the values of a list
+func writeArrayData(buf *bytes.Buffer, data arrow.ArrayData) error {
+	// Write array length (number of rows)
+	if err := binary.Write(buf, binary.LittleEndian, int64(data.Len())); err != nil {
+		return err
+	}
 
-			// Write length of buffer
-			if err := binary.Write(buf, binary.LittleEndian, uint64(b.Len())); err != nil {
-				return nil, err
-			}
+	// Number of buffers for this column
+	buffers := data.Buffers()
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(buffers))); err != nil {
+		return err
+	}
 
-			// Write buffer contents
-			if _, err := buf.Write(b.Bytes()); err != nil {
-				return nil, err
+	// Write each buffer
+	for _, b := range buffers {
+		if b == nil || b.Len() == 0 {
+			// Write 0 length
+			if err := binary.Write(buf, binary.LittleEndian, uint64(0)); err != nil {
+				return err
 			}
+			continue
+		}
+
+		// Write length of buffer
+		if err := binary.Write(buf, binary.LittleEndian, uint64(b.Len())); err != nil {
+			return err
+		}
+
+		// Write buffer contents
+		if _, err := buf.Write(b.Bytes()); err != nil {
+			return err
 		}
 	}
 
-	return buf.Bytes(), nil
+	// children follow their parent, their types come from the parent's type
+	for _, child := range data.Children() {
+		if err := writeArrayData(buf, child); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (ss *serializer) DeserializeSchema(data io.Reader) (*arrow.Schema, error) {
@@ -256,6 +271,17 @@ func (ss *serializer) DeserializeSchema(data io.Reader) (*arrow.Schema, error) {
 
 // after reading in the schema we read in one column at a time
 func (ss *serializer) DeserializeNextColumn(r io.Reader, dt arrow.DataType) (arrow.Array, error) {
+	data, err := ss.readArrayData(r, dt)
+	if err != nil {
+		return nil, err
+	}
+	// the array keeps its own reference to the data
+	defer data.Release()
+	return array.MakeFromData(data), nil
+}
+
+// readArrayData reads back what writeArrayData wrote for an array of type dt
+func (ss *serializer) readArrayData(r io.Reader, dt arrow.DataType) (arrow.ArrayData, error) {
 	// 1. Read the number of elements in this column batch
 	var length int64
 	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
@@ -285,26 +311,62 @@ func (ss *serializer) DeserializeNextColumn(r io.Reader, dt arrow.DataType) (arr
 		}
 
 		// Read raw bytes
-		raw := make([]byte, size)
-		if _, err := io.ReadFull(r, raw); err != nil {
+		var buf *memory.Buffer
+		if ss.mem != nil {
+			buf = memory.NewResizableBuffer(ss.mem)
+			buf.Resize(int(size))
+		} else {
+			buf = memory.NewBufferBytes(make([]byte, size))
+		}
+		buffers[i] = buf
+		if _, err := io.ReadFull(r, buf.Bytes()); err != nil {
+			releaseBuffers(buffers)
 			return nil, err
 		}
-
-		buffers[i] = memory.NewBufferBytes(raw)
+	}
+	// the array data holds its own reference to the buffers
+	defer releaseBuffers(buffers)
+
+	// 4. Read the children, ie the values of a list
+	var children []arrow.ArrayData
+	defer func() {
+		for _, c := range children {
+			c.Release()
+		}
+	}()
+	if nested, ok := dt.(arrow.NestedType); ok {
+		for _, f := range nested.Fields() {
+			child, err := ss.readArrayData(r, f.Type)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
 	}
 
-	// 4. Construct Arrow ArrayData
+	// 5. Construct Arrow ArrayData
+	// null count -1 lets Arrow compute it lazily from the validity bitmap, without one there are no nulls
+	nulls := array.UnknownNullCount
+	if len(buffers) > 0 && buffers[0] == nil {
+		nulls = 0
+	}
 	arrData := array.NewData(
 		dt,
 		int(length),
-		buffers, // buffers
-		nil,     // children (none for primitive)
-		-1,      // null count (setting it to -1 lets Arrow compute it lazily)
-		0,       // offset
+		buffers,  // buffers
+		children, // children (none for primitive)
+		nulls,    // null count
+		0,        // offset
 	)
+	return arrData, nil
+}
 
-	// 5. Wrap into Array type
-	return array.MakeFromData(arrData), nil
+func releaseBuffers(buffers []*memory.Buffer) {
+	for _, b := range buffers {
+		if b != nil {
+			b.Release()
+		}
+	}
 }
 
 // must call ss.DeserializeSchema first or else this will not work properly
@@ -346,7 +408,7 @@ func (ss *serializer) schemaFromDisk(data io.Reader) (*arrow.Schema, error) {
 			return nil, err
 		}
 		nameBytes := make([]byte, nameLen)
-		_, err = data.Read(nameBytes)
+		_, err = io.ReadFull(data, nameBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -358,7 +420,7 @@ func (ss *serializer) schemaFromDisk(data io.Reader) (*arrow.Schema, error) {
 			return nil, err
 		}
 		typeBytes := make([]byte, typeLen)
-		_, err = data.Read(typeBytes)
+		_, err = io.ReadFull(data, typeBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -423,6 +485,32 @@ func BasicArrowTypeFromString(s string) (arrow.DataType, error) {
 		return arrow.BinaryTypes.Binary, nil
 	case "large_binary":
 		return arrow.BinaryTypes.LargeBinary, nil
+
+	case "date32":
+		return arrow.FixedWidthTypes.Date32, nil
+	case "date64":
+		return arrow.FixedWidthTypes.Date64, nil
+	}
+
+	// timestamps are written as timestamp[unit] or timestamp[unit, tz=zone]
+	if rest, ok := strings.CutPrefix(s, "timestamp["); ok && strings.HasSuffix(rest, "]") {
+		unit, tz, _ := strings.Cut(strings.TrimSuffix(rest, "]"), ", tz=")
+		for _, u := range []arrow.TimeUnit{arrow.Second, arrow.Millisecond, arrow.Microsecond, arrow.Nanosecond} {
+			if u.String() == unit {
+				return &arrow.TimestampType{Unit: u, TimeZone: tz}, nil
+			}
+		}
+	}
+	// lists are written as list<name: type>, with ", nullable" after the type when their elements are
+	if rest, ok := strings.CutPrefix(s, "list<"); ok && strings.HasSuffix(rest, ">") {
+		if name, elem, ok := strings.Cut(strings.TrimSuffix(rest, ">"), ": "); ok {
+			elem, nullable := strings.CutSuffix(elem, ", nullable")
+			dt, err := BasicArrowTypeFromString(elem)
+			if err != nil {
+				return nil, err
+			}
+			return arrow.ListOfField(arrow.Field{Name: name, Type: dt, Nullable: nullable}), nil
+		}
 	}
 
 	return nil, fmt.Errorf("unsupported arrow type: %s", s)
@@ -547,6 +547,15 @@ func TestBasicArrowTypeFromString(t *testing.T) {
 		{"binary", arrow.BINARY, false},
 		{"large_binary", arrow.LARGE_BINARY, false},
 
+		{"date32", arrow.DATE32, false},
+		{"date64", arrow.DATE64, false},
+		{"timestamp[ms]", arrow.TIMESTAMP, false},
+		{"timestamp[us, tz=UTC]", arrow.TIMESTAMP, false},
+		{"timestamp[hours]", arrow.Type(0), true},
+		{"list<item: int64, nullable>", arrow.LIST, false},
+		{"list<item: timestamp[ms, tz=UTC]>", arrow.LIST, false},
+		{"list<item: not_a_type>", arrow.Type(0), true},
+
 		// unsupported type should return an error
 		{"not_a_type", arrow.Type(0), true},
 	}
@@ -0,0 +1,196 @@
+package operators
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/bitutil"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// spilling: blocking operators that are told to spill (see ShouldSpill) write what they hold to a
+// SpillFile and read it back later. a spill file is a temp file laid out like the serializer
+// describes, one schema block followed by any number of record batches. it is written front to
+// back, rewound once and then read front to back
+
+// SpillFile is a temp file of record batches that all share one schema
+type SpillFile struct {
+	ser    *serializer
+	schema *arrow.Schema
+	f      *os.File
+	w      *bufio.Writer
+	r      *bufio.Reader
+	rows   uint64
+	size   int64
+}
+
+// NewSpillFile creates an empty spill file in the os temp dir and writes the schema block. batches
+// read back are allocated from mem, so they count against the operator reading them
+func NewSpillFile(schema *arrow.Schema, mem memory.Allocator) (*SpillFile, error) {
+	ser, err := NewSerializer(schema)
+	if err != nil {
+		return nil, err
+	}
+	ser.mem = mem
+	f, err := os.CreateTemp("", "opti-sql-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("spill: failed to create spill file: %w", err)
+	}
+	s := &SpillFile{ser: ser, schema: schema, f: f, w: bufio.NewWriter(f)}
+	header, err := ser.SerializeSchema(schema)
+	if err == nil {
+		err = s.write(header)
+	}
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends batch to the file. the batch is still owned by the caller
+func (s *SpillFile) Write(batch *RecordBatch) error {
+	if s.r != nil {
+		return fmt.Errorf("spill: write after the file was rewound")
+	}
+	// the serializer writes whole buffers, a sliced column has to be copied out first
+	cols := make([]arrow.Array, len(batch.Columns))
+	for i, col := range batch.Columns {
+		if !oversized(col.Data()) {
+			col.Retain()
+			cols[i] = col
+			continue
+		}
+		c, err := array.Concatenate([]arrow.Array{col}, memory.DefaultAllocator)
+		if err != nil {
+			ReleaseArrays(cols)
+			return err
+		}
+		cols[i] = c
+	}
+	defer ReleaseArrays(cols)
+	data, err := s.ser.SerializeBatchColumns(RecordBatch{Schema: batch.Schema, Columns: cols, RowCount: batch.RowCount})
+	if err != nil {
+		return err
+	}
+	if err := s.write(data); err != nil {
+		return err
+	}
+	if len(cols) > 0 {
+		s.rows += uint64(cols[0].Len())
+	}
+	return nil
+}
+
+// oversized is true when data's buffers hold more than its own rows, like a slice of a larger
+// array does whatever its offset. types it can't size are always treated as oversized
+func oversized(data arrow.ArrayData) bool {
+	if data.Offset() != 0 {
+		return true
+	}
+	n := data.Len()
+	bufs := data.Buffers()
+	// buffers can be padded a little past what they need, only more than that counts
+	larger := func(i, need int) bool {
+		return i < len(bufs) && bufs[i] != nil && bufs[i].Len() > bitutil.CeilByte(need)+64
+	}
+	if larger(0, int(bitutil.BytesForBits(int64(n)))) {
+		return true
+	}
+	switch dt := data.DataType().(type) {
+	case arrow.FixedWidthDataType:
+		return larger(1, int(bitutil.BytesForBits(int64(n)*int64(dt.BitWidth()))))
+	case *arrow.StringType, *arrow.BinaryType:
+		if larger(1, (n+1)*arrow.Int32SizeBytes) {
+			return true
+		}
+		if len(bufs) < 3 || bufs[1] == nil {
+			return false
+		}
+		offsets := arrow.Int32Traits.CastFromBytes(bufs[1].Bytes())
+		return len(offsets) > n && larger(2, int(offsets[n]))
+	case *arrow.LargeStringType, *arrow.LargeBinaryType:
+		if larger(1, (n+1)*arrow.Int64SizeBytes) {
+			return true
+		}
+		if len(bufs) < 3 || bufs[1] == nil {
+			return false
+		}
+		offsets := arrow.Int64Traits.CastFromBytes(bufs[1].Bytes())
+		return len(offsets) > n && larger(2, int(offsets[n]))
+	}
+	return true
+}
+
+func (s *SpillFile) write(b []byte) error {
+	n, err := s.w.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("spill: failed to write spill file: %w", err)
+	}
+	return nil
+}
+
+// Rewind flushes what was written and moves back to the first batch, the file can only be read from
+// then on
+func (s *SpillFile) Rewind() error {
+	if s.r == nil {
+		if err := s.w.Flush(); err != nil {
+			return fmt.Errorf("spill: failed to flush spill file: %w", err)
+		}
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("spill: failed to rewind spill file: %w", err)
+	}
+	s.r = bufio.NewReader(s.f)
+	schema, err := s.ser.DeserializeSchema(s.r)
+	if err != nil {
+		return fmt.Errorf("spill: failed to read spill file schema: %w", err)
+	}
+	if !schema.Equal(s.schema) {
+		return ErrInvalidSchema("spill file schema does not match the schema it was written with")
+	}
+	return nil
+}
+
+// Read is the next batch of the file, io.EOF once all of them were read
+func (s *SpillFile) Read() (*RecordBatch, error) {
+	if s.r == nil {
+		return nil, fmt.Errorf("spill: read before the file was rewound")
+	}
+	cols, err := s.ser.DecodeRecordBatch(s.r, s.schema)
+	if err != nil {
+		return nil, err
+	}
+	var rows uint64
+	if len(cols) > 0 {
+		rows = uint64(cols[0].Len())
+	}
+	return &RecordBatch{Schema: s.schema, Columns: cols, RowCount: rows}, nil
+}
+
+func (s *SpillFile) Schema() *arrow.Schema { return s.schema }
+
+// Rows is the number of rows written to the file
+func (s *SpillFile) Rows() uint64 { return s.rows }
+
+// Size is the number of bytes written to the file
+func (s *SpillFile) Size() int64 { return s.size }
+
+// Close removes the file
+func (s *SpillFile) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	name := s.f.Name()
+	err := s.f.Close()
+	s.f = nil
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
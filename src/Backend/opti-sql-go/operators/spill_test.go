@@ -0,0 +1,200 @@
+package operators
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+func TestSpillFile(t *testing.T) {
+	t.Run("batches come back in the order they were written", func(t *testing.T) {
+		t.Setenv("TMPDIR", t.TempDir())
+		first := generateDummyRecordBatch1()
+		second := generateDummyRecordBatch1()
+		spill, err := NewSpillFile(first.Schema, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// a slice has to be written as the rows it covers, not the whole buffers behind it
+		sliced := RecordBatch{Schema: second.Schema, RowCount: 2}
+		for _, col := range second.Columns {
+			sliced.Columns = append(sliced.Columns, array.NewSlice(col, 3, 5))
+		}
+		for _, b := range []RecordBatch{first, sliced} {
+			if err := spill.Write(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if spill.Rows() != 7 || spill.Size() == 0 {
+			t.Fatalf("expected 7 rows on disk, got %d rows in %d bytes", spill.Rows(), spill.Size())
+		}
+		if err := spill.Rewind(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := spill.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.RowCount != 5 || got.Columns[1].(*array.String).Value(4) != "Eve" {
+			t.Fatalf("unexpected first batch %s", got.PrettyPrint())
+		}
+		got, err = spill.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names := got.Columns[1].(*array.String)
+		if got.RowCount != 2 || names.Value(0) != "David" || names.Value(1) != "Eve" {
+			t.Fatalf("unexpected sliced batch %s", got.PrettyPrint())
+		}
+		if _, err := spill.Read(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+		if err := spill.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entries, _ := os.ReadDir(os.TempDir()); len(entries) != 0 {
+			t.Fatalf("expected the spill file to be removed, found %d files", len(entries))
+		}
+	})
+	t.Run("a slice is written as its rows whatever its offset", func(t *testing.T) {
+		schema := arrow.NewSchema([]arrow.Field{
+			{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+			{Name: "name", Type: arrow.BinaryTypes.String},
+		}, nil)
+		ids := array.NewInt64Builder(memory.DefaultAllocator)
+		names := array.NewStringBuilder(memory.DefaultAllocator)
+		for i := 0; i < 100_000; i++ {
+			ids.Append(int64(i))
+			names.Append("name")
+		}
+		cols := []arrow.Array{ids.NewArray(), names.NewArray()}
+		defer ReleaseArrays(cols)
+		sizes := make([]int64, 0, 2)
+		for _, offset := range []int64{0, 1000} {
+			slice := RecordBatch{Schema: schema, RowCount: 1000}
+			for _, col := range cols {
+				slice.Columns = append(slice.Columns, array.NewSlice(col, offset, offset+1000))
+			}
+			spill, err := NewSpillFile(schema, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := spill.Write(&slice); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sizes = append(sizes, spill.Size())
+			ReleaseArrays(slice.Columns)
+			if err := spill.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// 1000 int64s and 1000 short strings are about 16KB, the whole columns are over 1MB
+		for i, size := range sizes {
+			if size > 20_000 {
+				t.Fatalf("expected a 1000 row slice to take about 16KB, slice %d took %d bytes", i, size)
+			}
+		}
+	})
+	t.Run("nulls survive the round trip", func(t *testing.T) {
+		batch := generateNullableRecordBatch()
+		spill, err := NewSpillFile(batch.Schema, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = spill.Close() }()
+		if err := spill.Write(&batch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := spill.Rewind(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := spill.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Columns[0].NullN() != 1 || !got.Columns[0].IsNull(1) || !got.Columns[1].IsNull(2) {
+			t.Fatalf("expected the nulls to be kept, got %s", got.PrettyPrint())
+		}
+	})
+	t.Run("columns without a validity bitmap have no nulls", func(t *testing.T) {
+		batch := generateDummyRecordBatch1()
+		spill, err := NewSpillFile(batch.Schema, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = spill.Close() }()
+		if err := spill.Write(&batch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := spill.Rewind(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := spill.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i, col := range got.Columns {
+			if col.NullN() != 0 {
+				t.Fatalf("expected no nulls in column %d, got %d", i, col.NullN())
+			}
+		}
+	})
+	t.Run("lists survive the round trip", func(t *testing.T) {
+		lb := array.NewListBuilder(memory.NewGoAllocator(), arrow.BinaryTypes.String)
+		values := lb.ValueBuilder().(*array.StringBuilder)
+		lb.Append(true)
+		values.AppendValues([]string{"a", "bc"}, nil)
+		lb.AppendNull()
+		lb.Append(true)
+		lb.Append(true)
+		values.Append("d")
+		list := lb.NewArray()
+		lb.Release()
+		schema := arrow.NewSchema([]arrow.Field{{Name: "values", Type: list.DataType(), Nullable: true}}, nil)
+		batch := RecordBatch{Schema: schema, Columns: []arrow.Array{list}, RowCount: 4}
+		defer ReleaseArrays(batch.Columns)
+		spill, err := NewSpillFile(schema, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = spill.Close() }()
+		if err := spill.Write(&batch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := spill.Rewind(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := spill.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !array.Equal(got.Columns[0], list) {
+			t.Fatalf("expected %v, got %v", list, got.Columns[0])
+		}
+	})
+	t.Run("reads and writes out of order", func(t *testing.T) {
+		batch := generateDummyRecordBatch1()
+		spill, err := NewSpillFile(batch.Schema, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = spill.Close() }()
+		if _, err := spill.Read(); err == nil {
+			t.Fatalf("expected reading before rewinding to fail")
+		}
+		if err := spill.Rewind(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := spill.Write(&batch); err == nil {
+			t.Fatalf("expected writing after rewinding to fail")
+		}
+		if _, err := spill.Read(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected an empty file, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// EXPLAIN ANALYZE: the planner wraps every operator it builds in an AnalyzedExec, which times the
+// calls to Next and counts what comes out. the wrappers know their children so the whole tree can
+// be printed afterwards with the numbers next to each operator
+
+var (
+	_ = (Operator)(&AnalyzedExec{})
+	_ = (Explainer)(&AnalyzedExec{})
+)
+
+// MemoryReporter is implemented by operators that hold on to data between calls to Next (sorts,
+// hash tables...). PeakMemory is the most bytes the operator held at once
+type MemoryReporter interface {
+	PeakMemory() int64
+}
+
+// OperatorStats is what a single operator did while the query ran
+type OperatorStats struct {
+	RowsIn     uint64        // rows handed over by the children
+	RowsOut    uint64        // rows returned to the parent
+	Batches    uint64        // batches returned to the parent
+	WallTime   time.Duration // time spent in Next, children included
+	ChildTime  time.Duration // the part of WallTime spent waiting on the children
+	PeakMemory int64         // 0 for operators that only pass batches through
+}
+
+// SelfTime is the time spent in the operator itself
+func (s OperatorStats) SelfTime() time.Duration {
+	return s.WallTime - s.ChildTime
+}
+
+func (s OperatorStats) String() string {
+	return fmt.Sprintf("rows_in=%d rows_out=%d batches=%d time=%s self=%s peak_mem=%s",
+		s.RowsIn, s.RowsOut, s.Batches, s.WallTime.Round(time.Microsecond), s.SelfTime().Round(time.Microsecond), formatBytes(s.PeakMemory))
+}
+
+// AnalyzedExec records the stats of the operator it wraps. children are the wrapped inputs of that
+// operator, their output is its input and their time is its child time
+type AnalyzedExec struct {
+	input    Operator
+	name     string
+	children []*AnalyzedExec
+
+	rowsOut  uint64
+	batches  uint64
+	wallTime time.Duration
+}
+
+func NewAnalyzedExec(input Operator, name string, children ...*AnalyzedExec) *AnalyzedExec {
+	return &AnalyzedExec{
+		input:    input,
+		name:     name,
+		children: children,
+	}
+}
+
+func (a *AnalyzedExec) Next(ctx context.Context, n uint16) (*RecordBatch, error) {
+	start := time.Now()
+	batch, err := a.input.Next(ctx, n)
+	a.wallTime += time.Since(start)
+	if err == nil && batch != nil {
+		a.batches++
+		a.rowsOut += batch.RowCount
+	}
+	return batch, err
+}
+func (a *AnalyzedExec) Schema() *arrow.Schema { return a.input.Schema() }
+func (a *AnalyzedExec) Close() error          { return a.input.Close() }
+
+func (a *AnalyzedExec) Explain() Explanation { return ExplainOperator(a.input) }
+
+func (a *AnalyzedExec) Name() string              { return a.name }
+func (a *AnalyzedExec) Children() []*AnalyzedExec { return a.children }
+
+// Stats is the operator's stats so far, usually read once the query is drained
+func (a *AnalyzedExec) Stats() OperatorStats {
+	s := OperatorStats{
+		RowsOut:  a.rowsOut,
+		Batches:  a.batches,
+		WallTime: a.wallTime,
+	}
+	for _, c := range a.children {
+		s.RowsIn += c.rowsOut
+		s.ChildTime += c.wallTime
+	}
+	if m, ok := a.input.(MemoryReporter); ok {
+		s.PeakMemory = m.PeakMemory()
+	}
+	return s
+}
+
+// String renders the tree below a, one operator per line with its stats
+//
+//	SortExec: age DESC NULLS LAST [rows_in=4 rows_out=4 batches=1 time=210µs self=95µs peak_mem=312B]
+//	  FilterExec: (age > 30) [rows_in=9 rows_out=4 batches=1 time=115µs self=40µs peak_mem=0B]
+//	    ScanExec: employees [rows_in=0 rows_out=9 batches=1 time=75µs self=75µs peak_mem=0B]
+func (a *AnalyzedExec) String() string {
+	var b strings.Builder
+	formatAnalyzed(&b, a, 0)
+	return b.String()
+}
+
+func formatAnalyzed(b *strings.Builder, a *AnalyzedExec, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(b, "%s [%s]\n", a.name, a.Stats())
+	for _, c := range a.children {
+		formatAnalyzed(b, c, depth+1)
+	}
+}
+
+// ExplainAnalyze runs root to completion, throwing the rows away, closes it and returns the
+// annotated tree. the tree is returned with the error as well, up to where the query failed
+func ExplainAnalyze(ctx context.Context, root *AnalyzedExec, batchSize uint16) (string, error) {
+	defer func() { _ = root.Close() }()
+	for {
+		batch, err := NextBatch(ctx, root, batchSize)
+		if errors.Is(err, io.EOF) {
+			return root.String(), nil
+		}
+		if err != nil {
+			return root.String(), err
+		}
+		ReleaseArrays(batch.Columns)
+	}
+}
+
+// ArraysSize is the number of bytes held by the buffers of arrs, children and dictionaries included.
+// buffers shared between arrays (slices of the same array) are counted for every array
+func ArraysSize(arrs []arrow.Array) int64 {
+	var size int64
+	for _, arr := range arrs {
+		if arr != nil {
+			size += arrayDataSize(arr.Data())
+		}
+	}
+	return size
+}
+
+func arrayDataSize(data arrow.ArrayData) int64 {
+	var size int64
+	for _, buf := range data.Buffers() {
+		if buf != nil {
+			size += int64(buf.Len())
+		}
+	}
+	for _, child := range data.Children() {
+		size += arrayDataSize(child)
+	}
+	// Dictionary() hands back a typed nil for everything else
+	if data.DataType().ID() == arrow.DICTIONARY {
+		size += arrayDataSize(data.Dictionary())
+	}
+	return size
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
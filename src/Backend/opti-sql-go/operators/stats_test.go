@@ -0,0 +1,103 @@
+package operators
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// batchesOp hands out the same batch a fixed number of times, taking a while for each
+type batchesOp struct {
+	batch   *RecordBatch
+	left    int
+	delay   time.Duration
+	err     error
+	retains int64
+}
+
+func (b *batchesOp) Next(_ context.Context, _ uint16) (*RecordBatch, error) {
+	time.Sleep(b.delay)
+	if b.left == 0 {
+		if b.err != nil {
+			return nil, b.err
+		}
+		return nil, io.EOF
+	}
+	b.left--
+	return b.batch, nil
+}
+func (b *batchesOp) Schema() *arrow.Schema { return b.batch.Schema }
+func (b *batchesOp) Close() error          { return nil }
+func (b *batchesOp) PeakMemory() int64     { return b.retains }
+
+func TestAnalyzedExec(t *testing.T) {
+	rbb := NewRecordBatchBuilder()
+	rbb.SchemaBuilder.WithField("id", arrow.PrimitiveTypes.Int32, false)
+	batch := &RecordBatch{Schema: rbb.Schema(), Columns: []arrow.Array{rbb.GenIntArray(1, 2, 3)}, RowCount: 3}
+
+	t.Run("stats", func(t *testing.T) {
+		leaf := NewAnalyzedExec(&batchesOp{batch: batch, left: 4, delay: time.Millisecond}, "Leaf")
+		// the parent passes every other batch through, like a filter would
+		parent := NewAnalyzedExec(&batchesOp{batch: batch, left: 2, retains: 2048}, "Parent", leaf)
+		ctx := context.Background()
+		for i := 0; i < 4; i++ {
+			if _, err := leaf.Next(ctx, 10); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if i%2 == 0 {
+				if _, err := parent.Next(ctx, 10); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		}
+		ls, ps := leaf.Stats(), parent.Stats()
+		if ls.RowsIn != 0 || ls.RowsOut != 12 || ls.Batches != 4 || ls.ChildTime != 0 || ls.WallTime < 4*time.Millisecond {
+			t.Fatalf("unexpected leaf stats %+v", ls)
+		}
+		if ps.RowsIn != 12 || ps.RowsOut != 6 || ps.Batches != 2 || ps.ChildTime != ls.WallTime || ps.PeakMemory != 2048 {
+			t.Fatalf("unexpected parent stats %+v", ps)
+		}
+		want := "Parent [rows_in=12 rows_out=6 batches=2 "
+		if out := parent.String(); !strings.HasPrefix(out, want) || !strings.Contains(out, "peak_mem=2.0KiB]\n  Leaf [rows_in=0 rows_out=12 batches=4 ") {
+			t.Fatalf("unexpected rendering\n%s", out)
+		}
+	})
+	t.Run("explain analyze drains the tree", func(t *testing.T) {
+		root := NewAnalyzedExec(&batchesOp{batch: batch, left: 3}, "Root")
+		out, err := ExplainAnalyze(context.Background(), root, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(out, "Root [rows_in=0 rows_out=9 batches=3 ") {
+			t.Fatalf("unexpected rendering\n%s", out)
+		}
+	})
+	t.Run("explain analyze keeps the stats of a failed query", func(t *testing.T) {
+		boom := errors.New("boom")
+		root := NewAnalyzedExec(&batchesOp{batch: batch, left: 1, err: boom}, "Root")
+		out, err := ExplainAnalyze(context.Background(), root, 10)
+		if !errors.Is(err, boom) || !strings.HasPrefix(out, "Root [rows_in=0 rows_out=3 batches=1 ") {
+			t.Fatalf("expected boom and the partial stats, got %v\n%s", err, out)
+		}
+	})
+}
+
+func TestArraysSize(t *testing.T) {
+	rbb := NewRecordBatchBuilder()
+	ints := rbb.GenInt64Array(1, 2, 3, 4)
+	strs := rbb.GenStringArray("a", "bc")
+	if got := ArraysSize([]arrow.Array{ints}); got < 4*8 {
+		t.Fatalf("expected at least the 32 bytes of values, got %d", got)
+	}
+	if got, want := ArraysSize([]arrow.Array{ints, nil, strs}), ArraysSize([]arrow.Array{ints})+ArraysSize([]arrow.Array{strs}); got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+	if got := ArraysSize(nil); got != 0 {
+		t.Fatalf("expected 0, got %d", got)
+	}
+}
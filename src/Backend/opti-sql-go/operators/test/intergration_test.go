@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -51,8 +52,8 @@ func source2Project() operators.Operator {
 }
 func TestPrettyPrintSources(t *testing.T) {
 	p1, p2 := source1Project(), source2Project()
-	rc1, _ := p1.Next(5)
-	rc2, _ := p2.Next(5)
+	rc1, _ := p1.Next(context.Background(), 5)
+	rc2, _ := p2.Next(context.Background(), 5)
 
 	t.Logf("source 1 batch: %v\n", rc1.PrettyPrint())
 	t.Logf("source 2 batch: %v\n", rc2.PrettyPrint())
@@ -110,7 +111,7 @@ func TestSelectFilterLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(10)
+		batch, err := lim.Next(context.Background(), 10)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -158,7 +159,7 @@ func TestSelectFilterLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -199,7 +200,7 @@ func TestSelectFilterLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -244,7 +245,7 @@ func TestFilterScalarFunctions(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -278,7 +279,7 @@ func TestFilterScalarFunctions(t *testing.T) {
 		if err != nil {
 			t.Fatalf("project init failed: %v", err)
 		}
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -312,7 +313,7 @@ func TestSelectSort(t *testing.T) {
 		if err != nil {
 			t.Fatalf("sort init failed: %v", err)
 		}
-		batch, err := sortExec.Next(100)
+		batch, err := sortExec.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -339,7 +340,7 @@ func TestSelectSort(t *testing.T) {
 		if err != nil {
 			t.Fatalf("sort init failed: %v", err)
 		}
-		batch, err := sortExec.Next(100)
+		batch, err := sortExec.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -378,7 +379,7 @@ func TestJoinSelect(t *testing.T) {
 		if err != nil {
 			t.Fatalf("project init failed: %v", err)
 		}
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -410,7 +411,7 @@ func TestJoinSelect(t *testing.T) {
 		if err != nil {
 			t.Fatalf("project init failed: %v", err)
 		}
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -442,7 +443,7 @@ func TestGroupByAggregation(t *testing.T) {
 			t.Fatalf("sort init failed: %v", err)
 		}
 
-		batch, err := sortExec.Next(1000)
+		batch, err := sortExec.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -467,7 +468,7 @@ func TestGroupByAggregation(t *testing.T) {
 			t.Fatalf("groupby init failed: %v", err)
 		}
 
-		batch, err := gb.Next(1000)
+		batch, err := gb.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -507,7 +508,7 @@ func TestDistinctSort(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -538,7 +539,7 @@ func TestDistinctSort(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -593,7 +594,7 @@ func TestJoinFilterProjLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -642,7 +643,7 @@ func TestJoinFilterProjLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -691,7 +692,7 @@ func TestJoinFilterProjLimit(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -747,7 +748,7 @@ func TestScalarAbsRound(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -784,7 +785,7 @@ func TestScalarAbsRound(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := proj.Next(100)
+		batch, err := proj.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -820,7 +821,7 @@ func TestSelectMultiSort(t *testing.T) {
 			t.Fatalf("sort init failed: %v", err)
 		}
 
-		batch, err := sortExec.Next(100)
+		batch, err := sortExec.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -851,7 +852,7 @@ func TestSelectMultiSort(t *testing.T) {
 			t.Fatalf("sort init failed: %v", err)
 		}
 
-		batch, err := sortExec.Next(100)
+		batch, err := sortExec.Next(context.Background(), 100)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -862,3 +863,86 @@ func TestSelectMultiSort(t *testing.T) {
 		t.Logf("(9B) batch:\n%v\n", batch.PrettyPrint())
 	})
 }
+
+// TestPipelinesReleaseMemory drains pipelines over the csv source with a tracked query, every
+// batch handed out must be released by whoever consumed it so nothing is left once they close
+func TestPipelinesReleaseMemory(t *testing.T) {
+	col := Expr.NewColumnResolve
+	must := func(op operators.Operator, err error) operators.Operator {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return op
+	}
+	overThirty := Expr.NewBinaryExpr(col("age_years"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int64(30)))
+	pipelines := []struct {
+		name  string
+		build func() operators.Operator
+	}{
+		{"filter", func() operators.Operator { return must(filter.NewFilterExec(source1Project(), overThirty)) }},
+		{"project", func() operators.Operator {
+			return must(project.NewProjectExec(source1Project(), Expr.NewExpressions(col("id"),
+				Expr.NewAlias(Expr.NewBinaryExpr(col("age_years"), Expr.Addition, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int64(1))), "next_age"))))
+		}},
+		{"filter project limit", func() operators.Operator {
+			f := must(filter.NewFilterExec(source1Project(), overThirty))
+			p := must(project.NewProjectExec(f, Expr.NewExpressions(col("username"))))
+			return must(filter.NewLimitExec(p, 7))
+		}},
+		{"group by having", func() operators.Operator {
+			gb := must(aggr.NewGroupByExec(source1Project(), []aggr.AggregateFunctions{
+				aggr.NewAggregateFunctions(aggr.Count, col("id")),
+				aggr.NewAggregateFunctions(aggr.Avg, col("age_years")),
+			}, []Expr.Expression{col("favorite_color")}))
+			return must(aggr.NewHavingExec(gb, Expr.NewBinaryExpr(col("count_Column(id)"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int64(1)))))
+		}},
+		{"global aggregate", func() operators.Operator {
+			return must(aggr.NewGlobalAggrExec(source1Project(), []aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Sum, col("account_balance_usd"))}))
+		}},
+		{"sort", func() operators.Operator {
+			return must(aggr.NewSortExec(source1Project(), aggr.CombineSortKeys(aggr.NewSortKey(col("account_balance_usd"), true))))
+		}},
+		{"top k", func() operators.Operator {
+			return must(aggr.NewTopKSortExec(source1Project(), aggr.CombineSortKeys(aggr.NewSortKey(col("account_balance_usd"), true)), 5))
+		}},
+		{"distinct", func() operators.Operator {
+			return must(filter.NewDistinctExec(source1Project(), []Expr.Expression{col("favorite_color")}))
+		}},
+		{"join", func() operators.Operator {
+			clause := join.NewJoinClause([]Expr.Expression{col("id")}, []Expr.Expression{col("id")})
+			return must(join.NewHashJoinExec(source1Project(), source2Project(), clause, join.InnerJoin, nil))
+		}},
+	}
+	for _, p := range pipelines {
+		// a spill threshold of one byte makes every blocking operator go through disk
+		for _, spillAt := range []int64{0, 1} {
+			t.Run(fmt.Sprintf("%s spilling at %d", p.name, spillAt), func(t *testing.T) {
+				q := operators.NewQueryMemory(nil, 0, spillAt)
+				ctx := operators.WithQueryMemory(context.Background(), q)
+				op := p.build()
+				rows := uint64(0)
+				for {
+					batch, err := operators.NextBatch(ctx, op, 16)
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					rows += batch.RowCount
+					operators.ReleaseArrays(batch.Columns)
+				}
+				if err := op.Close(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if rows == 0 || q.Peak() == 0 {
+					t.Fatalf("expected the pipeline to produce tracked rows")
+				}
+				if q.InUse() != 0 {
+					t.Fatalf("expected every batch to be released, %d bytes still in use (%+v)", q.InUse(), q.Operators())
+				}
+			})
+		}
+	}
+}
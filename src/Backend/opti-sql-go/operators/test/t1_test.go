@@ -1,6 +1,8 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"math"
@@ -235,7 +237,7 @@ func TestProjectExec(t *testing.T) {
 			t.Fatalf("unexpected error\t%v\n", basicProj)
 		}
 		//t.Logf("%v\n", basicProj.Schema())
-		rc, err := basicProj.Next(100)
+		rc, err := basicProj.Next(context.Background(), 100)
 		if err != nil {
 			if !errors.Is(err, io.EOF) {
 				t.Fatalf("unexpected error %v\n", err)
@@ -259,7 +261,7 @@ func TestProjectExec(t *testing.T) {
 			t.Fatalf("error: %v", err)
 		}
 
-		batch, _ := proj.Next(50)
+		batch, _ := proj.Next(context.Background(), 50)
 
 		// verify alias appears in schema
 		if batch.Schema.Fields()[1].Name != "emp_salary" {
@@ -287,7 +289,7 @@ func TestProjectExec(t *testing.T) {
 			t.Fatalf("error: %v", err)
 		}
 
-		batch, _ := proj.Next(50)
+		batch, _ := proj.Next(context.Background(), 50)
 
 		adjCol := batch.Columns[1].(*array.Float64)
 		_, origin := generateIntegrationDataset1(mem)
@@ -325,7 +327,7 @@ func TestProjectExec(t *testing.T) {
 			t.Fatalf("unexpected project exec error: %v", err)
 		}
 
-		batch, err := proj.Next(100) // pull all rows at once
+		batch, err := proj.Next(context.Background(), 100) // pull all rows at once
 		if err != nil {
 			t.Fatalf("unexpected error on Next: %v", err)
 		}
@@ -391,7 +393,7 @@ func TestFilterExec(t *testing.T) {
 			t.Fatalf("filter init failed: %v", err)
 		}
 
-		batch, err := filt.Next(1000)
+		batch, err := filt.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -436,7 +438,7 @@ func TestFilterExec(t *testing.T) {
 			t.Fatalf("filter init failed: %v", err)
 		}
 
-		batch, err := filt.Next(1000)
+		batch, err := filt.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -474,7 +476,7 @@ func TestFilterExec(t *testing.T) {
 			t.Fatalf("filter init failed: %v", err)
 		}
 
-		batch, err := filt.Next(1000)
+		batch, err := filt.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -517,7 +519,7 @@ func TestSortTest(t *testing.T) {
 			t.Fatalf("failed to create sort exec: %v", err)
 		}
 
-		batch, err := sortExec.Next(1000)
+		batch, err := sortExec.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -550,7 +552,7 @@ func TestSortTest(t *testing.T) {
 			t.Fatalf("failed to create sort exec: %v", err)
 		}
 
-		batch, err := sortExec.Next(1000)
+		batch, err := sortExec.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -585,7 +587,7 @@ func TestSortTest(t *testing.T) {
 			t.Fatalf("failed to create sort exec: %v", err)
 		}
 
-		batch, err := sortExec.Next(1000)
+		batch, err := sortExec.Next(context.Background(), 1000)
 		if err != nil && !errors.Is(err, io.EOF) {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -669,7 +671,7 @@ func TestIntegrationAggregations(t *testing.T) {
 			t.Fatalf("aggregation init failed: %v", err)
 		}
 
-		batch, err := agg.Next(100)
+		batch, err := agg.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("aggregation next failed: %v", err)
 		}
@@ -702,10 +704,10 @@ func TestIntegrationAggregations(t *testing.T) {
 		ageArr := cols[3].(*array.Int32)
 
 		// Expected SUM(age)
-		var sum int32
+		var sum int64
 		for i := 0; i < ageArr.Len(); i++ {
 			if !ageArr.IsNull(i) {
-				sum += ageArr.Value(i)
+				sum += int64(ageArr.Value(i))
 			}
 		}
 
@@ -722,10 +724,10 @@ func TestIntegrationAggregations(t *testing.T) {
 			t.Fatalf("agg init failed: %v", err)
 		}
 
-		batch, _ := agg.Next(100)
-		sumArr := batch.Columns[0].(*array.Float64) // SUM(int32) -> int64
+		batch, _ := agg.Next(context.Background(), 100)
+		sumArr := batch.Columns[0].(*array.Int64) // SUM(int32) -> int64
 
-		if sumArr.Value(0) != float64(sum) {
+		if sumArr.Value(0) != sum {
 			t.Fatalf("SUM(age) mismatch: expected %v, got %v", sum, sumArr.Value(0))
 		}
 	})
@@ -764,15 +766,15 @@ func TestIntegrationAggregations(t *testing.T) {
 			t.Fatalf("agg init failed: %v", err)
 		}
 
-		batch, _ := agg.Next(100)
+		batch, _ := agg.Next(context.Background(), 100)
 
-		minArr := batch.Columns[0].(*array.Float64)
-		maxArr := batch.Columns[1].(*array.Float64)
+		minArr := batch.Columns[0].(*array.Int32)
+		maxArr := batch.Columns[1].(*array.Int32)
 
-		if minArr.Value(0) != float64(min) {
+		if minArr.Value(0) != min {
 			t.Fatalf("MIN(age) mismatch: expected %v, got %v", min, minArr.Value(0))
 		}
-		if maxArr.Value(0) != float64(max) {
+		if maxArr.Value(0) != max {
 			t.Fatalf("MAX(age) mismatch: expected %v, got %v", max, maxArr.Value(0))
 		}
 	})
@@ -806,13 +808,13 @@ func TestGroupByExec(t *testing.T) {
 			t.Fatalf("gb init failed: %v", err)
 		}
 
-		batch, err := gb.Next(1024)
+		batch, err := gb.Next(context.Background(), 1024)
 		if err != nil {
 			t.Fatalf("group by Next failed: %v", err)
 		}
 
 		deptCol := batch.Columns[0].(*array.String)
-		countCol := batch.Columns[1].(*array.Float64) // count returns float64 in your impl
+		countCol := batch.Columns[1].(*array.Int64)
 
 		// Validate counts by manually counting departments
 		origDept := originCols[5].(*array.String)
@@ -857,7 +859,7 @@ func TestGroupByExec(t *testing.T) {
 			t.Fatalf("init failed: %v", err)
 		}
 
-		batch, err := gb.Next(1024)
+		batch, err := gb.Next(context.Background(), 1024)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
@@ -922,13 +924,13 @@ func TestGroupByExec(t *testing.T) {
 
 		gb, _ := aggr.NewGroupByExec(src, aggs, groupByExpr)
 
-		batch, err := gb.Next(1024)
+		batch, err := gb.Next(context.Background(), 1024)
 		if err != nil {
 			t.Fatalf("Next failed: %v", err)
 		}
 
 		regionCol := batch.Columns[0].(*array.String)
-		countCol := batch.Columns[1].(*array.Float64)
+		countCol := batch.Columns[1].(*array.Int64)
 
 		origRegion := originCols[6].(*array.String)
 		expected := make(map[string]int)
@@ -990,7 +992,7 @@ func TestHavingExec(t *testing.T) {
 		)
 
 		hv, _ := aggr.NewHavingExec(gb, having)
-		batch, err := hv.Next(500)
+		batch, err := hv.Next(context.Background(), 500)
 		if err != nil {
 			t.Fatalf("having next failed: %v", err)
 		}
@@ -1018,10 +1020,9 @@ func TestHavingExec(t *testing.T) {
 		)
 
 		hv, _ := aggr.NewHavingExec(gb, having)
-		batch, _ := hv.Next(100)
-
-		if batch.RowCount != 0 {
-			t.Fatalf("expected empty result")
+		// groups the predicate drops are skipped, nothing passing means straight to EOF
+		if _, err := hv.Next(context.Background(), 100); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected empty result, got %v", err)
 		}
 	})
 
@@ -1036,7 +1037,7 @@ func TestHavingExec(t *testing.T) {
 		)
 
 		hv, _ := aggr.NewHavingExec(gb, having)
-		batch, _ := hv.Next(1000)
+		batch, _ := hv.Next(context.Background(), 1000)
 
 		if batch.RowCount == 0 {
 			t.Fatalf("expected some rows")
@@ -1072,7 +1073,7 @@ func TestDistinctExec(t *testing.T) {
 			t.Fatalf("distinct init failed: %v", err)
 		}
 
-		batch, err := de.Next(100)
+		batch, err := de.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("distinct next failed: %v", err)
 		}
@@ -1112,7 +1113,7 @@ func TestDistinctExec(t *testing.T) {
 			t.Fatalf("distinct init failed: %v", err)
 		}
 
-		batch, err := de.Next(100)
+		batch, err := de.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("distinct next failed: %v", err)
 		}
@@ -1150,7 +1151,7 @@ func TestDistinctExec(t *testing.T) {
 			t.Fatalf("distinct init failed: %v", err)
 		}
 
-		batch, err := de.Next(100)
+		batch, err := de.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("distinct next failed: %v", err)
 		}
@@ -1181,7 +1182,7 @@ func TestLimitExec(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("limit next error: %v", err)
 		}
@@ -1213,7 +1214,7 @@ func TestLimitExec(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("limit error: %v", err)
 		}
@@ -1234,7 +1235,7 @@ func TestLimitExec(t *testing.T) {
 			t.Fatalf("limit init failed: %v", err)
 		}
 
-		batch, err := lim.Next(100)
+		batch, err := lim.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("limit next failed: %v", err)
 		}
@@ -1264,12 +1265,12 @@ func TestScalarStringFunctions(t *testing.T) {
 		upperExpr := Expr.NewScalarFunction(Expr.Upper, colDept)
 
 		// Evaluate: UPPER(department)
-		batch, err := src.Next(100)
+		batch, err := src.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
 
-		arr, err := Expr.EvalScalarFunction(upperExpr, batch)
+		arr, err := Expr.EvalScalarFunction(context.Background(), upperExpr, batch)
 		if err != nil {
 			t.Fatalf("upper eval failed: %v", err)
 		}
@@ -1277,7 +1278,7 @@ func TestScalarStringFunctions(t *testing.T) {
 		out := arr.(*array.String)
 
 		// Compare with strings.ToUpper
-		deptCol, _ := Expr.EvalExpression(colDept, batch)
+		deptCol, _ := Expr.EvalExpression(context.Background(), colDept, batch)
 		deptArr := deptCol.(*array.String)
 
 		for i := 0; i < int(out.Len()); i++ {
@@ -1302,19 +1303,19 @@ func TestScalarStringFunctions(t *testing.T) {
 		lowerExpr := Expr.NewScalarFunction(Expr.Lower, colDept)
 
 		// Evaluate: LOWER(department)
-		batch, err := src.Next(100)
+		batch, err := src.Next(context.Background(), 100)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
 
-		arr, err := Expr.EvalScalarFunction(lowerExpr, batch)
+		arr, err := Expr.EvalScalarFunction(context.Background(), lowerExpr, batch)
 		if err != nil {
 			t.Fatalf("lower eval failed: %v", err)
 		}
 
 		out := arr.(*array.String)
 
-		deptCol, _ := Expr.EvalExpression(colDept, batch)
+		deptCol, _ := Expr.EvalExpression(context.Background(), colDept, batch)
 		deptArr := deptCol.(*array.String)
 
 		for i := 0; i < int(out.Len()); i++ {
@@ -1340,7 +1341,7 @@ func TestScalarStringFunctions(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := exec.Next(50)
+		batch, err := exec.Next(context.Background(), 50)
 		if err != nil {
 			t.Fatalf("exec failed: %v", err)
 		}
@@ -1368,7 +1369,7 @@ func TestScalarStringFunctions(t *testing.T) {
 			t.Fatalf("project init failed: %v", err)
 		}
 
-		batch, err := exec.Next(50)
+		batch, err := exec.Next(context.Background(), 50)
 		if err != nil {
 			t.Fatalf("exec failed: %v", err)
 		}
@@ -1409,7 +1410,7 @@ func TestHashJoinExec(t *testing.T) {
 			t.Fatalf("inner join init failed: %v", err)
 		}
 
-		batch, err := j.Next(1000)
+		batch, err := j.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
@@ -1433,7 +1434,7 @@ func TestHashJoinExec(t *testing.T) {
 			t.Fatalf("left join init failed: %v", err)
 		}
 
-		batch, err := j.Next(1000)
+		batch, err := j.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
@@ -1457,7 +1458,7 @@ func TestHashJoinExec(t *testing.T) {
 			t.Fatalf("right join init failed: %v", err)
 		}
 
-		batch, err := j.Next(1000)
+		batch, err := j.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
@@ -1482,7 +1483,7 @@ func TestHashJoinExec(t *testing.T) {
 			t.Fatalf("inner join init failed: %v", err)
 		}
 
-		batch, err := j.Next(1000)
+		batch, err := j.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
@@ -1512,7 +1513,7 @@ func TestHashJoinExec(t *testing.T) {
 			t.Fatalf("multi-col join init failed: %v", err)
 		}
 
-		batch, err := j.Next(1000)
+		batch, err := j.Next(context.Background(), 1000)
 		if err != nil {
 			t.Fatalf("unexpected: %v", err)
 		}
@@ -1556,3 +1557,145 @@ func TestHashJoinExec(t *testing.T) {
 		}
 	})
 }
+
+// cancelAfterFirstBatch hands out two rows at a time and cancels the query once the first batch is out,
+// like a client going away while a pipeline breaker is still consuming its input
+type cancelAfterFirstBatch struct {
+	operators.Operator
+	cancel context.CancelFunc
+	pulls  int
+}
+
+func (c *cancelAfterFirstBatch) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
+	c.pulls++
+	defer c.cancel()
+	return c.Operator.Next(ctx, 2)
+}
+
+func TestCancellation(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	sum := []aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Sum, Expr.NewColumnResolve("salary"))}
+	department := []Expr.Expression{Expr.NewColumnResolve("department")}
+	cases := map[string]func(src operators.Operator) (operators.Operator, error){
+		"sort": func(src operators.Operator) (operators.Operator, error) {
+			return aggr.NewSortExec(src, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("age"))))
+		},
+		"top k": func(src operators.Operator) (operators.Operator, error) {
+			return aggr.NewTopKSortExec(src, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("age"))), 3)
+		},
+		"group by": func(src operators.Operator) (operators.Operator, error) {
+			return aggr.NewGroupByExec(src, sum, department)
+		},
+		"global aggregate": func(src operators.Operator) (operators.Operator, error) {
+			return aggr.NewGlobalAggrExec(src, sum)
+		},
+		"distinct": func(src operators.Operator) (operators.Operator, error) {
+			return filter.NewDistinctExec(src, department)
+		},
+		"hash join build side": func(src operators.Operator) (operators.Operator, error) {
+			right, err := NewIntegrationSource2(mem)
+			if err != nil {
+				return nil, err
+			}
+			return join.NewHashJoinExec(right, src, join.NewJoinClause(department, department), join.InnerJoin, nil)
+		},
+	}
+	for name, build := range cases {
+		t.Run(name, func(t *testing.T) {
+			src1, err := NewIntegrationSource1(mem)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			src := &cancelAfterFirstBatch{Operator: src1, cancel: cancel}
+			op, err := build(src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := op.Next(ctx, 100); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			// 20 rows, two at a time: the input must not be drained after the cancel
+			if src.pulls != 1 {
+				t.Fatalf("kept consuming after the cancel, %d pulls", src.pulls)
+			}
+		})
+	}
+}
+
+func TestExplainPipeline(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	left, err := NewIntegrationSource1(mem)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	right, err := NewIntegrationSource2(mem)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	department := []Expr.Expression{Expr.NewColumnResolve("department")}
+	joined, err := join.NewHashJoinExec(left, right, join.NewJoinClause(department, department), join.InnerJoin, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filtered, err := filter.NewFilterExec(joined, Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, int32(30))))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	grouped, err := aggr.NewGroupByExec(filtered, []aggr.AggregateFunctions{aggr.NewAggregateFunctions(aggr.Avg, Expr.NewColumnResolve("salary"))}, []Expr.Expression{Expr.NewColumnResolve("left_region")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sorted, err := aggr.NewTopKSortExec(grouped, aggr.CombineSortKeys(aggr.NewSortKey(Expr.NewColumnResolve("avg_Column(salary)"))), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limited, err := filter.NewLimitExec(sorted, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	text := operators.ExplainText(limited)
+	t.Log("\n" + text)
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	want := []string{
+		"LimitExec limit=1 schema=[group_Column(left_region): utf8, avg_Column(salary): float64]",
+		"  TopKSortExec k=2 keys=[Column(avg_Column(salary)) DESC NULLS LAST] schema=[group_Column(left_region): utf8, avg_Column(salary): float64]",
+		"    GroupByExec aggregates=[AVG(Column(salary))] group_by=[Column(left_region)] schema=[group_Column(left_region): utf8, avg_Column(salary): float64]",
+		"      FilterExec predicate=" + Expr.NewBinaryExpr(Expr.NewColumnResolve("age"), Expr.GreaterThan, Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, int32(30))).String() + " schema=[",
+		"        HashJoinExec join_type=INNER JOIN on=Column(department) = Column(department) schema=[id: ",
+		"          InMemorySource rows=20 schema=[id: ",
+		"          InMemorySource rows=",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d operators, got\n%s", len(want), text)
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Fatalf("line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+
+	data, err := operators.ExplainJSON(limited)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var root operators.PlanNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	join := root.Children[0].Children[0].Children[0].Children[0]
+	if join.Type != "HashJoinExec" || join.Params["on"] != "Column(department) = Column(department)" || len(join.Children) != 2 {
+		t.Fatalf("unexpected join node %+v", join)
+	}
+	if len(root.Schema) != 2 || root.Schema[1] != (operators.PlanField{Name: "avg_Column(salary)", Type: "float64", Nullable: true}) {
+		t.Fatalf("unexpected root schema %+v", root.Schema)
+	}
+
+	// explaining doesn't touch the pipeline, it still runs
+	batch, err := limited.Next(context.Background(), 10)
+	if err != nil || batch.RowCount != 1 {
+		t.Fatalf("expected a row, got %v %v", batch, err)
+	}
+}
@@ -0,0 +1,472 @@
+package physicaloptimizer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// typed syntax tree produced by ParseSQL. nothing in here has been checked against a schema yet,
+// that is the binder's job. every node remembers where it started in the original sql text so
+// later stages can point at the offending token.
+
+// Pos is a 1-based line/column position in the sql text
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Col)
+}
+
+type Node interface {
+	Position() Pos
+	fmt.Stringer
+}
+
+// ExprNode is any node that can appear where a value is expected
+type ExprNode interface {
+	Node
+	exprNode()
+}
+
+// TableExpr is anything that can appear in a FROM clause
+type TableExpr interface {
+	Node
+	tableExpr()
+}
+
+var (
+	_ = (ExprNode)(&Identifier{})
+	_ = (ExprNode)(&StarExpr{})
+	_ = (ExprNode)(&IntegerLiteral{})
+	_ = (ExprNode)(&FloatLiteral{})
+	_ = (ExprNode)(&StringLiteral{})
+	_ = (ExprNode)(&BinaryLiteral{})
+	_ = (ExprNode)(&BooleanLiteral{})
+	_ = (ExprNode)(&NullLiteral{})
+	_ = (ExprNode)(&BinaryOpExpr{})
+	_ = (ExprNode)(&UnaryOpExpr{})
+	_ = (ExprNode)(&IsNullExpr{})
+	_ = (ExprNode)(&FuncCall{})
+	_ = (ExprNode)(&CastExpr{})
+	_ = (TableExpr)(&TableRef{})
+	_ = (TableExpr)(&JoinExpr{})
+)
+
+// ================
+// Statement
+// ================
+
+// SelectStatement
+// sql: SELECT [DISTINCT] items FROM from [WHERE] [GROUP BY] [HAVING] [ORDER BY] [LIMIT]
+type SelectStatement struct {
+	Pos      Pos
+	Distinct bool
+	Items    []SelectItem
+	From     TableExpr
+	Where    ExprNode // nil when absent
+	GroupBy  []ExprNode
+	Having   ExprNode // nil when absent
+	OrderBy  []OrderItem
+	Limit    *LimitClause // nil when absent
+}
+
+func (s *SelectStatement) Position() Pos { return s.Pos }
+func (s *SelectStatement) String() string {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if s.Distinct {
+		b.WriteString("DISTINCT ")
+	}
+	for i, item := range s.Items {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(item.String())
+	}
+	b.WriteString(" FROM ")
+	b.WriteString(s.From.String())
+	if s.Where != nil {
+		b.WriteString(" WHERE ")
+		b.WriteString(s.Where.String())
+	}
+	if len(s.GroupBy) > 0 {
+		b.WriteString(" GROUP BY ")
+		b.WriteString(joinNodes(s.GroupBy))
+	}
+	if s.Having != nil {
+		b.WriteString(" HAVING ")
+		b.WriteString(s.Having.String())
+	}
+	if len(s.OrderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		for i, o := range s.OrderBy {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(o.String())
+		}
+	}
+	if s.Limit != nil {
+		b.WriteString(" ")
+		b.WriteString(s.Limit.String())
+	}
+	return b.String()
+}
+
+// SelectItem is one entry of the select list. Alias is empty when no AS was given
+type SelectItem struct {
+	Pos   Pos
+	Expr  ExprNode
+	Alias string
+}
+
+func (s SelectItem) Position() Pos { return s.Pos }
+func (s SelectItem) String() string {
+	if s.Alias == "" {
+		return s.Expr.String()
+	}
+	return fmt.Sprintf("%s AS %s", s.Expr, s.Alias)
+}
+
+type OrderItem struct {
+	Pos        Pos
+	Expr       ExprNode
+	Descending bool
+	NullsFirst bool
+}
+
+func (o OrderItem) Position() Pos { return o.Pos }
+func (o OrderItem) String() string {
+	dir := "ASC"
+	if o.Descending {
+		dir = "DESC"
+	}
+	nulls := "LAST"
+	if o.NullsFirst {
+		nulls = "FIRST"
+	}
+	return fmt.Sprintf("%s %s NULLS %s", o.Expr, dir, nulls)
+}
+
+type LimitClause struct {
+	Pos   Pos
+	Count uint64
+}
+
+func (l *LimitClause) Position() Pos  { return l.Pos }
+func (l *LimitClause) String() string { return fmt.Sprintf("LIMIT %d", l.Count) }
+
+// ================
+// FROM clause
+// ================
+
+// TableRef
+// sql: FROM source1 AS s
+type TableRef struct {
+	Pos   Pos
+	Name  string
+	Alias string
+}
+
+func (t *TableRef) Position() Pos { return t.Pos }
+func (t *TableRef) tableExpr()    {}
+func (t *TableRef) String() string {
+	if t.Alias == "" {
+		return t.Name
+	}
+	return fmt.Sprintf("%s AS %s", t.Name, t.Alias)
+}
+
+// Qualifier is the name columns of this table are referenced by
+func (t *TableRef) Qualifier() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.Name
+}
+
+type JoinKind int
+
+const (
+	InnerJoinKind JoinKind = iota
+	LeftJoinKind
+	RightJoinKind
+)
+
+func (k JoinKind) String() string {
+	switch k {
+	case InnerJoinKind:
+		return "INNER JOIN"
+	case LeftJoinKind:
+		return "LEFT JOIN"
+	case RightJoinKind:
+		return "RIGHT JOIN"
+	default:
+		return "UNKNOWN JOIN"
+	}
+}
+
+// JoinExpr
+// sql: left JOIN right ON condition. chains of joins nest to the left
+type JoinExpr struct {
+	Pos   Pos
+	Kind  JoinKind
+	Left  TableExpr
+	Right TableExpr
+	On    ExprNode
+}
+
+func (j *JoinExpr) Position() Pos { return j.Pos }
+func (j *JoinExpr) tableExpr()    {}
+func (j *JoinExpr) String() string {
+	return fmt.Sprintf("%s %s %s ON %s", j.Left, j.Kind, j.Right, j.On)
+}
+
+// ================
+// Expressions
+// ================
+
+// Identifier is a (possibly table qualified) column reference
+// sql: age | s.age
+type Identifier struct {
+	Pos   Pos
+	Table string // empty when unqualified
+	Name  string
+}
+
+func (i *Identifier) Position() Pos { return i.Pos }
+func (i *Identifier) exprNode()     {}
+func (i *Identifier) String() string {
+	if i.Table == "" {
+		return i.Name
+	}
+	return i.Table + "." + i.Name
+}
+
+// StarExpr is * or t.* in a select list, and the argument of COUNT(*)
+type StarExpr struct {
+	Pos   Pos
+	Table string
+}
+
+func (s *StarExpr) Position() Pos { return s.Pos }
+func (s *StarExpr) exprNode()     {}
+func (s *StarExpr) String() string {
+	if s.Table == "" {
+		return "*"
+	}
+	return s.Table + ".*"
+}
+
+type IntegerLiteral struct {
+	Pos   Pos
+	Value int64
+}
+
+func (l *IntegerLiteral) Position() Pos  { return l.Pos }
+func (l *IntegerLiteral) exprNode()      {}
+func (l *IntegerLiteral) String() string { return fmt.Sprintf("%d", l.Value) }
+
+type FloatLiteral struct {
+	Pos   Pos
+	Value float64
+}
+
+func (l *FloatLiteral) Position() Pos  { return l.Pos }
+func (l *FloatLiteral) exprNode()      {}
+func (l *FloatLiteral) String() string { return fmt.Sprintf("%g", l.Value) }
+
+type StringLiteral struct {
+	Pos   Pos
+	Value string
+}
+
+func (l *StringLiteral) Position() Pos { return l.Pos }
+func (l *StringLiteral) exprNode()     {}
+func (l *StringLiteral) String() string {
+	return "'" + strings.ReplaceAll(l.Value, "'", "''") + "'"
+}
+
+// BinaryLiteral
+// sql: X'DEADBEEF'
+type BinaryLiteral struct {
+	Pos   Pos
+	Value []byte
+}
+
+func (l *BinaryLiteral) Position() Pos  { return l.Pos }
+func (l *BinaryLiteral) exprNode()      {}
+func (l *BinaryLiteral) String() string { return fmt.Sprintf("X'%X'", l.Value) }
+
+type BooleanLiteral struct {
+	Pos   Pos
+	Value bool
+}
+
+func (l *BooleanLiteral) Position() Pos { return l.Pos }
+func (l *BooleanLiteral) exprNode()     {}
+func (l *BooleanLiteral) String() string {
+	if l.Value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+type NullLiteral struct {
+	Pos Pos
+}
+
+func (l *NullLiteral) Position() Pos  { return l.Pos }
+func (l *NullLiteral) exprNode()      {}
+func (l *NullLiteral) String() string { return "NULL" }
+
+type BinaryOp int
+
+const (
+	OpAdd BinaryOp = iota
+	OpSub
+	OpMul
+	OpDiv
+	OpEq
+	OpNotEq
+	OpLt
+	OpLtEq
+	OpGt
+	OpGtEq
+	OpAnd
+	OpOr
+	OpLike
+)
+
+func (o BinaryOp) String() string {
+	switch o {
+	case OpAdd:
+		return "+"
+	case OpSub:
+		return "-"
+	case OpMul:
+		return "*"
+	case OpDiv:
+		return "/"
+	case OpEq:
+		return "="
+	case OpNotEq:
+		return "!="
+	case OpLt:
+		return "<"
+	case OpLtEq:
+		return "<="
+	case OpGt:
+		return ">"
+	case OpGtEq:
+		return ">="
+	case OpAnd:
+		return "AND"
+	case OpOr:
+		return "OR"
+	case OpLike:
+		return "LIKE"
+	default:
+		return "?"
+	}
+}
+
+type BinaryOpExpr struct {
+	Pos   Pos
+	Op    BinaryOp
+	Left  ExprNode
+	Right ExprNode
+}
+
+func (b *BinaryOpExpr) Position() Pos { return b.Pos }
+func (b *BinaryOpExpr) exprNode()     {}
+func (b *BinaryOpExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", b.Left, b.Op, b.Right)
+}
+
+type UnaryOp int
+
+const (
+	OpNot UnaryOp = iota
+	OpNeg
+)
+
+func (o UnaryOp) String() string {
+	if o == OpNot {
+		return "NOT "
+	}
+	return "-"
+}
+
+type UnaryOpExpr struct {
+	Pos  Pos
+	Op   UnaryOp
+	Expr ExprNode
+}
+
+func (u *UnaryOpExpr) Position() Pos  { return u.Pos }
+func (u *UnaryOpExpr) exprNode()      {}
+func (u *UnaryOpExpr) String() string { return fmt.Sprintf("%s%s", u.Op, u.Expr) }
+
+// IsNullExpr
+// sql: x IS NULL | x IS NOT NULL
+type IsNullExpr struct {
+	Pos  Pos
+	Expr ExprNode
+	Not  bool
+}
+
+func (i *IsNullExpr) Position() Pos { return i.Pos }
+func (i *IsNullExpr) exprNode()     {}
+func (i *IsNullExpr) String() string {
+	if i.Not {
+		return fmt.Sprintf("%s IS NOT NULL", i.Expr)
+	}
+	return fmt.Sprintf("%s IS NULL", i.Expr)
+}
+
+// FuncCall covers both scalar functions (UPPER, ABS...) and aggregates (SUM, COUNT...).
+// Name is always upper case. COUNT(*) is represented with a single StarExpr argument
+type FuncCall struct {
+	Pos      Pos
+	Name     string
+	Distinct bool
+	Args     []ExprNode
+}
+
+func (f *FuncCall) Position() Pos { return f.Pos }
+func (f *FuncCall) exprNode()     {}
+func (f *FuncCall) String() string {
+	distinct := ""
+	if f.Distinct {
+		distinct = "DISTINCT "
+	}
+	return fmt.Sprintf("%s(%s%s)", f.Name, distinct, joinNodes(f.Args))
+}
+
+// CastExpr
+// sql: CAST(x AS BIGINT). the target type is resolved while parsing
+type CastExpr struct {
+	Pos      Pos
+	Expr     ExprNode
+	TypeName string
+	Type     arrow.DataType
+}
+
+func (c *CastExpr) Position() Pos { return c.Pos }
+func (c *CastExpr) exprNode()     {}
+func (c *CastExpr) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", c.Expr, c.TypeName)
+}
+
+func joinNodes(nodes []ExprNode) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,1005 @@
+package physicaloptimizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// the binder walks the syntax tree from ParseSQL, resolves every name against the schemas in a
+// catalog and produces a logical plan. this is where "which table is that column from" and
+// "can these two things be compared" get answered, everything after it can assume a well typed tree.
+
+// kinds of bind errors, test for them with errors.Is
+var (
+	ErrUnknownTable     = errors.New("unknown table")
+	ErrDuplicateTable   = errors.New("duplicate table")
+	ErrUnknownColumn    = errors.New("unknown column")
+	ErrAmbiguousColumn  = errors.New("ambiguous column")
+	ErrTypeMismatch     = errors.New("type mismatch")
+	ErrInvalidAggregate = errors.New("invalid aggregate")
+	ErrUnsupported      = errors.New("unsupported")
+)
+
+// BindError points at the part of the query that could not be bound
+type BindError struct {
+	Pos Pos
+	Err error // one of the Err* kinds above
+	Msg string
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("%s at %s: %s", e.Err, e.Pos, e.Msg)
+}
+func (e *BindError) Unwrap() error { return e.Err }
+
+func bindErr(n Node, kind error, format string, args ...any) error {
+	return &BindError{Pos: n.Position(), Err: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+type Binder struct {
+	catalog logicalplan.Catalog
+}
+
+func NewBinder(catalog logicalplan.Catalog) *Binder {
+	return &Binder{catalog: catalog}
+}
+
+// ================
+// name resolution
+// ================
+
+// scopeColumn is a column visible to expressions
+type scopeColumn struct {
+	qualifier string // table name or alias the column can be prefixed with, empty for computed columns
+	name      string // name the query refers to it by
+	field     string // name of the field in the schema of the plan below, differs from name after a join (left_id)
+}
+
+type scope struct {
+	columns []scopeColumn
+	schema  *arrow.Schema // schema the resolved Expr.ColumnResolve values refer to
+}
+
+func (s *scope) resolve(id *Identifier) (scopeColumn, error) {
+	matches := s.lookup(id, false)
+	if len(matches) == 0 {
+		matches = s.lookup(id, true)
+	}
+	if len(matches) == 0 && id.Table == "" {
+		// after a join both sides of a clashing name are exposed as left_x/right_x, allow those too
+		for _, c := range s.columns {
+			if strings.EqualFold(c.field, id.Name) {
+				matches = append(matches, c)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return scopeColumn{}, bindErr(id, ErrUnknownColumn, "column %q does not exist", id.String())
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, m := range matches {
+			candidates[i] = m.qualifier + "." + m.name
+		}
+		return scopeColumn{}, bindErr(id, ErrAmbiguousColumn, "column reference %q is ambiguous, could be any of %s", id.String(), strings.Join(candidates, ", "))
+	}
+}
+
+func (s *scope) lookup(id *Identifier, fold bool) []scopeColumn {
+	eq := func(a, b string) bool {
+		if fold {
+			return strings.EqualFold(a, b)
+		}
+		return a == b
+	}
+	var out []scopeColumn
+	for _, c := range s.columns {
+		if id.Table != "" && !eq(c.qualifier, id.Table) {
+			continue
+		}
+		if eq(c.name, id.Name) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// star expands * or t.*
+func (s *scope) star(st *StarExpr) ([]scopeColumn, error) {
+	if st.Table == "" {
+		return s.columns, nil
+	}
+	var out []scopeColumn
+	for _, c := range s.columns {
+		if strings.EqualFold(c.qualifier, st.Table) {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return nil, bindErr(st, ErrUnknownTable, "table %q is not part of the FROM clause", st.Table)
+	}
+	return out, nil
+}
+
+func (s *scope) hasQualifier(q string) bool {
+	for _, c := range s.columns {
+		if strings.EqualFold(c.qualifier, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprContext is everything needed to bind one expression
+type exprContext struct {
+	scope  *scope
+	clause string       // used in error messages, "WHERE", "ORDER BY"...
+	agg    *aggregation // set when the expression is evaluated on top of an aggregate
+}
+
+func (c *exprContext) schema() *arrow.Schema {
+	if c.agg != nil {
+		return c.agg.schema()
+	}
+	return c.scope.schema
+}
+
+// aggregation collects the group by keys and aggregate calls of a query. expressions evaluated above
+// the aggregate may only reference those, so they are rewritten into references to its output columns
+type aggregation struct {
+	input       *exprContext // aggregate arguments and group keys are bound here
+	groupBy     []Expr.Expression
+	groupFields []arrow.Field
+	aggs        []aggr.AggregateFunctions
+	aggFields   []arrow.Field
+}
+
+func (a *aggregation) schema() *arrow.Schema {
+	fields := append(append([]arrow.Field{}, a.groupFields...), a.aggFields...)
+	return arrow.NewSchema(fields, nil)
+}
+
+func (a *aggregation) groupField(e Expr.Expression) (string, bool) {
+	for i, g := range a.groupBy {
+		if g.String() == e.String() {
+			return a.groupFields[i].Name, true
+		}
+	}
+	return "", false
+}
+
+func (a *aggregation) add(f aggr.AggregateFunctions) (arrow.Field, error) {
+	s, err := aggr.AggregateSchema(a.input.scope.schema, a.groupBy, []aggr.AggregateFunctions{f})
+	if err != nil {
+		return arrow.Field{}, err
+	}
+	field := s.Field(s.NumFields() - 1)
+	for _, existing := range a.aggFields {
+		if existing.Name == field.Name {
+			return existing, nil
+		}
+	}
+	a.aggs = append(a.aggs, f)
+	a.aggFields = append(a.aggFields, field)
+	return field, nil
+}
+
+// ================
+// statements
+// ================
+
+// outputColumn is one column of the select list after * expansion
+type outputColumn struct {
+	name      string
+	qualifier string
+	expr      Expr.Expression
+}
+
+// Bind resolves stmt against the catalog and returns the logical plan for it
+func (b *Binder) Bind(stmt *SelectStatement) (logicalplan.Plan, error) {
+	plan, input, err := b.bindFrom(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Where != nil {
+		pred, err := b.bindPredicate(stmt.Where, &exprContext{scope: input, clause: "WHERE"})
+		if err != nil {
+			return nil, err
+		}
+		if plan, err = logicalplan.NewFilter(plan, pred); err != nil {
+			return nil, bindErr(stmt.Where, ErrTypeMismatch, "%v", err)
+		}
+	}
+
+	selectCtx := &exprContext{scope: input, clause: "SELECT"}
+	var ag *aggregation
+	if needsAggregation(stmt) {
+		if ag, err = b.bindGroupBy(stmt.GroupBy, input); err != nil {
+			return nil, err
+		}
+		selectCtx = &exprContext{scope: input, clause: "SELECT", agg: ag}
+	}
+
+	// select list
+	var outputs []outputColumn
+	for _, item := range stmt.Items {
+		if st, ok := item.Expr.(*StarExpr); ok {
+			cols, err := input.star(st)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range cols {
+				var e Expr.Expression = Expr.NewColumnResolve(c.field)
+				if ag != nil {
+					field, ok := ag.groupField(e)
+					if !ok {
+						return nil, bindErr(st, ErrInvalidAggregate, "column %q must appear in the GROUP BY clause or be used in an aggregate function", c.name)
+					}
+					e = Expr.NewColumnResolve(field)
+				}
+				outputs = append(outputs, outputColumn{name: c.field, qualifier: c.qualifier, expr: e})
+			}
+			continue
+		}
+		e, err := b.bindExpr(item.Expr, selectCtx)
+		if err != nil {
+			return nil, err
+		}
+		out := outputColumn{name: item.Alias, expr: e}
+		if id, ok := item.Expr.(*Identifier); ok {
+			if out.name == "" {
+				out.name = id.Name
+			}
+			if c, err := input.resolve(id); err == nil {
+				out.qualifier = c.qualifier
+			}
+		}
+		if out.name == "" {
+			out.name = item.Expr.String()
+		}
+		outputs = append(outputs, out)
+	}
+
+	var having Expr.Expression
+	if stmt.Having != nil {
+		having, err = b.bindPredicate(stmt.Having, &exprContext{scope: input, clause: "HAVING", agg: ag})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// order by, prefer the select list names and only fall back to the input columns when that fails
+	outScope := &scope{}
+	outFields := make([]arrow.Field, len(outputs))
+	for i, o := range outputs {
+		dt, err := Expr.ExprDataType(o.expr, selectCtx.schema())
+		if err != nil {
+			return nil, bindErr(stmt, ErrTypeMismatch, "%v", err)
+		}
+		outFields[i] = arrow.Field{Name: o.name, Type: dt, Nullable: true}
+		outScope.columns = append(outScope.columns, scopeColumn{qualifier: o.qualifier, name: o.name, field: o.name})
+	}
+	outScope.schema = arrow.NewSchema(outFields, nil)
+
+	type boundKey struct {
+		above, below Expr.Expression
+		item         OrderItem
+	}
+	keys := make([]boundKey, len(stmt.OrderBy))
+	sortBelow := false
+	for i, item := range stmt.OrderBy {
+		keys[i].item = item
+		if lit, ok := item.Expr.(*IntegerLiteral); ok {
+			if lit.Value < 1 || lit.Value > int64(len(outputs)) {
+				return nil, bindErr(lit, ErrUnknownColumn, "ORDER BY position %d is not in the select list", lit.Value)
+			}
+			keys[i].above = Expr.NewColumnResolve(outputs[lit.Value-1].name)
+			continue
+		}
+		e, err := b.bindExpr(item.Expr, &exprContext{scope: outScope, clause: "ORDER BY"})
+		if err == nil {
+			keys[i].above = e
+			continue
+		}
+		if stmt.Distinct {
+			return nil, bindErr(item.Expr, ErrUnsupported, "for SELECT DISTINCT, ORDER BY expressions must appear in the select list")
+		}
+		below, err := b.bindExpr(item.Expr, &exprContext{scope: input, clause: "ORDER BY", agg: ag})
+		if err != nil {
+			return nil, err
+		}
+		keys[i].below = below
+		sortBelow = true
+	}
+
+	// everything is bound, build the plan bottom up
+	if ag != nil {
+		agg, err := logicalplan.NewAggregate(plan, ag.groupBy, ag.aggs)
+		if err != nil {
+			return nil, bindErr(stmt, ErrTypeMismatch, "%v", err)
+		}
+		plan = agg
+		if having != nil {
+			if plan, err = logicalplan.NewHaving(plan, having); err != nil {
+				return nil, bindErr(stmt.Having, ErrTypeMismatch, "%v", err)
+			}
+		}
+	}
+
+	sortKeys := func(pick func(k boundKey) Expr.Expression) []aggr.SortKey {
+		out := make([]aggr.SortKey, len(keys))
+		for i, k := range keys {
+			out[i] = *aggr.NewSortKey(pick(k), !k.item.Descending, k.item.NullsFirst)
+		}
+		return out
+	}
+	if sortBelow {
+		// keys that resolved against the select list have to be rewritten in terms of the input
+		byName := make(map[string]Expr.Expression, len(outputs))
+		for _, o := range outputs {
+			byName[o.name] = o.expr
+		}
+		sorted, err := logicalplan.NewSort(plan, sortKeys(func(k boundKey) Expr.Expression {
+			if k.below != nil {
+				return k.below
+			}
+			return logicalplan.ReplaceColumns(k.above, byName)
+		}))
+		if err != nil {
+			return nil, bindErr(stmt, ErrTypeMismatch, "%v", err)
+		}
+		plan = sorted
+	}
+
+	exprs := make([]Expr.Expression, len(outputs))
+	for i, o := range outputs {
+		if c, ok := o.expr.(*Expr.ColumnResolve); ok && c.Name == o.name {
+			exprs[i] = c
+			continue
+		}
+		exprs[i] = Expr.NewAlias(o.expr, o.name)
+	}
+	if plan, err = logicalplan.NewProject(plan, exprs); err != nil {
+		return nil, bindErr(stmt, ErrTypeMismatch, "%v", err)
+	}
+
+	if stmt.Distinct {
+		plan = logicalplan.NewDistinct(plan)
+	}
+	if len(keys) > 0 && !sortBelow {
+		sorted, err := logicalplan.NewSort(plan, sortKeys(func(k boundKey) Expr.Expression { return k.above }))
+		if err != nil {
+			return nil, bindErr(stmt, ErrTypeMismatch, "%v", err)
+		}
+		plan = sorted
+	}
+	if stmt.Limit != nil {
+		plan = logicalplan.NewLimit(plan, stmt.Limit.Count)
+	}
+	return plan, nil
+}
+
+func needsAggregation(stmt *SelectStatement) bool {
+	if len(stmt.GroupBy) > 0 || stmt.Having != nil {
+		return true
+	}
+	for _, item := range stmt.Items {
+		if containsAggregate(item.Expr) {
+			return true
+		}
+	}
+	for _, item := range stmt.OrderBy {
+		if containsAggregate(item.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Binder) bindGroupBy(groupBy []ExprNode, input *scope) (*aggregation, error) {
+	ag := &aggregation{input: &exprContext{scope: input, clause: "aggregate arguments"}}
+	groupCtx := &exprContext{scope: input, clause: "GROUP BY"}
+	for _, g := range groupBy {
+		e, err := b.bindExpr(g, groupCtx)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := ag.groupField(e); dup {
+			continue
+		}
+		ag.groupBy = append(ag.groupBy, e)
+	}
+	s, err := aggr.AggregateSchema(input.schema, ag.groupBy, nil)
+	if err != nil {
+		return nil, bindErr(groupBy[0], ErrTypeMismatch, "%v", err)
+	}
+	ag.groupFields = s.Fields()
+	return ag, nil
+}
+
+// ================
+// FROM clause
+// ================
+
+func (b *Binder) bindFrom(t TableExpr) (logicalplan.Plan, *scope, error) {
+	switch n := t.(type) {
+	case *TableRef:
+		schema, err := b.catalog.TableSchema(n.Name)
+		if err != nil {
+			return nil, nil, bindErr(n, ErrUnknownTable, "%v", err)
+		}
+		scan, err := logicalplan.NewScan(n.Name, schema, nil)
+		if err != nil {
+			return nil, nil, bindErr(n, ErrUnknownTable, "%v", err)
+		}
+		sc := &scope{schema: schema}
+		for _, f := range schema.Fields() {
+			sc.columns = append(sc.columns, scopeColumn{qualifier: n.Qualifier(), name: f.Name, field: f.Name})
+		}
+		return scan, sc, nil
+	case *JoinExpr:
+		return b.bindJoin(n)
+	default:
+		return nil, nil, bindErr(t, ErrUnsupported, "unsupported FROM clause %s", t)
+	}
+}
+
+func (b *Binder) bindJoin(n *JoinExpr) (logicalplan.Plan, *scope, error) {
+	left, ls, err := b.bindFrom(n.Left)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, rs, err := b.bindFrom(n.Right)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range rs.columns {
+		if c.qualifier != "" && ls.hasQualifier(c.qualifier) {
+			return nil, nil, bindErr(n.Right, ErrDuplicateTable, "table name %q specified more than once, give one of them an alias", c.qualifier)
+		}
+	}
+
+	// split the ON condition into equality keys between the two sides and whatever is left over
+	lctx := &exprContext{scope: ls, clause: "JOIN condition"}
+	rctx := &exprContext{scope: rs, clause: "JOIN condition"}
+	var leftKeys, rightKeys []Expr.Expression
+	var residual []ExprNode
+	for _, conj := range splitConjuncts(n.On) {
+		if eq, ok := conj.(*BinaryOpExpr); ok && eq.Op == OpEq {
+			l, r, ok, err := b.bindJoinKeys(eq, eq.Left, eq.Right, lctx, rctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				l, r, ok, err = b.bindJoinKeys(eq, eq.Right, eq.Left, lctx, rctx)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			if ok {
+				leftKeys = append(leftKeys, l)
+				rightKeys = append(rightKeys, r)
+				continue
+			}
+		}
+		residual = append(residual, conj)
+	}
+
+	var joinType join.JoinType
+	switch n.Kind {
+	case LeftJoinKind:
+		joinType = join.LeftJoin
+	case RightJoinKind:
+		joinType = join.RightJoin
+	default:
+		joinType = join.InnerJoin
+	}
+	j, err := logicalplan.NewJoin(left, right, joinType, leftKeys, rightKeys)
+	if err != nil {
+		return nil, nil, bindErr(n, ErrTypeMismatch, "%v", err)
+	}
+
+	// the join renames clashing columns, the scope has to follow
+	sc := &scope{schema: j.Schema()}
+	for i, c := range append(append([]scopeColumn{}, ls.columns...), rs.columns...) {
+		c.field = j.Schema().Field(i).Name
+		sc.columns = append(sc.columns, c)
+	}
+
+	if len(leftKeys) == 0 {
+		return nil, nil, bindErr(n.On, ErrUnsupported, "JOIN condition must contain at least one equality between columns of both tables")
+	}
+	if len(residual) == 0 {
+		return j, sc, nil
+	}
+	if n.Kind != InnerJoinKind {
+		return nil, nil, bindErr(residual[0], ErrUnsupported, "only equality conditions are supported in the ON clause of a %s", n.Kind)
+	}
+	pred, err := b.bindPredicate(joinConjuncts(residual), &exprContext{scope: sc, clause: "JOIN condition"})
+	if err != nil {
+		return nil, nil, err
+	}
+	filter, err := logicalplan.NewFilter(j, pred)
+	if err != nil {
+		return nil, nil, bindErr(n.On, ErrTypeMismatch, "%v", err)
+	}
+	return filter, sc, nil
+}
+
+// bindJoinKeys tries to bind l against the left side and r against the right side. ok is false
+// when they don't belong to those sides, the caller then tries the other way around
+func (b *Binder) bindJoinKeys(eq *BinaryOpExpr, l, r ExprNode, lctx, rctx *exprContext) (Expr.Expression, Expr.Expression, bool, error) {
+	if !referencesColumn(l) || !referencesColumn(r) {
+		return nil, nil, false, nil
+	}
+	le, err := b.bindExpr(l, lctx)
+	if err != nil {
+		return nil, nil, false, nil
+	}
+	re, err := b.bindExpr(r, rctx)
+	if err != nil {
+		return nil, nil, false, nil
+	}
+	lt, err := Expr.ExprDataType(le, lctx.schema())
+	if err != nil {
+		return nil, nil, false, bindErr(l, ErrTypeMismatch, "%v", err)
+	}
+	rt, err := Expr.ExprDataType(re, rctx.schema())
+	if err != nil {
+		return nil, nil, false, bindErr(r, ErrTypeMismatch, "%v", err)
+	}
+	if !arrow.TypeEqual(lt, rt) && !(isNumeric(lt) && isNumeric(rt)) {
+		return nil, nil, false, bindErr(eq, ErrTypeMismatch, "cannot join %s (%s) with %s (%s)", l, lt, r, rt)
+	}
+	return le, re, true, nil
+}
+
+func splitConjuncts(n ExprNode) []ExprNode {
+	if b, ok := n.(*BinaryOpExpr); ok && b.Op == OpAnd {
+		return append(splitConjuncts(b.Left), splitConjuncts(b.Right)...)
+	}
+	return []ExprNode{n}
+}
+
+func joinConjuncts(nodes []ExprNode) ExprNode {
+	out := nodes[0]
+	for _, n := range nodes[1:] {
+		out = &BinaryOpExpr{Pos: out.Position(), Op: OpAnd, Left: out, Right: n}
+	}
+	return out
+}
+
+// ================
+// expressions
+// ================
+
+var aggregateFuncs = map[string]aggr.AggrFunc{
+	"MIN":   aggr.Min,
+	"MAX":   aggr.Max,
+	"COUNT": aggr.Count,
+	"SUM":   aggr.Sum,
+	"AVG":   aggr.Avg,
+}
+
+func isAggregateCall(n ExprNode) bool {
+	call, ok := n.(*FuncCall)
+	if !ok {
+		return false
+	}
+	_, ok = aggregateFuncs[call.Name]
+	return ok
+}
+
+// walk calls fn on n and every node below it, stopping early when fn returns true
+func walk(n ExprNode, fn func(ExprNode) bool) bool {
+	if fn(n) {
+		return true
+	}
+	switch e := n.(type) {
+	case *BinaryOpExpr:
+		return walk(e.Left, fn) || walk(e.Right, fn)
+	case *UnaryOpExpr:
+		return walk(e.Expr, fn)
+	case *IsNullExpr:
+		return walk(e.Expr, fn)
+	case *CastExpr:
+		return walk(e.Expr, fn)
+	case *FuncCall:
+		for _, a := range e.Args {
+			if walk(a, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsAggregate(n ExprNode) bool { return walk(n, isAggregateCall) }
+
+func referencesColumn(n ExprNode) bool {
+	return walk(n, func(n ExprNode) bool {
+		_, ok := n.(*Identifier)
+		return ok
+	})
+}
+
+func (b *Binder) bindPredicate(n ExprNode, ctx *exprContext) (Expr.Expression, error) {
+	e, err := b.bindExpr(n, ctx)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := Expr.ExprDataType(e, ctx.schema())
+	if err != nil {
+		return nil, bindErr(n, ErrTypeMismatch, "%v", err)
+	}
+	if dt.ID() != arrow.BOOL {
+		return nil, bindErr(n, ErrTypeMismatch, "%s condition must be boolean, got %s", ctx.clause, dt)
+	}
+	return e, nil
+}
+
+func (b *Binder) bindExpr(n ExprNode, ctx *exprContext) (Expr.Expression, error) {
+	if ctx.agg != nil {
+		if isAggregateCall(n) {
+			return b.bindAggregateCall(n.(*FuncCall), ctx)
+		}
+		if !containsAggregate(n) {
+			// the whole expression may be a group key, GROUP BY UPPER(name) lets you SELECT UPPER(name)
+			if e, err := b.bindExpr(n, ctx.agg.input); err == nil {
+				if field, ok := ctx.agg.groupField(e); ok {
+					return Expr.NewColumnResolve(field), nil
+				}
+			} else if _, ok := n.(*Identifier); ok {
+				return nil, err
+			}
+		}
+	}
+
+	switch e := n.(type) {
+	case *Identifier:
+		if ctx.agg != nil {
+			return nil, bindErr(e, ErrInvalidAggregate, "column %q must appear in the GROUP BY clause or be used in an aggregate function", e.String())
+		}
+		c, err := ctx.scope.resolve(e)
+		if err != nil {
+			return nil, err
+		}
+		return Expr.NewColumnResolve(c.field), nil
+	case *StarExpr:
+		return nil, bindErr(e, ErrUnsupported, "* is only allowed in the select list or as COUNT(*)")
+	case *IntegerLiteral:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int(e.Value)), nil
+	case *FloatLiteral:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float64, e.Value), nil
+	case *StringLiteral:
+		return Expr.NewLiteralResolve(arrow.BinaryTypes.String, e.Value), nil
+	case *BinaryLiteral:
+		return Expr.NewLiteralResolve(arrow.BinaryTypes.Binary, e.Value), nil
+	case *BooleanLiteral:
+		return Expr.NewLiteralResolve(arrow.FixedWidthTypes.Boolean, e.Value), nil
+	case *NullLiteral:
+		return Expr.NewLiteralResolve(arrow.Null, nil), nil
+	case *BinaryOpExpr:
+		return b.bindBinary(e, ctx)
+	case *UnaryOpExpr:
+		return b.bindUnary(e, ctx)
+	case *IsNullExpr:
+		inner, err := b.bindExpr(e.Expr, ctx)
+		if err != nil {
+			return nil, err
+		}
+		// NullCheckExpr is true for non null values
+		check := Expr.NewNullCheckExpr(inner)
+		if e.Not {
+			return check, nil
+		}
+		return Expr.NewNotExpr(check), nil
+	case *FuncCall:
+		if isAggregateCall(e) {
+			return nil, bindErr(e, ErrInvalidAggregate, "aggregate function %s is not allowed in %s", e.Name, ctx.clause)
+		}
+		return b.bindScalarFunction(e, ctx)
+	case *CastExpr:
+		inner, err := b.bindExpr(e.Expr, ctx)
+		if err != nil {
+			return nil, err
+		}
+		dt, err := Expr.ExprDataType(inner, ctx.schema())
+		if err != nil {
+			return nil, bindErr(e, ErrTypeMismatch, "%v", err)
+		}
+		if arrow.TypeEqual(dt, e.Type) {
+			return inner, nil
+		}
+		return Expr.NewCastExpr(inner, e.Type), nil
+	default:
+		return nil, bindErr(n, ErrUnsupported, "unsupported expression %s", n)
+	}
+}
+
+func (b *Binder) bindAggregateCall(call *FuncCall, ctx *exprContext) (Expr.Expression, error) {
+	if len(call.Args) != 1 {
+		return nil, bindErr(call, ErrInvalidAggregate, "%s takes exactly one argument, got %d", call.Name, len(call.Args))
+	}
+	agg, err := b.bindAggregateArg(call, ctx)
+	if err != nil {
+		return nil, err
+	}
+	field, err := ctx.agg.add(agg)
+	if err != nil {
+		return nil, bindErr(call, ErrTypeMismatch, "%v", err)
+	}
+	return Expr.NewColumnResolve(field.Name), nil
+}
+
+// bindAggregateArg binds the argument of an aggregate call, COUNT(*) counts rows and takes none. MIN,
+// MAX and COUNT take any type the aggregate operators do, SUM and AVG only numbers
+func (b *Binder) bindAggregateArg(call *FuncCall, ctx *exprContext) (aggr.AggregateFunctions, error) {
+	if _, ok := call.Args[0].(*StarExpr); ok {
+		if call.Name != "COUNT" {
+			return aggr.AggregateFunctions{}, bindErr(call, ErrInvalidAggregate, "%s(*) is not supported, pass a column instead", call.Name)
+		}
+		return aggr.NewAggregateFunctions(aggr.CountStar, nil), nil
+	}
+	if containsAggregate(call.Args[0]) {
+		return aggr.AggregateFunctions{}, bindErr(call, ErrInvalidAggregate, "aggregate function calls cannot be nested")
+	}
+	child, err := b.bindExpr(call.Args[0], ctx.agg.input)
+	if err != nil {
+		return aggr.AggregateFunctions{}, err
+	}
+	dt, err := Expr.ExprDataType(child, ctx.agg.input.schema())
+	if err != nil {
+		return aggr.AggregateFunctions{}, bindErr(call, ErrTypeMismatch, "%v", err)
+	}
+	if (call.Name == "SUM" || call.Name == "AVG") && !isNumeric(dt) {
+		return aggr.AggregateFunctions{}, bindErr(call, ErrTypeMismatch, "%s does not support arguments of type %s", call.Name, dt)
+	}
+	agg := aggr.NewAggregateFunctions(aggregateFuncs[call.Name], child)
+	agg.Distinct = call.Distinct
+	return agg, nil
+}
+
+func (b *Binder) bindScalarFunction(call *FuncCall, ctx *exprContext) (Expr.Expression, error) {
+	if len(call.Args) != 1 {
+		return nil, bindErr(call, ErrUnsupported, "%s takes exactly one argument, got %d", call.Name, len(call.Args))
+	}
+	arg, err := b.bindExpr(call.Args[0], ctx)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := Expr.ExprDataType(arg, ctx.schema())
+	if err != nil {
+		return nil, bindErr(call, ErrTypeMismatch, "%v", err)
+	}
+	switch call.Name {
+	case "UPPER", "LOWER":
+		if dt.ID() != arrow.STRING {
+			return nil, bindErr(call, ErrTypeMismatch, "%s expects a string argument, got %s", call.Name, dt)
+		}
+		if call.Name == "UPPER" {
+			return Expr.NewScalarFunction(Expr.Upper, arg), nil
+		}
+		return Expr.NewScalarFunction(Expr.Lower, arg), nil
+	case "ABS", "ROUND":
+		if !isNumeric(dt) {
+			return nil, bindErr(call, ErrTypeMismatch, "%s expects a numeric argument, got %s", call.Name, dt)
+		}
+		if call.Name == "ABS" {
+			return Expr.NewScalarFunction(Expr.Abs, arg), nil
+		}
+		return Expr.NewScalarFunction(Expr.Round, arg), nil
+	default:
+		return nil, bindErr(call, ErrUnsupported, "unknown function %s", call.Name)
+	}
+}
+
+func (b *Binder) bindUnary(u *UnaryOpExpr, ctx *exprContext) (Expr.Expression, error) {
+	inner, err := b.bindExpr(u.Expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := Expr.ExprDataType(inner, ctx.schema())
+	if err != nil {
+		return nil, bindErr(u, ErrTypeMismatch, "%v", err)
+	}
+	if u.Op == OpNot {
+		if dt.ID() != arrow.BOOL {
+			return nil, bindErr(u, ErrTypeMismatch, "NOT expects a boolean, got %s", dt)
+		}
+		return Expr.NewNotExpr(inner), nil
+	}
+	if !isNumeric(dt) {
+		return nil, bindErr(u, ErrTypeMismatch, "cannot negate %s", dt)
+	}
+	if lit, ok := inner.(*Expr.LiteralResolve); ok {
+		switch v := lit.Value.(type) {
+		case int64:
+			return Expr.NewLiteralResolve(lit.Type, int(-v)), nil
+		case float64:
+			return Expr.NewLiteralResolve(lit.Type, -v), nil
+		}
+	}
+	// arithmetic is always done in float64 (see Expr.ExprDataType)
+	zero := Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float64, 0.0)
+	return Expr.NewBinaryExpr(zero, Expr.Subtraction, toFloat64(inner, dt)), nil
+}
+
+func (b *Binder) bindBinary(n *BinaryOpExpr, ctx *exprContext) (Expr.Expression, error) {
+	l, err := b.bindExpr(n.Left, ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.bindExpr(n.Right, ctx)
+	if err != nil {
+		return nil, err
+	}
+	lt, err := Expr.ExprDataType(l, ctx.schema())
+	if err != nil {
+		return nil, bindErr(n.Left, ErrTypeMismatch, "%v", err)
+	}
+	rt, err := Expr.ExprDataType(r, ctx.schema())
+	if err != nil {
+		return nil, bindErr(n.Right, ErrTypeMismatch, "%v", err)
+	}
+
+	switch n.Op {
+	case OpAnd, OpOr:
+		if lt.ID() != arrow.BOOL || rt.ID() != arrow.BOOL {
+			return nil, bindErr(n, ErrTypeMismatch, "%s expects boolean operands, got %s and %s", n.Op, lt, rt)
+		}
+		if n.Op == OpAnd {
+			return Expr.NewBinaryExpr(l, Expr.And, r), nil
+		}
+		return Expr.NewBinaryExpr(l, Expr.Or, r), nil
+
+	case OpLike:
+		lit, ok := r.(*Expr.LiteralResolve)
+		if lt.ID() != arrow.STRING || !ok || rt.ID() != arrow.STRING {
+			return nil, bindErr(n, ErrTypeMismatch, "LIKE expects a string column and a string pattern, got %s and %s", lt, rt)
+		}
+		return Expr.NewBinaryExpr(l, Expr.Like, lit), nil
+
+	case OpAdd, OpSub, OpMul, OpDiv:
+		if !isNumeric(lt) || !isNumeric(rt) {
+			return nil, bindErr(n, ErrTypeMismatch, "cannot apply %s to %s and %s", n.Op, lt, rt)
+		}
+		// arithmetic results are float64 (see Expr.ExprDataType), make the inputs agree with that
+		l, r = toFloat64(l, lt), toFloat64(r, rt)
+		switch n.Op {
+		case OpAdd:
+			return Expr.NewBinaryExpr(l, Expr.Addition, r), nil
+		case OpSub:
+			return Expr.NewBinaryExpr(l, Expr.Subtraction, r), nil
+		case OpMul:
+			return Expr.NewBinaryExpr(l, Expr.Multiplication, r), nil
+		default:
+			return Expr.NewBinaryExpr(l, Expr.Division, r), nil
+		}
+
+	default:
+		if lt.ID() == arrow.NULL || rt.ID() == arrow.NULL {
+			return nil, bindErr(n, ErrTypeMismatch, "comparison with NULL is never true, use IS NULL or IS NOT NULL")
+		}
+		l, r, err = coerceComparison(l, lt, r, rt)
+		if err != nil {
+			return nil, bindErr(n, ErrTypeMismatch, "%v", err)
+		}
+		switch n.Op {
+		case OpEq:
+			return Expr.NewBinaryExpr(l, Expr.Equal, r), nil
+		case OpNotEq:
+			return Expr.NewBinaryExpr(l, Expr.NotEqual, r), nil
+		case OpLt:
+			return Expr.NewBinaryExpr(l, Expr.LessThan, r), nil
+		case OpLtEq:
+			return Expr.NewBinaryExpr(l, Expr.LessThanOrEqual, r), nil
+		case OpGt:
+			return Expr.NewBinaryExpr(l, Expr.GreaterThan, r), nil
+		case OpGtEq:
+			return Expr.NewBinaryExpr(l, Expr.GreaterThanOrEqual, r), nil
+		default:
+			return nil, bindErr(n, ErrUnsupported, "unsupported operator %s", n.Op)
+		}
+	}
+}
+
+// ================
+// typing helpers
+// ================
+
+// comparisons need both sides to have the exact same arrow type. literals are re-typed to match the
+// column they are compared with (age > 30 on an int32 column compares against int32(30)),
+// anything else numeric is compared as float64
+func coerceComparison(l Expr.Expression, lt arrow.DataType, r Expr.Expression, rt arrow.DataType) (Expr.Expression, Expr.Expression, error) {
+	if arrow.TypeEqual(lt, rt) {
+		return l, r, nil
+	}
+	if !isNumeric(lt) || !isNumeric(rt) {
+		return nil, nil, fmt.Errorf("cannot compare %s with %s", lt, rt)
+	}
+	if lit, ok := r.(*Expr.LiteralResolve); ok {
+		if c, ok := retypeLiteral(lit, lt); ok {
+			return l, c, nil
+		}
+	}
+	if lit, ok := l.(*Expr.LiteralResolve); ok {
+		if c, ok := retypeLiteral(lit, rt); ok {
+			return c, r, nil
+		}
+	}
+	return toFloat64(l, lt), toFloat64(r, rt), nil
+}
+
+// retypeLiteral converts a numeric literal to another numeric type when no precision is lost
+func retypeLiteral(lit *Expr.LiteralResolve, to arrow.DataType) (*Expr.LiteralResolve, bool) {
+	switch v := lit.Value.(type) {
+	case int64:
+		switch to.ID() {
+		case arrow.FLOAT32, arrow.FLOAT64:
+			return Expr.NewLiteralResolve(to, float64(v)), true
+		}
+		if intFits(v, to) {
+			return Expr.NewLiteralResolve(to, int(v)), true
+		}
+	case float64:
+		switch to.ID() {
+		case arrow.FLOAT32, arrow.FLOAT64:
+			return Expr.NewLiteralResolve(to, v), true
+		}
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 && intFits(int64(v), to) {
+			return Expr.NewLiteralResolve(to, int(v)), true
+		}
+	}
+	return nil, false
+}
+
+func intFits(v int64, to arrow.DataType) bool {
+	switch to.ID() {
+	case arrow.INT8:
+		return v >= math.MinInt8 && v <= math.MaxInt8
+	case arrow.INT16:
+		return v >= math.MinInt16 && v <= math.MaxInt16
+	case arrow.INT32:
+		return v >= math.MinInt32 && v <= math.MaxInt32
+	case arrow.INT64:
+		return true
+	case arrow.UINT8:
+		return v >= 0 && v <= math.MaxUint8
+	case arrow.UINT16:
+		return v >= 0 && v <= math.MaxUint16
+	case arrow.UINT32:
+		return v >= 0 && v <= math.MaxUint32
+	case arrow.UINT64:
+		return v >= 0
+	default:
+		return false
+	}
+}
+
+func toFloat64(e Expr.Expression, dt arrow.DataType) Expr.Expression {
+	if dt.ID() == arrow.FLOAT64 {
+		return e
+	}
+	if lit, ok := e.(*Expr.LiteralResolve); ok {
+		if c, ok := retypeLiteral(lit, arrow.PrimitiveTypes.Float64); ok {
+			return c
+		}
+	}
+	return Expr.NewCastExpr(e, arrow.PrimitiveTypes.Float64)
+}
+
+func isNumeric(dt arrow.DataType) bool {
+	return arrow.IsInteger(dt.ID()) || arrow.IsFloating(dt.ID())
+}
@@ -0,0 +1,247 @@
+package physicaloptimizer
+
+import (
+	"errors"
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func testCatalog() logicalplan.MapCatalog {
+	return logicalplan.MapCatalog{
+		"source1": arrow.NewSchema([]arrow.Field{
+			{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+			{Name: "name", Type: arrow.BinaryTypes.String},
+			{Name: "age", Type: arrow.PrimitiveTypes.Int32},
+			{Name: "salary", Type: arrow.PrimitiveTypes.Float64},
+			{Name: "active", Type: arrow.FixedWidthTypes.Boolean},
+		}, nil),
+		"source2": arrow.NewSchema([]arrow.Field{
+			{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+			{Name: "department_name", Type: arrow.BinaryTypes.String},
+		}, nil),
+	}
+}
+
+func mustBind(t *testing.T, sql string) logicalplan.Plan {
+	t.Helper()
+	plan, err := NewBinder(testCatalog()).Bind(mustParse(t, sql))
+	if err != nil {
+		t.Fatalf("failed to bind %q: %v", sql, err)
+	}
+	return plan
+}
+
+func expectPlan(t *testing.T, plan logicalplan.Plan, want string) {
+	t.Helper()
+	got := strings.TrimSpace(logicalplan.Format(plan))
+	want = strings.TrimSpace(want)
+	if got != want {
+		t.Fatalf("unexpected plan\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestBindSelect(t *testing.T) {
+	t.Run("filter and project", func(t *testing.T) {
+		plan := mustBind(t, "SELECT id, name AS who FROM source1 WHERE age > 30 AND active")
+		expectPlan(t, plan, `
+Project: id, name AS who
+  Filter: ((age > 30) AND active)
+    Scan: source1`)
+		schema := plan.Schema()
+		if schema.Field(0).Name != "id" || schema.Field(1).Name != "who" {
+			t.Fatalf("unexpected output names %v", schema)
+		}
+		if !arrow.TypeEqual(schema.Field(0).Type, arrow.PrimitiveTypes.Int32) {
+			t.Fatalf("expected id to stay int32, got %s", schema.Field(0).Type)
+		}
+	})
+	t.Run("literals take the column type", func(t *testing.T) {
+		plan := mustBind(t, "SELECT * FROM source1 WHERE age >= 30")
+		filter := plan.(*logicalplan.Project).Input.(*logicalplan.Filter)
+		lit := filter.Predicate.(*Expr.BinaryExpr).Right.(*Expr.LiteralResolve)
+		if !arrow.TypeEqual(lit.Type, arrow.PrimitiveTypes.Int32) || lit.Value != int32(30) {
+			t.Fatalf("expected int32 literal, got %s %v", lit.Type, lit.Value)
+		}
+	})
+	t.Run("mixed numeric comparison casts to float64", func(t *testing.T) {
+		plan := mustBind(t, "SELECT id FROM source1 WHERE age < salary")
+		expectPlan(t, plan, `
+Project: id
+  Filter: (CAST(age AS float64) < salary)
+    Scan: source1`)
+	})
+	t.Run("expressions", func(t *testing.T) {
+		plan := mustBind(t, "SELECT age + 1, UPPER(name), -age AS neg FROM source1 WHERE name LIKE 'A%' AND NOT active AND salary IS NOT NULL")
+		expectPlan(t, plan, `
+Project: (CAST(age AS float64) + 1) AS (age + 1), UPPER(name) AS UPPER(name), (0 - CAST(age AS float64)) AS neg
+  Filter: (((name LIKE 'A%') AND NOT active) AND salary IS NOT NULL)
+    Scan: source1`)
+		for i, f := range plan.Schema().Fields() {
+			want := []arrow.DataType{arrow.PrimitiveTypes.Float64, arrow.BinaryTypes.String, arrow.PrimitiveTypes.Float64}[i]
+			if !arrow.TypeEqual(f.Type, want) {
+				t.Fatalf("column %s: expected %s, got %s", f.Name, want, f.Type)
+			}
+		}
+	})
+	t.Run("is null", func(t *testing.T) {
+		plan := mustBind(t, "SELECT id FROM source1 WHERE name IS NULL")
+		expectPlan(t, plan, `
+Project: id
+  Filter: name IS NULL
+    Scan: source1`)
+	})
+	t.Run("order by and limit", func(t *testing.T) {
+		plan := mustBind(t, "SELECT id, age AS years FROM source1 ORDER BY years DESC, 1 LIMIT 5")
+		expectPlan(t, plan, `
+Limit: 5
+  Sort: years DESC NULLS LAST, id ASC NULLS LAST
+    Project: id, age AS years
+      Scan: source1`)
+	})
+	t.Run("order by a column that is not selected sorts below the projection", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name AS who FROM source1 ORDER BY age, who")
+		expectPlan(t, plan, `
+Project: name AS who
+  Sort: age ASC NULLS LAST, name ASC NULLS LAST
+    Scan: source1`)
+	})
+	t.Run("distinct", func(t *testing.T) {
+		plan := mustBind(t, "SELECT DISTINCT name FROM source1 ORDER BY name")
+		expectPlan(t, plan, `
+Sort: name ASC NULLS LAST
+  Distinct
+    Project: name
+      Scan: source1`)
+	})
+}
+
+func TestBindAggregate(t *testing.T) {
+	t.Run("group by with having", func(t *testing.T) {
+		plan := mustBind(t, `SELECT name, SUM(salary) AS total, COUNT(id) FROM source1
+			GROUP BY name HAVING SUM(salary) > 1000 ORDER BY total DESC LIMIT 3`)
+		expectPlan(t, plan, `
+Limit: 3
+  Sort: total DESC NULLS LAST
+    Project: group_Column(name) AS name, sum_Column(salary) AS total, count_Column(id) AS COUNT(id)
+      Having: (sum_Column(salary) > 1000)
+        Aggregate: groupBy=[name] aggr=[SUM(salary), COUNT(id)]
+          Scan: source1`)
+		if f := plan.Schema().Field(1); f.Name != "total" || f.Type.ID() != arrow.FLOAT64 {
+			t.Fatalf("unexpected total column %v", f)
+		}
+	})
+	t.Run("global aggregate", func(t *testing.T) {
+		plan := mustBind(t, "SELECT MIN(age), MAX(age) + 1 FROM source1 WHERE active")
+		expectPlan(t, plan, `
+Project: min_Column(age) AS MIN(age), (CAST(max_Column(age) AS float64) + 1) AS (MAX(age) + 1)
+  Aggregate: groupBy=[] aggr=[MIN(age), MAX(age)]
+    Filter: active
+      Scan: source1`)
+	})
+	t.Run("group key expressions", func(t *testing.T) {
+		plan := mustBind(t, "SELECT UPPER(name), AVG(age) FROM source1 GROUP BY UPPER(name) ORDER BY AVG(age)")
+		expectPlan(t, plan, `
+Project: group_ScalarFunction(1, Column(name)) AS UPPER(name), avg_Column(age) AS AVG(age)
+  Sort: avg_Column(age) ASC NULLS LAST
+    Aggregate: groupBy=[UPPER(name)] aggr=[AVG(age)]
+      Scan: source1`)
+	})
+	t.Run("count star and distinct", func(t *testing.T) {
+		plan := mustBind(t, "SELECT COUNT(*), COUNT(DISTINCT name), COUNT(name), MAX(name) FROM source1")
+		expectPlan(t, plan, `
+Project: count_* AS COUNT(*), count_distinct_Column(name) AS COUNT(DISTINCT name), count_Column(name) AS COUNT(name), max_Column(name) AS MAX(name)
+  Aggregate: groupBy=[] aggr=[COUNT(*), COUNT(DISTINCT name), COUNT(name), MAX(name)]
+    Scan: source1`)
+	})
+	t.Run("duplicate aggregates are computed once", func(t *testing.T) {
+		plan := mustBind(t, "SELECT SUM(age), SUM(age) * 2 FROM source1")
+		agg := plan.(*logicalplan.Project).Input.(*logicalplan.Aggregate)
+		if len(agg.Aggregates) != 1 {
+			t.Fatalf("expected 1 aggregate, got %d", len(agg.Aggregates))
+		}
+	})
+}
+
+func TestBindJoin(t *testing.T) {
+	t.Run("qualified names follow the join renaming", func(t *testing.T) {
+		plan := mustBind(t, "SELECT a.id, b.department_name, right_id FROM source1 a JOIN source2 b ON a.id = b.id")
+		expectPlan(t, plan, `
+Project: left_id AS id, department_name, right_id
+  Join: INNER JOIN ON id = id
+    Scan: source1
+    Scan: source2`)
+	})
+	t.Run("keys written right to left are swapped", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name FROM source1 JOIN source2 s ON s.id = source1.id AND source1.age > 18")
+		expectPlan(t, plan, `
+Project: name
+  Filter: (age > 18)
+    Join: INNER JOIN ON id = id
+      Scan: source1
+      Scan: source2`)
+	})
+	t.Run("left join", func(t *testing.T) {
+		plan := mustBind(t, "SELECT * FROM source1 a LEFT JOIN source2 b ON a.id = b.id")
+		j := plan.(*logicalplan.Project).Input.(*logicalplan.Join)
+		if j.Type.String() != "LEFT JOIN" {
+			t.Fatalf("expected LEFT JOIN, got %s", j.Type)
+		}
+		if plan.Schema().NumFields() != 7 || plan.Schema().Field(0).Name != "left_id" {
+			t.Fatalf("unexpected star expansion %v", plan.Schema())
+		}
+	})
+}
+
+func TestBindErrors(t *testing.T) {
+	cases := []struct {
+		sql  string
+		kind error
+		line int
+		col  int
+	}{
+		{"SELECT nope FROM source1", ErrUnknownColumn, 1, 8},
+		{"SELECT id FROM source1\nWHERE x.age > 1", ErrUnknownColumn, 2, 7},
+		{"SELECT id FROM missing", ErrUnknownTable, 1, 16},
+		{"SELECT id FROM source1 a JOIN source2 b ON a.id = b.id", ErrAmbiguousColumn, 1, 8},
+		{"SELECT a.id FROM source1 a JOIN source1 a ON a.id = a.id", ErrDuplicateTable, 1, 33},
+		{"SELECT id FROM source1 WHERE name > 5", ErrTypeMismatch, 1, 35},
+		{"SELECT id FROM source1 WHERE age", ErrTypeMismatch, 1, 30},
+		{"SELECT id FROM source1 WHERE age LIKE 'a%'", ErrTypeMismatch, 1, 34},
+		{"SELECT id FROM source1 WHERE age = NULL", ErrTypeMismatch, 1, 34},
+		{"SELECT name + 1 FROM source1", ErrTypeMismatch, 1, 13},
+		{"SELECT UPPER(age) FROM source1", ErrTypeMismatch, 1, 8},
+		{"SELECT name, SUM(age) FROM source1 GROUP BY id", ErrInvalidAggregate, 1, 8},
+		{"SELECT id FROM source1 WHERE SUM(age) > 1", ErrInvalidAggregate, 1, 30},
+		{"SELECT SUM(MAX(age)) FROM source1", ErrInvalidAggregate, 1, 8},
+		{"SELECT SUM(name) FROM source1", ErrTypeMismatch, 1, 8},
+		{"SELECT SUM(*) FROM source1", ErrInvalidAggregate, 1, 8},
+		{"SELECT AVG(DISTINCT name) FROM source1", ErrTypeMismatch, 1, 8},
+		{"SELECT DISTINCT name FROM source1 ORDER BY age", ErrUnsupported, 1, 44},
+		{"SELECT name FROM source1 a JOIN source2 b ON a.age > b.id", ErrUnsupported, 1, 52},
+		{"SELECT name FROM source1 a JOIN source2 b ON a.name = b.id", ErrTypeMismatch, 1, 53},
+		{"SELECT id FROM source1 ORDER BY 3", ErrUnknownColumn, 1, 33},
+		{"SELECT FOO(id) FROM source1", ErrUnsupported, 1, 8},
+	}
+	for _, c := range cases {
+		t.Run(c.sql, func(t *testing.T) {
+			_, err := NewBinder(testCatalog()).Bind(mustParse(t, c.sql))
+			if err == nil {
+				t.Fatalf("expected bind error")
+			}
+			if !errors.Is(err, c.kind) {
+				t.Fatalf("expected %v, got %v", c.kind, err)
+			}
+			var be *BindError
+			if !errors.As(err, &be) {
+				t.Fatalf("expected *BindError, got %T", err)
+			}
+			if be.Pos.Line != c.line || be.Pos.Col != c.col {
+				t.Fatalf("expected error at %d:%d, got %s (%v)", c.line, c.col, be.Pos, err)
+			}
+		})
+	}
+}
@@ -1,3 +1,337 @@
 package physicaloptimizer
 
-// optimize the parsed plan
+import (
+	"fmt"
+	"math"
+	"opti-sql-go/Expr"
+	"opti-sql-go/config"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"opti-sql-go/operators/exchange"
+	"opti-sql-go/operators/filter"
+	"opti-sql-go/operators/project"
+	"runtime"
+	"strings"
+)
+
+// the physical planner lowers a bound logical plan into the operator tree that actually runs.
+// the logical nodes map almost one to one onto operators, the interesting choices are
+//   - ORDER BY + LIMIT becomes a TopKSortExec instead of sorting everything
+//   - aggregates without GROUP BY use the global AggrExec instead of a hash table of groups
+//   - with batch.enable_parallel_read the filters and projections feeding an aggregate or a sort run
+//     on every core behind an ExchangeExec, their order doesn't matter to the operator reading them.
+//     aggregates are split in two, a Partial one on every worker and a Final one above the exchange
+
+var (
+	// ErrUnsupportedPlan wraps ErrUnsupported, a plan the planner can't run yet is reported the same
+	// as a query the binder rejects
+	ErrUnsupportedPlan = func(info string) error {
+		return fmt.Errorf("physical planner: %w plan: %s", ErrUnsupported, info)
+	}
+)
+
+// SourceProvider resolves the tables a query reads. the binder asks it for schemas,
+// the planner asks it for a fresh operator reading the table
+type SourceProvider interface {
+	logicalplan.Catalog
+	Open(scan *logicalplan.Scan) (operators.Operator, error)
+}
+
+type Planner struct {
+	sources   SourceProvider
+	optimizer *Optimizer
+	analyze   bool // wrap every operator for EXPLAIN ANALYZE, see CreateAnalyzedPlan
+	workers   int  // pipelines run in parallel by planParallel, 1 to run everything on one goroutine
+}
+
+func NewPlanner(sources SourceProvider) *Planner {
+	workers := 1
+	if config.GetConfig().Batch.EnableParallelRead {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &Planner{sources: sources, optimizer: NewOptimizer(), workers: workers}
+}
+
+// PlanSQL parses, binds, optimizes and lowers a query in one go
+func (p *Planner) PlanSQL(sql string) (operators.Operator, error) {
+	plan, err := p.LogicalPlan(sql)
+	if err != nil {
+		return nil, err
+	}
+	if plan, err = p.optimizer.Optimize(plan); err != nil {
+		return nil, err
+	}
+	return p.CreatePhysicalPlan(plan)
+}
+
+// LogicalPlan parses and binds sql against the planner's sources
+func (p *Planner) LogicalPlan(sql string) (logicalplan.Plan, error) {
+	stmt, err := ParseSQL(sql)
+	if err != nil {
+		return nil, err
+	}
+	return NewBinder(p.sources).Bind(stmt)
+}
+
+// CreatePhysicalPlan turns plan into an operator tree. the returned operator owns all of its
+// children, closing it closes the whole tree
+func (p *Planner) CreatePhysicalPlan(plan logicalplan.Plan) (operators.Operator, error) {
+	switch n := plan.(type) {
+	case *logicalplan.Scan:
+		op, err := p.planScan(n)
+		return p.analyzed(op, err, "ScanExec: "+strings.TrimPrefix(n.String(), "Scan: "))
+
+	case *logicalplan.Filter:
+		child, err := p.CreatePhysicalPlan(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		op, err := filter.NewFilterExec(child, n.Predicate)
+		return p.analyzed(op, err, "FilterExec: "+logicalplan.FormatExpr(n.Predicate), child)
+
+	case *logicalplan.Project:
+		child, err := p.CreatePhysicalPlan(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		op, err := project.NewProjectExec(child, n.Exprs)
+		return p.analyzed(op, err, "ProjectExec: "+logicalplan.FormatExprs(n.Exprs), child)
+
+	case *logicalplan.Aggregate:
+		aggregate := func(child operators.Operator, mode aggr.AggrMode) (operators.Operator, error) {
+			if len(n.GroupBy) == 0 {
+				return aggr.NewGlobalAggrExecWithMode(child, n.Aggregates, mode)
+			}
+			return aggr.NewGroupByExecWithMode(child, n.Aggregates, n.GroupBy, mode)
+		}
+		// in parallel every worker aggregates what it reads and the states are combined above the exchange
+		child, parallel, err := p.planParallel(n.Input, func(input operators.Operator) (operators.Operator, error) {
+			return aggregate(input, aggr.Partial)
+		})
+		if err != nil {
+			return nil, err
+		}
+		mode := aggr.Complete
+		if parallel {
+			mode = aggr.Final
+		}
+		aggs := make([]string, len(n.Aggregates))
+		for i, agg := range n.Aggregates {
+			aggs[i] = logicalplan.FormatAggregate(agg)
+		}
+		op, err := aggregate(child, mode)
+		if len(n.GroupBy) == 0 {
+			return p.analyzed(op, err, fmt.Sprintf("AggrExec: aggr=[%s]", strings.Join(aggs, ", ")), child)
+		}
+		return p.analyzed(op, err, fmt.Sprintf("GroupByExec: groupBy=[%s] aggr=[%s]", logicalplan.FormatExprs(n.GroupBy), strings.Join(aggs, ", ")), child)
+
+	case *logicalplan.Having:
+		child, err := p.CreatePhysicalPlan(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		op, err := aggr.NewHavingExec(child, n.Predicate)
+		return p.analyzed(op, err, "HavingExec: "+logicalplan.FormatExpr(n.Predicate), child)
+
+	case *logicalplan.Sort:
+		child, _, err := p.planParallel(n.Input, nil)
+		if err != nil {
+			return nil, err
+		}
+		op, err := aggr.NewSortExec(child, n.Keys)
+		return p.analyzed(op, err, "SortExec: "+formatSortKeys(n.Keys), child)
+
+	case *logicalplan.Limit:
+		return p.planLimit(n)
+
+	case *logicalplan.Join:
+		if n.Type != join.InnerJoin {
+			return nil, ErrUnsupportedPlan(fmt.Sprintf("%s is not supported by the hash join yet", n.Type))
+		}
+		left, err := p.CreatePhysicalPlan(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.CreatePhysicalPlan(n.Right)
+		if err != nil {
+			_ = left.Close()
+			return nil, err
+		}
+		op, err := join.NewHashJoinExec(left, right, join.NewJoinClause(n.LeftKeys, n.RightKeys), n.Type, nil)
+		return p.analyzed(op, err, "HashJoinExec: "+strings.TrimPrefix(n.String(), "Join: "), left, right)
+
+	case *logicalplan.Distinct:
+		child, err := p.CreatePhysicalPlan(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		cols := make([]Expr.Expression, child.Schema().NumFields())
+		for i, f := range child.Schema().Fields() {
+			cols[i] = Expr.NewColumnResolve(f.Name)
+		}
+		op, err := filter.NewDistinctExec(child, cols)
+		return p.analyzed(op, err, "DistinctExec: "+logicalplan.FormatExprs(cols), child)
+
+	default:
+		return nil, ErrUnsupportedPlan(fmt.Sprintf("%T", plan))
+	}
+}
+
+// CreateAnalyzedPlan is CreatePhysicalPlan with every operator wrapped in an operators.AnalyzedExec,
+// once the root is drained it prints the tree with the stats of every operator (EXPLAIN ANALYZE)
+func (p *Planner) CreateAnalyzedPlan(plan logicalplan.Plan) (*operators.AnalyzedExec, error) {
+	analyzing := *p
+	analyzing.analyze = true
+	op, err := analyzing.CreatePhysicalPlan(plan)
+	if err != nil {
+		return nil, err
+	}
+	return op.(*operators.AnalyzedExec), nil
+}
+
+// analyzed wraps op when the planner builds an analyzed plan, children are the (already wrapped) inputs of op.
+// when op failed to build its children are closed, nothing else holds on to them
+func (p *Planner) analyzed(op operators.Operator, err error, name string, children ...operators.Operator) (operators.Operator, error) {
+	if err != nil {
+		for _, c := range children {
+			_ = c.Close()
+		}
+		return nil, err
+	}
+	if !p.analyze {
+		return op, nil
+	}
+	inputs := make([]*operators.AnalyzedExec, len(children))
+	for i, c := range children {
+		inputs[i] = c.(*operators.AnalyzedExec)
+	}
+	return operators.NewAnalyzedExec(op, name, inputs...), nil
+}
+
+// planParallel lowers the input of an operator that doesn't care about the order of its rows. when
+// that input is a chain of filters and projections over a scan the chain is copied onto every worker
+// of an ExchangeExec reading the scan, topped by partial when it isn't nil (a partial aggregate).
+// parallel is false when the input was planned on one goroutine as is, partial isn't used then.
+// analyzed plans stay on one goroutine so each operator keeps its own stats
+func (p *Planner) planParallel(plan logicalplan.Plan, partial exchange.Pipeline) (op operators.Operator, parallel bool, err error) {
+	chain, scan := pipelineChain(plan)
+	if p.workers < 2 || p.analyze || scan == nil || (len(chain) == 0 && partial == nil) {
+		op, err := p.CreatePhysicalPlan(plan)
+		return op, false, err
+	}
+	src, err := p.CreatePhysicalPlan(scan)
+	if err != nil {
+		return nil, false, err
+	}
+	op, err = exchange.NewExchangeExec(src, exchange.Options{Workers: p.workers}, func(input operators.Operator) (operators.Operator, error) {
+		op := input
+		for i := len(chain) - 1; i >= 0; i-- {
+			var err error
+			switch n := chain[i].(type) {
+			case *logicalplan.Filter:
+				op, err = filter.NewFilterExec(op, n.Predicate)
+			case *logicalplan.Project:
+				op, err = project.NewProjectExec(op, n.Exprs)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if partial != nil {
+			return partial(op)
+		}
+		return op, nil
+	})
+	if err != nil {
+		_ = src.Close()
+		return nil, false, err
+	}
+	return op, true, nil
+}
+
+// pipelineChain splits plan into the filters and projections on top (outermost first) and the scan
+// below them, scan is nil when anything else is in between
+func pipelineChain(plan logicalplan.Plan) (chain []logicalplan.Plan, scan *logicalplan.Scan) {
+	for {
+		switch n := plan.(type) {
+		case *logicalplan.Filter:
+			chain = append(chain, n)
+			plan = n.Input
+		case *logicalplan.Project:
+			chain = append(chain, n)
+			plan = n.Input
+		case *logicalplan.Scan:
+			return chain, n
+		default:
+			return nil, nil
+		}
+	}
+}
+
+func formatSortKeys(keys []aggr.SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = logicalplan.FormatSortKey(k)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *Planner) planScan(n *logicalplan.Scan) (operators.Operator, error) {
+	src, err := p.sources.Open(n)
+	if err != nil {
+		return nil, err
+	}
+	if src.Schema().Equal(n.Schema()) {
+		return src, nil
+	}
+	// the source ignored the column list, prune it ourselves so parents see the schema they were bound against
+	cols := make([]Expr.Expression, n.Schema().NumFields())
+	for i, f := range n.Schema().Fields() {
+		cols[i] = Expr.NewColumnResolve(f.Name)
+	}
+	op, err := project.NewProjectExec(src, cols)
+	if err != nil {
+		_ = src.Close()
+		return nil, err
+	}
+	return op, nil
+}
+
+// LIMIT directly on top of a sort (possibly with a projection in between) only needs the
+// first k rows of the sort, which is what TopKSortExec keeps. TopKSortExec counts its rows in a
+// uint16, larger limits sort everything and let LimitExec hand out the first rows batch by batch
+func (p *Planner) planLimit(n *logicalplan.Limit) (operators.Operator, error) {
+	if n.Count <= math.MaxUint16 {
+		k := uint16(n.Count)
+		switch in := n.Input.(type) {
+		case *logicalplan.Sort:
+			return p.planTopK(in, k)
+		case *logicalplan.Project:
+			if sorted, ok := in.Input.(*logicalplan.Sort); ok {
+				topK, err := p.planTopK(sorted, k)
+				if err != nil {
+					return nil, err
+				}
+				op, err := project.NewProjectExec(topK, in.Exprs)
+				return p.analyzed(op, err, "ProjectExec: "+logicalplan.FormatExprs(in.Exprs), topK)
+			}
+		}
+	}
+	child, err := p.CreatePhysicalPlan(n.Input)
+	if err != nil {
+		return nil, err
+	}
+	op, err := filter.NewLimitExec(child, n.Count)
+	return p.analyzed(op, err, fmt.Sprintf("LimitExec: %d", n.Count), child)
+}
+
+func (p *Planner) planTopK(s *logicalplan.Sort, k uint16) (operators.Operator, error) {
+	child, err := p.CreatePhysicalPlan(s.Input)
+	if err != nil {
+		return nil, err
+	}
+	op, err := aggr.NewTopKSortExec(child, s.Keys, k)
+	return p.analyzed(op, err, fmt.Sprintf("TopKSortExec: k=%d %s", k, formatSortKeys(s.Keys)), child)
+}
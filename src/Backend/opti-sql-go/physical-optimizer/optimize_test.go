@@ -1,7 +1,454 @@
 package physicaloptimizer
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/aggr"
+	"opti-sql-go/operators/filter"
+	"opti-sql-go/operators/project"
+	"reflect"
+	"strings"
+	"testing"
 
-func TestOptimize(t *testing.T) {
-	// Simple passing test
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// memorySources hands out a fresh in memory source every time a table is opened
+type memorySources map[string]func() (*project.InMemorySource, error)
+
+func (m memorySources) TableSchema(name string) (*arrow.Schema, error) {
+	open, ok := m[name]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(name)
+	}
+	src, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return src.Schema(), nil
+}
+
+func (m memorySources) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	open, ok := m[scan.Table]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(scan.Table)
+	}
+	return open()
+}
+
+// closeTracking opens the tables of memorySources, except for fail which can't be opened, and
+// remembers whether the sources it handed out were closed
+type closeTracking struct {
+	memorySources
+	fail   string
+	opened []*trackedSource
+}
+
+type trackedSource struct {
+	operators.Operator
+	closed bool
+}
+
+func (s *trackedSource) Close() error {
+	s.closed = true
+	return s.Operator.Close()
+}
+
+func (c *closeTracking) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	if scan.Table == c.fail {
+		return nil, fmt.Errorf("cannot open %s", scan.Table)
+	}
+	op, err := c.memorySources.Open(scan)
+	if err != nil {
+		return nil, err
+	}
+	src := &trackedSource{Operator: op}
+	c.opened = append(c.opened, src)
+	return src, nil
+}
+
+func testSources() memorySources {
+	return memorySources{
+		"employees": func() (*project.InMemorySource, error) {
+			return project.NewInMemoryProjectExec(
+				[]string{"id", "name", "age", "salary", "dept_id"},
+				[]any{
+					[]int32{1, 2, 3, 4, 5, 6, 7, 8},
+					[]string{"Alice", "Bob", "Carol", "Dan", "Eve", "Frank", "Grace", "Heidi"},
+					[]int32{34, 28, 45, 23, 31, 52, 39, 27},
+					[]float64{70000, 50000, 90000, 40000, 65000, 120000, 80000, 48000},
+					[]int32{1, 2, 1, 3, 2, 1, 3, 2},
+				})
+		},
+		"departments": func() (*project.InMemorySource, error) {
+			return project.NewInMemoryProjectExec(
+				[]string{"id", "department_name"},
+				[]any{
+					[]int32{1, 2, 3},
+					[]string{"Engineering", "Sales", "Support"},
+				})
+		},
+		"contacts": func() (*project.InMemorySource, error) {
+			mem := memory.NewGoAllocator()
+			ids := array.NewInt32Builder(mem)
+			defer ids.Release()
+			ids.AppendValues([]int32{1, 2, 3, 4}, nil)
+			emails := array.NewStringBuilder(mem)
+			defer emails.Release()
+			emails.AppendValues([]string{"alice@example.com", "", "carol@example.com", ""}, []bool{true, false, true, false})
+			return project.NewInMemoryProjectExecFromArrays([]string{"id", "email"}, []arrow.Array{ids.NewArray(), emails.NewArray()})
+		},
+	}
+}
+
+// collectRows drains op in small batches and returns every row as strings
+func collectRows(t *testing.T, op operators.Operator) [][]string {
+	t.Helper()
+	var rows [][]string
+	for {
+		batch, err := op.Next(context.Background(), 3)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := make([]string, len(batch.Columns))
+			for c, col := range batch.Columns {
+				row[c] = col.ValueStr(r)
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := op.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	return rows
+}
+
+func TestPlanSQL(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want [][]string
+	}{
+		{
+			name: "order by with limit",
+			sql:  "SELECT name, age FROM employees WHERE age > 30 ORDER BY age DESC LIMIT 3",
+			want: [][]string{{"Frank", "52"}, {"Carol", "45"}, {"Grace", "39"}},
+		},
+		{
+			name: "group by with having",
+			sql: `SELECT dept_id, SUM(salary) AS total, COUNT(id) AS n FROM employees
+				GROUP BY dept_id HAVING COUNT(id) > 2 ORDER BY total DESC`,
+			want: [][]string{{"1", "280000", "3"}, {"2", "163000", "3"}},
+		},
+		{
+			name: "join",
+			sql: `SELECT e.name, d.department_name FROM employees e JOIN departments d ON e.dept_id = d.id
+				WHERE d.department_name = 'Support' ORDER BY e.name`,
+			want: [][]string{{"Dan", "Support"}, {"Grace", "Support"}},
+		},
+		{
+			name: "distinct",
+			sql:  "SELECT DISTINCT dept_id FROM employees ORDER BY dept_id",
+			want: [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			name: "global aggregate",
+			sql:  "SELECT COUNT(id), AVG(age) FROM employees WHERE salary >= 65000",
+			want: [][]string{{"5", "40.2"}},
+		},
+		{
+			name: "count star and distinct",
+			sql: `SELECT dept_id, COUNT(*), COUNT(DISTINCT age > 30), SUM(DISTINCT dept_id) FROM employees
+				GROUP BY dept_id ORDER BY dept_id`,
+			want: [][]string{{"1", "3", "1", "1"}, {"2", "3", "2", "2"}, {"3", "2", "2", "3"}},
+		},
+		{
+			name: "aggregates over no rows",
+			sql:  "SELECT COUNT(*), COUNT(DISTINCT id), SUM(salary), MIN(name), AVG(age) FROM employees WHERE age > 100",
+			want: [][]string{{"0", "0", "(null)", "(null)", "(null)"}},
+		},
+		{
+			name: "expressions with top k above the projection",
+			sql:  "SELECT UPPER(name) AS upper_name, age + 1 AS next FROM employees WHERE name LIKE '%a%' ORDER BY next LIMIT 2",
+			want: [][]string{{"DAN", "24"}, {"GRACE", "40"}},
+		},
+		{
+			name: "not",
+			sql:  "SELECT name FROM employees WHERE NOT (age > 30) ORDER BY name",
+			want: [][]string{{"Bob"}, {"Dan"}, {"Heidi"}},
+		},
+		{
+			name: "not drops nulls",
+			sql:  "SELECT id FROM contacts WHERE NOT (email = 'alice@example.com') ORDER BY id",
+			want: [][]string{{"3"}},
+		},
+		{
+			name: "is null",
+			sql:  "SELECT id FROM contacts WHERE email IS NULL ORDER BY id",
+			want: [][]string{{"2"}, {"4"}},
+		},
+		{
+			name: "is not null",
+			sql:  "SELECT id FROM contacts WHERE email IS NOT NULL ORDER BY id",
+			want: [][]string{{"1"}, {"3"}},
+		},
+		{
+			name: "limit without sort",
+			sql:  "SELECT id FROM employees LIMIT 4",
+			want: [][]string{{"1"}, {"2"}, {"3"}, {"4"}},
+		},
+		{
+			name: "limit above a join",
+			sql:  "SELECT d.department_name FROM employees e JOIN departments d ON e.dept_id = d.id LIMIT 2",
+			want: [][]string{{"Engineering"}, {"Sales"}},
+		},
+	}
+	for _, workers := range []int{1, 4} {
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%s on %d workers", c.name, workers), func(t *testing.T) {
+				planner := NewPlanner(testSources())
+				planner.workers = workers
+				op, err := planner.PlanSQL(c.sql)
+				if err != nil {
+					t.Fatalf("failed to plan %q: %v", c.sql, err)
+				}
+				got := collectRows(t, op)
+				if !reflect.DeepEqual(got, c.want) {
+					t.Fatalf("expected %v, got %v", c.want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestPlannerOperatorChoice(t *testing.T) {
+	planner := NewPlanner(testSources())
+	lower := func(t *testing.T, plan logicalplan.Plan) operators.Operator {
+		t.Helper()
+		op, err := planner.CreatePhysicalPlan(plan)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return op
+	}
+	bind := func(t *testing.T, sql string) logicalplan.Plan {
+		t.Helper()
+		plan, err := planner.LogicalPlan(sql)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return plan
+	}
+
+	t.Run("order by + limit uses top k", func(t *testing.T) {
+		plan := bind(t, "SELECT id FROM employees ORDER BY id LIMIT 2")
+		if _, ok := lower(t, plan).(*aggr.TopKSortExec); !ok {
+			t.Fatalf("expected TopKSortExec for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("order by alone uses a full sort", func(t *testing.T) {
+		plan := bind(t, "SELECT id FROM employees ORDER BY id")
+		if _, ok := lower(t, plan).(*aggr.SortExec); !ok {
+			t.Fatalf("expected SortExec for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("limit alone", func(t *testing.T) {
+		plan := bind(t, "SELECT id FROM employees LIMIT 2")
+		if _, ok := lower(t, plan).(*filter.LimitExec); !ok {
+			t.Fatalf("expected LimitExec for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("order by + a limit past top k sorts everything", func(t *testing.T) {
+		plan := bind(t, "SELECT id FROM employees ORDER BY id LIMIT 70000")
+		lim, ok := lower(t, plan).(*filter.LimitExec)
+		if !ok {
+			t.Fatalf("expected LimitExec for %s", logicalplan.Format(plan))
+		}
+		if _, ok := lim.Explain().Children[0].(*aggr.SortExec); !ok {
+			t.Fatalf("expected a SortExec below the limit for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("aggregates without group by are global", func(t *testing.T) {
+		plan := bind(t, "SELECT SUM(age) FROM employees")
+		if _, ok := lower(t, plan.Children()[0]).(*aggr.AggrExec); !ok {
+			t.Fatalf("expected AggrExec for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("group by", func(t *testing.T) {
+		plan := bind(t, "SELECT dept_id, SUM(age) FROM employees GROUP BY dept_id HAVING SUM(age) > 10")
+		having := plan.Children()[0]
+		if _, ok := lower(t, having).(*aggr.HavingExec); !ok {
+			t.Fatalf("expected HavingExec for %s", logicalplan.Format(plan))
+		}
+		if _, ok := lower(t, having.Children()[0]).(*aggr.GroupByExec); !ok {
+			t.Fatalf("expected GroupByExec for %s", logicalplan.Format(plan))
+		}
+	})
+	t.Run("filters below an aggregate run on every worker", func(t *testing.T) {
+		parallel := NewPlanner(testSources())
+		parallel.workers = 4
+		plan := bind(t, "SELECT dept_id, SUM(age) FROM employees WHERE age > 30 GROUP BY dept_id")
+		op, err := parallel.CreatePhysicalPlan(plan)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		text := operators.ExplainText(op)
+		if !strings.Contains(text, "ExchangeExec partitioning=round_robin workers=4") || !strings.Contains(text, "ExchangeSource worker=0") {
+			t.Fatalf("expected the filter behind an exchange\n%s", text)
+		}
+		// a plain scan keeps its order
+		op, err = parallel.CreatePhysicalPlan(bind(t, "SELECT name FROM employees WHERE age > 30"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if text := operators.ExplainText(op); strings.Contains(text, "ExchangeExec") {
+			t.Fatalf("expected no exchange\n%s", text)
+		}
+	})
+	t.Run("aggregates are split into a partial per worker and a final one", func(t *testing.T) {
+		parallel := NewPlanner(testSources())
+		parallel.workers = 4
+		for _, sql := range []string{"SELECT dept_id, AVG(age) FROM employees GROUP BY dept_id", "SELECT COUNT(id) FROM employees WHERE age > 30"} {
+			op, err := parallel.CreatePhysicalPlan(bind(t, sql))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			text := operators.ExplainText(op)
+			final := strings.Index(text, "mode=final")
+			exchange := strings.Index(text, "ExchangeExec")
+			partial := strings.Index(text, "mode=partial")
+			if final < 0 || exchange < final || partial < exchange {
+				t.Fatalf("expected a final aggregate over partial ones for %s\n%s", sql, text)
+			}
+		}
+	})
+	t.Run("projected scan columns are pruned", func(t *testing.T) {
+		scan, err := logicalplan.NewScan("employees", mustSchema(t, "employees"), []string{"name"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		op := lower(t, scan)
+		if !op.Schema().Equal(scan.Schema()) {
+			t.Fatalf("expected %v, got %v", scan.Schema(), op.Schema())
+		}
+	})
+}
+
+func TestCreateAnalyzedPlan(t *testing.T) {
+	planner := NewPlanner(testSources())
+	plan, err := planner.LogicalPlan("SELECT d.department_name, SUM(e.salary) FROM employees e JOIN departments d ON e.dept_id = d.id WHERE e.age > 30 GROUP BY d.department_name ORDER BY d.department_name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan, err = NewOptimizer().Optimize(plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	root, err := planner.CreateAnalyzedPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := operators.ExplainAnalyze(context.Background(), root, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Log("\n" + out)
+
+	// every operator of the plan shows up, one per line
+	find := func(prefix string) *operators.AnalyzedExec {
+		t.Helper()
+		var walk func(a *operators.AnalyzedExec) *operators.AnalyzedExec
+		walk = func(a *operators.AnalyzedExec) *operators.AnalyzedExec {
+			if strings.HasPrefix(a.Name(), prefix) {
+				return a
+			}
+			for _, c := range a.Children() {
+				if found := walk(c); found != nil {
+					return found
+				}
+			}
+			return nil
+		}
+		found := walk(root)
+		if found == nil {
+			t.Fatalf("no %s in\n%s", prefix, out)
+		}
+		if !strings.Contains(out, found.Name()+" [rows_in=") {
+			t.Fatalf("%s is missing from the output\n%s", found.Name(), out)
+		}
+		return found
+	}
+	filtered := find("FilterExec: (age > 30)").Stats()
+	if filtered.RowsIn != 8 || filtered.RowsOut != 5 {
+		t.Fatalf("expected the filter to keep 5 of 8 rows, got %+v", filtered)
+	}
+	joined := find("HashJoinExec").Stats()
+	if joined.RowsIn != 5+3 || joined.RowsOut != 5 || joined.PeakMemory == 0 {
+		t.Fatalf("unexpected join stats %+v", joined)
+	}
+	grouped := find("GroupByExec").Stats()
+	if grouped.RowsIn != 5 || grouped.RowsOut != 3 || grouped.PeakMemory == 0 {
+		t.Fatalf("unexpected group by stats %+v", grouped)
+	}
+	sorted := find("SortExec").Stats()
+	// the sort hands its 3 rows out 2 at a time
+	if sorted.RowsOut != 3 || sorted.Batches != 2 || sorted.PeakMemory == 0 {
+		t.Fatalf("unexpected sort stats %+v", sorted)
+	}
+	// the sort is the root, its child time is what the projection below it took
+	if root.Stats() != sorted || sorted.ChildTime != find("ProjectExec").Stats().WallTime || sorted.SelfTime() < 0 {
+		t.Fatalf("child time should add up, sort %+v", sorted)
+	}
+	if len(find("ScanExec: employees").Children()) != 0 {
+		t.Fatalf("scans are leaves")
+	}
+
+	// the planner itself is left alone
+	op, err := planner.CreatePhysicalPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := op.(*operators.AnalyzedExec); ok {
+		t.Fatalf("CreatePhysicalPlan should not wrap operators")
+	}
+}
+
+func TestPlannerErrors(t *testing.T) {
+	planner := NewPlanner(testSources())
+	if _, err := planner.PlanSQL("SELECT e.id FROM employees e LEFT JOIN departments d ON e.dept_id = d.id"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected outer joins to be unsupported, got %v", err)
+	}
+	sources := &closeTracking{memorySources: testSources(), fail: "departments"}
+	if _, err := NewPlanner(sources).PlanSQL("SELECT e.name FROM employees e JOIN departments d ON e.dept_id = d.id"); err == nil {
+		t.Fatalf("expected an error for a table that cannot be opened")
+	}
+	if len(sources.opened) != 1 || !sources.opened[0].closed {
+		t.Fatalf("expected the sources planned before the failure to be closed")
+	}
+	var pe *ParseError
+	if _, err := planner.PlanSQL("SELECT FROM"); !errors.As(err, &pe) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	var be *BindError
+	if _, err := planner.PlanSQL("SELECT nope FROM employees"); !errors.As(err, &be) {
+		t.Fatalf("expected a bind error, got %v", err)
+	}
+}
+
+func mustSchema(t *testing.T, table string) *arrow.Schema {
+	t.Helper()
+	s, err := testSources().TableSchema(table)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
 }
@@ -1,3 +1,922 @@
 package physicaloptimizer
 
-// parse substrait into a format we can work with and optimize
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+// parse sql text into the syntax tree in ast.go
+
+// ParseError is returned for any lexing or parsing failure. Pos points at the token that broke the parse
+type ParseError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("syntax error at %s: %s", e.Pos, e.Msg)
+}
+
+func newParseError(pos Pos, format string, args ...any) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseSQL parses a single SELECT statement. a trailing semicolon is allowed
+func ParseSQL(sql string) (*SelectStatement, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokSemicolon {
+		p.advance()
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, newParseError(tok.pos, "unexpected %s after end of statement", tok)
+	}
+	return stmt, nil
+}
+
+// ===================
+// Lexer
+// ===================
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent // "name" or `name`, never treated as a keyword
+	tokKeyword
+	tokInteger
+	tokFloat
+	tokString
+	tokHexString
+	tokComma
+	tokDot
+	tokLParen
+	tokRParen
+	tokSemicolon
+	tokStar
+	tokPlus
+	tokMinus
+	tokSlash
+	tokEq
+	tokNotEq
+	tokLt
+	tokLtEq
+	tokGt
+	tokGtEq
+)
+
+var keywords = map[string]struct{}{
+	"SELECT": {}, "DISTINCT": {}, "FROM": {}, "WHERE": {}, "GROUP": {}, "BY": {},
+	"HAVING": {}, "ORDER": {}, "ASC": {}, "DESC": {}, "LIMIT": {}, "JOIN": {},
+	"INNER": {}, "LEFT": {}, "RIGHT": {}, "OUTER": {}, "ON": {}, "AS": {}, "AND": {},
+	"OR": {}, "NOT": {}, "LIKE": {}, "IS": {}, "NULL": {}, "TRUE": {}, "FALSE": {},
+	"CAST": {},
+}
+
+type token struct {
+	kind tokenKind
+	text string // keywords are upper cased, everything else is kept as written
+	pos  Pos
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return fmt.Sprintf("string '%s'", t.text)
+	case tokKeyword:
+		return fmt.Sprintf("keyword %s", t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+func lex(sql string) ([]token, error) {
+	var tokens []token
+	src := []rune(sql)
+	line, col := 1, 1
+	i := 0
+	// advance i by n runes keeping line/col in sync
+	step := func(n int) {
+		for k := 0; k < n && i < len(src); k++ {
+			if src[i] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			i++
+		}
+	}
+	for i < len(src) {
+		r := src[i]
+		start := Pos{Line: line, Col: col}
+		switch {
+		case unicode.IsSpace(r):
+			step(1)
+		case r == '-' && i+1 < len(src) && src[i+1] == '-':
+			// line comment
+			for i < len(src) && src[i] != '\n' {
+				step(1)
+			}
+		case (r == 'x' || r == 'X') && i+1 < len(src) && src[i+1] == '\'':
+			step(2)
+			begin := i
+			for i < len(src) && src[i] != '\'' {
+				step(1)
+			}
+			if i >= len(src) {
+				return nil, newParseError(start, "unterminated binary literal")
+			}
+			text := string(src[begin:i])
+			step(1)
+			tokens = append(tokens, token{kind: tokHexString, text: text, pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			begin := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(src[i]) || unicode.IsDigit(src[i])) {
+				step(1)
+			}
+			word := string(src[begin:i])
+			if _, ok := keywords[strings.ToUpper(word)]; ok {
+				tokens = append(tokens, token{kind: tokKeyword, text: strings.ToUpper(word), pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(src) && unicode.IsDigit(src[i+1])):
+			begin := i
+			isFloat := false
+			for i < len(src) && unicode.IsDigit(src[i]) {
+				step(1)
+			}
+			if i < len(src) && src[i] == '.' {
+				isFloat = true
+				step(1)
+				for i < len(src) && unicode.IsDigit(src[i]) {
+					step(1)
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				isFloat = true
+				step(1)
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					step(1)
+				}
+				if i >= len(src) || !unicode.IsDigit(src[i]) {
+					return nil, newParseError(start, "malformed exponent in numeric literal")
+				}
+				for i < len(src) && unicode.IsDigit(src[i]) {
+					step(1)
+				}
+			}
+			kind := tokInteger
+			if isFloat {
+				kind = tokFloat
+			}
+			tokens = append(tokens, token{kind: kind, text: string(src[begin:i]), pos: start})
+		case r == '\'':
+			step(1)
+			var b strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == '\'' {
+					// '' is an escaped quote
+					if i+1 < len(src) && src[i+1] == '\'' {
+						b.WriteRune('\'')
+						step(2)
+						continue
+					}
+					step(1)
+					closed = true
+					break
+				}
+				b.WriteRune(src[i])
+				step(1)
+			}
+			if !closed {
+				return nil, newParseError(start, "unterminated string literal")
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+		case r == '"' || r == '`':
+			quote := r
+			step(1)
+			begin := i
+			for i < len(src) && src[i] != quote {
+				step(1)
+			}
+			if i >= len(src) {
+				return nil, newParseError(start, "unterminated quoted identifier")
+			}
+			text := string(src[begin:i])
+			step(1)
+			if text == "" {
+				return nil, newParseError(start, "empty quoted identifier")
+			}
+			tokens = append(tokens, token{kind: tokQuotedIdent, text: text, pos: start})
+		default:
+			kind, width, ok := symbolToken(src[i:])
+			if !ok {
+				return nil, newParseError(start, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: kind, text: string(src[i : i+width]), pos: start})
+			step(width)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: Pos{Line: line, Col: col}})
+	return tokens, nil
+}
+
+func symbolToken(s []rune) (tokenKind, int, bool) {
+	if len(s) >= 2 {
+		switch string(s[:2]) {
+		case "<=":
+			return tokLtEq, 2, true
+		case ">=":
+			return tokGtEq, 2, true
+		case "!=", "<>":
+			return tokNotEq, 2, true
+		}
+	}
+	switch s[0] {
+	case ',':
+		return tokComma, 1, true
+	case '.':
+		return tokDot, 1, true
+	case '(':
+		return tokLParen, 1, true
+	case ')':
+		return tokRParen, 1, true
+	case ';':
+		return tokSemicolon, 1, true
+	case '*':
+		return tokStar, 1, true
+	case '+':
+		return tokPlus, 1, true
+	case '-':
+		return tokMinus, 1, true
+	case '/':
+		return tokSlash, 1, true
+	case '=':
+		return tokEq, 1, true
+	case '<':
+		return tokLt, 1, true
+	case '>':
+		return tokGt, 1, true
+	}
+	return 0, 0, false
+}
+
+// ===================
+// Parser
+// ===================
+
+// recursive descent, lowest precedence first:
+// OR -> AND -> NOT -> comparison/LIKE/IS NULL -> + - -> * / -> unary - -> primary
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+func (p *parser) isKeyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == tokKeyword && tok.text == kw
+}
+
+// acceptKeyword consumes kw if it is next
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// acceptWord consumes an unquoted identifier spelled like word in any case. NULLS, FIRST and LAST
+// are only keywords right after an ORDER BY key, everywhere else they name columns
+func (p *parser) acceptWord(word string) bool {
+	if tok := p.peek(); tok.kind == tokIdent && strings.EqualFold(tok.text, word) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) (token, error) {
+	tok := p.peek()
+	if tok.kind != tokKeyword || tok.text != kw {
+		return tok, newParseError(tok.pos, "expected %s, found %s", kw, tok)
+	}
+	return p.advance(), nil
+}
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.peek()
+	if tok.kind != kind {
+		return tok, newParseError(tok.pos, "expected %s, found %s", what, tok)
+	}
+	return p.advance(), nil
+}
+
+// identifiers may be bare words or quoted names
+func (p *parser) parseName(what string) (token, error) {
+	tok := p.peek()
+	if tok.kind == tokIdent || tok.kind == tokQuotedIdent {
+		return p.advance(), nil
+	}
+	return tok, newParseError(tok.pos, "expected %s, found %s", what, tok)
+}
+
+func (p *parser) parseSelect() (*SelectStatement, error) {
+	start, err := p.expectKeyword("SELECT")
+	if err != nil {
+		return nil, err
+	}
+	stmt := &SelectStatement{Pos: start.pos}
+	stmt.Distinct = p.acceptKeyword("DISTINCT")
+
+	stmt.Items, err = p.parseSelectList()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	stmt.From, err = p.parseFrom()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("GROUP") {
+		p.advance()
+		if _, err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.GroupBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("HAVING") {
+		if stmt.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("ORDER") {
+		p.advance()
+		if _, err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.OrderBy, err = p.parseOrderList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("LIMIT") {
+		limitTok := p.advance()
+		numTok, err := p.expect(tokInteger, "row count after LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.ParseUint(numTok.text, 10, 64)
+		if err != nil {
+			return nil, newParseError(numTok.pos, "invalid LIMIT %s", numTok.text)
+		}
+		stmt.Limit = &LimitClause{Pos: limitTok.pos, Count: count}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseSelectList() ([]SelectItem, error) {
+	var items []SelectItem
+	for {
+		tok := p.peek()
+		item := SelectItem{Pos: tok.pos}
+		switch {
+		case tok.kind == tokStar:
+			p.advance()
+			item.Expr = &StarExpr{Pos: tok.pos}
+		case (tok.kind == tokIdent || tok.kind == tokQuotedIdent) &&
+			p.peekAt(1).kind == tokDot && p.peekAt(2).kind == tokStar:
+			// t.*
+			p.advance()
+			p.advance()
+			p.advance()
+			item.Expr = &StarExpr{Pos: tok.pos, Table: tok.text}
+		default:
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item.Expr = expr
+			alias, err := p.parseOptionalAlias()
+			if err != nil {
+				return nil, err
+			}
+			item.Alias = alias
+		}
+		items = append(items, item)
+		if p.peek().kind != tokComma {
+			return items, nil
+		}
+		p.advance()
+	}
+}
+
+// [AS] name
+func (p *parser) parseOptionalAlias() (string, error) {
+	if p.acceptKeyword("AS") {
+		tok, err := p.parseName("alias after AS")
+		if err != nil {
+			return "", err
+		}
+		return tok.text, nil
+	}
+	if tok := p.peek(); tok.kind == tokIdent || tok.kind == tokQuotedIdent {
+		p.advance()
+		return tok.text, nil
+	}
+	return "", nil
+}
+
+func (p *parser) parseFrom() (TableExpr, error) {
+	var left TableExpr
+	left, err := p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		kind := InnerJoinKind
+		switch {
+		case p.isKeyword("JOIN"):
+			p.advance()
+		case p.isKeyword("INNER"):
+			p.advance()
+			if _, err := p.expectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		case p.isKeyword("LEFT"), p.isKeyword("RIGHT"):
+			if p.advance().text == "LEFT" {
+				kind = LeftJoinKind
+			} else {
+				kind = RightJoinKind
+			}
+			p.acceptKeyword("OUTER")
+			if _, err := p.expectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		default:
+			return left, nil
+		}
+		right, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expectKeyword("ON"); err != nil {
+			return nil, err
+		}
+		on, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &JoinExpr{Pos: tok.pos, Kind: kind, Left: left, Right: right, On: on}
+	}
+}
+
+func (p *parser) parseTableRef() (*TableRef, error) {
+	tok, err := p.parseName("table name")
+	if err != nil {
+		return nil, err
+	}
+	ref := &TableRef{Pos: tok.pos, Name: tok.text}
+	// file style names are common here (source.csv) so allow dotted names
+	for p.peek().kind == tokDot {
+		p.advance()
+		part, err := p.parseName("table name after '.'")
+		if err != nil {
+			return nil, err
+		}
+		ref.Name += "." + part.text
+	}
+	if ref.Alias, err = p.parseOptionalAlias(); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+func (p *parser) parseOrderList() ([]OrderItem, error) {
+	var items []OrderItem
+	for {
+		tok := p.peek()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		item := OrderItem{Pos: tok.pos, Expr: expr}
+		if p.acceptKeyword("DESC") {
+			item.Descending = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		if p.acceptWord("NULLS") {
+			switch {
+			case p.acceptWord("FIRST"):
+				item.NullsFirst = true
+			case p.acceptWord("LAST"):
+				item.NullsFirst = false
+			default:
+				next := p.peek()
+				return nil, newParseError(next.pos, "expected FIRST or LAST after NULLS, found %s", next)
+			}
+		}
+		items = append(items, item)
+		if p.peek().kind != tokComma {
+			return items, nil
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) parseExprList() ([]ExprNode, error) {
+	var exprs []ExprNode
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if p.peek().kind != tokComma {
+			return exprs, nil
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) parseExpr() (ExprNode, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (ExprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		tok := p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOpExpr{Pos: tok.pos, Op: OpOr, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (ExprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		tok := p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOpExpr{Pos: tok.pos, Op: OpAnd, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (ExprNode, error) {
+	if p.isKeyword("NOT") {
+		tok := p.advance()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOpExpr{Pos: tok.pos, Op: OpNot, Expr: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (ExprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	var op BinaryOp
+	switch tok.kind {
+	case tokEq:
+		op = OpEq
+	case tokNotEq:
+		op = OpNotEq
+	case tokLt:
+		op = OpLt
+	case tokLtEq:
+		op = OpLtEq
+	case tokGt:
+		op = OpGt
+	case tokGtEq:
+		op = OpGtEq
+	case tokKeyword:
+		switch tok.text {
+		case "LIKE":
+			op = OpLike
+		case "NOT":
+			// x NOT LIKE 'pattern'
+			if next := p.peekAt(1); next.kind == tokKeyword && next.text == "LIKE" {
+				p.advance()
+				p.advance()
+				right, err := p.parseAdditive()
+				if err != nil {
+					return nil, err
+				}
+				like := &BinaryOpExpr{Pos: tok.pos, Op: OpLike, Left: left, Right: right}
+				return &UnaryOpExpr{Pos: tok.pos, Op: OpNot, Expr: like}, nil
+			}
+			return left, nil
+		case "IS":
+			p.advance()
+			not := p.acceptKeyword("NOT")
+			if _, err := p.expectKeyword("NULL"); err != nil {
+				return nil, err
+			}
+			return &IsNullExpr{Pos: tok.pos, Expr: left, Not: not}, nil
+		default:
+			return left, nil
+		}
+	default:
+		return left, nil
+	}
+	p.advance()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryOpExpr{Pos: tok.pos, Op: op, Left: left, Right: right}, nil
+}
+
+func (p *parser) parseAdditive() (ExprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		var op BinaryOp
+		switch tok.kind {
+		case tokPlus:
+			op = OpAdd
+		case tokMinus:
+			op = OpSub
+		default:
+			return left, nil
+		}
+		p.advance()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOpExpr{Pos: tok.pos, Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (ExprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		var op BinaryOp
+		switch tok.kind {
+		case tokStar:
+			op = OpMul
+		case tokSlash:
+			op = OpDiv
+		default:
+			return left, nil
+		}
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOpExpr{Pos: tok.pos, Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (ExprNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokPlus:
+		p.advance()
+		return p.parseUnary()
+	case tokMinus:
+		p.advance()
+		next := p.peek()
+		// fold the sign straight into numeric literals so -9223372036854775808 parses
+		if next.kind == tokInteger {
+			p.advance()
+			v, err := strconv.ParseInt("-"+next.text, 10, 64)
+			if err != nil {
+				return nil, newParseError(next.pos, "integer literal -%s out of range", next.text)
+			}
+			return &IntegerLiteral{Pos: tok.pos, Value: v}, nil
+		}
+		if next.kind == tokFloat {
+			p.advance()
+			v, err := strconv.ParseFloat(next.text, 64)
+			if err != nil {
+				return nil, newParseError(next.pos, "invalid float literal %s", next.text)
+			}
+			return &FloatLiteral{Pos: tok.pos, Value: -v}, nil
+		}
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOpExpr{Pos: tok.pos, Op: OpNeg, Expr: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (ExprNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokInteger:
+		p.advance()
+		v, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, newParseError(tok.pos, "integer literal %s out of range", tok.text)
+		}
+		return &IntegerLiteral{Pos: tok.pos, Value: v}, nil
+	case tokFloat:
+		p.advance()
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, newParseError(tok.pos, "invalid float literal %s", tok.text)
+		}
+		return &FloatLiteral{Pos: tok.pos, Value: v}, nil
+	case tokString:
+		p.advance()
+		return &StringLiteral{Pos: tok.pos, Value: tok.text}, nil
+	case tokHexString:
+		p.advance()
+		raw, err := hex.DecodeString(tok.text)
+		if err != nil {
+			return nil, newParseError(tok.pos, "invalid hex in binary literal X'%s'", tok.text)
+		}
+		return &BinaryLiteral{Pos: tok.pos, Value: raw}, nil
+	case tokLParen:
+		p.advance()
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokKeyword:
+		switch tok.text {
+		case "TRUE", "FALSE":
+			p.advance()
+			return &BooleanLiteral{Pos: tok.pos, Value: tok.text == "TRUE"}, nil
+		case "NULL":
+			p.advance()
+			return &NullLiteral{Pos: tok.pos}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		return nil, newParseError(tok.pos, "unexpected %s in expression", tok)
+	case tokIdent, tokQuotedIdent:
+		p.advance()
+		if tok.kind == tokIdent && p.peek().kind == tokLParen {
+			return p.parseFuncCall(tok)
+		}
+		if p.peek().kind == tokDot {
+			p.advance()
+			col, err := p.parseName("column name after '.'")
+			if err != nil {
+				return nil, err
+			}
+			return &Identifier{Pos: tok.pos, Table: tok.text, Name: col.text}, nil
+		}
+		return &Identifier{Pos: tok.pos, Name: tok.text}, nil
+	case tokEOF:
+		return nil, newParseError(tok.pos, "unexpected end of input, expected an expression")
+	default:
+		return nil, newParseError(tok.pos, "unexpected %s in expression", tok)
+	}
+}
+
+// name has already been consumed, next token is '('
+func (p *parser) parseFuncCall(name token) (ExprNode, error) {
+	p.advance()
+	call := &FuncCall{Pos: name.pos, Name: strings.ToUpper(name.text)}
+	if tok := p.peek(); tok.kind == tokStar {
+		p.advance()
+		call.Args = []ExprNode{&StarExpr{Pos: tok.pos}}
+	} else if p.peek().kind != tokRParen {
+		call.Distinct = p.acceptKeyword("DISTINCT")
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+	}
+	if _, err := p.expect(tokRParen, "')' to close "+call.Name); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+func (p *parser) parseCast() (ExprNode, error) {
+	start := p.advance()
+	if _, err := p.expect(tokLParen, "'(' after CAST"); err != nil {
+		return nil, err
+	}
+	inner, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	typeTok, err := p.parseName("type name")
+	if err != nil {
+		return nil, err
+	}
+	dt, ok := sqlTypeToArrow(typeTok.text)
+	if !ok {
+		return nil, newParseError(typeTok.pos, "unknown type %s", typeTok.text)
+	}
+	if _, err := p.expect(tokRParen, "')' to close CAST"); err != nil {
+		return nil, err
+	}
+	return &CastExpr{Pos: start.pos, Expr: inner, TypeName: strings.ToUpper(typeTok.text), Type: dt}, nil
+}
+
+// sql type names onto the arrow types LiteralResolve and CastExpr understand
+func sqlTypeToArrow(name string) (arrow.DataType, bool) {
+	switch strings.ToUpper(name) {
+	case "BOOL", "BOOLEAN":
+		return arrow.FixedWidthTypes.Boolean, true
+	case "TINYINT", "INT8":
+		return arrow.PrimitiveTypes.Int8, true
+	case "SMALLINT", "INT16":
+		return arrow.PrimitiveTypes.Int16, true
+	case "INT", "INTEGER", "INT32":
+		return arrow.PrimitiveTypes.Int32, true
+	case "BIGINT", "INT64":
+		return arrow.PrimitiveTypes.Int64, true
+	case "UTINYINT", "UINT8":
+		return arrow.PrimitiveTypes.Uint8, true
+	case "USMALLINT", "UINT16":
+		return arrow.PrimitiveTypes.Uint16, true
+	case "UINTEGER", "UINT32":
+		return arrow.PrimitiveTypes.Uint32, true
+	case "UBIGINT", "UINT64":
+		return arrow.PrimitiveTypes.Uint64, true
+	case "REAL", "FLOAT", "FLOAT32":
+		return arrow.PrimitiveTypes.Float32, true
+	case "DOUBLE", "FLOAT64":
+		return arrow.PrimitiveTypes.Float64, true
+	case "VARCHAR", "TEXT", "STRING":
+		return arrow.BinaryTypes.String, true
+	case "BINARY", "VARBINARY", "BLOB", "BYTEA":
+		return arrow.BinaryTypes.Binary, true
+	}
+	return nil, false
+}
@@ -1,7 +1,254 @@
 package physicaloptimizer
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func mustParse(t *testing.T, sql string) *SelectStatement {
+	t.Helper()
+	stmt, err := ParseSQL(sql)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", sql, err)
+	}
+	return stmt
+}
 
 func TestParse(t *testing.T) {
-	// Simple passing test
+	t.Run("select star", func(t *testing.T) {
+		stmt := mustParse(t, "SELECT * FROM source1")
+		if len(stmt.Items) != 1 {
+			t.Fatalf("expected 1 select item, got %d", len(stmt.Items))
+		}
+		if _, ok := stmt.Items[0].Expr.(*StarExpr); !ok {
+			t.Fatalf("expected StarExpr, got %T", stmt.Items[0].Expr)
+		}
+		ref, ok := stmt.From.(*TableRef)
+		if !ok || ref.Name != "source1" {
+			t.Fatalf("expected table source1, got %v", stmt.From)
+		}
+	})
+	t.Run("columns and aliases", func(t *testing.T) {
+		stmt := mustParse(t, "select id, username AS name, age_years years, s.email FROM source1 s;")
+		want := []struct {
+			expr, alias string
+		}{
+			{"id", ""}, {"username", "name"}, {"age_years", "years"}, {"s.email", ""},
+		}
+		if len(stmt.Items) != len(want) {
+			t.Fatalf("expected %d items, got %d", len(want), len(stmt.Items))
+		}
+		for i, w := range want {
+			if stmt.Items[i].Expr.String() != w.expr || stmt.Items[i].Alias != w.alias {
+				t.Fatalf("item %d: expected %s AS %q, got %s AS %q", i, w.expr, w.alias, stmt.Items[i].Expr, stmt.Items[i].Alias)
+			}
+		}
+		if ref := stmt.From.(*TableRef); ref.Alias != "s" || ref.Qualifier() != "s" {
+			t.Fatalf("expected table alias s, got %q", ref.Alias)
+		}
+	})
+	t.Run("full clause order", func(t *testing.T) {
+		stmt := mustParse(t, `SELECT DISTINCT department, SUM(salary) AS total
+			FROM employees
+			WHERE age > 30 AND active = true
+			GROUP BY department
+			HAVING SUM(salary) > 1000.5
+			ORDER BY total DESC NULLS FIRST, department
+			LIMIT 10`)
+		if !stmt.Distinct {
+			t.Fatalf("expected DISTINCT")
+		}
+		if stmt.Where == nil || stmt.Having == nil {
+			t.Fatalf("expected WHERE and HAVING to be set")
+		}
+		if len(stmt.GroupBy) != 1 || stmt.GroupBy[0].String() != "department" {
+			t.Fatalf("unexpected group by %v", stmt.GroupBy)
+		}
+		if len(stmt.OrderBy) != 2 {
+			t.Fatalf("expected 2 order items, got %d", len(stmt.OrderBy))
+		}
+		if !stmt.OrderBy[0].Descending || !stmt.OrderBy[0].NullsFirst {
+			t.Fatalf("expected first order key DESC NULLS FIRST, got %v", stmt.OrderBy[0])
+		}
+		if stmt.OrderBy[1].Descending {
+			t.Fatalf("expected second order key to default to ASC")
+		}
+		if stmt.Limit == nil || stmt.Limit.Count != 10 {
+			t.Fatalf("expected LIMIT 10, got %v", stmt.Limit)
+		}
+		call, ok := stmt.Items[1].Expr.(*FuncCall)
+		if !ok || call.Name != "SUM" || len(call.Args) != 1 {
+			t.Fatalf("expected SUM(salary), got %v", stmt.Items[1].Expr)
+		}
+	})
+	t.Run("nulls first and last are only keywords in order by", func(t *testing.T) {
+		stmt := mustParse(t, "SELECT first, last, nulls FROM people ORDER BY last nulls last, first DESC NULLS FIRST")
+		for i, want := range []string{"first", "last", "nulls"} {
+			if got := stmt.Items[i].Expr.String(); got != want {
+				t.Fatalf("item %d: expected column %s, got %s", i, want, got)
+			}
+		}
+		if len(stmt.OrderBy) != 2 {
+			t.Fatalf("expected 2 order items, got %d", len(stmt.OrderBy))
+		}
+		if k := stmt.OrderBy[0]; k.Expr.String() != "last" || k.Descending || k.NullsFirst {
+			t.Fatalf("expected last ASC NULLS LAST, got %v", k)
+		}
+		if k := stmt.OrderBy[1]; k.Expr.String() != "first" || !k.Descending || !k.NullsFirst {
+			t.Fatalf("expected first DESC NULLS FIRST, got %v", k)
+		}
+	})
+	t.Run("joins", func(t *testing.T) {
+		stmt := mustParse(t, `SELECT a.id, b.department_name FROM source1 a
+			JOIN source2 b ON a.id = b.id
+			LEFT OUTER JOIN source3 AS c ON b.id = c.id AND a.age = c.age`)
+		outer, ok := stmt.From.(*JoinExpr)
+		if !ok {
+			t.Fatalf("expected JoinExpr, got %T", stmt.From)
+		}
+		if outer.Kind != LeftJoinKind {
+			t.Fatalf("expected outer join to be LEFT, got %v", outer.Kind)
+		}
+		inner, ok := outer.Left.(*JoinExpr)
+		if !ok || inner.Kind != InnerJoinKind {
+			t.Fatalf("expected left side to be an inner join, got %v", outer.Left)
+		}
+		if inner.On.String() != "(a.id = b.id)" {
+			t.Fatalf("unexpected join condition %s", inner.On)
+		}
+		if right := outer.Right.(*TableRef); right.Qualifier() != "c" {
+			t.Fatalf("expected alias c, got %s", right.Qualifier())
+		}
+	})
+	t.Run("qualified star", func(t *testing.T) {
+		stmt := mustParse(t, "SELECT a.*, b.id FROM t1 a JOIN t2 b ON a.id = b.id")
+		star, ok := stmt.Items[0].Expr.(*StarExpr)
+		if !ok || star.Table != "a" {
+			t.Fatalf("expected a.*, got %v", stmt.Items[0].Expr)
+		}
+	})
+}
+
+func TestParseLiterals(t *testing.T) {
+	stmt := mustParse(t, `SELECT 42, -7, 3.25, -1.5e2, 'it''s', X'CAFE', true, FALSE, NULL,
+		CAST(age AS SMALLINT), CAST(1 AS UBIGINT), CAST(x AS DOUBLE) FROM t`)
+	items := stmt.Items
+	if v := items[0].Expr.(*IntegerLiteral).Value; v != 42 {
+		t.Fatalf("expected 42, got %d", v)
+	}
+	if v := items[1].Expr.(*IntegerLiteral).Value; v != -7 {
+		t.Fatalf("expected -7, got %d", v)
+	}
+	if v := items[2].Expr.(*FloatLiteral).Value; v != 3.25 {
+		t.Fatalf("expected 3.25, got %f", v)
+	}
+	if v := items[3].Expr.(*FloatLiteral).Value; v != -150 {
+		t.Fatalf("expected -150, got %f", v)
+	}
+	if v := items[4].Expr.(*StringLiteral).Value; v != "it's" {
+		t.Fatalf("expected it's, got %s", v)
+	}
+	if v := items[5].Expr.(*BinaryLiteral).Value; len(v) != 2 || v[0] != 0xCA || v[1] != 0xFE {
+		t.Fatalf("expected CAFE bytes, got %x", v)
+	}
+	if v := items[6].Expr.(*BooleanLiteral).Value; !v {
+		t.Fatalf("expected true")
+	}
+	if v := items[7].Expr.(*BooleanLiteral).Value; v {
+		t.Fatalf("expected false")
+	}
+	if _, ok := items[8].Expr.(*NullLiteral); !ok {
+		t.Fatalf("expected NULL literal, got %T", items[8].Expr)
+	}
+	casts := []arrow.DataType{arrow.PrimitiveTypes.Int16, arrow.PrimitiveTypes.Uint64, arrow.PrimitiveTypes.Float64}
+	for i, want := range casts {
+		c, ok := items[9+i].Expr.(*CastExpr)
+		if !ok {
+			t.Fatalf("expected CastExpr, got %T", items[9+i].Expr)
+		}
+		if !arrow.TypeEqual(c.Type, want) {
+			t.Fatalf("expected cast to %s, got %s", want, c.Type)
+		}
+	}
+}
+
+func TestParseExpressionPrecedence(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want string
+	}{
+		{"a + b * c", "(a + (b * c))"},
+		{"(a + b) * c", "((a + b) * c)"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a = 1 OR b = 2 AND c = 3", "((a = 1) OR ((b = 2) AND (c = 3)))"},
+		{"NOT a = 1 AND b <> 2", "(NOT (a = 1) AND (b != 2))"},
+		{"name LIKE 'A%'", "(name LIKE 'A%')"},
+		{"name NOT LIKE 'A%'", "NOT (name LIKE 'A%')"},
+		{"email IS NOT NULL", "email IS NOT NULL"},
+		{"UPPER(name) = 'BOB'", "(UPPER(name) = 'BOB')"},
+		{"-(a + 1)", "-(a + 1)"},
+		{"COUNT(DISTINCT id) >= 2", "(COUNT(DISTINCT id) >= 2)"},
+		{"COUNT(*)", "COUNT(*)"},
+	}
+	for _, c := range cases {
+		t.Run(c.sql, func(t *testing.T) {
+			stmt := mustParse(t, "SELECT "+c.sql+" FROM t")
+			if got := stmt.Items[0].Expr.String(); got != c.want {
+				t.Fatalf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		sql      string
+		line     int
+		col      int
+		contains string
+	}{
+		{"SELECT FROM t", 1, 8, "unexpected keyword FROM"},
+		{"SELECT a t", 1, 11, "expected FROM"},
+		{"SELECT a FROM", 1, 14, "expected table name"},
+		{"SELECT a FROM t WHERE", 1, 22, "unexpected end of input"},
+		{"SELECT a FROM t\nWHERE b = 'oops", 2, 11, "unterminated string"},
+		{"SELECT a FROM t LIMIT x", 1, 23, "row count after LIMIT"},
+		{"SELECT CAST(a AS WIDGET) FROM t", 1, 18, "unknown type WIDGET"},
+		{"SELECT a FROM t ORDER BY a NULLS", 1, 33, "expected FIRST or LAST"},
+		{"SELECT a FROM t1 JOIN t2", 1, 25, "expected ON"},
+		{"SELECT a FROM t extra junk", 1, 23, "unexpected"},
+		{"SELECT a # b FROM t", 1, 10, "unexpected character"},
+		{"SELECT 99999999999999999999 FROM t", 1, 8, "out of range"},
+	}
+	for _, c := range cases {
+		t.Run(c.sql, func(t *testing.T) {
+			_, err := ParseSQL(c.sql)
+			if err == nil {
+				t.Fatalf("expected parse error for %q", c.sql)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %T", err)
+			}
+			if pe.Pos.Line != c.line || pe.Pos.Col != c.col {
+				t.Fatalf("expected error at %d:%d, got %s (%v)", c.line, c.col, pe.Pos, err)
+			}
+			if !strings.Contains(err.Error(), c.contains) {
+				t.Fatalf("expected error to contain %q, got %q", c.contains, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseRoundTripString(t *testing.T) {
+	// printing the tree and parsing it again should give the same tree back
+	sql := "SELECT DISTINCT a, SUM(b) AS s FROM t1 x JOIN t2 y ON x.id = y.id WHERE (a > 1) GROUP BY a HAVING SUM(b) > 2 ORDER BY s DESC LIMIT 5"
+	first := mustParse(t, sql)
+	second := mustParse(t, first.String())
+	if first.String() != second.String() {
+		t.Fatalf("round trip mismatch:\n%s\n%s", first, second)
+	}
 }
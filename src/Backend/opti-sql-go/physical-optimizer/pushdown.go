@@ -0,0 +1,367 @@
+package physicaloptimizer
+
+import (
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	join "opti-sql-go/operators/Join"
+)
+
+var (
+	_ = (Rule)(&PushDownFilter{})
+	_ = (Rule)(&PushDownProjection{})
+)
+
+// ================
+// filter pushdown
+// ================
+
+// PushDownFilter moves predicates as close to the scans as possible so fewer rows flow through
+// the rest of the plan.
+//   - through projections, rewriting the predicate in terms of the projection's input
+//   - through sorts and distincts, which do not care which rows they see
+//   - into the sides of a join, conjunct by conjunct, as long as the side is not null extended
+//   - HAVING conjuncts on group keys become a WHERE below the aggregate
+//
+// adjacent filters are merged into one
+type PushDownFilter struct{}
+
+func (r *PushDownFilter) Name() string { return "push_down_filter" }
+
+func (r *PushDownFilter) Apply(plan logicalplan.Plan) (logicalplan.Plan, error) {
+	return transformUp(plan, func(p logicalplan.Plan) (logicalplan.Plan, error) {
+		switch n := p.(type) {
+		case *logicalplan.Filter:
+			return pushFilter(n.Input, n.Predicate)
+		case *logicalplan.Having:
+			agg, ok := n.Input.(*logicalplan.Aggregate)
+			if !ok {
+				return n, nil
+			}
+			newAgg, rest, err := pushThroughAggregate(agg, n.Predicate)
+			if err != nil || newAgg == agg {
+				return n, err
+			}
+			if rest == nil {
+				return newAgg, nil
+			}
+			return logicalplan.NewHaving(newAgg, rest)
+		}
+		return p, nil
+	})
+}
+
+// pushFilter places pred on top of input, or as far below it as it can go
+func pushFilter(input logicalplan.Plan, pred Expr.Expression) (logicalplan.Plan, error) {
+	switch in := input.(type) {
+	case *logicalplan.Filter:
+		return pushFilter(in.Input, Expr.NewBinaryExpr(in.Predicate, Expr.And, pred))
+
+	case *logicalplan.Project:
+		byName := make(map[string]Expr.Expression, len(in.Exprs))
+		for i, e := range in.Exprs {
+			if a, ok := e.(*Expr.Alias); ok {
+				e = a.Expr
+			}
+			byName[in.Schema().Field(i).Name] = e
+		}
+		below, err := pushFilter(in.Input, logicalplan.ReplaceColumns(pred, byName))
+		if err != nil {
+			return nil, err
+		}
+		return logicalplan.NewProject(below, in.Exprs)
+
+	case *logicalplan.Sort, *logicalplan.Distinct:
+		below, err := pushFilter(in.Children()[0], pred)
+		if err != nil {
+			return nil, err
+		}
+		return logicalplan.WithChildren(in, []logicalplan.Plan{below})
+
+	case *logicalplan.Join:
+		return pushIntoJoin(in, pred)
+
+	case *logicalplan.Aggregate:
+		newAgg, rest, err := pushThroughAggregate(in, pred)
+		if err != nil {
+			return nil, err
+		}
+		if rest == nil {
+			return newAgg, nil
+		}
+		return logicalplan.NewFilter(newAgg, rest)
+	}
+	return logicalplan.NewFilter(input, pred)
+}
+
+// pushIntoJoin sends every conjunct that only reads one side of the join down that side.
+// the side of an outer join that gets null extended has to stay above the join, filtering it
+// early would turn dropped rows into null padded ones
+func pushIntoJoin(j *logicalplan.Join, pred Expr.Expression) (logicalplan.Plan, error) {
+	leftCols, rightCols := joinSideColumns(j)
+	var toLeft, toRight, keep []Expr.Expression
+	for _, c := range logicalplan.SplitConjunction(pred) {
+		refs := logicalplan.ColumnRefs(c)
+		switch {
+		case len(refs) > 0 && allIn(refs, leftCols) && j.Type != join.RightJoin:
+			toLeft = append(toLeft, logicalplan.ReplaceColumns(c, leftCols))
+		case len(refs) > 0 && allIn(refs, rightCols) && j.Type != join.LeftJoin:
+			toRight = append(toRight, logicalplan.ReplaceColumns(c, rightCols))
+		default:
+			keep = append(keep, c)
+		}
+	}
+	if len(toLeft) == 0 && len(toRight) == 0 {
+		return logicalplan.NewFilter(j, pred)
+	}
+	left, right := j.Left, j.Right
+	var err error
+	if len(toLeft) > 0 {
+		if left, err = pushFilter(left, logicalplan.Conjunction(toLeft)); err != nil {
+			return nil, err
+		}
+	}
+	if len(toRight) > 0 {
+		if right, err = pushFilter(right, logicalplan.Conjunction(toRight)); err != nil {
+			return nil, err
+		}
+	}
+	newJoin, err := logicalplan.NewJoin(left, right, j.Type, j.LeftKeys, j.RightKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keep) == 0 {
+		return newJoin, nil
+	}
+	return logicalplan.NewFilter(newJoin, logicalplan.Conjunction(keep))
+}
+
+// joinSideColumns maps the join's output names (which may carry a left_/right_ prefix) back
+// to the column of the side they came from
+func joinSideColumns(j *logicalplan.Join) (left, right map[string]Expr.Expression) {
+	left = make(map[string]Expr.Expression)
+	right = make(map[string]Expr.Expression)
+	nl := j.Left.Schema().NumFields()
+	for i, f := range j.Schema().Fields() {
+		if i < nl {
+			left[f.Name] = Expr.NewColumnResolve(j.Left.Schema().Field(i).Name)
+		} else {
+			right[f.Name] = Expr.NewColumnResolve(j.Right.Schema().Field(i - nl).Name)
+		}
+	}
+	return left, right
+}
+
+// pushThroughAggregate moves the conjuncts of pred that only read group keys below agg.
+// rest is what has to stay above it, nil if everything moved. a global aggregate keeps
+// everything, it produces a row even when nothing reaches it
+func pushThroughAggregate(agg *logicalplan.Aggregate, pred Expr.Expression) (newAgg *logicalplan.Aggregate, rest Expr.Expression, err error) {
+	if len(agg.GroupBy) == 0 {
+		return agg, pred, nil
+	}
+	groups := make(map[string]Expr.Expression, len(agg.GroupBy))
+	for i, g := range agg.GroupBy {
+		groups[agg.Schema().Field(i).Name] = g
+	}
+	var push, keep []Expr.Expression
+	for _, c := range logicalplan.SplitConjunction(pred) {
+		if refs := logicalplan.ColumnRefs(c); len(refs) > 0 && allIn(refs, groups) {
+			push = append(push, logicalplan.ReplaceColumns(c, groups))
+		} else {
+			keep = append(keep, c)
+		}
+	}
+	if len(push) == 0 {
+		return agg, pred, nil
+	}
+	below, err := pushFilter(agg.Input, logicalplan.Conjunction(push))
+	if err != nil {
+		return nil, nil, err
+	}
+	if newAgg, err = logicalplan.NewAggregate(below, agg.GroupBy, agg.Aggregates); err != nil {
+		return nil, nil, err
+	}
+	return newAgg, logicalplan.Conjunction(keep), nil
+}
+
+func allIn(names []string, set map[string]Expr.Expression) bool {
+	for _, n := range names {
+		if _, ok := set[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ================
+// projection pushdown
+// ================
+
+// PushDownProjection works out which columns every node actually needs and narrows each Scan
+// to those columns. sources that support it (see ParquetFiles) then only decode the projected
+// columns. named projection expressions nobody above reads are dropped as well
+type PushDownProjection struct{}
+
+func (r *PushDownProjection) Name() string { return "push_down_projection" }
+
+func (r *PushDownProjection) Apply(plan logicalplan.Plan) (logicalplan.Plan, error) {
+	required := make(map[string]bool)
+	for _, f := range plan.Schema().Fields() {
+		required[f.Name] = true
+	}
+	return prune(plan, required)
+}
+
+// prune rebuilds plan so it produces at least the required columns (and ideally not many more)
+func prune(plan logicalplan.Plan, required map[string]bool) (logicalplan.Plan, error) {
+	switch n := plan.(type) {
+	case *logicalplan.Scan:
+		var cols []string
+		for _, f := range n.Schema().Fields() {
+			if required[f.Name] {
+				cols = append(cols, f.Name)
+			}
+		}
+		if len(cols) == 0 {
+			// something still has to produce the row count
+			cols = []string{n.Schema().Field(0).Name}
+		}
+		if len(cols) == n.Schema().NumFields() {
+			return n, nil
+		}
+		return logicalplan.NewScan(n.Table, n.Source, cols)
+
+	case *logicalplan.Project:
+		exprs := n.Exprs
+		if !hasUnnamed(n.Exprs) {
+			// unnamed expressions are called col_<i>, only drop columns when no position can shift
+			exprs = nil
+			for i, e := range n.Exprs {
+				if required[n.Schema().Field(i).Name] {
+					exprs = append(exprs, e)
+				}
+			}
+			if len(exprs) == 0 {
+				exprs = n.Exprs[:1]
+			}
+		}
+		childReq := make(map[string]bool)
+		for _, e := range exprs {
+			need(childReq, e)
+		}
+		child, err := prune(n.Input, childReq)
+		if err != nil {
+			return nil, err
+		}
+		return logicalplan.NewProject(child, exprs)
+
+	case *logicalplan.Filter:
+		return pruneSingle(n, required, n.Predicate)
+
+	case *logicalplan.Having:
+		return pruneSingle(n, required, n.Predicate)
+
+	case *logicalplan.Sort:
+		keys := make([]Expr.Expression, len(n.Keys))
+		for i, k := range n.Keys {
+			keys[i] = k.Expr
+		}
+		return pruneSingle(n, required, keys...)
+
+	case *logicalplan.Limit:
+		return pruneSingle(n, required)
+
+	case *logicalplan.Distinct:
+		// every column takes part in the comparison
+		all := make(map[string]bool)
+		for _, f := range n.Input.Schema().Fields() {
+			all[f.Name] = true
+		}
+		return pruneSingle(n, all)
+
+	case *logicalplan.Aggregate:
+		childReq := make(map[string]bool)
+		need(childReq, n.GroupBy...)
+		for _, a := range n.Aggregates {
+			need(childReq, a.Child)
+		}
+		child, err := prune(n.Input, childReq)
+		if err != nil {
+			return nil, err
+		}
+		return logicalplan.WithChildren(n, []logicalplan.Plan{child})
+
+	case *logicalplan.Join:
+		return pruneJoin(n, required)
+	}
+	return plan, nil
+}
+
+// pruneSingle prunes the only child of plan, which needs what its parent needs plus whatever
+// exprs read
+func pruneSingle(plan logicalplan.Plan, required map[string]bool, exprs ...Expr.Expression) (logicalplan.Plan, error) {
+	childReq := make(map[string]bool, len(required))
+	for name := range required {
+		childReq[name] = true
+	}
+	need(childReq, exprs...)
+	child, err := prune(plan.Children()[0], childReq)
+	if err != nil {
+		return nil, err
+	}
+	return logicalplan.WithChildren(plan, []logicalplan.Plan{child})
+}
+
+func pruneJoin(j *logicalplan.Join, required map[string]bool) (logicalplan.Plan, error) {
+	leftReq, rightReq := make(map[string]bool), make(map[string]bool)
+	need(leftReq, j.LeftKeys...)
+	need(rightReq, j.RightKeys...)
+	nl := j.Left.Schema().NumFields()
+	for i, f := range j.Schema().Fields() {
+		if !required[f.Name] {
+			continue
+		}
+		if i < nl {
+			leftReq[j.Left.Schema().Field(i).Name] = true
+		} else {
+			rightReq[j.Right.Schema().Field(i-nl).Name] = true
+		}
+	}
+	// a name both sides share is prefixed with left_/right_ in the output. keep both or neither,
+	// dropping only one would remove the prefix and break the columns above that use it
+	for _, f := range j.Left.Schema().Fields() {
+		if len(j.Right.Schema().FieldIndices(f.Name)) == 0 {
+			continue
+		}
+		if leftReq[f.Name] || rightReq[f.Name] {
+			leftReq[f.Name], rightReq[f.Name] = true, true
+		}
+	}
+	left, err := prune(j.Left, leftReq)
+	if err != nil {
+		return nil, err
+	}
+	right, err := prune(j.Right, rightReq)
+	if err != nil {
+		return nil, err
+	}
+	return logicalplan.NewJoin(left, right, j.Type, j.LeftKeys, j.RightKeys)
+}
+
+func need(set map[string]bool, exprs ...Expr.Expression) {
+	for _, e := range exprs {
+		for _, name := range logicalplan.ColumnRefs(e) {
+			set[name] = true
+		}
+	}
+}
+
+func hasUnnamed(exprs []Expr.Expression) bool {
+	for _, e := range exprs {
+		switch e.(type) {
+		case *Expr.Alias, *Expr.ColumnResolve:
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package physicaloptimizer
+
+import (
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+)
+
+func applyRule(t *testing.T, r Rule, plan logicalplan.Plan) logicalplan.Plan {
+	t.Helper()
+	out, err := r.Apply(plan)
+	if err != nil {
+		t.Fatalf("%s failed: %v", r.Name(), err)
+	}
+	if !out.Schema().Equal(plan.Schema()) {
+		t.Fatalf("%s changed the output schema from %v to %v", r.Name(), plan.Schema(), out.Schema())
+	}
+	return out
+}
+
+func scanOf(t *testing.T, table string) *logicalplan.Scan {
+	t.Helper()
+	schema, err := testCatalog().TableSchema(table)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := logicalplan.NewScan(table, schema, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestPushDownFilter(t *testing.T) {
+	rule := &PushDownFilter{}
+	col := Expr.NewColumnResolve
+	int32Lit := func(v int32) Expr.Expression { return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, v) }
+
+	t.Run("through a projection", func(t *testing.T) {
+		proj, err := logicalplan.NewProject(scanOf(t, "source1"), []Expr.Expression{
+			col("id"), Expr.NewAlias(col("age"), "years"),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		plan, err := logicalplan.NewFilter(proj, Expr.NewBinaryExpr(col("years"), Expr.GreaterThan, int32Lit(30)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, plan, `
+Filter: (years > 30)
+  Project: id, age AS years
+    Scan: source1`)
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: id, age AS years
+  Filter: (age > 30)
+    Scan: source1`)
+	})
+	t.Run("split across the sides of a join", func(t *testing.T) {
+		plan := mustBind(t, `SELECT name FROM source1 a JOIN source2 b ON a.id = b.id
+			WHERE a.age > 18 AND b.department_name = 'Sales' AND a.salary > b.id`)
+		expectPlan(t, plan, `
+Project: name
+  Filter: (((age > 18) AND (department_name = 'Sales')) AND (salary > CAST(right_id AS float64)))
+    Join: INNER JOIN ON id = id
+      Scan: source1
+      Scan: source2`)
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name
+  Filter: (salary > CAST(right_id AS float64))
+    Join: INNER JOIN ON id = id
+      Filter: (age > 18)
+        Scan: source1
+      Filter: (department_name = 'Sales')
+        Scan: source2`)
+	})
+	t.Run("prefixed join columns are renamed for their side", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name FROM source1 a JOIN source2 b ON a.id = b.id WHERE b.id < 10")
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name
+  Join: INNER JOIN ON id = id
+    Scan: source1
+    Filter: (id < 10)
+      Scan: source2`)
+	})
+	t.Run("null extended side of an outer join stays above it", func(t *testing.T) {
+		plan := mustBind(t, `SELECT name FROM source1 a LEFT JOIN source2 b ON a.id = b.id
+			WHERE a.age > 18 AND b.department_name = 'Sales'`)
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name
+  Filter: (department_name = 'Sales')
+    Join: LEFT JOIN ON id = id
+      Filter: (age > 18)
+        Scan: source1
+      Scan: source2`)
+	})
+	t.Run("having on group keys moves below the aggregate", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name, SUM(age) FROM source1 GROUP BY name HAVING name <> 'x' AND SUM(age) > 10")
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: group_Column(name) AS name, sum_Column(age) AS SUM(age)
+  Having: (sum_Column(age) > 10)
+    Aggregate: groupBy=[name] aggr=[SUM(age)]
+      Filter: (name != 'x')
+        Scan: source1`)
+	})
+	t.Run("global aggregates keep their having", func(t *testing.T) {
+		plan := mustBind(t, "SELECT SUM(age) FROM source1 HAVING SUM(age) > 10")
+		expectPlan(t, applyRule(t, rule, plan), logicalplan.Format(plan))
+	})
+	t.Run("adjacent filters merge", func(t *testing.T) {
+		inner, err := logicalplan.NewFilter(scanOf(t, "source1"), col("active"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		s, err := logicalplan.NewSort(inner, aggr.CombineSortKeys(aggr.NewSortKey(col("id"), true)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		plan, err := logicalplan.NewFilter(s, Expr.NewBinaryExpr(col("id"), Expr.NotEqual, int32Lit(3)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, applyRule(t, rule, plan), `
+Sort: id ASC NULLS LAST
+  Filter: (active AND (id != 3))
+    Scan: source1`)
+	})
+	t.Run("nothing to push", func(t *testing.T) {
+		plan := mustBind(t, "SELECT id FROM source1 WHERE age > 1")
+		expectPlan(t, applyRule(t, rule, plan), logicalplan.Format(plan))
+	})
+	t.Run("join without a filter", func(t *testing.T) {
+		keys := []Expr.Expression{col("id")}
+		plan, err := logicalplan.NewJoin(scanOf(t, "source1"), scanOf(t, "source2"), join.InnerJoin, keys, keys)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, applyRule(t, rule, plan), logicalplan.Format(plan))
+	})
+}
+
+func TestPushDownProjection(t *testing.T) {
+	rule := &PushDownProjection{}
+
+	t.Run("scan reads only the selected and filtered columns", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name FROM source1 WHERE age > 30")
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name
+  Filter: (age > 30)
+    Scan: source1 projection=[name, age]`)
+	})
+	t.Run("aggregate inputs", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name, SUM(salary) FROM source1 GROUP BY name ORDER BY name")
+		expectPlan(t, applyRule(t, rule, plan), `
+Sort: name ASC NULLS LAST
+  Project: group_Column(name) AS name, sum_Column(salary) AS SUM(salary)
+    Aggregate: groupBy=[name] aggr=[SUM(salary)]
+      Scan: source1 projection=[name, salary]`)
+	})
+	t.Run("join sides keep their keys and clashing names stay paired", func(t *testing.T) {
+		plan := mustBind(t, "SELECT a.name, b.id FROM source1 a JOIN source2 b ON a.id = b.id")
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name, right_id AS id
+  Join: INNER JOIN ON id = id
+    Scan: source1 projection=[id, name]
+    Scan: source2 projection=[id]`)
+	})
+	t.Run("sort keys below the projection", func(t *testing.T) {
+		plan := mustBind(t, "SELECT name FROM source1 ORDER BY salary")
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: name
+  Sort: salary ASC NULLS LAST
+    Scan: source1 projection=[name, salary]`)
+	})
+	t.Run("distinct needs every column below it", func(t *testing.T) {
+		plan := mustBind(t, "SELECT DISTINCT name, age FROM source1")
+		expectPlan(t, applyRule(t, rule, plan), `
+Distinct
+  Project: name, age
+    Scan: source1 projection=[name, age]`)
+	})
+	t.Run("inner projections drop unused columns", func(t *testing.T) {
+		inner, err := logicalplan.NewProject(scanOf(t, "source1"), []Expr.Expression{
+			Expr.NewColumnResolve("id"), Expr.NewAlias(Expr.NewColumnResolve("name"), "who"),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		plan, err := logicalplan.NewProject(inner, []Expr.Expression{Expr.NewColumnResolve("who")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, applyRule(t, rule, plan), `
+Project: who
+  Project: name AS who
+    Scan: source1 projection=[name]`)
+	})
+	t.Run("all columns used", func(t *testing.T) {
+		plan := mustBind(t, "SELECT * FROM source2")
+		expectPlan(t, applyRule(t, rule, plan), logicalplan.Format(plan))
+	})
+}
@@ -0,0 +1,122 @@
+package physicaloptimizer
+
+import (
+	"fmt"
+	logicalplan "opti-sql-go/logical-plan"
+	"strings"
+)
+
+// the optimizer runs a fixed list of rewrite rules over the bound logical plan before it is lowered.
+// a rule takes a whole plan and returns an equivalent one (or the same one if it had nothing to do),
+// so every rule can be tested on its own by feeding it a hand built plan and comparing
+// logicalplan.Format before and after.
+
+// Rule rewrites a logical plan into an equivalent, hopefully cheaper, one
+type Rule interface {
+	Name() string
+	Apply(plan logicalplan.Plan) (logicalplan.Plan, error)
+}
+
+// DefaultRules is the rule list PlanSQL uses. order matters, filters are pushed first so the
+// projection rule can see which columns the pushed down predicates still need
+func DefaultRules() []Rule {
+	return []Rule{
+		&PushDownFilter{},
+		&PushDownProjection{},
+	}
+}
+
+// RuleStep records what one rule did to the plan
+type RuleStep struct {
+	Rule   string
+	Before string // logicalplan.Format of the input
+	After  string // logicalplan.Format of the output
+}
+
+func (s RuleStep) Changed() bool { return s.Before != s.After }
+
+func (s RuleStep) String() string {
+	if !s.Changed() {
+		return fmt.Sprintf("%s: unchanged\n", s.Rule)
+	}
+	return fmt.Sprintf("%s:\nbefore:\n%s\nafter:\n%s", s.Rule, indent(s.Before), indent(s.After))
+}
+
+type Optimizer struct {
+	rules []Rule
+}
+
+// NewOptimizer runs rules in the given order, with no rules it uses DefaultRules
+func NewOptimizer(rules ...Rule) *Optimizer {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &Optimizer{rules: rules}
+}
+
+func (o *Optimizer) Optimize(plan logicalplan.Plan) (logicalplan.Plan, error) {
+	out, _, err := o.optimize(plan, false)
+	return out, err
+}
+
+// Explain optimizes plan and also returns one step per rule describing its before/after plan
+func (o *Optimizer) Explain(plan logicalplan.Plan) (logicalplan.Plan, []RuleStep, error) {
+	return o.optimize(plan, true)
+}
+
+func (o *Optimizer) optimize(plan logicalplan.Plan, trace bool) (logicalplan.Plan, []RuleStep, error) {
+	var steps []RuleStep
+	for _, r := range o.rules {
+		var before string
+		if trace {
+			before = logicalplan.Format(plan)
+		}
+		out, err := r.Apply(plan)
+		if err != nil {
+			return nil, steps, fmt.Errorf("optimizer rule %s: %w", r.Name(), err)
+		}
+		plan = out
+		if trace {
+			steps = append(steps, RuleStep{Rule: r.Name(), Before: before, After: logicalplan.Format(plan)})
+		}
+	}
+	return plan, steps, nil
+}
+
+func indent(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+		b.WriteString("  ")
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// transformUp applies fn to every node bottom up, children are rewritten before their parent
+// so fn always sees a node whose inputs are already final
+func transformUp(plan logicalplan.Plan, fn func(logicalplan.Plan) (logicalplan.Plan, error)) (logicalplan.Plan, error) {
+	children := plan.Children()
+	if len(children) > 0 {
+		rewritten := make([]logicalplan.Plan, len(children))
+		changed := false
+		for i, c := range children {
+			nc, err := transformUp(c, fn)
+			if err != nil {
+				return nil, err
+			}
+			rewritten[i] = nc
+			changed = changed || nc != c
+		}
+		if changed {
+			var err error
+			if plan, err = logicalplan.WithChildren(plan, rewritten); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fn(plan)
+}
@@ -0,0 +1,60 @@
+package physicaloptimizer
+
+import (
+	"errors"
+	logicalplan "opti-sql-go/logical-plan"
+	"strings"
+	"testing"
+)
+
+type failingRule struct{}
+
+func (failingRule) Name() string { return "always_fails" }
+func (failingRule) Apply(logicalplan.Plan) (logicalplan.Plan, error) {
+	return nil, errors.New("boom")
+}
+
+func TestOptimizer(t *testing.T) {
+	t.Run("default rules", func(t *testing.T) {
+		plan := mustBind(t, "SELECT a.name FROM source1 a JOIN source2 b ON a.id = b.id WHERE b.department_name = 'Sales'")
+		out, steps, err := NewOptimizer().Explain(plan)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, out, `
+Project: name
+  Join: INNER JOIN ON id = id
+    Scan: source1 projection=[id, name]
+    Filter: (department_name = 'Sales')
+      Scan: source2`)
+		if len(steps) != 2 || steps[0].Rule != "push_down_filter" || steps[1].Rule != "push_down_projection" {
+			t.Fatalf("unexpected steps %v", steps)
+		}
+		if steps[0].Before != logicalplan.Format(plan) || steps[1].After != logicalplan.Format(out) {
+			t.Fatalf("steps must chain from the input plan to the output plan")
+		}
+		for _, s := range steps {
+			if !s.Changed() {
+				t.Fatalf("expected %s to change the plan", s.Rule)
+			}
+		}
+		if !strings.Contains(steps[1].String(), "after:\n  Project: name\n") {
+			t.Fatalf("unexpected step description\n%s", steps[1])
+		}
+	})
+	t.Run("unchanged steps", func(t *testing.T) {
+		_, steps, err := NewOptimizer(&PushDownFilter{}).Explain(mustBind(t, "SELECT * FROM source2"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(steps) != 1 || steps[0].Changed() || steps[0].String() != "push_down_filter: unchanged\n" {
+			t.Fatalf("unexpected steps %v", steps)
+		}
+	})
+	t.Run("rule errors name the rule", func(t *testing.T) {
+		_, err := NewOptimizer(failingRule{}).Optimize(mustBind(t, "SELECT * FROM source2"))
+		if err == nil || !strings.Contains(err.Error(), "always_fails") {
+			t.Fatalf("expected the failing rule in the error, got %v", err)
+		}
+	})
+}
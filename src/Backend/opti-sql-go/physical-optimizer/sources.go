@@ -0,0 +1,58 @@
+package physicaloptimizer
+
+import (
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/project"
+	"os"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+)
+
+var _ = (SourceProvider)(ParquetFiles{})
+
+// ParquetFiles serves tables from local parquet files, table name -> file path.
+// scans narrowed by the optimizer open the file with project.NewParquetSourcePushDown so only
+// the projected columns are ever decoded
+type ParquetFiles map[string]string
+
+func (p ParquetFiles) TableSchema(name string) (*arrow.Schema, error) {
+	path, ok := p[name]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// closes f as well
+	rdr, err := file.NewParquetReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	defer func() { _ = rdr.Close() }()
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		return nil, err
+	}
+	return fr.Schema()
+}
+
+func (p ParquetFiles) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	path, ok := p[scan.Table]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(scan.Table)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if scan.Columns == nil {
+		return project.NewParquetSource(f)
+	}
+	return project.NewParquetSourcePushDown(f, scan.Columns)
+}
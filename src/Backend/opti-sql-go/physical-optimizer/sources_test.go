@@ -0,0 +1,53 @@
+package physicaloptimizer
+
+import (
+	logicalplan "opti-sql-go/logical-plan"
+	"testing"
+)
+
+const capitalsParquet = "../../test_data/parquet/capitals_clean.parquet"
+
+func TestParquetFiles(t *testing.T) {
+	sources := ParquetFiles{"capitals": capitalsParquet}
+
+	t.Run("schema", func(t *testing.T) {
+		schema, err := sources.TableSchema("capitals")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schema.NumFields() != 5 || schema.Field(0).Name != "country" {
+			t.Fatalf("unexpected schema %v", schema)
+		}
+		if _, err := sources.TableSchema("missing"); err == nil {
+			t.Fatalf("expected error for missing table")
+		}
+	})
+	t.Run("only projected columns are decoded", func(t *testing.T) {
+		planner := NewPlanner(sources)
+		plan, err := planner.LogicalPlan("SELECT capital FROM capitals WHERE country = 'France'")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if plan, err = NewOptimizer().Optimize(plan); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		scan := plan.Children()[0].Children()[0].(*logicalplan.Scan)
+		src, err := sources.Open(scan)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if src.Schema().NumFields() != 2 {
+			t.Fatalf("expected the source to decode 2 columns, got %v", src.Schema())
+		}
+		_ = src.Close()
+
+		op, err := planner.PlanSQL("SELECT capital FROM capitals WHERE country = 'France'")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rows := collectRows(t, op)
+		if len(rows) != 1 || rows[0][0] != "Paris" {
+			t.Fatalf("expected [[Paris]], got %v", rows)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"opti-sql-go/config"
+	"opti-sql-go/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// every query, whether it comes in over ExecuteQuery, ExecuteQueryStream or flight sql, has to be
+// admitted before it runs. up to maxRunning queries run at once, the next maxQueued wait in line for
+// a slot (first come first served) and anything past that is turned away right away with OVERLOADED
+// instead of piling up in memory
+
+var (
+	ErrQueueFull    = errors.New("too many queries running and queued, retry later")
+	ErrQueueTimeout = errors.New("timed out waiting for a free query slot, retry later")
+)
+
+type AdmissionController struct {
+	slots     chan struct{} // one entry per running query, nil means no limit
+	maxQueued int
+	timeout   time.Duration // 0 waits until a slot frees up
+
+	mu      sync.Mutex
+	queued  int
+	running atomic.Int64
+}
+
+// NewAdmissionController runs up to maxRunning queries at once, maxRunning <= 0 means there is no limit
+func NewAdmissionController(maxRunning, maxQueued int, timeout time.Duration) *AdmissionController {
+	a := &AdmissionController{maxQueued: max(maxQueued, 0), timeout: timeout}
+	if maxRunning > 0 {
+		a.slots = make(chan struct{}, maxRunning)
+	}
+	return a
+}
+
+// admissionFromConfig builds the controller from the query config, with concurrent execution
+// turned off queries run one at a time
+func admissionFromConfig() *AdmissionController {
+	q := config.GetConfig().Query
+	maxRunning := q.MaxConcurrentQueries
+	if !q.EnableConcurrentExecution {
+		maxRunning = 1
+	}
+	return NewAdmissionController(maxRunning, q.MaxQueuedQueries, time.Duration(q.QueueTimeoutSeconds)*time.Second)
+}
+
+// Admit blocks until the query may run and returns the func that hands its slot back, it has to be
+// called exactly once when the query is done. fails with ErrQueueFull, ErrQueueTimeout or the
+// context's error when the caller gave up while queued
+func (a *AdmissionController) Admit(ctx context.Context) (func(), error) {
+	if a.slots == nil {
+		return a.admitted(time.Time{}), nil
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return a.admitted(time.Time{}), nil
+	default:
+	}
+	queuedAt := time.Now()
+
+	a.mu.Lock()
+	if a.queued >= a.maxQueued {
+		a.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	a.queued++
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.queued--
+		a.mu.Unlock()
+	}()
+
+	var expired <-chan time.Time
+	if a.timeout > 0 {
+		timer := time.NewTimer(a.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	// blocked senders are woken in the order they blocked, so the queue is fifo
+	select {
+	case a.slots <- struct{}{}:
+		return a.admitted(queuedAt), nil
+	case <-expired:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// admitted counts the query as running and returns its release func, queuedAt is zero when the
+// query got a slot right away
+func (a *AdmissionController) admitted(queuedAt time.Time) func() {
+	var waited time.Duration
+	if !queuedAt.IsZero() {
+		waited = time.Since(queuedAt)
+	}
+	metrics.QueueWait(waited)
+	a.running.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.running.Add(-1)
+			if a.slots != nil {
+				<-a.slots
+			}
+		})
+	}
+}
+
+// QueueLength is the number of queries waiting for a slot, anything above 0 means the backend is saturated
+func (a *AdmissionController) QueueLength() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.queued
+}
+
+// Running is the number of admitted queries that haven't released their slot yet
+func (a *AdmissionController) Running() int {
+	return int(a.running.Load())
+}
+
+// admissionErr tags a failed Admit, only a full queue or a timeout is the server's doing
+func admissionErr(err error) error {
+	if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueTimeout) {
+		return queryErr(ReturnTypes_OVERLOADED, err)
+	}
+	return execErr(err)
+}
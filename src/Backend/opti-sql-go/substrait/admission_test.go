@@ -0,0 +1,172 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"opti-sql-go/config"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// waitQueued waits until n queries are queued on a
+func waitQueued(t *testing.T, a *AdmissionController, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.QueueLength() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued, got %d", n, a.QueueLength())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionController(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("admits up to the limit then queues", func(t *testing.T) {
+		a := NewAdmissionController(2, 1, 0)
+		r1, err := a.Admit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		r2, err := a.Admit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if a.Running() != 2 || a.QueueLength() != 0 {
+			t.Fatalf("expected 2 running and none queued, got %d and %d", a.Running(), a.QueueLength())
+		}
+
+		admitted := make(chan func())
+		go func() {
+			r3, err := a.Admit(ctx)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			admitted <- r3
+		}()
+		waitQueued(t, a, 1)
+		// the queue is full
+		if _, err := a.Admit(ctx); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("expected ErrQueueFull, got %v", err)
+		}
+
+		r1()
+		r1() // releasing twice only frees one slot
+		r3 := <-admitted
+		if a.Running() != 2 || a.QueueLength() != 0 {
+			t.Fatalf("expected 2 running and none queued, got %d and %d", a.Running(), a.QueueLength())
+		}
+		r2()
+		r3()
+		if a.Running() != 0 {
+			t.Fatalf("expected nothing running, got %d", a.Running())
+		}
+	})
+	t.Run("queued queries time out", func(t *testing.T) {
+		a := NewAdmissionController(1, 1, 10*time.Millisecond)
+		release, err := a.Admit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer release()
+		if _, err := a.Admit(ctx); !errors.Is(err, ErrQueueTimeout) {
+			t.Fatalf("expected ErrQueueTimeout, got %v", err)
+		}
+		if a.QueueLength() != 0 {
+			t.Fatalf("a timed out query is still queued")
+		}
+	})
+	t.Run("queued queries give up with their context", func(t *testing.T) {
+		a := NewAdmissionController(1, 1, 0)
+		release, err := a.Admit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer release()
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := a.Admit(cctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("no limit", func(t *testing.T) {
+		a := NewAdmissionController(0, 0, 0)
+		for i := 0; i < 100; i++ {
+			if _, err := a.Admit(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if a.Running() != 100 {
+			t.Fatalf("expected 100 running, got %d", a.Running())
+		}
+	})
+}
+
+func TestServerRejectsWhenOverloaded(t *testing.T) {
+	store, _ := newFakeStore(t, map[string][]byte{"employees.csv": []byte(employeesCSV)})
+	admission := NewAdmissionController(1, 0, 0)
+	release, err := admission.Admit(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := &QueryExecutionRequest{
+		SqlStatement: "SELECT name FROM employees",
+		Source:       &SourceType{S3Source: "employees.csv"},
+	}
+
+	ss := newSubstraitServer(nil, store, admission, nil)
+	resp, err := ss.ExecuteQuery(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetErrorType().GetErrorType() != ReturnTypes_OVERLOADED {
+		t.Fatalf("expected OVERLOADED, got %v", resp.GetErrorType())
+	}
+	if code := status.Code(flightError(admissionErr(ErrQueueFull))); code != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted for flight sql, got %v", code)
+	}
+
+	release()
+	resp, err = ss.ExecuteQuery(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetErrorType().GetErrorType() != ReturnTypes_SUCCESS {
+		t.Fatalf("expected SUCCESS once the slot was released, got %v", resp.GetErrorType())
+	}
+	if admission.Running() != 0 {
+		t.Fatalf("the query didn't release its slot")
+	}
+}
+
+func TestServerTimeoutStartsOnceAdmitted(t *testing.T) {
+	c := &config.GetConfig().Server
+	timeout := c.Timeout
+	t.Cleanup(func() { c.Timeout = timeout })
+	c.Timeout = 1
+
+	store, _ := newFakeStore(t, map[string][]byte{"employees.csv": []byte(employeesCSV)})
+	// the queue gives up after the query timeout would have passed, queueing mustn't eat into it
+	admission := NewAdmissionController(1, 1, 1500*time.Millisecond)
+	release, err := admission.Admit(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+	ss := newSubstraitServer(nil, store, admission, nil)
+	resp, err := ss.ExecuteQuery(context.Background(), &QueryExecutionRequest{
+		SqlStatement: "SELECT name FROM employees",
+		Source:       &SourceType{S3Source: "employees.csv"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetErrorType().GetErrorType() != ReturnTypes_OVERLOADED || !strings.Contains(resp.GetErrorType().GetMessage(), ErrQueueTimeout.Error()) {
+		t.Fatalf("expected the queue to time out with OVERLOADED, got %v", resp.GetErrorType())
+	}
+}
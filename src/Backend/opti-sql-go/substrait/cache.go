@@ -0,0 +1,259 @@
+package substrait
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"opti-sql-go/config"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+)
+
+// results are cached as the arrow IPC stream of their batches, keyed by the optimized plan and the
+// objects it scans. every entry remembers the version (etag, size, mtime) of those objects when it was
+// computed, a lookup made against a different version drops the entry, so an overwritten source is
+// never served stale. past the ttl an entry is gone, past the size budget the least recently used go
+
+var _ = (operators.Operator)(&cachedResult{})
+var _ = (operators.Operator)(&recordingResult{})
+
+type ResultCache struct {
+	ttl      time.Duration // 0 never expires
+	maxBytes int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	size    int
+	lru     *list.List // of *cacheEntry, most recently used at the front
+	entries map[string]*list.Element
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	key     string
+	version string
+	data    []byte
+	expires time.Time
+}
+
+func NewResultCache(ttl time.Duration, maxBytes int) *ResultCache {
+	return &ResultCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// resultCacheFromConfig is nil when caching is turned off
+func resultCacheFromConfig() *ResultCache {
+	q := config.GetConfig().Query
+	if !q.EnableCache {
+		return nil
+	}
+	return NewResultCache(time.Duration(q.CacheTTLSeconds)*time.Second, q.CacheMaxSizeMB*1024*1024)
+}
+
+// get returns the cached result for key if it was computed from the same version of the sources
+func (c *ResultCache) get(key, version string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if e.version != version || (c.ttl > 0 && c.now().After(e.expires)) {
+		c.remove(el)
+		c.misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return e.data, true
+}
+
+func (c *ResultCache) put(key, version string, data []byte) {
+	if len(data) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &cacheEntry{key: key, version: version, data: data, expires: c.now().Add(c.ttl)}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += len(data)
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *ResultCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.size -= len(e.data)
+}
+
+// Len is the number of cached results
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Size is the number of bytes held by the cached results
+func (c *ResultCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// cacheKey identifies a query by its optimized plan, its output schema (the plan text doesn't tell
+// 3 from 3.0) and the objects behind its tables. the version identifies what those objects held
+func cacheKey(sources tableSource, plan logicalplan.Plan) (string, string, error) {
+	tables := make(map[string]*objectTable)
+	if err := scannedTables(sources, plan, tables); err != nil {
+		return "", "", err
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key, version strings.Builder
+	key.WriteString(logicalplan.Format(plan))
+	key.WriteString(plan.Schema().String())
+	for _, name := range names {
+		t := tables[name]
+		fmt.Fprintf(&key, "\n%s=%s", name, t.location)
+		fmt.Fprintf(&version, "%s %s %d %d\n", t.location, t.info.ETag, t.info.Size, t.info.LastModified.UnixNano())
+	}
+	return key.String(), version.String(), nil
+}
+
+func scannedTables(sources tableSource, plan logicalplan.Plan, tables map[string]*objectTable) error {
+	if scan, ok := plan.(*logicalplan.Scan); ok {
+		t, err := sources.table(scan.Table)
+		if err != nil {
+			return err
+		}
+		tables[scan.Table] = t
+	}
+	for _, c := range plan.Children() {
+		if err := scannedTables(sources, c, tables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachedResult replays a cached IPC stream, batches come back the size they were produced at
+type cachedResult struct {
+	rdr *ipc.Reader
+}
+
+func newCachedResult(data []byte) (*cachedResult, error) {
+	rdr, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &cachedResult{rdr: rdr}, nil
+}
+
+func (r *cachedResult) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !r.rdr.Next() {
+		if err := r.rdr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	rec := r.rdr.Record()
+	// the reader releases rec on the next call
+	cols := rec.Columns()
+	for _, c := range cols {
+		c.Retain()
+	}
+	return &operators.RecordBatch{Schema: r.rdr.Schema(), Columns: cols, RowCount: uint64(rec.NumRows())}, nil
+}
+
+func (r *cachedResult) Schema() *arrow.Schema { return r.rdr.Schema() }
+
+func (r *cachedResult) Explain() operators.Explanation {
+	return operators.Explanation{Type: "CachedResult"}
+}
+
+func (r *cachedResult) Close() error {
+	r.rdr.Release()
+	return nil
+}
+
+// recordingResult passes the batches of a query through and writes a copy to the cache once the query
+// ran to the end. a result that outgrows the cache, fails or is closed early isn't cached
+type recordingResult struct {
+	operators.Operator
+	cache   *ResultCache
+	key     string
+	version string
+	buf     bytes.Buffer
+	w       *ipc.Writer // nil once recording was given up
+}
+
+func newRecordingResult(op operators.Operator, cache *ResultCache, key, version string) *recordingResult {
+	r := &recordingResult{Operator: op, cache: cache, key: key, version: version}
+	r.w = ipc.NewWriter(&r.buf, ipc.WithSchema(op.Schema()))
+	return r
+}
+
+func (r *recordingResult) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	batch, err := r.Operator.Next(ctx, n)
+	if r.w == nil {
+		return batch, err
+	}
+	if errors.Is(err, io.EOF) {
+		if r.w.Close() == nil {
+			r.cache.put(r.key, r.version, r.buf.Bytes())
+		}
+		r.stopRecording()
+		return batch, err
+	}
+	if err != nil {
+		r.stopRecording()
+		return batch, err
+	}
+	rec := array.NewRecord(r.Schema(), batch.Columns, int64(batch.RowCount))
+	werr := r.w.Write(rec)
+	rec.Release()
+	if werr != nil || r.buf.Len() > r.cache.maxBytes {
+		r.stopRecording()
+	}
+	return batch, nil
+}
+
+func (r *recordingResult) Explain() operators.Explanation {
+	return operators.ExplainOperator(r.Operator)
+}
+
+func (r *recordingResult) stopRecording() {
+	r.w = nil
+	r.buf = bytes.Buffer{}
+}
@@ -0,0 +1,100 @@
+package substrait
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	t.Run("hit only for the same version", func(t *testing.T) {
+		c := NewResultCache(0, 100)
+		c.put("q", "v1", []byte("result"))
+		if data, ok := c.get("q", "v1"); !ok || string(data) != "result" {
+			t.Fatalf("expected a hit, got %q %v", data, ok)
+		}
+		if _, ok := c.get("q", "v2"); ok {
+			t.Fatalf("a changed source must not hit")
+		}
+		// the stale entry is gone for good
+		if _, ok := c.get("q", "v1"); ok || c.Len() != 0 || c.Size() != 0 {
+			t.Fatalf("stale entry still cached (%d entries, %d bytes)", c.Len(), c.Size())
+		}
+	})
+	t.Run("entries expire", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		c := NewResultCache(time.Minute, 100)
+		c.now = func() time.Time { return now }
+		c.put("q", "v", []byte("result"))
+		now = now.Add(59 * time.Second)
+		if _, ok := c.get("q", "v"); !ok {
+			t.Fatalf("expected a hit before the ttl")
+		}
+		now = now.Add(2 * time.Second)
+		if _, ok := c.get("q", "v"); ok {
+			t.Fatalf("expected the entry to have expired")
+		}
+	})
+	t.Run("least recently used go first", func(t *testing.T) {
+		c := NewResultCache(0, 10)
+		c.put("a", "v", []byte("aaaa"))
+		c.put("b", "v", []byte("bbbb"))
+		c.get("a", "v")
+		c.put("c", "v", []byte("cccc"))
+		if _, ok := c.get("b", "v"); ok {
+			t.Fatalf("expected b to be evicted")
+		}
+		if _, ok := c.get("a", "v"); !ok {
+			t.Fatalf("expected a to be kept")
+		}
+		if c.Size() != 8 {
+			t.Fatalf("expected 8 bytes cached, got %d", c.Size())
+		}
+	})
+	t.Run("results larger than the cache are skipped", func(t *testing.T) {
+		c := NewResultCache(0, 4)
+		c.put("q", "v", []byte("too large"))
+		if c.Len() != 0 {
+			t.Fatalf("expected nothing cached")
+		}
+	})
+}
+
+func TestCachedQueries(t *testing.T) {
+	store, fake := newFakeStore(t, map[string][]byte{"employees.csv": []byte(employeesCSV)})
+	cache := NewResultCache(0, 1<<20)
+	run := func(sql string) [][]string {
+		t.Helper()
+		link, _, err := execute(context.Background(), store, cache, &QueryExecutionRequest{SqlStatement: sql, Source: &SourceType{S3Source: "employees.csv"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return readResult(t, store, link)
+	}
+	const query = "SELECT name FROM employees WHERE age > 30 ORDER BY name"
+
+	first := run(query)
+	if cache.Len() != 1 || cache.hits != 0 {
+		t.Fatalf("expected the result to be cached, %d entries and %d hits", cache.Len(), cache.hits)
+	}
+	if second := run(query); !reflect.DeepEqual(first, second) || cache.hits != 1 {
+		t.Fatalf("expected %v from the cache, got %v (%d hits)", first, second, cache.hits)
+	}
+	// same source, different query
+	run("SELECT name FROM employees WHERE age > 40")
+	if cache.Len() != 2 || cache.hits != 1 {
+		t.Fatalf("expected another entry, %d entries and %d hits", cache.Len(), cache.hits)
+	}
+
+	// another client overwrites the source, the cached result must not be served
+	fake.put("employees.csv", []byte(strings.Replace(employeesCSV, "Carol", "Caroline", 1)))
+	want := [][]string{{"Alice"}, {"Caroline"}}
+	if got := run(query); !reflect.DeepEqual(got, want) || cache.hits != 1 {
+		t.Fatalf("expected %v, got %v (%d hits)", want, got, cache.hits)
+	}
+	if got := run(query); !reflect.DeepEqual(got, want) || cache.hits != 2 {
+		t.Fatalf("expected the new result to be cached, got %v (%d hits)", got, cache.hits)
+	}
+}
@@ -0,0 +1,864 @@
+package substrait
+
+import (
+	"errors"
+	"fmt"
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// the consumer turns a serialized substrait plan (QueryExecutionRequest.substrait_logical) into our
+// logical plan, from there the physical planner takes over exactly like it does for sql.
+// substrait references columns by position, every relation is converted bottom up and field
+// references are resolved against the schema of the relation's input at that point.
+// anything without an equivalent in the engine is reported as an *UnsupportedError so the
+// client can tell "you sent something broken" apart from "we can't run that yet"
+
+var (
+	ErrInvalidPlan = func(format string, args ...any) error {
+		return fmt.Errorf("substrait: invalid plan: %s", fmt.Sprintf(format, args...))
+	}
+)
+
+// UnsupportedError is returned for relations, expressions, types and functions of a valid plan
+// that the engine has no equivalent for
+type UnsupportedError struct {
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("substrait: unsupported %s", e.Feature)
+}
+
+func unsupported(format string, args ...any) error {
+	return &UnsupportedError{Feature: fmt.Sprintf(format, args...)}
+}
+
+// IsUnsupported reports whether err (or anything it wraps) is an *UnsupportedError
+func IsUnsupported(err error) bool {
+	var u *UnsupportedError
+	return errors.As(err, &u)
+}
+
+type Consumer struct {
+	catalog logicalplan.Catalog
+	// function anchor -> function name without its signature (add:i32_i32 -> add)
+	functions map[uint32]string
+}
+
+// NewConsumer creates a consumer. catalog is only used for named tables whose ReadRel carries
+// no base_schema, it may be nil
+func NewConsumer(catalog logicalplan.Catalog) *Consumer {
+	return &Consumer{catalog: catalog}
+}
+
+// ConsumeBytes decodes a serialized substrait Plan and converts it
+func (c *Consumer) ConsumeBytes(data []byte) (logicalplan.Plan, error) {
+	var plan substraitpb.Plan
+	if err := proto.Unmarshal(data, &plan); err != nil {
+		return nil, ErrInvalidPlan("failed to decode plan: %v", err)
+	}
+	return c.Consume(&plan)
+}
+
+// Consume converts the single root relation of plan into a logical plan
+func (c *Consumer) Consume(plan *substraitpb.Plan) (logicalplan.Plan, error) {
+	c.functions = make(map[uint32]string)
+	for _, ext := range plan.GetExtensions() {
+		if f := ext.GetExtensionFunction(); f != nil {
+			name, _, _ := strings.Cut(f.GetName(), ":")
+			c.functions[f.GetFunctionAnchor()] = name
+		}
+	}
+	switch len(plan.GetRelations()) {
+	case 0:
+		return nil, ErrInvalidPlan("plan has no relations")
+	case 1:
+	default:
+		return nil, unsupported("plans with %d relations", len(plan.GetRelations()))
+	}
+	switch r := plan.Relations[0].GetRelType().(type) {
+	case *substraitpb.PlanRel_Root:
+		out, err := c.rel(r.Root.GetInput())
+		if err != nil {
+			return nil, err
+		}
+		return renameOutput(out, r.Root.GetNames())
+	case *substraitpb.PlanRel_Rel:
+		return c.rel(r.Rel)
+	default:
+		return nil, ErrInvalidPlan("plan relation has no content")
+	}
+}
+
+// renameOutput gives the root's columns the names the producer asked for
+func renameOutput(plan logicalplan.Plan, names []string) (logicalplan.Plan, error) {
+	if len(names) == 0 {
+		return plan, nil
+	}
+	schema := plan.Schema()
+	if len(names) != schema.NumFields() {
+		return nil, ErrInvalidPlan("root has %d names for %d columns", len(names), schema.NumFields())
+	}
+	exprs := make([]Expr.Expression, len(names))
+	renamed := false
+	for i, f := range schema.Fields() {
+		exprs[i] = Expr.NewColumnResolve(f.Name)
+		if names[i] != f.Name {
+			exprs[i] = Expr.NewAlias(exprs[i], names[i])
+			renamed = true
+		}
+	}
+	if !renamed {
+		return plan, nil
+	}
+	return logicalplan.NewProject(plan, exprs)
+}
+
+// ================
+// relations
+// ================
+
+func (c *Consumer) rel(r *substraitpb.Rel) (logicalplan.Plan, error) {
+	if r == nil {
+		return nil, ErrInvalidPlan("missing relation")
+	}
+	var (
+		out    logicalplan.Plan
+		common *substraitpb.RelCommon
+		err    error
+	)
+	switch rt := r.GetRelType().(type) {
+	case *substraitpb.Rel_Read:
+		out, err = c.read(rt.Read)
+		common = rt.Read.GetCommon()
+	case *substraitpb.Rel_Filter:
+		out, err = c.filter(rt.Filter)
+		common = rt.Filter.GetCommon()
+	case *substraitpb.Rel_Project:
+		out, err = c.project(rt.Project)
+		common = rt.Project.GetCommon()
+	case *substraitpb.Rel_Aggregate:
+		out, err = c.aggregate(rt.Aggregate)
+		common = rt.Aggregate.GetCommon()
+	case *substraitpb.Rel_Sort:
+		out, err = c.sort(rt.Sort)
+		common = rt.Sort.GetCommon()
+	case *substraitpb.Rel_Fetch:
+		out, err = c.fetch(rt.Fetch)
+		common = rt.Fetch.GetCommon()
+	case *substraitpb.Rel_Join:
+		out, err = c.join(rt.Join)
+		common = rt.Join.GetCommon()
+	case nil:
+		return nil, ErrInvalidPlan("relation has no content")
+	default:
+		return nil, unsupported("relation %s", strings.TrimPrefix(fmt.Sprintf("%T", rt), "*proto.Rel_"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return applyEmit(out, common)
+}
+
+// applyEmit reorders/selects the relation's output columns when it has an emit mapping
+func applyEmit(plan logicalplan.Plan, common *substraitpb.RelCommon) (logicalplan.Plan, error) {
+	emit := common.GetEmit()
+	if emit == nil {
+		return plan, nil
+	}
+	schema := plan.Schema()
+	exprs := make([]Expr.Expression, len(emit.GetOutputMapping()))
+	for i, idx := range emit.GetOutputMapping() {
+		if idx < 0 || int(idx) >= schema.NumFields() {
+			return nil, ErrInvalidPlan("emit references column %d of a relation with %d columns", idx, schema.NumFields())
+		}
+		exprs[i] = Expr.NewColumnResolve(schema.Field(int(idx)).Name)
+	}
+	return logicalplan.NewProject(plan, exprs)
+}
+
+func (c *Consumer) read(r *substraitpb.ReadRel) (logicalplan.Plan, error) {
+	named := r.GetNamedTable()
+	if named == nil {
+		switch r.GetReadType().(type) {
+		case nil:
+			return nil, ErrInvalidPlan("read relation has no source")
+		default:
+			return nil, unsupported("read type %s", strings.TrimPrefix(fmt.Sprintf("%T", r.GetReadType()), "*proto.ReadRel_"))
+		}
+	}
+	table := strings.Join(named.GetNames(), ".")
+	var source *arrow.Schema
+	if r.GetBaseSchema() != nil {
+		var err error
+		if source, err = schemaFromNamedStruct(r.GetBaseSchema()); err != nil {
+			return nil, err
+		}
+	} else {
+		if c.catalog == nil {
+			return nil, ErrInvalidPlan("read of %q has no base_schema", table)
+		}
+		var err error
+		if source, err = c.catalog.TableSchema(table); err != nil {
+			return nil, err
+		}
+	}
+
+	var columns []string
+	for _, item := range r.GetProjection().GetSelect().GetStructItems() {
+		if item.GetChild() != nil {
+			return nil, unsupported("nested projection in read of %q", table)
+		}
+		if item.GetField() < 0 || int(item.GetField()) >= source.NumFields() {
+			return nil, ErrInvalidPlan("projection of read %q references column %d of %d", table, item.GetField(), source.NumFields())
+		}
+		columns = append(columns, source.Field(int(item.GetField())).Name)
+	}
+
+	pred := r.GetFilter()
+	if pred == nil {
+		pred = r.GetBestEffortFilter()
+	}
+	if pred == nil {
+		return logicalplan.NewScan(table, source, columns)
+	}
+	// the filter is expressed against the full base schema, so read everything, filter, then narrow
+	scan, err := logicalplan.NewScan(table, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := c.expr(pred, source)
+	if err != nil {
+		return nil, err
+	}
+	filtered, err := logicalplan.NewFilter(scan, cond)
+	if err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		return filtered, nil
+	}
+	exprs := make([]Expr.Expression, len(columns))
+	for i, col := range columns {
+		exprs[i] = Expr.NewColumnResolve(col)
+	}
+	return logicalplan.NewProject(filtered, exprs)
+}
+
+func (c *Consumer) filter(r *substraitpb.FilterRel) (logicalplan.Plan, error) {
+	input, err := c.rel(r.GetInput())
+	if err != nil {
+		return nil, err
+	}
+	if r.GetCondition() == nil {
+		return nil, ErrInvalidPlan("filter relation without a condition")
+	}
+	cond, err := c.expr(r.GetCondition(), input.Schema())
+	if err != nil {
+		return nil, err
+	}
+	return logicalplan.NewFilter(input, cond)
+}
+
+// project appends the new expressions to the input columns, the emit mapping (if any) then picks
+// what actually leaves the relation. substrait has no names for the new columns, they are called
+// expr<position>
+func (c *Consumer) project(r *substraitpb.ProjectRel) (logicalplan.Plan, error) {
+	input, err := c.rel(r.GetInput())
+	if err != nil {
+		return nil, err
+	}
+	schema := input.Schema()
+	exprs := make([]Expr.Expression, 0, schema.NumFields()+len(r.GetExpressions()))
+	for _, f := range schema.Fields() {
+		exprs = append(exprs, Expr.NewColumnResolve(f.Name))
+	}
+	for _, e := range r.GetExpressions() {
+		converted, err := c.expr(e, schema)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("expr%d", len(exprs))
+		// names coming from an earlier projection can already be taken
+		for len(schema.FieldIndices(name)) > 0 {
+			name += "_"
+		}
+		exprs = append(exprs, Expr.NewAlias(converted, name))
+	}
+	return logicalplan.NewProject(input, exprs)
+}
+
+var aggregateFuncs = map[string]aggr.AggrFunc{
+	"sum":   aggr.Sum,
+	"count": aggr.Count,
+	"avg":   aggr.Avg,
+	"min":   aggr.Min,
+	"max":   aggr.Max,
+}
+
+func (c *Consumer) aggregate(r *substraitpb.AggregateRel) (logicalplan.Plan, error) {
+	input, err := c.rel(r.GetInput())
+	if err != nil {
+		return nil, err
+	}
+	schema := input.Schema()
+	if len(r.GetGroupings()) > 1 {
+		return nil, unsupported("grouping sets (%d groupings)", len(r.GetGroupings()))
+	}
+	if len(r.GetMeasures()) == 0 && groupsAllColumns(r.GetGroupings(), schema.NumFields()) {
+		return logicalplan.NewDistinct(input), nil
+	}
+	var groupBy []Expr.Expression
+	if len(r.GetGroupings()) == 1 {
+		for _, g := range r.Groupings[0].GetGroupingExpressions() {
+			e, err := c.expr(g, schema)
+			if err != nil {
+				return nil, err
+			}
+			groupBy = append(groupBy, e)
+		}
+	}
+	aggregates := make([]aggr.AggregateFunctions, 0, len(r.GetMeasures()))
+	for _, m := range r.GetMeasures() {
+		fn := m.GetMeasure()
+		if fn == nil {
+			return nil, ErrInvalidPlan("aggregate measure without a function")
+		}
+		name, err := c.functionName(fn.GetFunctionReference())
+		if err != nil {
+			return nil, err
+		}
+		kind, ok := aggregateFuncs[name]
+		if !ok {
+			return nil, unsupported("aggregate function %s", name)
+		}
+		if m.GetFilter() != nil {
+			return nil, unsupported("filtered aggregate %s", name)
+		}
+		switch fn.GetPhase() {
+		case substraitpb.AggregationPhase_AGGREGATION_PHASE_UNSPECIFIED, substraitpb.AggregationPhase_AGGREGATION_PHASE_INITIAL_TO_RESULT:
+		default:
+			return nil, unsupported("aggregation phase %s", fn.GetPhase())
+		}
+		args, err := functionArgs(fn.GetArguments(), fn.GetArgs())
+		if err != nil {
+			return nil, err
+		}
+		distinct := fn.GetInvocation() == substraitpb.AggregateFunction_AGGREGATION_INVOCATION_DISTINCT
+		if len(args) == 0 {
+			// count without arguments counts rows, COUNT(*)
+			if kind != aggr.Count || distinct {
+				return nil, unsupported("%s without arguments", name)
+			}
+			aggregates = append(aggregates, aggr.NewAggregateFunctions(aggr.CountStar, nil))
+			continue
+		}
+		if len(args) != 1 {
+			return nil, ErrInvalidPlan("%s takes one argument, got %d", name, len(args))
+		}
+		child, err := c.expr(args[0], schema)
+		if err != nil {
+			return nil, err
+		}
+		agg := aggr.NewAggregateFunctions(kind, child)
+		agg.Distinct = distinct
+		aggregates = append(aggregates, agg)
+	}
+	return logicalplan.NewAggregate(input, groupBy, aggregates)
+}
+
+// groupsAllColumns reports whether groupings is the single grouping on every input column in
+// order, which is how a DISTINCT is written in substrait
+func groupsAllColumns(groupings []*substraitpb.AggregateRel_Grouping, width int) bool {
+	if len(groupings) != 1 || len(groupings[0].GetGroupingExpressions()) != width {
+		return false
+	}
+	for i, g := range groupings[0].GetGroupingExpressions() {
+		f := g.GetSelection().GetDirectReference().GetStructField()
+		if f == nil || f.GetChild() != nil || int(f.GetField()) != i {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Consumer) sort(r *substraitpb.SortRel) (logicalplan.Plan, error) {
+	input, err := c.rel(r.GetInput())
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]aggr.SortKey, 0, len(r.GetSorts()))
+	for _, s := range r.GetSorts() {
+		e, err := c.expr(s.GetExpr(), input.Schema())
+		if err != nil {
+			return nil, err
+		}
+		var asc, nullsFirst bool
+		switch s.GetDirection() {
+		case substraitpb.SortField_SORT_DIRECTION_ASC_NULLS_FIRST:
+			asc, nullsFirst = true, true
+		case substraitpb.SortField_SORT_DIRECTION_ASC_NULLS_LAST:
+			asc = true
+		case substraitpb.SortField_SORT_DIRECTION_DESC_NULLS_FIRST:
+			nullsFirst = true
+		case substraitpb.SortField_SORT_DIRECTION_DESC_NULLS_LAST:
+		default:
+			if _, ok := s.GetSortKind().(*substraitpb.SortField_ComparisonFunctionReference); ok {
+				return nil, unsupported("sort by comparison function")
+			}
+			return nil, unsupported("sort direction %s", s.GetDirection())
+		}
+		keys = append(keys, *aggr.NewSortKey(e, asc, nullsFirst))
+	}
+	return logicalplan.NewSort(input, keys)
+}
+
+func (c *Consumer) fetch(r *substraitpb.FetchRel) (logicalplan.Plan, error) {
+	input, err := c.rel(r.GetInput())
+	if err != nil {
+		return nil, err
+	}
+	if r.GetOffset() != 0 {
+		return nil, unsupported("fetch with an offset")
+	}
+	if r.GetCount() < 0 {
+		// -1 means all remaining rows
+		return input, nil
+	}
+	return logicalplan.NewLimit(input, uint64(r.GetCount())), nil
+}
+
+// join turns the join condition into equi-join keys. equalities between a column of each side
+// become keys, what is left is applied as a filter on top of an inner join
+func (c *Consumer) join(r *substraitpb.JoinRel) (logicalplan.Plan, error) {
+	var joinType join.JoinType
+	switch r.GetType() {
+	case substraitpb.JoinRel_JOIN_TYPE_INNER:
+		joinType = join.InnerJoin
+	case substraitpb.JoinRel_JOIN_TYPE_LEFT:
+		joinType = join.LeftJoin
+	case substraitpb.JoinRel_JOIN_TYPE_RIGHT:
+		joinType = join.RightJoin
+	default:
+		return nil, unsupported("join type %s", r.GetType())
+	}
+	left, err := c.rel(r.GetLeft())
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.rel(r.GetRight())
+	if err != nil {
+		return nil, err
+	}
+	if r.GetExpression() == nil {
+		return nil, unsupported("join without a condition")
+	}
+	// the condition sees left ++ right, the same positions as the join output
+	outSchema, err := join.JoinSchemas(left.Schema(), right.Schema())
+	if err != nil {
+		return nil, err
+	}
+	cond, err := c.expr(r.GetExpression(), outSchema)
+	if err != nil {
+		return nil, err
+	}
+	leftCols, rightCols := sideColumns(outSchema, left.Schema(), right.Schema())
+	var leftKeys, rightKeys, residual []Expr.Expression
+	for _, conj := range logicalplan.SplitConjunction(cond) {
+		if b, ok := conj.(*Expr.BinaryExpr); ok && b.Op == Expr.Equal {
+			l, r := logicalplan.ColumnRefs(b.Left), logicalplan.ColumnRefs(b.Right)
+			switch {
+			case onlyIn(l, leftCols) && onlyIn(r, rightCols):
+				leftKeys = append(leftKeys, logicalplan.ReplaceColumns(b.Left, leftCols))
+				rightKeys = append(rightKeys, logicalplan.ReplaceColumns(b.Right, rightCols))
+				continue
+			case onlyIn(l, rightCols) && onlyIn(r, leftCols):
+				leftKeys = append(leftKeys, logicalplan.ReplaceColumns(b.Right, leftCols))
+				rightKeys = append(rightKeys, logicalplan.ReplaceColumns(b.Left, rightCols))
+				continue
+			}
+		}
+		residual = append(residual, conj)
+	}
+	if len(leftKeys) == 0 {
+		return nil, unsupported("join without an equality between both sides")
+	}
+	if len(residual) > 0 && joinType != join.InnerJoin {
+		return nil, unsupported("non equality condition on a %s", joinType)
+	}
+	var out logicalplan.Plan
+	if out, err = logicalplan.NewJoin(left, right, joinType, leftKeys, rightKeys); err != nil {
+		return nil, err
+	}
+	if len(residual) > 0 {
+		if out, err = logicalplan.NewFilter(out, logicalplan.Conjunction(residual)); err != nil {
+			return nil, err
+		}
+	}
+	if r.GetPostJoinFilter() != nil {
+		post, err := c.expr(r.GetPostJoinFilter(), outSchema)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = logicalplan.NewFilter(out, post); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// sideColumns maps the join output names back to the column of the side they came from
+func sideColumns(out, left, right *arrow.Schema) (l, r map[string]Expr.Expression) {
+	l = make(map[string]Expr.Expression)
+	r = make(map[string]Expr.Expression)
+	for i, f := range out.Fields() {
+		if i < left.NumFields() {
+			l[f.Name] = Expr.NewColumnResolve(left.Field(i).Name)
+		} else {
+			r[f.Name] = Expr.NewColumnResolve(right.Field(i - left.NumFields()).Name)
+		}
+	}
+	return l, r
+}
+
+func onlyIn(names []string, set map[string]Expr.Expression) bool {
+	if len(names) == 0 {
+		return false
+	}
+	for _, n := range names {
+		if _, ok := set[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ================
+// expressions
+// ================
+
+func (c *Consumer) expr(e *substraitpb.Expression, schema *arrow.Schema) (Expr.Expression, error) {
+	switch rt := e.GetRexType().(type) {
+	case *substraitpb.Expression_Literal_:
+		return literal(rt.Literal)
+	case *substraitpb.Expression_Selection:
+		return fieldReference(rt.Selection, schema)
+	case *substraitpb.Expression_ScalarFunction_:
+		return c.scalarFunction(rt.ScalarFunction, schema)
+	case *substraitpb.Expression_Cast_:
+		if rt.Cast.GetInput() == nil {
+			return nil, ErrInvalidPlan("cast without an input")
+		}
+		inner, err := c.expr(rt.Cast.GetInput(), schema)
+		if err != nil {
+			return nil, err
+		}
+		to, err := arrowType(rt.Cast.GetType())
+		if err != nil {
+			return nil, err
+		}
+		return Expr.NewCastExpr(inner, to), nil
+	case nil:
+		return nil, ErrInvalidPlan("empty expression")
+	default:
+		return nil, unsupported("expression %s", strings.TrimPrefix(fmt.Sprintf("%T", rt), "*proto.Expression_"))
+	}
+}
+
+// fieldReference only handles the common case, a direct reference to a top level column of the input
+func fieldReference(ref *substraitpb.Expression_FieldReference, schema *arrow.Schema) (Expr.Expression, error) {
+	if ref.GetOuterReference() != nil || ref.GetExpression() != nil {
+		return nil, unsupported("field reference outside the input relation")
+	}
+	field := ref.GetDirectReference().GetStructField()
+	if field == nil {
+		return nil, unsupported("field reference that is not a struct field")
+	}
+	if field.GetChild() != nil {
+		return nil, unsupported("nested field reference")
+	}
+	idx := int(field.GetField())
+	if idx < 0 || idx >= schema.NumFields() {
+		return nil, ErrInvalidPlan("field reference %d out of range for %d columns", idx, schema.NumFields())
+	}
+	return Expr.NewColumnResolve(schema.Field(idx).Name), nil
+}
+
+func literal(l *substraitpb.Expression_Literal) (Expr.Expression, error) {
+	switch v := l.GetLiteralType().(type) {
+	case *substraitpb.Expression_Literal_Boolean:
+		return Expr.NewLiteralResolve(arrow.FixedWidthTypes.Boolean, v.Boolean), nil
+	case *substraitpb.Expression_Literal_I8:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int8, int(v.I8)), nil
+	case *substraitpb.Expression_Literal_I16:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int16, int(v.I16)), nil
+	case *substraitpb.Expression_Literal_I32:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int32, int(v.I32)), nil
+	case *substraitpb.Expression_Literal_I64:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Int64, int(v.I64)), nil
+	case *substraitpb.Expression_Literal_Fp32:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float32, float64(v.Fp32)), nil
+	case *substraitpb.Expression_Literal_Fp64:
+		return Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float64, v.Fp64), nil
+	case *substraitpb.Expression_Literal_String_:
+		return Expr.NewLiteralResolve(arrow.BinaryTypes.String, v.String_), nil
+	case *substraitpb.Expression_Literal_VarChar_:
+		return Expr.NewLiteralResolve(arrow.BinaryTypes.String, v.VarChar.GetValue()), nil
+	case *substraitpb.Expression_Literal_Binary:
+		return Expr.NewLiteralResolve(arrow.BinaryTypes.Binary, v.Binary), nil
+	case *substraitpb.Expression_Literal_Null:
+		// literals carry no null flag, a typed null becomes the untyped NULL
+		return Expr.NewLiteralResolve(arrow.Null, nil), nil
+	case nil:
+		return nil, ErrInvalidPlan("empty literal")
+	default:
+		return nil, unsupported("literal %s", strings.TrimPrefix(fmt.Sprintf("%T", v), "*proto.Expression_Literal_"))
+	}
+}
+
+func (c *Consumer) scalarFunction(f *substraitpb.Expression_ScalarFunction, schema *arrow.Schema) (Expr.Expression, error) {
+	name, err := c.functionName(f.GetFunctionReference())
+	if err != nil {
+		return nil, err
+	}
+	rawArgs, err := functionArgs(f.GetArguments(), f.GetArgs())
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Expr.Expression, len(rawArgs))
+	types := make([]arrow.DataType, len(rawArgs))
+	for i, a := range rawArgs {
+		if args[i], err = c.expr(a, schema); err != nil {
+			return nil, err
+		}
+		if types[i], err = Expr.ExprDataType(args[i], schema); err != nil {
+			return nil, err
+		}
+	}
+	arity := func(n int) error {
+		if len(args) != n {
+			return ErrInvalidPlan("%s takes %d argument(s), got %d", name, n, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "add", "subtract", "multiply", "divide":
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		if !isNumeric(types[0]) || !isNumeric(types[1]) {
+			return nil, ErrInvalidPlan("cannot apply %s to %s and %s", name, types[0], types[1])
+		}
+		// arithmetic results are float64 (see Expr.ExprDataType), make the inputs agree with that
+		l, r := toFloat64(args[0], types[0]), toFloat64(args[1], types[1])
+		switch name {
+		case "add":
+			return Expr.NewBinaryExpr(l, Expr.Addition, r), nil
+		case "subtract":
+			return Expr.NewBinaryExpr(l, Expr.Subtraction, r), nil
+		case "multiply":
+			return Expr.NewBinaryExpr(l, Expr.Multiplication, r), nil
+		default:
+			return Expr.NewBinaryExpr(l, Expr.Division, r), nil
+		}
+
+	case "equal", "not_equal", "lt", "lte", "gt", "gte":
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		l, r := args[0], args[1]
+		if !arrow.TypeEqual(types[0], types[1]) {
+			if !isNumeric(types[0]) || !isNumeric(types[1]) {
+				return nil, ErrInvalidPlan("cannot compare %s with %s", types[0], types[1])
+			}
+			l, r = toFloat64(l, types[0]), toFloat64(r, types[1])
+		}
+		switch name {
+		case "equal":
+			return Expr.NewBinaryExpr(l, Expr.Equal, r), nil
+		case "not_equal":
+			return Expr.NewBinaryExpr(l, Expr.NotEqual, r), nil
+		case "lt":
+			return Expr.NewBinaryExpr(l, Expr.LessThan, r), nil
+		case "lte":
+			return Expr.NewBinaryExpr(l, Expr.LessThanOrEqual, r), nil
+		case "gt":
+			return Expr.NewBinaryExpr(l, Expr.GreaterThan, r), nil
+		default:
+			return Expr.NewBinaryExpr(l, Expr.GreaterThanOrEqual, r), nil
+		}
+
+	case "and", "or":
+		if len(args) == 0 {
+			return nil, ErrInvalidPlan("%s without arguments", name)
+		}
+		out := args[0]
+		for _, a := range args[1:] {
+			if name == "and" {
+				out = Expr.NewBinaryExpr(out, Expr.And, a)
+			} else {
+				out = Expr.NewBinaryExpr(out, Expr.Or, a)
+			}
+		}
+		return out, nil
+
+	case "not":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		if types[0].ID() != arrow.BOOL {
+			return nil, ErrInvalidPlan("not expects a boolean, got %s", types[0])
+		}
+		return Expr.NewNotExpr(args[0]), nil
+
+	case "is_null", "is_not_null":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		// NullCheckExpr is true for non null values
+		check := Expr.NewNullCheckExpr(args[0])
+		if name == "is_not_null" {
+			return check, nil
+		}
+		return Expr.NewNotExpr(check), nil
+
+	case "like":
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		if _, ok := args[1].(*Expr.LiteralResolve); !ok || types[1].ID() != arrow.STRING {
+			return nil, unsupported("like with a pattern that is not a string literal")
+		}
+		return Expr.NewBinaryExpr(args[0], Expr.Like, args[1]), nil
+
+	case "upper", "lower":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		if name == "upper" {
+			return Expr.NewScalarFunction(Expr.Upper, args[0]), nil
+		}
+		return Expr.NewScalarFunction(Expr.Lower, args[0]), nil
+
+	case "abs":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		return Expr.NewScalarFunction(Expr.Abs, args[0]), nil
+
+	case "round":
+		if len(args) == 2 {
+			return nil, unsupported("round to a number of decimals")
+		}
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		return Expr.NewScalarFunction(Expr.Round, args[0]), nil
+
+	case "negate":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		if !isNumeric(types[0]) {
+			return nil, ErrInvalidPlan("cannot negate %s", types[0])
+		}
+		zero := Expr.NewLiteralResolve(arrow.PrimitiveTypes.Float64, 0.0)
+		return Expr.NewBinaryExpr(zero, Expr.Subtraction, toFloat64(args[0], types[0])), nil
+
+	default:
+		return nil, unsupported("function %s", name)
+	}
+}
+
+func (c *Consumer) functionName(anchor uint32) (string, error) {
+	name, ok := c.functions[anchor]
+	if !ok {
+		return "", ErrInvalidPlan("function reference %d has no extension declaration", anchor)
+	}
+	return name, nil
+}
+
+// functionArgs returns the value arguments of a function call. older producers still fill the
+// deprecated args field instead of arguments
+func functionArgs(arguments []*substraitpb.FunctionArgument, legacy []*substraitpb.Expression) ([]*substraitpb.Expression, error) {
+	if len(arguments) == 0 {
+		return legacy, nil
+	}
+	out := make([]*substraitpb.Expression, len(arguments))
+	for i, a := range arguments {
+		v := a.GetValue()
+		if v == nil {
+			return nil, unsupported("enum or type function arguments")
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// ================
+// types
+// ================
+
+func schemaFromNamedStruct(ns *substraitpb.NamedStruct) (*arrow.Schema, error) {
+	types := ns.GetStruct().GetTypes()
+	if len(types) != len(ns.GetNames()) {
+		// names are depth first, only flat schemas have one name per type
+		return nil, unsupported("nested base_schema (%d names for %d columns)", len(ns.GetNames()), len(types))
+	}
+	fields := make([]arrow.Field, len(types))
+	for i, t := range types {
+		dt, err := arrowType(t)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = arrow.Field{Name: ns.Names[i], Type: dt, Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+func arrowType(t *substraitpb.Type) (arrow.DataType, error) {
+	switch k := t.GetKind().(type) {
+	case *substraitpb.Type_Bool:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case *substraitpb.Type_I8_:
+		return arrow.PrimitiveTypes.Int8, nil
+	case *substraitpb.Type_I16_:
+		return arrow.PrimitiveTypes.Int16, nil
+	case *substraitpb.Type_I32_:
+		return arrow.PrimitiveTypes.Int32, nil
+	case *substraitpb.Type_I64_:
+		return arrow.PrimitiveTypes.Int64, nil
+	case *substraitpb.Type_Fp32:
+		return arrow.PrimitiveTypes.Float32, nil
+	case *substraitpb.Type_Fp64:
+		return arrow.PrimitiveTypes.Float64, nil
+	case *substraitpb.Type_String_, *substraitpb.Type_Varchar:
+		return arrow.BinaryTypes.String, nil
+	case *substraitpb.Type_Binary_:
+		return arrow.BinaryTypes.Binary, nil
+	case nil:
+		return nil, ErrInvalidPlan("missing type")
+	default:
+		return nil, unsupported("type %s", strings.TrimPrefix(fmt.Sprintf("%T", k), "*proto.Type_"))
+	}
+}
+
+func isNumeric(dt arrow.DataType) bool {
+	return arrow.IsInteger(dt.ID()) || arrow.IsFloating(dt.ID())
+}
+
+func toFloat64(e Expr.Expression, dt arrow.DataType) Expr.Expression {
+	if dt.ID() == arrow.FLOAT64 {
+		return e
+	}
+	return Expr.NewCastExpr(e, arrow.PrimitiveTypes.Float64)
+}
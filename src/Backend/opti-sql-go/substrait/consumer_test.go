@@ -0,0 +1,414 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"io"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/project"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"google.golang.org/protobuf/proto"
+)
+
+// ================
+// plan building helpers
+// ================
+
+// function anchors used by every test plan
+var testFunctions = []string{"equal:any_any", "gt:i32_i32", "and:bool", "add:i32_i32", "sum:fp64", "count:any", "upper:str", "multiply:fp64_fp64", "regexp_match:str_str", "lt:i32_i32", "not:bool", "is_null:any", "is_not_null:any"}
+
+func anchor(name string) uint32 {
+	for i, f := range testFunctions {
+		if strings.HasPrefix(f, name+":") {
+			return uint32(i + 1)
+		}
+	}
+	panic("unknown test function " + name)
+}
+
+func testPlan(root *substraitpb.Rel, names ...string) *substraitpb.Plan {
+	plan := &substraitpb.Plan{
+		Relations: []*substraitpb.PlanRel{{RelType: &substraitpb.PlanRel_Root{Root: &substraitpb.RelRoot{Input: root, Names: names}}}},
+	}
+	for i, f := range testFunctions {
+		plan.Extensions = append(plan.Extensions, &extensionspb.SimpleExtensionDeclaration{
+			MappingType: &extensionspb.SimpleExtensionDeclaration_ExtensionFunction_{
+				ExtensionFunction: &extensionspb.SimpleExtensionDeclaration_ExtensionFunction{FunctionAnchor: uint32(i + 1), Name: f},
+			},
+		})
+	}
+	return plan
+}
+
+func i32Type() *substraitpb.Type {
+	return &substraitpb.Type{Kind: &substraitpb.Type_I32_{I32: &substraitpb.Type_I32{}}}
+}
+func strType() *substraitpb.Type {
+	return &substraitpb.Type{Kind: &substraitpb.Type_String_{String_: &substraitpb.Type_String{}}}
+}
+func fp64Type() *substraitpb.Type {
+	return &substraitpb.Type{Kind: &substraitpb.Type_Fp64{Fp64: &substraitpb.Type_FP64{}}}
+}
+
+// employees(id i32, name string, age i32, salary fp64, dept_id i32)
+func employeesRead() *substraitpb.Rel {
+	return readRel("employees", []string{"id", "name", "age", "salary", "dept_id"},
+		[]*substraitpb.Type{i32Type(), strType(), i32Type(), fp64Type(), i32Type()})
+}
+
+// departments(id i32, department_name string)
+func departmentsRead() *substraitpb.Rel {
+	return readRel("departments", []string{"id", "department_name"}, []*substraitpb.Type{i32Type(), strType()})
+}
+
+func readRel(table string, names []string, types []*substraitpb.Type) *substraitpb.Rel {
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Read{Read: &substraitpb.ReadRel{
+		BaseSchema: &substraitpb.NamedStruct{Names: names, Struct: &substraitpb.Type_Struct{Types: types}},
+		ReadType:   &substraitpb.ReadRel_NamedTable_{NamedTable: &substraitpb.ReadRel_NamedTable{Names: []string{table}}},
+	}}}
+}
+
+func field(i int32) *substraitpb.Expression {
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Selection{Selection: &substraitpb.Expression_FieldReference{
+		ReferenceType: &substraitpb.Expression_FieldReference_DirectReference{DirectReference: &substraitpb.Expression_ReferenceSegment{
+			ReferenceType: &substraitpb.Expression_ReferenceSegment_StructField_{StructField: &substraitpb.Expression_ReferenceSegment_StructField{Field: i}},
+		}},
+		RootType: &substraitpb.Expression_FieldReference_RootReference_{RootReference: &substraitpb.Expression_FieldReference_RootReference{}},
+	}}}
+}
+
+func i32Lit(v int32) *substraitpb.Expression {
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Literal_{Literal: &substraitpb.Expression_Literal{
+		LiteralType: &substraitpb.Expression_Literal_I32{I32: v}}}}
+}
+
+func strLit(v string) *substraitpb.Expression {
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Literal_{Literal: &substraitpb.Expression_Literal{
+		LiteralType: &substraitpb.Expression_Literal_String_{String_: v}}}}
+}
+
+func call(name string, args ...*substraitpb.Expression) *substraitpb.Expression {
+	f := &substraitpb.Expression_ScalarFunction{FunctionReference: anchor(name)}
+	for _, a := range args {
+		f.Arguments = append(f.Arguments, &substraitpb.FunctionArgument{ArgType: &substraitpb.FunctionArgument_Value{Value: a}})
+	}
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_ScalarFunction_{ScalarFunction: f}}
+}
+
+func measure(name string, arg *substraitpb.Expression) *substraitpb.AggregateRel_Measure {
+	return &substraitpb.AggregateRel_Measure{Measure: &substraitpb.AggregateFunction{
+		FunctionReference: anchor(name),
+		Arguments:         []*substraitpb.FunctionArgument{{ArgType: &substraitpb.FunctionArgument_Value{Value: arg}}},
+	}}
+}
+
+func emit(cols ...int32) *substraitpb.RelCommon {
+	return &substraitpb.RelCommon{EmitKind: &substraitpb.RelCommon_Emit_{Emit: &substraitpb.RelCommon_Emit{OutputMapping: cols}}}
+}
+
+func filterRel(input *substraitpb.Rel, cond *substraitpb.Expression) *substraitpb.Rel {
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Filter{Filter: &substraitpb.FilterRel{Input: input, Condition: cond}}}
+}
+
+func consume(t *testing.T, plan *substraitpb.Plan) logicalplan.Plan {
+	t.Helper()
+	data, err := proto.Marshal(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := NewConsumer(nil).ConsumeBytes(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return out
+}
+
+func expectPlan(t *testing.T, plan logicalplan.Plan, want string) {
+	t.Helper()
+	got := strings.TrimSpace(logicalplan.Format(plan))
+	want = strings.TrimSpace(want)
+	if got != want {
+		t.Fatalf("unexpected plan\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+// ================
+// tests
+// ================
+
+func TestConsumeRelations(t *testing.T) {
+	t.Run("read with projection and filter", func(t *testing.T) {
+		read := employeesRead()
+		read.GetRead().Filter = call("gt", field(2), i32Lit(30))
+		read.GetRead().Projection = &substraitpb.Expression_MaskExpression{Select: &substraitpb.Expression_MaskExpression_StructSelect{
+			StructItems: []*substraitpb.Expression_MaskExpression_StructItem{{Field: 1}, {Field: 2}},
+		}}
+		expectPlan(t, consume(t, testPlan(read, "name", "age")), `
+Project: name, age
+  Filter: (age > 30)
+    Scan: employees`)
+	})
+	t.Run("project with emit and root names", func(t *testing.T) {
+		proj := &substraitpb.Rel{RelType: &substraitpb.Rel_Project{Project: &substraitpb.ProjectRel{
+			Common:      emit(5, 6),
+			Input:       filterRel(employeesRead(), call("equal", field(1), strLit("Bob"))),
+			Expressions: []*substraitpb.Expression{call("upper", field(1)), call("add", field(2), i32Lit(1))},
+		}}}
+		expectPlan(t, consume(t, testPlan(proj, "upper_name", "next")), `
+Project: expr5 AS upper_name, expr6 AS next
+  Project: expr5, expr6
+    Project: id, name, age, salary, dept_id, UPPER(name) AS expr5, (CAST(age AS float64) + CAST(1 AS float64)) AS expr6
+      Filter: (name = 'Bob')
+        Scan: employees`)
+	})
+	t.Run("aggregate, sort and fetch", func(t *testing.T) {
+		agg := &substraitpb.Rel{RelType: &substraitpb.Rel_Aggregate{Aggregate: &substraitpb.AggregateRel{
+			Input:     employeesRead(),
+			Groupings: []*substraitpb.AggregateRel_Grouping{{GroupingExpressions: []*substraitpb.Expression{field(4)}}},
+			Measures:  []*substraitpb.AggregateRel_Measure{measure("sum", field(3)), measure("count", field(0))},
+		}}}
+		sorted := &substraitpb.Rel{RelType: &substraitpb.Rel_Sort{Sort: &substraitpb.SortRel{
+			Input: agg,
+			Sorts: []*substraitpb.SortField{{Expr: field(1), SortKind: &substraitpb.SortField_Direction{Direction: substraitpb.SortField_SORT_DIRECTION_DESC_NULLS_LAST}}},
+		}}}
+		fetch := &substraitpb.Rel{RelType: &substraitpb.Rel_Fetch{Fetch: &substraitpb.FetchRel{Input: sorted, Count: 2}}}
+		expectPlan(t, consume(t, testPlan(fetch, "dept_id", "total", "n")), `
+Project: group_Column(dept_id) AS dept_id, sum_Column(salary) AS total, count_Column(id) AS n
+  Limit: 2
+    Sort: sum_Column(salary) DESC NULLS LAST
+      Aggregate: groupBy=[dept_id] aggr=[SUM(salary), COUNT(id)]
+        Scan: employees`)
+	})
+	t.Run("join keys and residual condition", func(t *testing.T) {
+		j := &substraitpb.Rel{RelType: &substraitpb.Rel_Join{Join: &substraitpb.JoinRel{
+			Left:       employeesRead(),
+			Right:      departmentsRead(),
+			Type:       substraitpb.JoinRel_JOIN_TYPE_INNER,
+			Expression: call("and", call("equal", field(5), field(4)), call("gt", field(2), i32Lit(30))),
+		}}}
+		expectPlan(t, consume(t, testPlan(j)), `
+Filter: (age > 30)
+  Join: INNER JOIN ON dept_id = id
+    Scan: employees
+    Scan: departments`)
+	})
+}
+
+func TestConsumeErrors(t *testing.T) {
+	unsupportedPlans := map[string]*substraitpb.Rel{
+		"set relation":     {RelType: &substraitpb.Rel_Set{Set: &substraitpb.SetRel{}}},
+		"unknown function": filterRel(employeesRead(), call("regexp_match", field(1), strLit("a.*"))),
+		"outer join": {RelType: &substraitpb.Rel_Join{Join: &substraitpb.JoinRel{
+			Left: employeesRead(), Right: departmentsRead(), Type: substraitpb.JoinRel_JOIN_TYPE_OUTER,
+			Expression: call("equal", field(4), field(5)),
+		}}},
+		"join without equality": {RelType: &substraitpb.Rel_Join{Join: &substraitpb.JoinRel{
+			Left: employeesRead(), Right: departmentsRead(), Type: substraitpb.JoinRel_JOIN_TYPE_INNER,
+			Expression: call("lt", field(4), field(5)),
+		}}},
+		"fetch offset": {RelType: &substraitpb.Rel_Fetch{Fetch: &substraitpb.FetchRel{Input: employeesRead(), Offset: 2, Count: 1}}},
+		"sum without arguments": {RelType: &substraitpb.Rel_Aggregate{Aggregate: &substraitpb.AggregateRel{
+			Input: employeesRead(),
+			Measures: []*substraitpb.AggregateRel_Measure{{Measure: &substraitpb.AggregateFunction{
+				FunctionReference: anchor("sum"),
+			}}},
+		}}},
+		"date column": readRel("events", []string{"day"}, []*substraitpb.Type{{Kind: &substraitpb.Type_Date_{Date: &substraitpb.Type_Date{}}}}),
+		"local files": {RelType: &substraitpb.Rel_Read{Read: &substraitpb.ReadRel{
+			ReadType: &substraitpb.ReadRel_LocalFiles_{LocalFiles: &substraitpb.ReadRel_LocalFiles{}},
+		}}},
+	}
+	for name, rel := range unsupportedPlans {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewConsumer(nil).Consume(testPlan(rel))
+			if !IsUnsupported(err) {
+				t.Fatalf("expected an UnsupportedError, got %v", err)
+			}
+		})
+	}
+
+	invalid := map[string][]byte{
+		"garbage bytes": []byte("CgJTUxIMCgpTZWxlY3QgKiBGUk9NIHRhYmxl"),
+		"no relations":  nil,
+	}
+	if data, err := proto.Marshal(testPlan(filterRel(employeesRead(), call("gt", field(9), i32Lit(1))))); err == nil {
+		invalid["field out of range"] = data
+	}
+	if data, err := proto.Marshal(&substraitpb.Plan{Relations: []*substraitpb.PlanRel{{RelType: &substraitpb.PlanRel_Rel{
+		Rel: filterRel(employeesRead(), call("gt", field(2), i32Lit(1))),
+	}}}}); err == nil {
+		invalid["undeclared function"] = data
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewConsumer(nil).ConsumeBytes(data)
+			if err == nil || IsUnsupported(err) {
+				t.Fatalf("expected an invalid plan error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("catalog fills in a missing base schema", func(t *testing.T) {
+		read := employeesRead()
+		read.GetRead().BaseSchema = nil
+		catalog := logicalplan.MapCatalog{"employees": arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int32}}, nil)}
+		if _, err := NewConsumer(nil).Consume(testPlan(read)); err == nil {
+			t.Fatalf("expected an error without base_schema or catalog")
+		}
+		plan, err := NewConsumer(catalog).Consume(testPlan(read))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectPlan(t, plan, "Scan: employees")
+	})
+}
+
+// memorySources hands out a fresh in memory source every time a table is opened
+type memorySources map[string]func() (*project.InMemorySource, error)
+
+func (m memorySources) TableSchema(name string) (*arrow.Schema, error) {
+	open, ok := m[name]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(name)
+	}
+	src, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return src.Schema(), nil
+}
+
+func (m memorySources) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	open, ok := m[scan.Table]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(scan.Table)
+	}
+	return open()
+}
+
+func testSources() memorySources {
+	return memorySources{
+		"employees": func() (*project.InMemorySource, error) {
+			return project.NewInMemoryProjectExec(
+				[]string{"id", "name", "age", "salary", "dept_id"},
+				[]any{
+					[]int32{1, 2, 3, 4},
+					[]string{"Alice", "Bob", "Carol", "Dan"},
+					[]int32{34, 28, 45, 23},
+					[]float64{70000, 50000, 90000, 40000},
+					[]int32{1, 2, 1, 3},
+				})
+		},
+		"departments": func() (*project.InMemorySource, error) {
+			return project.NewInMemoryProjectExec(
+				[]string{"id", "department_name"},
+				[]any{[]int32{1, 2, 3}, []string{"Engineering", "Sales", "Support"}})
+		},
+		// contacts(id i32, email string), every other email is null
+		"contacts": func() (*project.InMemorySource, error) {
+			mem := memory.NewGoAllocator()
+			ids := array.NewInt32Builder(mem)
+			defer ids.Release()
+			ids.AppendValues([]int32{1, 2, 3, 4}, nil)
+			emails := array.NewStringBuilder(mem)
+			defer emails.Release()
+			emails.AppendValues([]string{"alice@example.com", "", "carol@example.com", ""}, []bool{true, false, true, false})
+			return project.NewInMemoryProjectExecFromArrays([]string{"id", "email"}, []arrow.Array{ids.NewArray(), emails.NewArray()})
+		},
+	}
+}
+
+// collectRows drains op and returns every row as strings
+func collectRows(t *testing.T, op operators.Operator) [][]string {
+	t.Helper()
+	var rows [][]string
+	for {
+		batch, err := op.Next(context.Background(), 10)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for r := 0; r < int(batch.RowCount); r++ {
+			row := make([]string, len(batch.Columns))
+			for c, col := range batch.Columns {
+				row[c] = col.ValueStr(r)
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := op.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	return rows
+}
+
+func TestConsumedPlanExecutes(t *testing.T) {
+	// SELECT e.name, d.department_name FROM employees e JOIN departments d ON e.dept_id = d.id
+	// WHERE e.age > 30 ORDER BY e.name ASC
+	j := &substraitpb.Rel{RelType: &substraitpb.Rel_Join{Join: &substraitpb.JoinRel{
+		Common:     emit(1, 6),
+		Left:       filterRel(employeesRead(), call("gt", field(2), i32Lit(30))),
+		Right:      departmentsRead(),
+		Type:       substraitpb.JoinRel_JOIN_TYPE_INNER,
+		Expression: call("equal", field(4), field(5)),
+	}}}
+	sorted := &substraitpb.Rel{RelType: &substraitpb.Rel_Sort{Sort: &substraitpb.SortRel{
+		Input: j,
+		Sorts: []*substraitpb.SortField{{Expr: field(0), SortKind: &substraitpb.SortField_Direction{Direction: substraitpb.SortField_SORT_DIRECTION_ASC_NULLS_LAST}}},
+	}}}
+	plan := consume(t, testPlan(sorted, "name", "department"))
+
+	op, err := physicaloptimizer.NewPlanner(testSources()).CreatePhysicalPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := collectRows(t, op)
+	want := [][]string{{"Alice", "Engineering"}, {"Carol", "Engineering"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("expected %v, got %v", want, rows)
+	}
+	if got := op.Schema().Field(1).Name; got != "department" {
+		t.Fatalf("expected the root names on the output, got %s", got)
+	}
+}
+
+func TestConsumedNotAndIsNullExecute(t *testing.T) {
+	contactsRead := readRel("contacts", []string{"id", "email"}, []*substraitpb.Type{i32Type(), strType()})
+	cases := []struct {
+		name string
+		root *substraitpb.Rel
+		want []string // the first column of every row
+	}{
+		{"not", filterRel(employeesRead(), call("not", call("gt", field(2), i32Lit(30)))), []string{"2", "4"}},
+		{"is null", filterRel(contactsRead, call("is_null", field(1))), []string{"2", "4"}},
+		{"is not null", filterRel(contactsRead, call("is_not_null", field(1))), []string{"1", "3"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			plan := consume(t, testPlan(c.root))
+			op, err := physicaloptimizer.NewPlanner(testSources()).CreatePhysicalPlan(plan)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, row := range collectRows(t, op) {
+				got = append(got, row[0])
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("expected ids %v, got %v", c.want, got)
+			}
+		})
+	}
+}
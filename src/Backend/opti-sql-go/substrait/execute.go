@@ -0,0 +1,242 @@
+package substrait
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"opti-sql-go/config"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/operators"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+)
+
+// a request runs in stages: open the source, decode the plan (substrait, or the sql statement
+// when no plan was sent), check the plan against the source, plan and run it, then either write the
+// result as parquet and upload it (ExecuteQuery) or send it back batch by batch (ExecuteQueryStream). every stage tags its failure with the ReturnTypes the client gets back.
+// running the plan is bounded by the request's context, which ends when the client goes away or
+// server.timeout passes (see queryContext)
+
+const resultContentType = "application/vnd.apache.parquet"
+
+var (
+	ErrUnknownMime = func(mime string) error {
+		return fmt.Errorf("unknown source mime type %q, expected csv or parquet", mime)
+	}
+	ErrSourceMismatch = func(column string, want, got arrow.DataType) error {
+		return fmt.Errorf("plan reads column %s as %s but the source has %s", column, want, got)
+	}
+	ErrNoQuery = errors.New("request has neither a substrait plan nor a sql statement")
+)
+
+// QueryError is a failed request together with the ReturnTypes it is reported as
+type QueryError struct {
+	Type ReturnTypes
+	Err  error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+func (e *QueryError) Unwrap() error { return e.Err }
+
+func queryErr(t ReturnTypes, err error) error {
+	return &QueryError{Type: t, Err: err}
+}
+
+// execute runs req against store and returns the link to the uploaded result. when the request asked
+// for analyze the operator tree with its stats comes back as well, even if execution failed
+func execute(ctx context.Context, store ObjectStore, cache *ResultCache, req *QueryExecutionRequest) (string, string, error) {
+	op, err := planRequest(store, cache, req)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := writeParquet(ctx, op)
+	if err != nil {
+		return "", explainAnalyze(op), execErr(err)
+	}
+
+	link, err := store.Put(resultKey(req.GetId()), data, resultContentType)
+	if err != nil {
+		return "", explainAnalyze(op), queryErr(ReturnTypes_UPLOAD_ERROR, err)
+	}
+	return link, explainAnalyze(op), nil
+}
+
+// planRequest runs every stage up to (not including) execution and returns the physical plan
+func planRequest(store ObjectStore, cache *ResultCache, req *QueryExecutionRequest) (operators.Operator, error) {
+	src, err := newRequestSource(store, req.GetSource())
+	if err != nil {
+		return nil, queryErr(ReturnTypes_SOURCE_ERROR, err)
+	}
+	return planQuery(src, cache, req.GetSubstraitLogical(), req.GetSqlStatement(), req.GetAnalyze())
+}
+
+// planQuery plans a substrait plan, or sql when there is no plan, against sources. with a cache the
+// result is replayed from it when the same plan already ran over the same objects.
+// with analyze every operator is wrapped to collect its stats, see explainAnalyze. such a plan has
+// to actually run so the cache is skipped
+func planQuery(sources tableSource, cache *ResultCache, substraitPlan []byte, sql string, analyze bool) (operators.Operator, error) {
+	planner := physicaloptimizer.NewPlanner(sources)
+
+	var plan logicalplan.Plan
+	var err error
+	switch {
+	case len(substraitPlan) > 0:
+		plan, err = NewConsumer(sources).ConsumeBytes(substraitPlan)
+	case sql != "":
+		plan, err = planner.LogicalPlan(sql)
+	default:
+		err = ErrNoQuery
+	}
+	if err != nil {
+		return nil, queryErr(planErrorType(err), err)
+	}
+	if err := checkScans(sources, plan); err != nil {
+		return nil, queryErr(ReturnTypes_TYPE_ERROR, err)
+	}
+
+	optimized, err := physicaloptimizer.NewOptimizer().Optimize(plan)
+	if err != nil {
+		return nil, queryErr(ReturnTypes_EXECUTION_ERROR, err)
+	}
+	if analyze {
+		op, err := planner.CreateAnalyzedPlan(optimized)
+		if err != nil {
+			return nil, physicalPlanErr(err)
+		}
+		return op, nil
+	}
+	if cache == nil {
+		op, err := planner.CreatePhysicalPlan(optimized)
+		if err != nil {
+			return nil, physicalPlanErr(err)
+		}
+		return op, nil
+	}
+
+	key, version, err := cacheKey(sources, optimized)
+	if err != nil {
+		return nil, queryErr(ReturnTypes_SOURCE_ERROR, err)
+	}
+	if data, ok := cache.get(key, version); ok {
+		op, err := newCachedResult(data)
+		if err != nil {
+			return nil, queryErr(ReturnTypes_EXECUTION_ERROR, err)
+		}
+		return op, nil
+	}
+	op, err := planner.CreatePhysicalPlan(optimized)
+	if err != nil {
+		return nil, physicalPlanErr(err)
+	}
+	return newRecordingResult(op, cache, key, version), nil
+}
+
+// explainAnalyze renders the stats of a plan built with analyze once it ran, "" for any other plan
+func explainAnalyze(op operators.Operator) string {
+	if analyzed, ok := op.(*operators.AnalyzedExec); ok {
+		return analyzed.String()
+	}
+	return ""
+}
+
+// planErrorType classifies a failure to turn the request into a logical plan
+func planErrorType(err error) ReturnTypes {
+	switch {
+	case IsUnsupported(err), errors.Is(err, physicaloptimizer.ErrUnsupported):
+		return ReturnTypes_UNSUPPORTED
+	case errors.Is(err, physicaloptimizer.ErrTypeMismatch):
+		return ReturnTypes_TYPE_ERROR
+	case errors.Is(err, physicaloptimizer.ErrUnknownTable):
+		// the catalog couldn't find or read the table
+		return ReturnTypes_SOURCE_ERROR
+	}
+	return ReturnTypes_PARSE_ERROR
+}
+
+// physicalPlanErr tags a failure to build the operators of a plan, plans the planner can't run yet
+// (outer joins) are unsupported like the queries the binder rejects
+func physicalPlanErr(err error) error {
+	if errors.Is(err, physicaloptimizer.ErrUnsupported) {
+		return queryErr(ReturnTypes_UNSUPPORTED, err)
+	}
+	return queryErr(ReturnTypes_EXECUTION_ERROR, err)
+}
+
+// execErr tags a failure while running the plan, a query that was cancelled, ran out of time or
+// went over its memory limit is reported as such
+func execErr(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return queryErr(ReturnTypes_CANCELLED, err)
+	}
+	if errors.Is(err, operators.ErrOutOfMemory) {
+		return queryErr(ReturnTypes_OUT_OF_MEMORY, err)
+	}
+	return queryErr(ReturnTypes_EXECUTION_ERROR, err)
+}
+
+// queryContext bounds a query by server.timeout, it is cancelled as well when parent is (the client went away).
+// the query's operators allocate through a fresh QueryMemory carried by the returned ctx. it is
+// created once the query was admitted, time spent queued is bounded by query.queue_timeout_seconds
+func queryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx := operators.WithQueryMemory(parent, newQueryMemory())
+	if timeout := config.GetConfig().Server.Timeout; timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+// newQueryMemory tracks a query against query.max_memory_mb, blocking operators are told to spill
+// past batch.max_memory_before_spill
+func newQueryMemory() *operators.QueryMemory {
+	c := config.GetConfig()
+	limit := int64(c.Query.MaxMemoryMB) << 20
+	return operators.NewQueryMemory(memory.DefaultAllocator, limit, int64(c.Batch.MaxMemoryBeforeSpill))
+}
+
+func resultKey(id string) string {
+	if id == "" {
+		id = "query"
+	}
+	return fmt.Sprintf("results/%s-%d.parquet", id, time.Now().UnixNano())
+}
+
+// writeParquet drains op into an in memory parquet file and closes it
+func writeParquet(ctx context.Context, op operators.Operator) ([]byte, error) {
+	defer func() { _ = op.Close() }()
+	var buf bytes.Buffer
+	w, err := pqarrow.NewFileWriter(op.Schema(), &buf, parquet.NewWriterProperties(), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+	batchSize := uint16(config.GetConfig().Batch.Size)
+	for {
+		batch, err := operators.NextBatch(ctx, op, batchSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := array.NewRecord(op.Schema(), batch.Columns, int64(batch.RowCount))
+		err = w.Write(rec)
+		rec.Release()
+		operators.ReleaseArrays(batch.Columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,419 @@
+package substrait
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/project"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/minio/minio-go"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeS3 is a local stand-in for an s3 compatible service, just enough of the api for minio-go:
+// HEAD (stat), ranged GET, PUT and ListObjectsV2. objects are keyed by bucket/key
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, strings.TrimSuffix(name, "/"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f.mu.Lock()
+		data, ok := f.objects[name]
+		f.mu.Unlock()
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			}
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		http.ServeContent(w, r, name, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(data))
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// minio signs PUTs over plain http with the chunked streaming signature
+		if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+			if body, err = decodeChunked(body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		f.mu.Lock()
+		f.objects[name] = body
+		f.mu.Unlock()
+		w.Header().Set("ETag", etag(body))
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// etag is the md5 of the object like s3 does for single part uploads
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+// put overwrites an object the way another client would
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects["data/"+key] = data
+}
+
+// list answers a ListObjectsV2 for the top level of bucket, deeper keys are grouped into common prefixes
+func (f *fakeS3) list(w http.ResponseWriter, bucket string) {
+	f.mu.Lock()
+	var keys []string
+	prefixes := make(map[string]bool)
+	for name := range f.objects {
+		key, ok := strings.CutPrefix(name, bucket+"/")
+		if !ok {
+			continue
+		}
+		if dir, _, nested := strings.Cut(key, "/"); nested {
+			prefixes[dir+"/"] = true
+			continue
+		}
+		keys = append(keys, key)
+	}
+	f.mu.Unlock()
+	sort.Strings(keys)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "<ListBucketResult><Name>%s</Name><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><Delimiter>/</Delimiter><IsTruncated>false</IsTruncated>", bucket, len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(&out, "<Contents><Key>%s</Key><LastModified>2024-01-01T00:00:00.000Z</LastModified><ETag>\"fake\"</ETag><Size>1</Size></Contents>", key)
+	}
+	for p := range prefixes {
+		fmt.Fprintf(&out, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", p)
+	}
+	out.WriteString("</ListBucketResult>")
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, out.String())
+}
+
+// decodeChunked strips the aws-chunked framing: <hex size>;chunk-signature=...\r\n<data>\r\n
+func decodeChunked(body []byte) ([]byte, error) {
+	var out []byte
+	r := bufio.NewReader(bytes.NewReader(body))
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk[:size]...)
+	}
+}
+
+// newFakeStore starts a fakeS3 holding objects (keys inside bucket "data") and returns a store using it
+func newFakeStore(t *testing.T, objects map[string][]byte) (*S3Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	for k, v := range objects {
+		fake.objects["data/"+k] = v
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// with a region minio skips the bucket location lookup
+	client, err := minio.NewWithRegion(u.Host, "access", "secret", false, "us-east-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return NewS3Store(client, "data"), fake
+}
+
+const employeesCSV = `id,name,age,salary,dept_id
+1,Alice,34,70000.5,1
+2,Bob,28,50000.5,2
+3,Carol,45,90000.5,1
+4,Dan,23,40000.5,3
+`
+
+// employeesParquet writes the in memory employees table, it keeps the int32 columns the test plans use
+func employeesParquet(t *testing.T) []byte {
+	t.Helper()
+	src, err := testSources()["employees"]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := writeParquet(context.Background(), src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return data
+}
+
+func TestWriteParquetReleasesBatches(t *testing.T) {
+	planner := physicaloptimizer.NewPlanner(testSources())
+	plan, err := planner.LogicalPlan("SELECT name, age + 1 AS next FROM employees WHERE age > 25")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	op, err := planner.CreatePhysicalPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := operators.NewQueryMemory(memory.DefaultAllocator, 0, 0)
+	if _, err := writeParquet(operators.WithQueryMemory(context.Background(), q), op); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Peak() == 0 || q.InUse() != 0 {
+		t.Fatalf("expected every batch to be released once written, %d of %d bytes still in use", q.InUse(), q.Peak())
+	}
+}
+
+// readResult downloads a result link and returns its rows
+func readResult(t *testing.T, store *S3Store, link string) [][]string {
+	t.Helper()
+	r, err := store.Open(link)
+	if err != nil {
+		t.Fatalf("failed to open result %s: %v", link, err)
+	}
+	src, err := project.NewParquetSource(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return collectRows(t, src)
+}
+
+func TestExecute(t *testing.T) {
+	store, fake := newFakeStore(t, map[string][]byte{
+		"employees.csv":     []byte(employeesCSV),
+		"employees.parquet": employeesParquet(t),
+		"broken.parquet":    []byte("not a parquet file"),
+	})
+	// SELECT name FROM employees WHERE age > 30
+	adults := &substraitpb.Rel{RelType: &substraitpb.Rel_Project{Project: &substraitpb.ProjectRel{
+		Common: emit(1),
+		Input:  filterRel(employeesRead(), call("gt", field(2), i32Lit(30))),
+	}}}
+	adultsPlan, err := proto.Marshal(testPlan(adults))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("substrait plan over parquet", func(t *testing.T) {
+		link, _, err := execute(context.Background(), store, nil, &QueryExecutionRequest{
+			Id:               "q1",
+			SubstraitLogical: adultsPlan,
+			Source:           &SourceType{S3Source: "s3://data/employees.parquet", Mime: "application/vnd.apache.parquet"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(link, "s3://data/results/q1-") {
+			t.Fatalf("unexpected result link %s", link)
+		}
+		if got, want := readResult(t, store, link), [][]string{{"Alice"}, {"Carol"}}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+	t.Run("sql over csv", func(t *testing.T) {
+		link, _, err := execute(context.Background(), store, nil, &QueryExecutionRequest{
+			SqlStatement: "SELECT dept_id, COUNT(id) AS n FROM employees GROUP BY dept_id ORDER BY dept_id",
+			Source:       &SourceType{S3Source: "employees.csv", Mime: "text/csv"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := [][]string{{"1", "2"}, {"2", "1"}, {"3", "1"}}
+		if got := readResult(t, store, link); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+	t.Run("result is a parquet object", func(t *testing.T) {
+		link, _, err := execute(context.Background(), store, nil, &QueryExecutionRequest{
+			Id:           "q3",
+			SqlStatement: "SELECT name, salary FROM employees",
+			Source:       &SourceType{S3Source: "employees.csv"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		fake.mu.Lock()
+		data := fake.objects[strings.TrimPrefix(link, "s3://")]
+		fake.mu.Unlock()
+		rdr, err := file.NewParquetReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("uploaded result is not parquet: %v", err)
+		}
+		fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		schema, err := fr.Schema()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schema.NumFields() != 2 || schema.Field(0).Name != "name" || schema.Field(1).Name != "salary" {
+			t.Fatalf("unexpected result schema %v", schema)
+		}
+	})
+
+	t.Run("cancelled and timed out queries", func(t *testing.T) {
+		req := &QueryExecutionRequest{SqlStatement: "SELECT name FROM employees ORDER BY name", Source: &SourceType{S3Source: "employees.csv"}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := execute(ctx, store, nil, req)
+		if got := errorDetails(err).ErrorType; got != ReturnTypes_CANCELLED {
+			t.Fatalf("expected %v, got %v (%v)", ReturnTypes_CANCELLED, got, err)
+		}
+		if code := status.Code(flightError(err)); code != codes.Canceled {
+			t.Fatalf("expected %v, got %v", codes.Canceled, code)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		_, _, err = execute(ctx, store, nil, req)
+		if got := errorDetails(err).ErrorType; got != ReturnTypes_CANCELLED {
+			t.Fatalf("expected %v, got %v (%v)", ReturnTypes_CANCELLED, got, err)
+		}
+		if code := status.Code(flightError(err)); code != codes.DeadlineExceeded {
+			t.Fatalf("expected %v, got %v", codes.DeadlineExceeded, code)
+		}
+	})
+
+	t.Run("queries over their memory limit", func(t *testing.T) {
+		req := &QueryExecutionRequest{SqlStatement: "SELECT name FROM employees ORDER BY name", Source: &SourceType{S3Source: "employees.csv"}}
+		q := operators.NewQueryMemory(memory.NewGoAllocator(), 64, 0)
+		_, _, err := execute(operators.WithQueryMemory(context.Background(), q), store, nil, req)
+		if got := errorDetails(err).ErrorType; got != ReturnTypes_OUT_OF_MEMORY {
+			t.Fatalf("expected %v, got %v (%v)", ReturnTypes_OUT_OF_MEMORY, got, err)
+		}
+		if !strings.Contains(err.Error(), "asked for") {
+			t.Fatalf("expected the error to say who ran out, got %v", err)
+		}
+
+		// the same query fits in the default limit
+		ctx, cancel := queryContext(context.Background())
+		defer cancel()
+		if _, _, err := execute(ctx, store, nil, req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if q := operators.QueryMemoryFrom(ctx); q == nil || q.Peak() == 0 {
+			t.Fatalf("expected the query's memory to be tracked")
+		}
+	})
+
+	t.Run("explain analyze", func(t *testing.T) {
+		cache := NewResultCache(0, 1<<20)
+		req := &QueryExecutionRequest{
+			SqlStatement: "SELECT dept_id, COUNT(id) AS n FROM employees GROUP BY dept_id ORDER BY dept_id",
+			Source:       &SourceType{S3Source: "employees.csv"},
+			Analyze:      true,
+		}
+		for i := 0; i < 2; i++ {
+			link, analysis, err := execute(context.Background(), store, cache, req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := readResult(t, store, link); len(got) != 3 {
+				t.Fatalf("expected the usual result, got %v", got)
+			}
+			if !strings.Contains(analysis, "GroupByExec: groupBy=[dept_id] aggr=[COUNT(id)] [rows_in=4 rows_out=3 ") {
+				t.Fatalf("unexpected analysis\n%s", analysis)
+			}
+		}
+		// analyzed queries have to run, they neither read nor fill the cache
+		if cache.Len() != 0 || cache.hits != 0 {
+			t.Fatalf("expected the cache to be left alone, %d entries and %d hits", cache.Len(), cache.hits)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, analysis, err := execute(ctx, store, nil, req)
+		if errorDetails(err).ErrorType != ReturnTypes_CANCELLED || !strings.HasPrefix(analysis, "SortExec") {
+			t.Fatalf("expected the stats of the cancelled query, got %v\n%s", err, analysis)
+		}
+	})
+
+	failures := map[string]struct {
+		req  *QueryExecutionRequest
+		want ReturnTypes
+	}{
+		"missing source": {&QueryExecutionRequest{SubstraitLogical: adultsPlan,
+			Source: &SourceType{S3Source: "s3://data/nope.parquet", Mime: "application/vnd.apache.parquet"}}, ReturnTypes_SOURCE_ERROR},
+		"no source": {&QueryExecutionRequest{SubstraitLogical: adultsPlan}, ReturnTypes_SOURCE_ERROR},
+		"unknown mime": {&QueryExecutionRequest{SubstraitLogical: adultsPlan,
+			Source: &SourceType{S3Source: "employees.csv", Mime: "application/json"}}, ReturnTypes_SOURCE_ERROR},
+		"unreadable source": {&QueryExecutionRequest{SubstraitLogical: adultsPlan,
+			Source: &SourceType{S3Source: "broken.parquet"}}, ReturnTypes_SOURCE_ERROR},
+		"garbage plan": {&QueryExecutionRequest{SubstraitLogical: []byte("CgJTUxIMCgpTZWxlY3QgKiBGUk9NIHRhYmxl"),
+			Source: &SourceType{S3Source: "employees.csv"}}, ReturnTypes_PARSE_ERROR},
+		"sql syntax error": {&QueryExecutionRequest{SqlStatement: "SELECT FROM",
+			Source: &SourceType{S3Source: "employees.csv"}}, ReturnTypes_PARSE_ERROR},
+		// the plan reads id and age as i32, the csv leaf infers int64
+		"plan types differ from the source": {&QueryExecutionRequest{SubstraitLogical: adultsPlan,
+			Source: &SourceType{S3Source: "employees.csv", Mime: "text/csv"}}, ReturnTypes_TYPE_ERROR},
+		"sql type error": {&QueryExecutionRequest{SqlStatement: "SELECT name FROM employees WHERE name > 3",
+			Source: &SourceType{S3Source: "employees.csv"}}, ReturnTypes_TYPE_ERROR},
+		"outer join": {&QueryExecutionRequest{SqlStatement: "SELECT e.id FROM employees e LEFT JOIN employees m ON e.id = m.id",
+			Source: &SourceType{S3Source: "employees.csv"}}, ReturnTypes_UNSUPPORTED},
+		"unsupported plan": {&QueryExecutionRequest{
+			SubstraitLogical: func() []byte {
+				data, _ := proto.Marshal(testPlan(&substraitpb.Rel{RelType: &substraitpb.Rel_Set{Set: &substraitpb.SetRel{}}}))
+				return data
+			}(),
+			Source: &SourceType{S3Source: "employees.parquet"}}, ReturnTypes_UNSUPPORTED},
+	}
+	for name, c := range failures {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := execute(context.Background(), store, nil, c.req)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got := errorDetails(err).ErrorType; got != c.want {
+				t.Fatalf("expected %v, got %v (%v)", c.want, got, err)
+			}
+		})
+	}
+}
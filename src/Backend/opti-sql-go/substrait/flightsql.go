@@ -0,0 +1,263 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"io"
+	"opti-sql-go/Expr"
+	"opti-sql-go/config"
+	"opti-sql-go/metrics"
+	"opti-sql-go/operators"
+	"slices"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/flight"
+	"github.com/apache/arrow/go/v17/arrow/flight/flightsql"
+	"github.com/apache/arrow/go/v17/arrow/flight/flightsql/schema_ref"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the flight sql service lets ADBC/JDBC drivers and pyarrow query the engine without going through
+// contract.SSOperation. flight sql has no notion of a per request source, tables are the csv and
+// parquet objects at the top of the object store's bucket (see bucketCatalog). statements go through
+// planQuery exactly like ExecuteQuery, only the result travels back over DoGet instead of s3
+
+const flightTableType = "TABLE"
+
+var _ = (flightsql.Server)(&FlightSQLServer{})
+
+type FlightSQLServer struct {
+	flightsql.BaseServer
+	store     ObjectStore
+	admission *AdmissionController
+	cache     *ResultCache
+}
+
+func NewFlightSQLServer(store ObjectStore, admission *AdmissionController, cache *ResultCache) (*FlightSQLServer, error) {
+	s := &FlightSQLServer{store: store, admission: admission, cache: cache}
+	s.Alloc = memory.DefaultAllocator
+	info := map[flightsql.SqlInfo]any{
+		flightsql.SqlInfoFlightSqlServerName:         "opti-sql",
+		flightsql.SqlInfoFlightSqlServerVersion:      "0.1.0",
+		flightsql.SqlInfoFlightSqlServerArrowVersion: "17.0.0",
+		flightsql.SqlInfoFlightSqlServerReadOnly:     true,
+		flightsql.SqlInfoFlightSqlServerSql:          true,
+		flightsql.SqlInfoFlightSqlServerSubstrait:    false,
+		flightsql.SqlInfoFlightSqlServerTransaction:  int32(flightsql.SqlTransactionNone),
+		flightsql.SqlInfoFlightSqlServerCancel:       false,
+	}
+	for id, v := range info {
+		if err := s.RegisterSqlInfo(id, v); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// plan plans a statement against the bucket's tables
+func (s *FlightSQLServer) plan(query string) (operators.Operator, error) {
+	catalog, err := newBucketCatalog(s.store)
+	if err != nil {
+		return nil, queryErr(ReturnTypes_SOURCE_ERROR, err)
+	}
+	return planQuery(catalog, s.cache, nil, query, false)
+}
+
+// GetFlightInfoStatement plans the query to validate it and learn the result schema, DoGetStatement
+// plans it again from the ticket, which is just the query text
+func (s *FlightSQLServer) GetFlightInfoStatement(_ context.Context, cmd flightsql.StatementQuery, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	op, err := s.plan(cmd.GetQuery())
+	if err != nil {
+		return nil, flightError(err)
+	}
+	schema := op.Schema()
+	_ = op.Close()
+
+	ticket, err := flightsql.CreateStatementQueryTicket([]byte(cmd.GetQuery()))
+	if err != nil {
+		return nil, err
+	}
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: ticket}}},
+		FlightDescriptor: desc,
+		Schema:           flight.SerializeSchema(schema, s.Alloc),
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}, nil
+}
+
+// DoGetStatement is where the statement runs, so only it goes through admission. the slot is held
+// until the last batch was handed to the client
+func (s *FlightSQLServer) DoGetStatement(ctx context.Context, ticket flightsql.StatementQueryTicket) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	received := time.Now()
+	metrics.QueryStarted(apiFlightSQL)
+	release, err := s.admission.Admit(ctx)
+	if err != nil {
+		err = admissionErr(err)
+		recordQuery(apiFlightSQL, received, errorDetails(err))
+		return nil, nil, flightError(err)
+	}
+	ctx, cancel := queryContext(ctx)
+	op, err := s.plan(string(ticket.GetStatementHandle()))
+	if err != nil {
+		release()
+		cancel()
+		recordQuery(apiFlightSQL, received, errorDetails(err))
+		return nil, nil, flightError(err)
+	}
+	// unbuffered, the next batch is only pulled once the previous one was written to the client
+	ch := make(chan flight.StreamChunk)
+	go func() {
+		result := &ErrorDetails{ErrorType: ReturnTypes_SUCCESS}
+		defer func() { recordQuery(apiFlightSQL, received, result) }()
+		defer close(ch)
+		defer cancel()
+		defer release()
+		defer func() { _ = op.Close() }()
+		batchSize := uint16(config.GetConfig().Batch.Size)
+		for {
+			batch, err := operators.NextBatch(ctx, op, batchSize)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			chunk := flight.StreamChunk{Err: err}
+			if err == nil {
+				// the record holds its own reference, the flight server releases it once it was written
+				chunk.Data = array.NewRecord(op.Schema(), batch.Columns, int64(batch.RowCount))
+				operators.ReleaseArrays(batch.Columns)
+			} else {
+				err = execErr(err)
+				chunk.Err = flightError(err)
+				result = errorDetails(err)
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				if chunk.Data != nil {
+					chunk.Data.Release()
+				}
+				result = errorDetails(execErr(ctx.Err()))
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return op.Schema(), ch, nil
+}
+
+func (s *FlightSQLServer) GetFlightInfoTables(_ context.Context, cmd flightsql.GetTables, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	schema := schema_ref.Tables
+	if cmd.GetIncludeSchema() {
+		schema = schema_ref.TablesWithIncludedSchema
+	}
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: desc.Cmd}}},
+		FlightDescriptor: desc,
+		Schema:           flight.SerializeSchema(schema, s.Alloc),
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}, nil
+}
+
+// DoGetTables lists the bucket's tables. there are no catalogs or db schemas so both columns are
+// null and their filters are ignored
+func (s *FlightSQLServer) DoGetTables(ctx context.Context, cmd flightsql.GetTables) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	catalog, err := newBucketCatalog(s.store)
+	if err != nil {
+		return nil, nil, flightError(queryErr(ReturnTypes_SOURCE_ERROR, err))
+	}
+	names := catalog.names()
+	if pattern := cmd.GetTableNameFilterPattern(); pattern != nil {
+		if names, err = matchLike(ctx, names, *pattern); err != nil {
+			return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if types := cmd.GetTableTypes(); len(types) > 0 && !slices.Contains(types, flightTableType) {
+		names = nil
+	}
+
+	schema := schema_ref.Tables
+	if cmd.GetIncludeSchema() {
+		schema = schema_ref.TablesWithIncludedSchema
+	}
+	b := array.NewRecordBuilder(s.Alloc, schema)
+	defer b.Release()
+	for _, name := range names {
+		b.Field(0).AppendNull()
+		b.Field(1).AppendNull()
+		b.Field(2).(*array.StringBuilder).Append(name)
+		b.Field(3).(*array.StringBuilder).Append(flightTableType)
+		if cmd.GetIncludeSchema() {
+			t, err := catalog.table(name)
+			if err != nil {
+				return nil, nil, flightError(queryErr(ReturnTypes_SOURCE_ERROR, err))
+			}
+			b.Field(4).(*array.BinaryBuilder).Append(flight.SerializeSchema(t.schema, s.Alloc))
+		}
+	}
+	ch := make(chan flight.StreamChunk, 1)
+	ch <- flight.StreamChunk{Data: b.NewRecord()}
+	close(ch)
+	return schema, ch, nil
+}
+
+// matchLike keeps the names matching a sql LIKE pattern, evaluated with the same kernel as WHERE x LIKE p
+func matchLike(ctx context.Context, names []string, pattern string) ([]string, error) {
+	if len(names) == 0 {
+		return names, nil
+	}
+	sb := array.NewStringBuilder(memory.NewGoAllocator())
+	defer sb.Release()
+	sb.AppendValues(names, nil)
+	col := sb.NewArray()
+	defer col.Release()
+	batch := &operators.RecordBatch{
+		Schema:   arrow.NewSchema([]arrow.Field{{Name: "table_name", Type: arrow.BinaryTypes.String}}, nil),
+		Columns:  []arrow.Array{col},
+		RowCount: uint64(len(names)),
+	}
+	like := Expr.NewBinaryExpr(Expr.NewColumnResolve("table_name"), Expr.Like, Expr.NewLiteralResolve(arrow.BinaryTypes.String, pattern))
+	mask, err := Expr.EvalExpression(ctx, like, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer mask.Release()
+	matches := mask.(*array.Boolean)
+	var out []string
+	for i, name := range names {
+		if matches.Value(i) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// flightError turns a failed query into a grpc status the flight sql clients understand
+func flightError(err error) error {
+	code := codes.Internal
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		switch qe.Type {
+		case ReturnTypes_PARSE_ERROR, ReturnTypes_TYPE_ERROR:
+			code = codes.InvalidArgument
+		case ReturnTypes_UNSUPPORTED:
+			code = codes.Unimplemented
+		case ReturnTypes_SOURCE_ERROR:
+			code = codes.NotFound
+		case ReturnTypes_OVERLOADED:
+			code = codes.ResourceExhausted
+		case ReturnTypes_CANCELLED:
+			code = codes.Canceled
+			if errors.Is(err, context.DeadlineExceeded) {
+				code = codes.DeadlineExceeded
+			}
+		}
+	}
+	return status.Error(code, err.Error())
+}
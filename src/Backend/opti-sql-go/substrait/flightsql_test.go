@@ -0,0 +1,183 @@
+package substrait
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/flight"
+	"github.com/apache/arrow/go/v17/arrow/flight/flightsql"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const departmentsCSV = `id,department_name
+1,Engineering
+2,Sales
+3,Support
+`
+
+// flightClient serves store over flight sql on an in memory connection
+func flightClient(t *testing.T, store ObjectStore) *flightsql.Client {
+	t.Helper()
+	fs, err := NewFlightSQLServer(store, admissionFromConfig(), resultCacheFromConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	flight.RegisterFlightServiceServer(srv, flightsql.NewFlightServer(fs))
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+	client, err := flightsql.NewClient("passthrough:///bufnet", nil, nil,
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// fetch runs DoGet on every endpoint of info and returns the rows
+func fetch(t *testing.T, client *flightsql.Client, info *flight.FlightInfo) (*arrow.Schema, [][]any) {
+	t.Helper()
+	var schema *arrow.Schema
+	var rows [][]any
+	for _, ep := range info.GetEndpoint() {
+		rdr, err := client.DoGet(context.Background(), ep.GetTicket())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		schema = rdr.Schema()
+		for rdr.Next() {
+			rec := rdr.Record()
+			for r := 0; r < int(rec.NumRows()); r++ {
+				row := make([]any, rec.NumCols())
+				for c := range row {
+					if rec.Column(c).IsNull(r) {
+						continue
+					}
+					row[c] = rec.Column(c).GetOneForMarshal(r)
+				}
+				rows = append(rows, row)
+			}
+		}
+		if err := rdr.Err(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rdr.Release()
+	}
+	return schema, rows
+}
+
+func TestFlightSQL(t *testing.T) {
+	store, _ := newFakeStore(t, map[string][]byte{
+		"employees.csv":         []byte(employeesCSV),
+		"departments.csv":       []byte(departmentsCSV),
+		"notes.txt":             []byte("not a table"),
+		"results/old-1.parquet": []byte("results are not tables"),
+	})
+	client := flightClient(t, store)
+	ctx := context.Background()
+
+	t.Run("statement query", func(t *testing.T) {
+		info, err := client.Execute(ctx, `SELECT e.name, d.department_name FROM employees e JOIN departments d ON e.dept_id = d.id
+			WHERE e.age > 30 ORDER BY e.name`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		infoSchema, err := flight.DeserializeSchema(info.GetSchema(), memory.DefaultAllocator)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		schema, rows := fetch(t, client, info)
+		if !schema.Equal(infoSchema) {
+			t.Fatalf("GetFlightInfo promised %v but DoGet returned %v", infoSchema, schema)
+		}
+		want := [][]any{{"Alice", "Engineering"}, {"Carol", "Engineering"}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Fatalf("expected %v, got %v", want, rows)
+		}
+	})
+	t.Run("query errors map to status codes", func(t *testing.T) {
+		cases := map[string]codes.Code{
+			"SELECT FROM":           codes.InvalidArgument,
+			"SELECT name FROM nope": codes.NotFound,
+		}
+		for query, want := range cases {
+			_, err := client.Execute(ctx, query)
+			if got := status.Code(err); got != want {
+				t.Fatalf("%s: expected %v, got %v (%v)", query, want, got, err)
+			}
+		}
+	})
+	t.Run("tables", func(t *testing.T) {
+		info, err := client.GetTables(ctx, &flightsql.GetTablesOpts{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, rows := fetch(t, client, info)
+		want := [][]any{{nil, nil, "departments", "TABLE"}, {nil, nil, "employees", "TABLE"}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Fatalf("expected %v, got %v", want, rows)
+		}
+	})
+	t.Run("tables filtered by name with schemas", func(t *testing.T) {
+		pattern := "emp%"
+		info, err := client.GetTables(ctx, &flightsql.GetTablesOpts{TableNameFilterPattern: &pattern, IncludeSchema: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, rows := fetch(t, client, info)
+		if len(rows) != 1 || rows[0][2] != "employees" {
+			t.Fatalf("expected only employees, got %v", rows)
+		}
+		schema, err := flight.DeserializeSchema(rows[0][4].([]byte), memory.DefaultAllocator)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if names := fieldNames(schema); !reflect.DeepEqual(names, []string{"id", "name", "age", "salary", "dept_id"}) {
+			t.Fatalf("unexpected table schema %v", names)
+		}
+	})
+	t.Run("sql info", func(t *testing.T) {
+		info, err := client.GetSqlInfo(ctx, []flightsql.SqlInfo{flightsql.SqlInfoFlightSqlServerName, flightsql.SqlInfoFlightSqlServerReadOnly})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rdr, err := client.DoGet(ctx, info.GetEndpoint()[0].GetTicket())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer rdr.Release()
+		got := map[uint32]any{}
+		for rdr.Next() {
+			rec := rdr.Record()
+			names := rec.Column(0).(*array.Uint32)
+			values := rec.Column(1).(*array.DenseUnion)
+			for i := 0; i < int(rec.NumRows()); i++ {
+				child := values.Field(values.ChildID(i))
+				got[names.Value(i)] = child.GetOneForMarshal(int(values.ValueOffset(i)))
+			}
+		}
+		if got[uint32(flightsql.SqlInfoFlightSqlServerName)] != "opti-sql" || got[uint32(flightsql.SqlInfoFlightSqlServerReadOnly)] != true {
+			t.Fatalf("unexpected sql info %v", got)
+		}
+	})
+}
+
+func fieldNames(s *arrow.Schema) []string {
+	names := make([]string, s.NumFields())
+	for i, f := range s.Fields() {
+		names[i] = f.Name
+	}
+	return names
+}
@@ -31,18 +31,26 @@ const (
 	ReturnTypes_UPLOAD_ERROR    ReturnTypes = 4
 	ReturnTypes_OUT_OF_MEMORY   ReturnTypes = 5
 	ReturnTypes_UNKNOWN_ERROR   ReturnTypes = 6
+	ReturnTypes_UNSUPPORTED     ReturnTypes = 7  // the plan uses a relation, expression or function the engine can't run
+	ReturnTypes_TYPE_ERROR      ReturnTypes = 8  // the plan expects different column types than the source has
+	ReturnTypes_OVERLOADED      ReturnTypes = 9  // too many queries running and queued, or the query waited too long for a slot. retry later
+	ReturnTypes_CANCELLED       ReturnTypes = 10 // the client went away or the query ran past server.timeout
 )
 
 // Enum value maps for ReturnTypes.
 var (
 	ReturnTypes_name = map[int32]string{
-		0: "SUCCESS",
-		1: "PARSE_ERROR",
-		2: "EXECUTION_ERROR",
-		3: "SOURCE_ERROR",
-		4: "UPLOAD_ERROR",
-		5: "OUT_OF_MEMORY",
-		6: "UNKNOWN_ERROR",
+		0:  "SUCCESS",
+		1:  "PARSE_ERROR",
+		2:  "EXECUTION_ERROR",
+		3:  "SOURCE_ERROR",
+		4:  "UPLOAD_ERROR",
+		5:  "OUT_OF_MEMORY",
+		6:  "UNKNOWN_ERROR",
+		7:  "UNSUPPORTED",
+		8:  "TYPE_ERROR",
+		9:  "OVERLOADED",
+		10: "CANCELLED",
 	}
 	ReturnTypes_value = map[string]int32{
 		"SUCCESS":         0,
@@ -52,6 +60,10 @@ var (
 		"UPLOAD_ERROR":    4,
 		"OUT_OF_MEMORY":   5,
 		"UNKNOWN_ERROR":   6,
+		"UNSUPPORTED":     7,
+		"TYPE_ERROR":      8,
+		"OVERLOADED":      9,
+		"CANCELLED":       10,
 	}
 )
 
@@ -85,10 +97,11 @@ func (ReturnTypes) EnumDescriptor() ([]byte, []int) {
 // The request message containing the operation details.
 type QueryExecutionRequest struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
-	SubstraitLogical []byte                 `protobuf:"bytes,1,opt,name=substrait_logical,json=substraitLogical,proto3" json:"substrait_logical,omitempty"` //SS logical plan
+	SubstraitLogical []byte                 `protobuf:"bytes,1,opt,name=substrait_logical,json=substraitLogical,proto3" json:"substrait_logical,omitempty"` // Substrait logical plan: serialized representation of the query execution
 	SqlStatement     string                 `protobuf:"bytes,2,opt,name=sql_statement,json=sqlStatement,proto3" json:"sql_statement,omitempty"`             // original sql statement
 	Id               string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`                                                     // unique id for this client
 	Source           *SourceType            `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`                                             // (s3 link| base64 data)
+	Analyze          bool                   `protobuf:"varint,5,opt,name=analyze,proto3" json:"analyze,omitempty"`                                          // EXPLAIN ANALYZE: time every operator and send the annotated operator tree back, never served from the result cache
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
@@ -151,13 +164,21 @@ func (x *QueryExecutionRequest) GetSource() *SourceType {
 	return nil
 }
 
+func (x *QueryExecutionRequest) GetAnalyze() bool {
+	if x != nil {
+		return x.Analyze
+	}
+	return false
+}
+
 // The response message containing the result.
 type QueryExecutionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	S3ResultLink  string                 `protobuf:"bytes,1,opt,name=s3_result_link,json=s3ResultLink,proto3" json:"s3_result_link,omitempty"` // s3 link to the result data
-	ErrorType     *ErrorDetails          `protobuf:"bytes,2,opt,name=error_type,json=errorType,proto3" json:"error_type,omitempty"`            // error type if any
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	S3ResultLink   string                 `protobuf:"bytes,1,opt,name=s3_result_link,json=s3ResultLink,proto3" json:"s3_result_link,omitempty"`     // s3 link to the result data
+	ErrorType      *ErrorDetails          `protobuf:"bytes,2,opt,name=error_type,json=errorType,proto3" json:"error_type,omitempty"`                // error type if any
+	ExplainAnalyze string                 `protobuf:"bytes,3,opt,name=explain_analyze,json=explainAnalyze,proto3" json:"explain_analyze,omitempty"` // operator tree with per operator stats when the request asked for analyze, also filled in when execution failed
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *QueryExecutionResponse) Reset() {
@@ -204,6 +225,198 @@ func (x *QueryExecutionResponse) GetErrorType() *ErrorDetails {
 	return nil
 }
 
+func (x *QueryExecutionResponse) GetExplainAnalyze() string {
+	if x != nil {
+		return x.ExplainAnalyze
+	}
+	return ""
+}
+
+// one message of ExecuteQueryStream: the schema first, then every record batch, then the trailer.
+// schema and record_batch are encapsulated arrow IPC messages, concatenated they form an IPC stream
+type QueryResultChunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Chunk:
+	//
+	//	*QueryResultChunk_Schema
+	//	*QueryResultChunk_RecordBatch
+	//	*QueryResultChunk_Trailer
+	Chunk         isQueryResultChunk_Chunk `protobuf_oneof:"chunk"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResultChunk) Reset() {
+	*x = QueryResultChunk{}
+	mi := &file_operation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResultChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResultChunk) ProtoMessage() {}
+
+func (x *QueryResultChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_operation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResultChunk.ProtoReflect.Descriptor instead.
+func (*QueryResultChunk) Descriptor() ([]byte, []int) {
+	return file_operation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *QueryResultChunk) GetChunk() isQueryResultChunk_Chunk {
+	if x != nil {
+		return x.Chunk
+	}
+	return nil
+}
+
+func (x *QueryResultChunk) GetSchema() []byte {
+	if x != nil {
+		if x, ok := x.Chunk.(*QueryResultChunk_Schema); ok {
+			return x.Schema
+		}
+	}
+	return nil
+}
+
+func (x *QueryResultChunk) GetRecordBatch() []byte {
+	if x != nil {
+		if x, ok := x.Chunk.(*QueryResultChunk_RecordBatch); ok {
+			return x.RecordBatch
+		}
+	}
+	return nil
+}
+
+func (x *QueryResultChunk) GetTrailer() *QueryTrailer {
+	if x != nil {
+		if x, ok := x.Chunk.(*QueryResultChunk_Trailer); ok {
+			return x.Trailer
+		}
+	}
+	return nil
+}
+
+type isQueryResultChunk_Chunk interface {
+	isQueryResultChunk_Chunk()
+}
+
+type QueryResultChunk_Schema struct {
+	Schema []byte `protobuf:"bytes,1,opt,name=schema,proto3,oneof"`
+}
+
+type QueryResultChunk_RecordBatch struct {
+	RecordBatch []byte `protobuf:"bytes,2,opt,name=record_batch,json=recordBatch,proto3,oneof"`
+}
+
+type QueryResultChunk_Trailer struct {
+	Trailer *QueryTrailer `protobuf:"bytes,3,opt,name=trailer,proto3,oneof"`
+}
+
+func (*QueryResultChunk_Schema) isQueryResultChunk_Chunk() {}
+
+func (*QueryResultChunk_RecordBatch) isQueryResultChunk_Chunk() {}
+
+func (*QueryResultChunk_Trailer) isQueryResultChunk_Chunk() {}
+
+// always the last message of a stream, error_type tells whether the batches before it are the whole result
+type QueryTrailer struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ErrorType       *ErrorDetails          `protobuf:"bytes,1,opt,name=error_type,json=errorType,proto3" json:"error_type,omitempty"`
+	RowCount        uint64                 `protobuf:"varint,2,opt,name=row_count,json=rowCount,proto3" json:"row_count,omitempty"`
+	BatchCount      uint64                 `protobuf:"varint,3,opt,name=batch_count,json=batchCount,proto3" json:"batch_count,omitempty"`
+	PlanningMicros  uint64                 `protobuf:"varint,4,opt,name=planning_micros,json=planningMicros,proto3" json:"planning_micros,omitempty"`    // source, plan decoding and physical planning
+	ExecutionMicros uint64                 `protobuf:"varint,5,opt,name=execution_micros,json=executionMicros,proto3" json:"execution_micros,omitempty"` // first Next until the last batch was sent
+	ExplainAnalyze  string                 `protobuf:"bytes,6,opt,name=explain_analyze,json=explainAnalyze,proto3" json:"explain_analyze,omitempty"`     // same as QueryExecutionResponse.explain_analyze
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *QueryTrailer) Reset() {
+	*x = QueryTrailer{}
+	mi := &file_operation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryTrailer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryTrailer) ProtoMessage() {}
+
+func (x *QueryTrailer) ProtoReflect() protoreflect.Message {
+	mi := &file_operation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryTrailer.ProtoReflect.Descriptor instead.
+func (*QueryTrailer) Descriptor() ([]byte, []int) {
+	return file_operation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *QueryTrailer) GetErrorType() *ErrorDetails {
+	if x != nil {
+		return x.ErrorType
+	}
+	return nil
+}
+
+func (x *QueryTrailer) GetRowCount() uint64 {
+	if x != nil {
+		return x.RowCount
+	}
+	return 0
+}
+
+func (x *QueryTrailer) GetBatchCount() uint64 {
+	if x != nil {
+		return x.BatchCount
+	}
+	return 0
+}
+
+func (x *QueryTrailer) GetPlanningMicros() uint64 {
+	if x != nil {
+		return x.PlanningMicros
+	}
+	return 0
+}
+
+func (x *QueryTrailer) GetExecutionMicros() uint64 {
+	if x != nil {
+		return x.ExecutionMicros
+	}
+	return 0
+}
+
+func (x *QueryTrailer) GetExplainAnalyze() string {
+	if x != nil {
+		return x.ExplainAnalyze
+	}
+	return ""
+}
+
 type SourceType struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	S3Source      string                 `protobuf:"bytes,1,opt,name=s3_source,json=s3Source,proto3" json:"s3_source,omitempty"` // s3 link to the source data
@@ -214,7 +427,7 @@ type SourceType struct {
 
 func (x *SourceType) Reset() {
 	*x = SourceType{}
-	mi := &file_operation_proto_msgTypes[2]
+	mi := &file_operation_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -226,7 +439,7 @@ func (x *SourceType) String() string {
 func (*SourceType) ProtoMessage() {}
 
 func (x *SourceType) ProtoReflect() protoreflect.Message {
-	mi := &file_operation_proto_msgTypes[2]
+	mi := &file_operation_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -239,7 +452,7 @@ func (x *SourceType) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SourceType.ProtoReflect.Descriptor instead.
 func (*SourceType) Descriptor() ([]byte, []int) {
-	return file_operation_proto_rawDescGZIP(), []int{2}
+	return file_operation_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *SourceType) GetS3Source() string {
@@ -266,7 +479,7 @@ type ErrorDetails struct {
 
 func (x *ErrorDetails) Reset() {
 	*x = ErrorDetails{}
-	mi := &file_operation_proto_msgTypes[3]
+	mi := &file_operation_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -278,7 +491,7 @@ func (x *ErrorDetails) String() string {
 func (*ErrorDetails) ProtoMessage() {}
 
 func (x *ErrorDetails) ProtoReflect() protoreflect.Message {
-	mi := &file_operation_proto_msgTypes[3]
+	mi := &file_operation_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -291,7 +504,7 @@ func (x *ErrorDetails) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ErrorDetails.ProtoReflect.Descriptor instead.
 func (*ErrorDetails) Descriptor() ([]byte, []int) {
-	return file_operation_proto_rawDescGZIP(), []int{3}
+	return file_operation_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ErrorDetails) GetErrorType() ReturnTypes {
@@ -312,16 +525,32 @@ var File_operation_proto protoreflect.FileDescriptor
 
 const file_operation_proto_rawDesc = "" +
 	"\n" +
-	"\x0foperation.proto\x12\bcontract\"\xa7\x01\n" +
+	"\x0foperation.proto\x12\bcontract\"\xc1\x01\n" +
 	"\x15QueryExecutionRequest\x12+\n" +
 	"\x11substrait_logical\x18\x01 \x01(\fR\x10substraitLogical\x12#\n" +
 	"\rsql_statement\x18\x02 \x01(\tR\fsqlStatement\x12\x0e\n" +
 	"\x02id\x18\x03 \x01(\tR\x02id\x12,\n" +
-	"\x06source\x18\x04 \x01(\v2\x14.contract.SourceTypeR\x06source\"u\n" +
+	"\x06source\x18\x04 \x01(\v2\x14.contract.SourceTypeR\x06source\x12\x18\n" +
+	"\aanalyze\x18\x05 \x01(\bR\aanalyze\"\x9e\x01\n" +
 	"\x16QueryExecutionResponse\x12$\n" +
 	"\x0es3_result_link\x18\x01 \x01(\tR\fs3ResultLink\x125\n" +
 	"\n" +
-	"error_type\x18\x02 \x01(\v2\x16.contract.ErrorDetailsR\terrorType\"=\n" +
+	"error_type\x18\x02 \x01(\v2\x16.contract.ErrorDetailsR\terrorType\x12'\n" +
+	"\x0fexplain_analyze\x18\x03 \x01(\tR\x0eexplainAnalyze\"\x8e\x01\n" +
+	"\x10QueryResultChunk\x12\x18\n" +
+	"\x06schema\x18\x01 \x01(\fH\x00R\x06schema\x12#\n" +
+	"\frecord_batch\x18\x02 \x01(\fH\x00R\vrecordBatch\x122\n" +
+	"\atrailer\x18\x03 \x01(\v2\x16.contract.QueryTrailerH\x00R\atrailerB\a\n" +
+	"\x05chunk\"\x80\x02\n" +
+	"\fQueryTrailer\x125\n" +
+	"\n" +
+	"error_type\x18\x01 \x01(\v2\x16.contract.ErrorDetailsR\terrorType\x12\x1b\n" +
+	"\trow_count\x18\x02 \x01(\x04R\browCount\x12\x1f\n" +
+	"\vbatch_count\x18\x03 \x01(\x04R\n" +
+	"batchCount\x12'\n" +
+	"\x0fplanning_micros\x18\x04 \x01(\x04R\x0eplanningMicros\x12)\n" +
+	"\x10execution_micros\x18\x05 \x01(\x04R\x0fexecutionMicros\x12'\n" +
+	"\x0fexplain_analyze\x18\x06 \x01(\tR\x0eexplainAnalyze\"=\n" +
 	"\n" +
 	"SourceType\x12\x1b\n" +
 	"\ts3_source\x18\x01 \x01(\tR\bs3Source\x12\x12\n" +
@@ -329,7 +558,7 @@ const file_operation_proto_rawDesc = "" +
 	"\fErrorDetails\x124\n" +
 	"\n" +
 	"error_type\x18\x01 \x01(\x0e2\x15.contract.returnTypesR\terrorType\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage*\x8a\x01\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage*\xca\x01\n" +
 	"\vreturnTypes\x12\v\n" +
 	"\aSUCCESS\x10\x00\x12\x0f\n" +
 	"\vPARSE_ERROR\x10\x01\x12\x13\n" +
@@ -337,9 +566,17 @@ const file_operation_proto_rawDesc = "" +
 	"\fSOURCE_ERROR\x10\x03\x12\x10\n" +
 	"\fUPLOAD_ERROR\x10\x04\x12\x11\n" +
 	"\rOUT_OF_MEMORY\x10\x05\x12\x11\n" +
-	"\rUNKNOWN_ERROR\x10\x062`\n" +
+	"\rUNKNOWN_ERROR\x10\x06\x12\x0f\n" +
+	"\vUNSUPPORTED\x10\a\x12\x0e\n" +
+	"\n" +
+	"TYPE_ERROR\x10\b\x12\x0e\n" +
+	"\n" +
+	"OVERLOADED\x10\t\x12\r\n" +
+	"\tCANCELLED\x10\n" +
+	"2\xb5\x01\n" +
 	"\vSSOperation\x12Q\n" +
-	"\fExecuteQuery\x12\x1f.contract.QueryExecutionRequest\x1a .contract.QueryExecutionResponseB+Z)opti-sql-go/Backend/opti-sql-go/substraitb\x06proto3"
+	"\fExecuteQuery\x12\x1f.contract.QueryExecutionRequest\x1a .contract.QueryExecutionResponse\x12S\n" +
+	"\x12ExecuteQueryStream\x12\x1f.contract.QueryExecutionRequest\x1a\x1a.contract.QueryResultChunk0\x01B+Z)opti-sql-go/Backend/opti-sql-go/substraitb\x06proto3"
 
 var (
 	file_operation_proto_rawDescOnce sync.Once
@@ -354,25 +591,31 @@ func file_operation_proto_rawDescGZIP() []byte {
 }
 
 var file_operation_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_operation_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_operation_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_operation_proto_goTypes = []any{
 	(ReturnTypes)(0),               // 0: contract.returnTypes
 	(*QueryExecutionRequest)(nil),  // 1: contract.QueryExecutionRequest
 	(*QueryExecutionResponse)(nil), // 2: contract.QueryExecutionResponse
-	(*SourceType)(nil),             // 3: contract.SourceType
-	(*ErrorDetails)(nil),           // 4: contract.ErrorDetails
+	(*QueryResultChunk)(nil),       // 3: contract.QueryResultChunk
+	(*QueryTrailer)(nil),           // 4: contract.QueryTrailer
+	(*SourceType)(nil),             // 5: contract.SourceType
+	(*ErrorDetails)(nil),           // 6: contract.ErrorDetails
 }
 var file_operation_proto_depIdxs = []int32{
-	3, // 0: contract.QueryExecutionRequest.source:type_name -> contract.SourceType
-	4, // 1: contract.QueryExecutionResponse.error_type:type_name -> contract.ErrorDetails
-	0, // 2: contract.ErrorDetails.error_type:type_name -> contract.returnTypes
-	1, // 3: contract.SSOperation.ExecuteQuery:input_type -> contract.QueryExecutionRequest
-	2, // 4: contract.SSOperation.ExecuteQuery:output_type -> contract.QueryExecutionResponse
-	4, // [4:5] is the sub-list for method output_type
-	3, // [3:4] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	5, // 0: contract.QueryExecutionRequest.source:type_name -> contract.SourceType
+	6, // 1: contract.QueryExecutionResponse.error_type:type_name -> contract.ErrorDetails
+	4, // 2: contract.QueryResultChunk.trailer:type_name -> contract.QueryTrailer
+	6, // 3: contract.QueryTrailer.error_type:type_name -> contract.ErrorDetails
+	0, // 4: contract.ErrorDetails.error_type:type_name -> contract.returnTypes
+	1, // 5: contract.SSOperation.ExecuteQuery:input_type -> contract.QueryExecutionRequest
+	1, // 6: contract.SSOperation.ExecuteQueryStream:input_type -> contract.QueryExecutionRequest
+	2, // 7: contract.SSOperation.ExecuteQuery:output_type -> contract.QueryExecutionResponse
+	3, // 8: contract.SSOperation.ExecuteQueryStream:output_type -> contract.QueryResultChunk
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_operation_proto_init() }
@@ -380,13 +623,18 @@ func file_operation_proto_init() {
 	if File_operation_proto != nil {
 		return
 	}
+	file_operation_proto_msgTypes[2].OneofWrappers = []any{
+		(*QueryResultChunk_Schema)(nil),
+		(*QueryResultChunk_RecordBatch)(nil),
+		(*QueryResultChunk_Trailer)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_operation_proto_rawDesc), len(file_operation_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   4,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -16,11 +16,12 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.64.0 or later.
-//const _ = grpc.SupportPackageIsVersion9
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
 
 const (
-	SSOperation_ExecuteQuery_FullMethodName = "/contract.SSOperation/ExecuteQuery"
+	SSOperation_ExecuteQuery_FullMethodName       = "/contract.SSOperation/ExecuteQuery"
+	SSOperation_ExecuteQueryStream_FullMethodName = "/contract.SSOperation/ExecuteQueryStream"
 )
 
 // SSOperationClient is the client API for SSOperation service.
@@ -30,6 +31,8 @@ const (
 // The service definition.
 type SSOperationClient interface {
 	ExecuteQuery(ctx context.Context, in *QueryExecutionRequest, opts ...grpc.CallOption) (*QueryExecutionResponse, error)
+	// same request as ExecuteQuery but the result comes back on the stream instead of through s3
+	ExecuteQueryStream(ctx context.Context, in *QueryExecutionRequest, opts ...grpc.CallOption) (SSOperation_ExecuteQueryStreamClient, error)
 }
 
 type sSOperationClient struct {
@@ -50,6 +53,39 @@ func (c *sSOperationClient) ExecuteQuery(ctx context.Context, in *QueryExecution
 	return out, nil
 }
 
+func (c *sSOperationClient) ExecuteQueryStream(ctx context.Context, in *QueryExecutionRequest, opts ...grpc.CallOption) (SSOperation_ExecuteQueryStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SSOperation_ServiceDesc.Streams[0], SSOperation_ExecuteQueryStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sSOperationExecuteQueryStreamClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SSOperation_ExecuteQueryStreamClient interface {
+	Recv() (*QueryResultChunk, error)
+	grpc.ClientStream
+}
+
+type sSOperationExecuteQueryStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *sSOperationExecuteQueryStreamClient) Recv() (*QueryResultChunk, error) {
+	m := new(QueryResultChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // SSOperationServer is the server API for SSOperation service.
 // All implementations must embed UnimplementedSSOperationServer
 // for forward compatibility.
@@ -57,6 +93,8 @@ func (c *sSOperationClient) ExecuteQuery(ctx context.Context, in *QueryExecution
 // The service definition.
 type SSOperationServer interface {
 	ExecuteQuery(context.Context, *QueryExecutionRequest) (*QueryExecutionResponse, error)
+	// same request as ExecuteQuery but the result comes back on the stream instead of through s3
+	ExecuteQueryStream(*QueryExecutionRequest, SSOperation_ExecuteQueryStreamServer) error
 	mustEmbedUnimplementedSSOperationServer()
 }
 
@@ -70,6 +108,9 @@ type UnimplementedSSOperationServer struct{}
 func (UnimplementedSSOperationServer) ExecuteQuery(context.Context, *QueryExecutionRequest) (*QueryExecutionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ExecuteQuery not implemented")
 }
+func (UnimplementedSSOperationServer) ExecuteQueryStream(*QueryExecutionRequest, SSOperation_ExecuteQueryStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExecuteQueryStream not implemented")
+}
 func (UnimplementedSSOperationServer) mustEmbedUnimplementedSSOperationServer() {}
 func (UnimplementedSSOperationServer) testEmbeddedByValue()                     {}
 
@@ -109,6 +150,27 @@ func _SSOperation_ExecuteQuery_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SSOperation_ExecuteQueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryExecutionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SSOperationServer).ExecuteQueryStream(m, &sSOperationExecuteQueryStreamServer{ServerStream: stream})
+}
+
+type SSOperation_ExecuteQueryStreamServer interface {
+	Send(*QueryResultChunk) error
+	grpc.ServerStream
+}
+
+type sSOperationExecuteQueryStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *sSOperationExecuteQueryStreamServer) Send(m *QueryResultChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // SSOperation_ServiceDesc is the grpc.ServiceDesc for SSOperation service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -121,6 +183,12 @@ var SSOperation_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _SSOperation_ExecuteQuery_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExecuteQueryStream",
+			Handler:       _SSOperation_ExecuteQueryStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "operation.proto",
 }
@@ -0,0 +1,622 @@
+package substrait
+
+import (
+	"fmt"
+	"opti-sql-go/Expr"
+	logicalplan "opti-sql-go/logical-plan"
+	join "opti-sql-go/operators/Join"
+	"opti-sql-go/operators/aggr"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"google.golang.org/protobuf/proto"
+)
+
+// the producer is the consumer run backwards, it writes a logical plan out as a substrait plan so
+// it can be handed to another engine (differential testing) or stored next to a query's results.
+// operator trees are exported through the logical plan they were lowered from, operators don't
+// keep enough of their construction around to be turned back into relations.
+//
+// column names only survive at the root (RelRoot.names), everything below is positional.
+// projections emit only their expressions, so a projection's output lines up with the logical
+// Project's schema
+
+var (
+	ErrCannotProduce = func(format string, args ...any) error {
+		return fmt.Errorf("substrait: cannot produce plan: %s", fmt.Sprintf(format, args...))
+	}
+)
+
+// substraitVersion is the spec version of the proto definitions we build against
+var substraitVersion = &substraitpb.Version{MinorNumber: 29, Producer: "opti-sql-go"}
+
+// extension yaml files of the standard functions we emit
+const (
+	arithmeticURI = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_arithmetic.yaml"
+	comparisonURI = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_comparison.yaml"
+	booleanURI    = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_boolean.yaml"
+	stringURI     = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_string.yaml"
+	roundingURI   = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_rounding.yaml"
+	aggregateURI  = "https://github.com/substrait-io/substrait/blob/main/extensions/functions_aggregate_generic.yaml"
+)
+
+var functionURIs = map[string]string{
+	"add": arithmeticURI, "subtract": arithmeticURI, "multiply": arithmeticURI, "divide": arithmeticURI,
+	"abs": arithmeticURI, "sum": arithmeticURI, "avg": arithmeticURI, "min": arithmeticURI, "max": arithmeticURI,
+	"equal": comparisonURI, "not_equal": comparisonURI, "lt": comparisonURI, "lte": comparisonURI,
+	"gt": comparisonURI, "gte": comparisonURI, "is_not_null": comparisonURI,
+	"and": booleanURI, "or": booleanURI,
+	"like": stringURI, "upper": stringURI, "lower": stringURI,
+	"round": roundingURI,
+	"count": aggregateURI,
+}
+
+type Producer struct {
+	uris      map[string]uint32
+	functions map[string]uint32
+	plan      *substraitpb.Plan
+}
+
+func NewProducer() *Producer {
+	return &Producer{}
+}
+
+// ProduceBytes converts plan and serializes the result
+func (p *Producer) ProduceBytes(plan logicalplan.Plan) ([]byte, error) {
+	out, err := p.Produce(plan)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(out)
+}
+
+// Produce converts plan into a substrait Plan with a single root named after plan's output columns
+func (p *Producer) Produce(plan logicalplan.Plan) (*substraitpb.Plan, error) {
+	p.uris = make(map[string]uint32)
+	p.functions = make(map[string]uint32)
+	p.plan = &substraitpb.Plan{Version: substraitVersion}
+
+	root, err := p.rel(plan)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, plan.Schema().NumFields())
+	for i, f := range plan.Schema().Fields() {
+		names[i] = f.Name
+	}
+	p.plan.Relations = []*substraitpb.PlanRel{{
+		RelType: &substraitpb.PlanRel_Root{Root: &substraitpb.RelRoot{Input: root, Names: names}},
+	}}
+	return p.plan, nil
+}
+
+// functionAnchor declares name (and its extension uri) the first time it is used
+func (p *Producer) functionAnchor(name string) uint32 {
+	if a, ok := p.functions[name]; ok {
+		return a
+	}
+	uri := functionURIs[name]
+	uriAnchor, ok := p.uris[uri]
+	if !ok {
+		uriAnchor = uint32(len(p.uris) + 1)
+		p.uris[uri] = uriAnchor
+		p.plan.ExtensionUris = append(p.plan.ExtensionUris, &extensionspb.SimpleExtensionURI{ExtensionUriAnchor: uriAnchor, Uri: uri})
+	}
+	a := uint32(len(p.functions) + 1)
+	p.functions[name] = a
+	p.plan.Extensions = append(p.plan.Extensions, &extensionspb.SimpleExtensionDeclaration{
+		MappingType: &extensionspb.SimpleExtensionDeclaration_ExtensionFunction_{
+			ExtensionFunction: &extensionspb.SimpleExtensionDeclaration_ExtensionFunction{
+				ExtensionUriReference: uriAnchor,
+				FunctionAnchor:        a,
+				Name:                  name,
+			},
+		},
+	})
+	return a
+}
+
+// ================
+// relations
+// ================
+
+func (p *Producer) rel(plan logicalplan.Plan) (*substraitpb.Rel, error) {
+	switch n := plan.(type) {
+	case *logicalplan.Scan:
+		return p.read(n)
+
+	case *logicalplan.Filter:
+		return p.filter(n.Input, n.Predicate)
+
+	case *logicalplan.Having:
+		return p.filter(n.Input, n.Predicate)
+
+	case *logicalplan.Project:
+		input, err := p.rel(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		exprs := make([]*substraitpb.Expression, len(n.Exprs))
+		mapping := make([]int32, len(n.Exprs))
+		width := int32(n.Input.Schema().NumFields())
+		for i, e := range n.Exprs {
+			if exprs[i], err = p.expr(e, n.Input.Schema()); err != nil {
+				return nil, err
+			}
+			mapping[i] = width + int32(i)
+		}
+		return &substraitpb.Rel{RelType: &substraitpb.Rel_Project{Project: &substraitpb.ProjectRel{
+			Common:      emitOnly(mapping),
+			Input:       input,
+			Expressions: exprs,
+		}}}, nil
+
+	case *logicalplan.Aggregate:
+		return p.aggregate(n)
+
+	case *logicalplan.Sort:
+		input, err := p.rel(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		sorts := make([]*substraitpb.SortField, len(n.Keys))
+		for i, k := range n.Keys {
+			e, err := p.expr(k.Expr, n.Input.Schema())
+			if err != nil {
+				return nil, err
+			}
+			sorts[i] = &substraitpb.SortField{Expr: e, SortKind: &substraitpb.SortField_Direction{Direction: sortDirection(k)}}
+		}
+		return &substraitpb.Rel{RelType: &substraitpb.Rel_Sort{Sort: &substraitpb.SortRel{Input: input, Sorts: sorts}}}, nil
+
+	case *logicalplan.Limit:
+		input, err := p.rel(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		return &substraitpb.Rel{RelType: &substraitpb.Rel_Fetch{Fetch: &substraitpb.FetchRel{Input: input, Count: int64(n.Count)}}}, nil
+
+	case *logicalplan.Join:
+		return p.join(n)
+
+	case *logicalplan.Distinct:
+		// an aggregate grouping on every column and computing nothing
+		input, err := p.rel(n.Input)
+		if err != nil {
+			return nil, err
+		}
+		grouping := &substraitpb.AggregateRel_Grouping{}
+		for i := 0; i < n.Input.Schema().NumFields(); i++ {
+			grouping.GroupingExpressions = append(grouping.GroupingExpressions, fieldRef(int32(i)))
+		}
+		return &substraitpb.Rel{RelType: &substraitpb.Rel_Aggregate{Aggregate: &substraitpb.AggregateRel{
+			Input:     input,
+			Groupings: []*substraitpb.AggregateRel_Grouping{grouping},
+		}}}, nil
+
+	default:
+		return nil, ErrCannotProduce("unknown plan node %T", plan)
+	}
+}
+
+func emitOnly(mapping []int32) *substraitpb.RelCommon {
+	return &substraitpb.RelCommon{EmitKind: &substraitpb.RelCommon_Emit_{Emit: &substraitpb.RelCommon_Emit{OutputMapping: mapping}}}
+}
+
+func (p *Producer) read(s *logicalplan.Scan) (*substraitpb.Rel, error) {
+	base, err := namedStruct(s.Source)
+	if err != nil {
+		return nil, err
+	}
+	read := &substraitpb.ReadRel{
+		BaseSchema: base,
+		ReadType:   &substraitpb.ReadRel_NamedTable_{NamedTable: &substraitpb.ReadRel_NamedTable{Names: []string{s.Table}}},
+	}
+	if s.Columns != nil {
+		sel := &substraitpb.Expression_MaskExpression_StructSelect{}
+		for _, c := range s.Columns {
+			sel.StructItems = append(sel.StructItems, &substraitpb.Expression_MaskExpression_StructItem{Field: int32(s.Source.FieldIndices(c)[0])})
+		}
+		read.Projection = &substraitpb.Expression_MaskExpression{Select: sel, MaintainSingularStruct: true}
+	}
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Read{Read: read}}, nil
+}
+
+func (p *Producer) filter(inputPlan logicalplan.Plan, pred Expr.Expression) (*substraitpb.Rel, error) {
+	input, err := p.rel(inputPlan)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.expr(pred, inputPlan.Schema())
+	if err != nil {
+		return nil, err
+	}
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Filter{Filter: &substraitpb.FilterRel{Input: input, Condition: cond}}}, nil
+}
+
+func (p *Producer) aggregate(a *logicalplan.Aggregate) (*substraitpb.Rel, error) {
+	input, err := p.rel(a.Input)
+	if err != nil {
+		return nil, err
+	}
+	schema := a.Input.Schema()
+	rel := &substraitpb.AggregateRel{Input: input}
+	if len(a.GroupBy) > 0 {
+		grouping := &substraitpb.AggregateRel_Grouping{}
+		for _, g := range a.GroupBy {
+			e, err := p.expr(g, schema)
+			if err != nil {
+				return nil, err
+			}
+			grouping.GroupingExpressions = append(grouping.GroupingExpressions, e)
+		}
+		rel.Groupings = []*substraitpb.AggregateRel_Grouping{grouping}
+	}
+	for i, m := range a.Aggregates {
+		// COUNT(*) is count without arguments
+		fn, args := m.AggrFunc, []*substraitpb.FunctionArgument(nil)
+		if fn == aggr.CountStar {
+			fn = aggr.Count
+		} else {
+			arg, err := p.expr(m.Child, schema)
+			if err != nil {
+				return nil, err
+			}
+			args = []*substraitpb.FunctionArgument{{ArgType: &substraitpb.FunctionArgument_Value{Value: arg}}}
+		}
+		name, err := aggregateName(fn)
+		if err != nil {
+			return nil, err
+		}
+		outType, err := substraitType(a.Schema().Field(len(a.GroupBy) + i).Type)
+		if err != nil {
+			return nil, err
+		}
+		invocation := substraitpb.AggregateFunction_AGGREGATION_INVOCATION_ALL
+		if m.Distinct {
+			invocation = substraitpb.AggregateFunction_AGGREGATION_INVOCATION_DISTINCT
+		}
+		rel.Measures = append(rel.Measures, &substraitpb.AggregateRel_Measure{Measure: &substraitpb.AggregateFunction{
+			FunctionReference: p.functionAnchor(name),
+			Arguments:         args,
+			OutputType:        outType,
+			Phase:             substraitpb.AggregationPhase_AGGREGATION_PHASE_INITIAL_TO_RESULT,
+			Invocation:        invocation,
+		}})
+	}
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Aggregate{Aggregate: rel}}, nil
+}
+
+func aggregateName(fn aggr.AggrFunc) (string, error) {
+	for name, f := range aggregateFuncs {
+		if f == fn {
+			return name, nil
+		}
+	}
+	return "", ErrCannotProduce("unknown aggregate function %d", fn)
+}
+
+func sortDirection(k aggr.SortKey) substraitpb.SortField_SortDirection {
+	switch {
+	case k.Ascending && k.NullFirst:
+		return substraitpb.SortField_SORT_DIRECTION_ASC_NULLS_FIRST
+	case k.Ascending:
+		return substraitpb.SortField_SORT_DIRECTION_ASC_NULLS_LAST
+	case k.NullFirst:
+		return substraitpb.SortField_SORT_DIRECTION_DESC_NULLS_FIRST
+	default:
+		return substraitpb.SortField_SORT_DIRECTION_DESC_NULLS_LAST
+	}
+}
+
+// join writes the key pairs as one AND-ed equality condition over left ++ right
+func (p *Producer) join(j *logicalplan.Join) (*substraitpb.Rel, error) {
+	var joinType substraitpb.JoinRel_JoinType
+	switch j.Type {
+	case join.InnerJoin:
+		joinType = substraitpb.JoinRel_JOIN_TYPE_INNER
+	case join.LeftJoin:
+		joinType = substraitpb.JoinRel_JOIN_TYPE_LEFT
+	case join.RightJoin:
+		joinType = substraitpb.JoinRel_JOIN_TYPE_RIGHT
+	default:
+		return nil, ErrCannotProduce("unknown join type %s", j.Type)
+	}
+	left, err := p.rel(j.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.rel(j.Right)
+	if err != nil {
+		return nil, err
+	}
+	// keys are written against the join output, so right side names need their left_/right_ prefix
+	leftNames, rightNames := outputNames(j.Schema(), j.Left.Schema(), j.Right.Schema())
+	var cond Expr.Expression
+	for i := range j.LeftKeys {
+		eq := Expr.NewBinaryExpr(logicalplan.ReplaceColumns(j.LeftKeys[i], leftNames), Expr.Equal,
+			logicalplan.ReplaceColumns(j.RightKeys[i], rightNames))
+		if cond == nil {
+			cond = eq
+		} else {
+			cond = Expr.NewBinaryExpr(cond, Expr.And, eq)
+		}
+	}
+	e, err := p.expr(cond, j.Schema())
+	if err != nil {
+		return nil, err
+	}
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Join{Join: &substraitpb.JoinRel{
+		Left:       left,
+		Right:      right,
+		Expression: e,
+		Type:       joinType,
+	}}}, nil
+}
+
+// outputNames maps each side's column names to the name they have in the join output
+func outputNames(out, left, right *arrow.Schema) (l, r map[string]Expr.Expression) {
+	l = make(map[string]Expr.Expression)
+	r = make(map[string]Expr.Expression)
+	for i, f := range out.Fields() {
+		if i < left.NumFields() {
+			l[left.Field(i).Name] = Expr.NewColumnResolve(f.Name)
+		} else {
+			r[right.Field(i-left.NumFields()).Name] = Expr.NewColumnResolve(f.Name)
+		}
+	}
+	return l, r
+}
+
+// ================
+// expressions
+// ================
+
+func (p *Producer) expr(e Expr.Expression, schema *arrow.Schema) (*substraitpb.Expression, error) {
+	switch ex := e.(type) {
+	case *Expr.ColumnResolve:
+		idx := schema.FieldIndices(ex.Name)
+		if len(idx) == 0 {
+			return nil, logicalplan.ErrColumnNotFound(ex.Name, schema)
+		}
+		return fieldRef(int32(idx[0])), nil
+
+	case *Expr.LiteralResolve:
+		return literalExpr(ex)
+
+	case *Expr.Alias:
+		return p.expr(ex.Expr, schema)
+
+	case *Expr.BinaryExpr:
+		name, err := binaryFunctionName(ex)
+		if err != nil {
+			return nil, err
+		}
+		return p.call(name, e, schema, ex.Left, ex.Right)
+
+	case *Expr.ScalarFunction:
+		var name string
+		switch ex.Function {
+		case Expr.Upper:
+			name = "upper"
+		case Expr.Lower:
+			name = "lower"
+		case Expr.Abs:
+			name = "abs"
+		case Expr.Round:
+			name = "round"
+		default:
+			return nil, ErrCannotProduce("unknown scalar function %d", ex.Function)
+		}
+		return p.call(name, e, schema, ex.Arguments)
+
+	case *Expr.NullCheckExpr:
+		return p.call("is_not_null", e, schema, ex.Expr)
+
+	case *Expr.NotExpr:
+		if check, ok := ex.Expr.(*Expr.NullCheckExpr); ok {
+			return p.call("is_null", e, schema, check.Expr)
+		}
+		return p.call("not", e, schema, ex.Expr)
+
+	case *Expr.CastExpr:
+		inner, err := p.expr(ex.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		to, err := substraitType(ex.TargetType)
+		if err != nil {
+			return nil, err
+		}
+		return &substraitpb.Expression{RexType: &substraitpb.Expression_Cast_{Cast: &substraitpb.Expression_Cast{
+			Type:            to,
+			Input:           inner,
+			FailureBehavior: substraitpb.Expression_Cast_FAILURE_BEHAVIOR_THROW_EXCEPTION,
+		}}}, nil
+
+	default:
+		return nil, ErrCannotProduce("unknown expression %T", e)
+	}
+}
+
+// call builds a scalar function call of name over args, e is the whole call (used for its output type)
+func (p *Producer) call(name string, e Expr.Expression, schema *arrow.Schema, args ...Expr.Expression) (*substraitpb.Expression, error) {
+	f := &substraitpb.Expression_ScalarFunction{FunctionReference: p.functionAnchor(name)}
+	for _, a := range args {
+		arg, err := p.expr(a, schema)
+		if err != nil {
+			return nil, err
+		}
+		f.Arguments = append(f.Arguments, &substraitpb.FunctionArgument{ArgType: &substraitpb.FunctionArgument_Value{Value: arg}})
+	}
+	if dt, err := Expr.ExprDataType(e, schema); err == nil {
+		if t, err := substraitType(dt); err == nil {
+			f.OutputType = t
+		}
+	}
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_ScalarFunction_{ScalarFunction: f}}, nil
+}
+
+func binaryFunctionName(b *Expr.BinaryExpr) (string, error) {
+	switch b.Op {
+	case Expr.Addition:
+		return "add", nil
+	case Expr.Subtraction:
+		return "subtract", nil
+	case Expr.Multiplication:
+		return "multiply", nil
+	case Expr.Division:
+		return "divide", nil
+	case Expr.Equal:
+		return "equal", nil
+	case Expr.NotEqual:
+		return "not_equal", nil
+	case Expr.LessThan:
+		return "lt", nil
+	case Expr.LessThanOrEqual:
+		return "lte", nil
+	case Expr.GreaterThan:
+		return "gt", nil
+	case Expr.GreaterThanOrEqual:
+		return "gte", nil
+	case Expr.And:
+		return "and", nil
+	case Expr.Or:
+		return "or", nil
+	case Expr.Like:
+		return "like", nil
+	default:
+		return "", ErrCannotProduce("unknown binary operator %d", b.Op)
+	}
+}
+
+func fieldRef(i int32) *substraitpb.Expression {
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Selection{Selection: &substraitpb.Expression_FieldReference{
+		ReferenceType: &substraitpb.Expression_FieldReference_DirectReference{DirectReference: &substraitpb.Expression_ReferenceSegment{
+			ReferenceType: &substraitpb.Expression_ReferenceSegment_StructField_{StructField: &substraitpb.Expression_ReferenceSegment_StructField{Field: i}},
+		}},
+		RootType: &substraitpb.Expression_FieldReference_RootReference_{RootReference: &substraitpb.Expression_FieldReference_RootReference{}},
+	}}}
+}
+
+func literalExpr(l *Expr.LiteralResolve) (*substraitpb.Expression, error) {
+	lit := &substraitpb.Expression_Literal{}
+	var ok bool
+	switch l.Type.ID() {
+	case arrow.NULL:
+		lit.LiteralType, ok = &substraitpb.Expression_Literal_Null{Null: &substraitpb.Type{Kind: &substraitpb.Type_Bool{
+			Bool: &substraitpb.Type_Boolean{Nullability: substraitpb.Type_NULLABILITY_NULLABLE}}}}, true
+	case arrow.BOOL:
+		var v bool
+		if v, ok = l.Value.(bool); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_Boolean{Boolean: v}
+		}
+	case arrow.INT8:
+		var v int64
+		if v, ok = intValue(l.Value); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_I8{I8: int32(v)}
+		}
+	case arrow.INT16:
+		var v int64
+		if v, ok = intValue(l.Value); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_I16{I16: int32(v)}
+		}
+	case arrow.INT32:
+		var v int64
+		if v, ok = intValue(l.Value); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_I32{I32: int32(v)}
+		}
+	case arrow.INT64:
+		var v int64
+		if v, ok = intValue(l.Value); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_I64{I64: v}
+		}
+	case arrow.FLOAT32:
+		var v float32
+		if v, ok = l.Value.(float32); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_Fp32{Fp32: v}
+		}
+	case arrow.FLOAT64:
+		var v float64
+		if v, ok = l.Value.(float64); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_Fp64{Fp64: v}
+		}
+	case arrow.STRING:
+		var v string
+		if v, ok = l.Value.(string); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_String_{String_: v}
+		}
+	case arrow.BINARY:
+		var v []byte
+		if v, ok = l.Value.([]byte); ok {
+			lit.LiteralType = &substraitpb.Expression_Literal_Binary{Binary: v}
+		}
+	default:
+		return nil, ErrCannotProduce("literal of type %s", l.Type)
+	}
+	if !ok {
+		return nil, ErrCannotProduce("literal %v (%T) does not match its type %s", l.Value, l.Value, l.Type)
+	}
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Literal_{Literal: lit}}, nil
+}
+
+func intValue(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// ================
+// types
+// ================
+
+func namedStruct(schema *arrow.Schema) (*substraitpb.NamedStruct, error) {
+	ns := &substraitpb.NamedStruct{Struct: &substraitpb.Type_Struct{Nullability: substraitpb.Type_NULLABILITY_REQUIRED}}
+	for _, f := range schema.Fields() {
+		t, err := substraitType(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		ns.Names = append(ns.Names, f.Name)
+		ns.Struct.Types = append(ns.Struct.Types, t)
+	}
+	return ns, nil
+}
+
+func substraitType(dt arrow.DataType) (*substraitpb.Type, error) {
+	n := substraitpb.Type_NULLABILITY_NULLABLE
+	switch dt.ID() {
+	case arrow.BOOL:
+		return &substraitpb.Type{Kind: &substraitpb.Type_Bool{Bool: &substraitpb.Type_Boolean{Nullability: n}}}, nil
+	case arrow.INT8:
+		return &substraitpb.Type{Kind: &substraitpb.Type_I8_{I8: &substraitpb.Type_I8{Nullability: n}}}, nil
+	case arrow.INT16:
+		return &substraitpb.Type{Kind: &substraitpb.Type_I16_{I16: &substraitpb.Type_I16{Nullability: n}}}, nil
+	case arrow.INT32:
+		return &substraitpb.Type{Kind: &substraitpb.Type_I32_{I32: &substraitpb.Type_I32{Nullability: n}}}, nil
+	case arrow.INT64:
+		return &substraitpb.Type{Kind: &substraitpb.Type_I64_{I64: &substraitpb.Type_I64{Nullability: n}}}, nil
+	case arrow.FLOAT32:
+		return &substraitpb.Type{Kind: &substraitpb.Type_Fp32{Fp32: &substraitpb.Type_FP32{Nullability: n}}}, nil
+	case arrow.FLOAT64:
+		return &substraitpb.Type{Kind: &substraitpb.Type_Fp64{Fp64: &substraitpb.Type_FP64{Nullability: n}}}, nil
+	case arrow.STRING:
+		return &substraitpb.Type{Kind: &substraitpb.Type_String_{String_: &substraitpb.Type_String{Nullability: n}}}, nil
+	case arrow.BINARY:
+		return &substraitpb.Type{Kind: &substraitpb.Type_Binary_{Binary: &substraitpb.Type_Binary{Nullability: n}}}, nil
+	default:
+		return nil, ErrCannotProduce("type %s has no substrait equivalent", dt)
+	}
+}
@@ -0,0 +1,122 @@
+package substrait
+
+import (
+	logicalplan "opti-sql-go/logical-plan"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+)
+
+func run(t *testing.T, plan logicalplan.Plan) [][]string {
+	t.Helper()
+	op, err := physicaloptimizer.NewPlanner(testSources()).CreatePhysicalPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return collectRows(t, op)
+}
+
+func TestProduceRoundTrip(t *testing.T) {
+	queries := map[string]string{
+		"filter and project":  "SELECT name, age + 1 AS next FROM employees WHERE age > 25 AND name <> 'Bob'",
+		"order by with limit": "SELECT name, salary FROM employees ORDER BY salary DESC LIMIT 2",
+		"group by with having": `SELECT dept_id, SUM(salary) AS total, COUNT(id) AS n FROM employees
+			GROUP BY dept_id HAVING COUNT(id) > 1`,
+		"global aggregate": "SELECT AVG(age), MAX(salary) FROM employees",
+		"count star and distinct": `SELECT dept_id, COUNT(*), COUNT(DISTINCT age > 30) FROM employees
+			GROUP BY dept_id ORDER BY dept_id`,
+		"join": `SELECT e.name, d.department_name FROM employees e JOIN departments d ON e.dept_id = d.id
+			WHERE d.department_name <> 'Sales' ORDER BY e.name`,
+		"join with clashing names": "SELECT e.id, d.id FROM employees e JOIN departments d ON e.dept_id = d.id ORDER BY e.id",
+		"distinct":                 "SELECT DISTINCT dept_id FROM employees ORDER BY dept_id",
+		"functions and casts":      "SELECT UPPER(name), ABS(age - 40), CAST(age AS DOUBLE) FROM employees WHERE name LIKE '%a%' AND salary IS NOT NULL",
+		"not and is null":          "SELECT id, email IS NULL FROM contacts WHERE NOT (id > 3) ORDER BY id",
+	}
+	planner := physicaloptimizer.NewPlanner(testSources())
+	for name, sql := range queries {
+		t.Run(name, func(t *testing.T) {
+			bound, err := planner.LogicalPlan(sql)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			optimized, err := physicaloptimizer.NewOptimizer().Optimize(bound)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// the optimized plan adds scan projections
+			for _, plan := range []logicalplan.Plan{bound, optimized} {
+				data, err := NewProducer().ProduceBytes(plan)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				back, err := NewConsumer(nil).ConsumeBytes(data)
+				if err != nil {
+					t.Fatalf("unexpected error: %v\nplan:\n%s", err, logicalplan.Format(plan))
+				}
+				if !back.Schema().Equal(plan.Schema()) {
+					t.Fatalf("schema changed from %v to %v", plan.Schema(), back.Schema())
+				}
+				want, got := run(t, plan), run(t, back)
+				if len(want) == 0 {
+					t.Fatalf("query returned no rows, the comparison would be meaningless")
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Fatalf("round trip changed the results\nwant: %v\ngot:  %v\nconsumed plan:\n%s", want, got, logicalplan.Format(back))
+				}
+			}
+		})
+	}
+}
+
+func TestProduce(t *testing.T) {
+	t.Run("functions are declared once", func(t *testing.T) {
+		plan, err := physicaloptimizer.NewPlanner(testSources()).LogicalPlan("SELECT id FROM employees WHERE age > 1 AND age > 2 AND salary > 3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out, err := NewProducer().Produce(plan)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var names []string
+		for _, ext := range out.GetExtensions() {
+			names = append(names, ext.GetExtensionFunction().GetName())
+		}
+		if !reflect.DeepEqual(names, []string{"and", "gt"}) {
+			t.Fatalf("expected and, gt to be declared, got %v", names)
+		}
+		if len(out.GetExtensionUris()) != 2 {
+			t.Fatalf("expected the boolean and comparison extension uris, got %v", out.GetExtensionUris())
+		}
+		if got := out.GetRelations()[0].GetRoot().GetNames(); !reflect.DeepEqual(got, []string{"id"}) {
+			t.Fatalf("expected root names [id], got %v", got)
+		}
+		if _, ok := out.GetRelations()[0].GetRoot().GetInput().GetRelType().(*substraitpb.Rel_Project); !ok {
+			t.Fatalf("expected a project at the root")
+		}
+	})
+	t.Run("types without a substrait equivalent", func(t *testing.T) {
+		scan, err := logicalplan.NewScan("t", arrow.NewSchema([]arrow.Field{{Name: "u", Type: arrow.PrimitiveTypes.Uint32}}, nil), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewProducer().Produce(scan); err == nil {
+			t.Fatalf("expected an error for a uint32 column")
+		}
+	})
+	t.Run("unknown plan nodes", func(t *testing.T) {
+		_, err := NewProducer().Produce(fakePlan{})
+		if err == nil {
+			t.Fatalf("expected an error for an unknown node")
+		}
+	})
+}
+
+type fakePlan struct{}
+
+func (fakePlan) Schema() *arrow.Schema        { return arrow.NewSchema(nil, nil) }
+func (fakePlan) Children() []logicalplan.Plan { return nil }
+func (fakePlan) String() string               { return "Fake" }
@@ -2,26 +2,39 @@ package substrait
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"opti-sql-go/config"
+	"opti-sql-go/metrics"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/apache/arrow/go/v17/arrow/flight"
+	"github.com/apache/arrow/go/v17/arrow/flight/flightsql"
+	"github.com/apache/arrow/go/v17/arrow/memory"
 	"google.golang.org/grpc"
 )
 
 // SubstraitServer receives the substrait plan (gRPC) and sends out the optimized substrait plan (gRPC)
 type SubstraitServer struct {
 	UnimplementedSSOperationServer
-	listener *net.Listener
+	listener  *net.Listener
+	store     ObjectStore // sources are read from and results written to here
+	admission *AdmissionController
+	cache     *ResultCache // nil when results aren't cached
 }
 
-func newSubstraitServer(l *net.Listener) *SubstraitServer {
+func newSubstraitServer(l *net.Listener, store ObjectStore, admission *AdmissionController, cache *ResultCache) *SubstraitServer {
 	return &SubstraitServer{
-		listener: l,
+		listener:  l,
+		store:     store,
+		admission: admission,
+		cache:     cache,
 	}
 }
 
@@ -29,16 +42,66 @@ func newSubstraitServer(l *net.Listener) *SubstraitServer {
 func (s *SubstraitServer) ExecuteQuery(ctx context.Context, req *QueryExecutionRequest) (*QueryExecutionResponse, error) {
 	fmt.Printf("Received query request: logical_plan:%v\n sql:%s\n id:%v\n source: %v\n", req.SubstraitLogical, req.SqlStatement, req.Id, req.Source)
 
-	// Placeholder response
+	received := time.Now()
+	metrics.QueryStarted(apiExecute)
+	release, err := s.admission.Admit(ctx)
+	if err != nil {
+		details := errorDetails(admissionErr(err))
+		recordQuery(apiExecute, received, details)
+		return &QueryExecutionResponse{ErrorType: details}, nil
+	}
+	defer release()
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	link, analysis, err := execute(ctx, s.store, s.cache, req)
+	if err != nil {
+		details := errorDetails(err)
+		recordQuery(apiExecute, received, details)
+		return &QueryExecutionResponse{ErrorType: details, ExplainAnalyze: analysis}, nil
+	}
+
+	details := &ErrorDetails{
+		ErrorType: ReturnTypes_SUCCESS,
+		Message:   "Query executed successfully",
+	}
+	recordQuery(apiExecute, received, details)
 	return &QueryExecutionResponse{
-		S3ResultLink: "",
-		ErrorType: &ErrorDetails{
-			ErrorType: ReturnTypes_SUCCESS,
-			Message:   "Query executed successfully",
-		},
+		S3ResultLink:   link,
+		ErrorType:      details,
+		ExplainAnalyze: analysis,
 	}, nil
 }
 
+// the api label of the query metrics
+const (
+	apiExecute   = "execute"
+	apiStream    = "stream"
+	apiFlightSQL = "flightsql"
+)
+
+// recordQuery reports how a query received at received ended
+func recordQuery(api string, received time.Time, result *ErrorDetails) {
+	if result.GetErrorType() == ReturnTypes_SUCCESS {
+		metrics.QuerySucceeded(api, time.Since(received))
+		return
+	}
+	metrics.QueryFailed(api, result.GetErrorType().String(), time.Since(received))
+}
+
+// errorDetails maps an error to what the client receives
+func errorDetails(err error) *ErrorDetails {
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		return &ErrorDetails{ErrorType: qe.Type, Message: err.Error()}
+	}
+	t := ReturnTypes_UNKNOWN_ERROR
+	if IsUnsupported(err) {
+		t = ReturnTypes_UNSUPPORTED
+	}
+	return &ErrorDetails{ErrorType: t, Message: err.Error()}
+}
+
 func Start() chan struct{} {
 	c := config.GetConfig()
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port))
@@ -46,14 +109,47 @@ func Start() chan struct{} {
 		log.Fatalf("Failed to listen on port %d: %v", c.Server.Port, err)
 	}
 
+	store, err := NewDefaultS3Store()
+	if err != nil {
+		log.Fatalf("Failed to create object store client: %v", err)
+	}
+
+	// queries (through their QueryMemory) and flight sql allocate from the default allocator, count what they hold
+	alloc := metrics.NewAllocator(memory.DefaultAllocator)
+	memory.DefaultAllocator = alloc
+
+	// both services share the same slots and cached results
+	admission := admissionFromConfig()
+	cache := resultCacheFromConfig()
+	fs, err := NewFlightSQLServer(store, admission, cache)
+	if err != nil {
+		log.Fatalf("Failed to create flight sql server: %v", err)
+	}
+
 	grpcServer := grpc.NewServer()
-	ss := newSubstraitServer(&listener)
+	ss := newSubstraitServer(&listener, store, admission, cache)
 	RegisterSSOperationServer(grpcServer, ss)
+	// same port, flight sql clients (ADBC, JDBC, pyarrow) talk to this one
+	flight.RegisterFlightServiceServer(grpcServer, flightsql.NewFlightServer(fs))
+
+	var metricsServer *http.Server
+	if c.Metrics.EnableMetrics {
+		reg := metrics.NewRegistry(metrics.Gauges{
+			QueueLength:     admission.QueueLength,
+			RunningQueries:  admission.Running,
+			ArrowBytesInUse: alloc.InUse,
+		})
+		metricsServer, err = metrics.Serve(reg)
+		if err != nil {
+			log.Fatalf("Failed to serve metrics on port %d: %v", c.Metrics.MetricsPort, err)
+		}
+		log.Printf("Metrics served on %s:%d/metrics", c.Metrics.MetricsHost, c.Metrics.MetricsPort)
+	}
 
 	stopChan := make(chan struct{})
 
 	log.Printf("Substrait server listening on port %d", c.Server.Port)
-	go unifiedShutdownHandler(ss, grpcServer, stopChan)
+	go unifiedShutdownHandler(ss, grpcServer, metricsServer, stopChan)
 	go func() {
 		if err := grpcServer.Serve(*ss.listener); err != nil {
 			log.Fatalf("Failed to serve: %v", err)
@@ -61,7 +157,7 @@ func Start() chan struct{} {
 	}()
 	return stopChan
 }
-func unifiedShutdownHandler(s *SubstraitServer, grpcServer *grpc.Server, stopChan chan struct{}) {
+func unifiedShutdownHandler(s *SubstraitServer, grpcServer *grpc.Server, metricsServer *http.Server, stopChan chan struct{}) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
@@ -76,6 +172,9 @@ func unifiedShutdownHandler(s *SubstraitServer, grpcServer *grpc.Server, stopCha
 	_ = l.Close()
 
 	grpcServer.GracefulStop()
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
 
 	fmt.Println("Server shutdown complete")
 }
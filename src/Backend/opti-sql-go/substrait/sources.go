@@ -0,0 +1,311 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	logicalplan "opti-sql-go/logical-plan"
+	"opti-sql-go/metrics"
+	"opti-sql-go/operators"
+	"opti-sql-go/operators/project"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+)
+
+var (
+	_ = (tableSource)(&requestSource{})
+	_ = (tableSource)(&bucketCatalog{})
+)
+
+// tableSource is what a query is planned against, table hands back the object behind a table
+// name so the plan can be checked against what is actually stored
+type tableSource interface {
+	physicaloptimizer.SourceProvider
+	table(name string) (*objectTable, error)
+}
+
+type sourceFormat int
+
+const (
+	csvFormat sourceFormat = iota
+	parquetFormat
+)
+
+func (f sourceFormat) String() string {
+	if f == csvFormat {
+		return "csv"
+	}
+	return "parquet"
+}
+
+// objectTable is a csv or parquet object used as a table
+type objectTable struct {
+	store    ObjectStore
+	location string
+	format   sourceFormat
+	schema   *arrow.Schema
+	info     ObjectInfo // what was stored when the table was opened, cached results are tied to it
+}
+
+func openObjectTable(store ObjectStore, location, mimeType string) (*objectTable, error) {
+	format, err := detectFormat(mimeType, location)
+	if err != nil {
+		return nil, err
+	}
+	info, err := store.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	t := &objectTable{store: store, location: location, format: format, info: info}
+	t.schema, err = t.readSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	return t, nil
+}
+
+// detectFormat picks the leaf for a mime type, without one the file extension decides
+func detectFormat(mimeType, location string) (sourceFormat, error) {
+	if mimeType == "" {
+		switch strings.ToLower(path.Ext(location)) {
+		case ".csv":
+			return csvFormat, nil
+		case ".parquet":
+			return parquetFormat, nil
+		}
+		return 0, ErrUnknownMime(mimeType)
+	}
+	media, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return 0, ErrUnknownMime(mimeType)
+	}
+	switch {
+	case media == "text/csv" || media == "application/csv":
+		return csvFormat, nil
+	case strings.HasSuffix(media, "parquet"):
+		return parquetFormat, nil
+	}
+	return 0, ErrUnknownMime(mimeType)
+}
+
+func (t *objectTable) readSchema() (*arrow.Schema, error) {
+	r, err := t.store.Open(t.location)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	if t.format == csvFormat {
+		leaf, err := project.NewProjectCSVLeaf(r.Stream())
+		if err != nil {
+			return nil, err
+		}
+		return leaf.Schema(), nil
+	}
+	// only the footer is read
+	rdr, err := file.NewParquetReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rdr.Close() }()
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		return nil, err
+	}
+	return fr.Schema()
+}
+
+// open reopens the object on every scan, a csv stream can only be read once
+func (t *objectTable) open(scan *logicalplan.Scan) (operators.Operator, error) {
+	r, err := t.store.Open(t.location)
+	if err != nil {
+		return nil, err
+	}
+	kind := t.format.String()
+	var leaf operators.Operator
+	switch {
+	case t.format == csvFormat:
+		// the csv leaf always reads every column, the planner prunes the rest
+		leaf, err = project.NewProjectCSVLeaf(countedStream{Reader: r.Stream(), kind: kind})
+	case scan.Columns == nil:
+		leaf, err = project.NewParquetSource(countedObject{NetworkResource: r, kind: kind})
+	default:
+		leaf, err = project.NewParquetSourcePushDown(countedObject{NetworkResource: r, kind: kind}, scan.Columns)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &countedScan{Operator: leaf, kind: kind}, nil
+}
+
+// the counted* wrappers report what scans read to the source metrics
+
+type countedStream struct {
+	io.Reader
+	kind string
+}
+
+func (c countedStream) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	metrics.BytesScanned(c.kind, n)
+	return n, err
+}
+
+type countedObject struct {
+	*project.NetworkResource
+	kind string
+}
+
+func (c countedObject) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.NetworkResource.ReadAt(p, off)
+	metrics.BytesScanned(c.kind, n)
+	return n, err
+}
+
+type countedScan struct {
+	operators.Operator
+	kind string
+}
+
+func (c *countedScan) Explain() operators.Explanation {
+	return operators.ExplainOperator(c.Operator)
+}
+
+func (c *countedScan) Next(ctx context.Context, n uint16) (*operators.RecordBatch, error) {
+	batch, err := c.Operator.Next(ctx, n)
+	if err == nil {
+		metrics.RowsScanned(c.kind, batch.RowCount)
+	}
+	return batch, err
+}
+
+// checkScans makes sure every scan in plan reads columns its table actually has with the types it has.
+// a substrait plan carries its own base_schema which can disagree with the file
+func checkScans(sources tableSource, plan logicalplan.Plan) error {
+	if scan, ok := plan.(*logicalplan.Scan); ok {
+		t, err := sources.table(scan.Table)
+		if err != nil {
+			return err
+		}
+		for _, f := range scan.Source.Fields() {
+			idx := t.schema.FieldIndices(f.Name)
+			if len(idx) == 0 {
+				return logicalplan.ErrColumnNotFound(f.Name, t.schema)
+			}
+			if got := t.schema.Field(idx[0]).Type; !arrow.TypeEqual(f.Type, got) {
+				return ErrSourceMismatch(f.Name, f.Type, got)
+			}
+		}
+	}
+	for _, c := range plan.Children() {
+		if err := checkScans(sources, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestSource serves the single source of a request, every table name in the plan resolves to it
+type requestSource struct {
+	*objectTable
+}
+
+func newRequestSource(store ObjectStore, src *SourceType) (*requestSource, error) {
+	if src.GetS3Source() == "" {
+		return nil, errors.New("request has no source")
+	}
+	t, err := openObjectTable(store, src.GetS3Source(), src.GetMime())
+	if err != nil {
+		return nil, err
+	}
+	return &requestSource{objectTable: t}, nil
+}
+
+func (rs *requestSource) table(string) (*objectTable, error) {
+	return rs.objectTable, nil
+}
+
+func (rs *requestSource) TableSchema(string) (*arrow.Schema, error) {
+	return rs.schema, nil
+}
+
+func (rs *requestSource) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	return rs.open(scan)
+}
+
+// bucketCatalog serves the csv and parquet objects at the top level of the store's bucket as
+// tables named after the key without its extension (employees.csv is the table employees).
+// it remembers what it listed and read, so it is meant to live for a single query
+type bucketCatalog struct {
+	store  ObjectStore
+	keys   map[string]string // table name -> object key
+	tables map[string]*objectTable
+}
+
+func newBucketCatalog(store ObjectStore) (*bucketCatalog, error) {
+	keys, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	c := &bucketCatalog{store: store, keys: make(map[string]string), tables: make(map[string]*objectTable)}
+	for _, key := range keys {
+		if strings.Contains(key, "/") {
+			continue
+		}
+		if _, err := detectFormat("", key); err != nil {
+			continue
+		}
+		c.keys[strings.TrimSuffix(key, path.Ext(key))] = key
+	}
+	return c, nil
+}
+
+// names returns the table names in order
+func (c *bucketCatalog) names() []string {
+	names := make([]string, 0, len(c.keys))
+	for name := range c.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *bucketCatalog) table(name string) (*objectTable, error) {
+	if t, ok := c.tables[name]; ok {
+		return t, nil
+	}
+	key, ok := c.keys[name]
+	if !ok {
+		return nil, logicalplan.ErrTableNotFound(name)
+	}
+	t, err := openObjectTable(c.store, key, "")
+	if err != nil {
+		return nil, err
+	}
+	c.tables[name] = t
+	return t, nil
+}
+
+func (c *bucketCatalog) TableSchema(name string) (*arrow.Schema, error) {
+	t, err := c.table(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema, nil
+}
+
+func (c *bucketCatalog) Open(scan *logicalplan.Scan) (operators.Operator, error) {
+	t, err := c.table(scan.Table)
+	if err != nil {
+		return nil, err
+	}
+	return t.open(scan)
+}
@@ -0,0 +1,106 @@
+package substrait
+
+import (
+	"bytes"
+	"fmt"
+	"opti-sql-go/config"
+	"opti-sql-go/operators/project"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+var _ = (ObjectStore)(&S3Store{})
+
+// ObjectStore is where ExecuteQuery reads its sources from and writes its results to.
+// locations are either s3://bucket/key links or plain keys inside the store's own bucket
+type ObjectStore interface {
+	// Open returns the object at location, a missing object is reported here and not on the first read
+	Open(location string) (*project.NetworkResource, error)
+	// Put stores data under key and returns the link handed back to the client
+	Put(key string, data []byte, contentType string) (string, error)
+	// List returns the keys at the top level of the store's bucket
+	List() ([]string, error)
+	// Stat describes the object at location without reading it
+	Stat(location string) (ObjectInfo, error)
+}
+
+// ObjectInfo identifies the current contents of an object, it changes whenever the object is overwritten
+type ObjectInfo struct {
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+// S3Store is an ObjectStore backed by any s3 compatible service
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(client *minio.Client, bucket string) *S3Store {
+	return &S3Store{client: client, bucket: bucket}
+}
+
+// NewDefaultS3Store connects to the object store from the config secrets
+func NewDefaultS3Store() (*S3Store, error) {
+	s := config.GetConfig().Secretes
+	client, err := minio.New(s.EndpointURL, s.AccessKey, s.SecretKey, true)
+	if err != nil {
+		return nil, err
+	}
+	return NewS3Store(client, s.BucketName), nil
+}
+
+func (s *S3Store) Open(location string) (*project.NetworkResource, error) {
+	// GetObject is lazy, stat first so a missing object fails here
+	if _, err := s.Stat(location); err != nil {
+		return nil, err
+	}
+	bucket, key := s.split(location)
+	return project.NewStreamReaderFromClient(s.client, bucket, key)
+}
+
+func (s *S3Store) Stat(location string) (ObjectInfo, error) {
+	bucket, key := s.split(location)
+	if key == "" {
+		return ObjectInfo{}, fmt.Errorf("no object key in %q", location)
+	}
+	info, err := s.client.StatObject(bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{ETag: info.ETag, Size: info.Size, LastModified: info.LastModified}, nil
+}
+
+func (s *S3Store) Put(key string, data []byte, contentType string) (string, error) {
+	_, err := s.client.PutObject(s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+func (s *S3Store) List() ([]string, error) {
+	done := make(chan struct{})
+	defer close(done)
+	var keys []string
+	for obj := range s.client.ListObjectsV2(s.bucket, "", false, done) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// split turns a location into bucket and key, plain keys live in the store's bucket
+func (s *S3Store) split(location string) (string, string) {
+	rest, ok := strings.CutPrefix(location, "s3://")
+	if !ok {
+		return s.bucket, strings.TrimPrefix(location, "/")
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	return bucket, key
+}
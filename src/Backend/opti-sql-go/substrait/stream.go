@@ -0,0 +1,136 @@
+package substrait
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"opti-sql-go/config"
+	"opti-sql-go/metrics"
+	"opti-sql-go/operators"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+)
+
+// ExecuteQueryStream sends the result back as arrow IPC messages instead of uploading it.
+// there is no buffering between the operators and the client: the next batch is only pulled once
+// the previous one was handed to grpc, and Send blocks as soon as the client stops reading (http2
+// flow control), so a slow reader slows the query down rather than piling batches up in memory.
+// failures while planning or executing are reported in the trailer, only a broken stream is
+// returned as a grpc error
+func (s *SubstraitServer) ExecuteQueryStream(req *QueryExecutionRequest, stream SSOperation_ExecuteQueryStreamServer) error {
+	received := time.Now()
+	metrics.QueryStarted(apiStream)
+	trailer := &QueryTrailer{ErrorType: &ErrorDetails{ErrorType: ReturnTypes_SUCCESS, Message: "Query executed successfully"}}
+	defer func() { recordQuery(apiStream, received, trailer.ErrorType) }()
+	release, err := s.admission.Admit(stream.Context())
+	if err != nil {
+		trailer.ErrorType = errorDetails(admissionErr(err))
+		return stream.Send(&QueryResultChunk{Chunk: &QueryResultChunk_Trailer{Trailer: trailer}})
+	}
+	defer release()
+	ctx, cancel := queryContext(stream.Context())
+	defer cancel()
+
+	start := time.Now()
+	op, err := planRequest(s.store, s.cache, req)
+	trailer.PlanningMicros = uint64(time.Since(start).Microseconds())
+	if err != nil {
+		trailer.ErrorType = errorDetails(err)
+		return stream.Send(&QueryResultChunk{Chunk: &QueryResultChunk_Trailer{Trailer: trailer}})
+	}
+
+	start = time.Now()
+	chunks := &ipcChunks{send: stream.Send}
+	err = streamResult(ctx, op, chunks, trailer)
+	trailer.ExecutionMicros = uint64(time.Since(start).Microseconds())
+	trailer.ExplainAnalyze = explainAnalyze(op)
+	if chunks.sendErr != nil {
+		trailer.ErrorType = errorDetails(chunks.sendErr)
+		return chunks.sendErr
+	}
+	if err != nil {
+		trailer.ErrorType = errorDetails(execErr(err))
+	}
+	return stream.Send(&QueryResultChunk{Chunk: &QueryResultChunk_Trailer{Trailer: trailer}})
+}
+
+// streamResult drains op through an IPC writer into chunks and counts what went out in trailer
+func streamResult(ctx context.Context, op operators.Operator, chunks *ipcChunks, trailer *QueryTrailer) error {
+	defer func() { _ = op.Close() }()
+	w := ipc.NewWriterWithPayloadWriter(chunks, ipc.WithSchema(op.Schema()))
+	batchSize := uint16(config.GetConfig().Batch.Size)
+	for {
+		batch, err := operators.NextBatch(ctx, op, batchSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rec := array.NewRecord(op.Schema(), batch.Columns, int64(batch.RowCount))
+		err = w.Write(rec)
+		rec.Release()
+		operators.ReleaseArrays(batch.Columns)
+		if err != nil {
+			return err
+		}
+		trailer.RowCount += batch.RowCount
+		trailer.BatchCount++
+	}
+	// for an empty result this is what sends the schema
+	return w.Close()
+}
+
+// ipcChunks is an ipc.PayloadWriter that sends every IPC message as its own chunk, the first
+// message an ipc.Writer produces is always the schema
+type ipcChunks struct {
+	send    func(*QueryResultChunk) error
+	sent    int
+	sendErr error // the client went away, nothing else can be sent
+}
+
+func (c *ipcChunks) Start() error { return nil }
+
+// Close doesn't write the end of stream marker, the trailer ends the stream
+func (c *ipcChunks) Close() error { return nil }
+
+func (c *ipcChunks) WritePayload(p ipc.Payload) error {
+	msg, err := encapsulate(p)
+	if err != nil {
+		return err
+	}
+	chunk := &QueryResultChunk{Chunk: &QueryResultChunk_RecordBatch{RecordBatch: msg}}
+	if c.sent == 0 {
+		chunk.Chunk = &QueryResultChunk_Schema{Schema: msg}
+	}
+	if err := c.send(chunk); err != nil {
+		c.sendErr = err
+		return err
+	}
+	c.sent++
+	return nil
+}
+
+// encapsulate lays out a payload the way the IPC stream format does: continuation marker, metadata
+// length, the metadata padded to 8 bytes, then the body
+func encapsulate(p ipc.Payload) ([]byte, error) {
+	meta := p.Meta()
+	defer meta.Release()
+	padded := (meta.Len() + 7) &^ 7
+
+	var buf bytes.Buffer
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[:4], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(padded))
+	buf.Write(prefix[:])
+	buf.Write(meta.Bytes())
+	buf.Write(make([]byte, padded-meta.Len()))
+	if err := p.SerializeBody(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
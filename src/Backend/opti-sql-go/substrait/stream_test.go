@@ -0,0 +1,248 @@
+package substrait
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"opti-sql-go/operators"
+	physicaloptimizer "opti-sql-go/physical-optimizer"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// streamClient serves store over an in memory connection
+func streamClient(t *testing.T, store ObjectStore) SSOperationClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterSSOperationServer(srv, newSubstraitServer(nil, store, admissionFromConfig(), resultCacheFromConfig()))
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewSSOperationClient(conn)
+}
+
+// receive reads a whole stream, checks the chunk order and returns the IPC bytes and the trailer
+func receive(t *testing.T, stream SSOperation_ExecuteQueryStreamClient) ([]byte, *QueryTrailer) {
+	t.Helper()
+	var ipcStream bytes.Buffer
+	var trailer *QueryTrailer
+	for i := 0; ; i++ {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if trailer != nil {
+			t.Fatalf("received a chunk after the trailer")
+		}
+		switch c := chunk.GetChunk().(type) {
+		case *QueryResultChunk_Schema:
+			if i != 0 {
+				t.Fatalf("schema sent as chunk %d", i)
+			}
+			ipcStream.Write(c.Schema)
+		case *QueryResultChunk_RecordBatch:
+			if i == 0 {
+				t.Fatalf("record batch sent before the schema")
+			}
+			ipcStream.Write(c.RecordBatch)
+		case *QueryResultChunk_Trailer:
+			trailer = c.Trailer
+		}
+	}
+	if trailer == nil {
+		t.Fatalf("stream ended without a trailer")
+	}
+	return ipcStream.Bytes(), trailer
+}
+
+// readIPC decodes a concatenated IPC stream into rows
+func readIPC(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	rdr, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("result is not an arrow IPC stream: %v", err)
+	}
+	defer rdr.Release()
+	rows := [][]string{}
+	for rdr.Next() {
+		rec := rdr.Record()
+		for r := 0; r < int(rec.NumRows()); r++ {
+			row := make([]string, rec.NumCols())
+			for c := range row {
+				row[c] = rec.Column(c).ValueStr(r)
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rows
+}
+
+func TestExecuteQueryStream(t *testing.T) {
+	store, _ := newFakeStore(t, map[string][]byte{"employees.csv": []byte(employeesCSV)})
+	client := streamClient(t, store)
+
+	t.Run("schema, batches and trailer", func(t *testing.T) {
+		stream, err := client.ExecuteQueryStream(context.Background(), &QueryExecutionRequest{
+			SqlStatement: "SELECT name, age FROM employees WHERE age > 25 ORDER BY age",
+			Source:       &SourceType{S3Source: "employees.csv"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, trailer := receive(t, stream)
+		if trailer.GetErrorType().GetErrorType() != ReturnTypes_SUCCESS {
+			t.Fatalf("expected SUCCESS, got %v", trailer.GetErrorType())
+		}
+		want := [][]string{{"Bob", "28"}, {"Alice", "34"}, {"Carol", "45"}}
+		if got := readIPC(t, data); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		if trailer.GetRowCount() != 3 || trailer.GetBatchCount() == 0 {
+			t.Fatalf("unexpected counts in trailer %v", trailer)
+		}
+		if trailer.GetExplainAnalyze() != "" {
+			t.Fatalf("only analyzed queries carry stats, got %s", trailer.GetExplainAnalyze())
+		}
+	})
+	t.Run("explain analyze in the trailer", func(t *testing.T) {
+		stream, err := client.ExecuteQueryStream(context.Background(), &QueryExecutionRequest{
+			SqlStatement: "SELECT name, age FROM employees WHERE age > 25 ORDER BY age",
+			Source:       &SourceType{S3Source: "employees.csv"},
+			Analyze:      true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, trailer := receive(t, stream)
+		if got := readIPC(t, data); len(got) != 3 {
+			t.Fatalf("expected the 3 rows as usual, got %v", got)
+		}
+		analysis := trailer.GetExplainAnalyze()
+		if !strings.HasPrefix(analysis, "SortExec: age ASC NULLS LAST [rows_in=3 rows_out=3 ") || !strings.Contains(analysis, "FilterExec: (age > 25) [rows_in=4 rows_out=3 ") {
+			t.Fatalf("unexpected analysis\n%s", analysis)
+		}
+	})
+	t.Run("empty result still has a schema", func(t *testing.T) {
+		stream, err := client.ExecuteQueryStream(context.Background(), &QueryExecutionRequest{
+			SqlStatement: "SELECT name FROM employees WHERE age > 100",
+			Source:       &SourceType{S3Source: "employees.csv"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, trailer := receive(t, stream)
+		if got := readIPC(t, data); len(got) != 0 || trailer.GetRowCount() != 0 {
+			t.Fatalf("expected no rows, got %v (trailer %v)", got, trailer)
+		}
+	})
+	t.Run("failures end up in the trailer", func(t *testing.T) {
+		stream, err := client.ExecuteQueryStream(context.Background(), &QueryExecutionRequest{
+			SqlStatement: "SELECT name FROM employees",
+			Source:       &SourceType{S3Source: "missing.csv"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, trailer := receive(t, stream)
+		if len(data) != 0 {
+			t.Fatalf("expected only a trailer")
+		}
+		if trailer.GetErrorType().GetErrorType() != ReturnTypes_SOURCE_ERROR {
+			t.Fatalf("expected SOURCE_ERROR, got %v", trailer.GetErrorType())
+		}
+	})
+}
+
+// oneRowAtATime hands out a single row per Next and counts the calls
+type oneRowAtATime struct {
+	operators.Operator
+	pulls atomic.Int32
+}
+
+func (o *oneRowAtATime) Next(ctx context.Context, _ uint16) (*operators.RecordBatch, error) {
+	o.pulls.Add(1)
+	return o.Operator.Next(ctx, 1)
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	src, err := testSources()["employees"]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	op := &oneRowAtATime{Operator: src}
+	// the client reads a chunk whenever it sends on reads, until then Send blocks
+	reads := make(chan struct{})
+	sent := make(chan *QueryResultChunk)
+	chunks := &ipcChunks{send: func(c *QueryResultChunk) error {
+		<-reads
+		sent <- c
+		return nil
+	}}
+	trailer := &QueryTrailer{}
+	done := make(chan error)
+	go func() { done <- streamResult(context.Background(), op, chunks, trailer) }()
+
+	for i := 0; i < 3; i++ {
+		reads <- struct{}{}
+		<-sent
+		// chunk 0 is the schema, chunk i carries the row from pull i
+		if pulls := int(op.pulls.Load()); pulls > i+1 {
+			t.Fatalf("pulled %d batches while the client only read %d chunks", pulls, i+1)
+		}
+	}
+	go func() {
+		for range sent {
+		}
+	}()
+	close(reads)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(sent)
+	if trailer.GetRowCount() != 4 || trailer.GetBatchCount() != 4 {
+		t.Fatalf("expected 4 rows in 4 batches, got %v", trailer)
+	}
+}
+
+func TestStreamResultReleasesBatches(t *testing.T) {
+	planner := physicaloptimizer.NewPlanner(testSources())
+	plan, err := planner.LogicalPlan("SELECT name, age + 1 AS next FROM employees WHERE age > 25")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	op, err := planner.CreatePhysicalPlan(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := operators.NewQueryMemory(memory.DefaultAllocator, 0, 0)
+	chunks := &ipcChunks{send: func(*QueryResultChunk) error { return nil }}
+	if err := streamResult(operators.WithQueryMemory(context.Background(), q), op, chunks, &QueryTrailer{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Peak() == 0 || q.InUse() != 0 {
+		t.Fatalf("expected every batch to be released once sent, %d of %d bytes still in use", q.InUse(), q.Peak())
+	}
+}
@@ -3,7 +3,11 @@ package substrait
 import (
 	"context"
 	"net"
+	"opti-sql-go/metrics"
 	"testing"
+
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestInitServer(t *testing.T) {
@@ -13,7 +17,7 @@ func TestInitServer(t *testing.T) {
 		t.Fatalf("Failed to listen: %v", err)
 	}
 
-	ss := newSubstraitServer(&l)
+	ss := newSubstraitServer(&l, nil, admissionFromConfig(), resultCacheFromConfig())
 	if ss == nil {
 		t.Errorf("Expected non-nil Substrait server")
 	}
@@ -24,16 +28,21 @@ func TestDummyInput(t *testing.T) {
 		t.Fatalf("Failed to listen: %v", err)
 	}
 
-	ss := newSubstraitServer(&l)
+	store, _ := newFakeStore(t, map[string][]byte{"table.parquet": employeesParquet(t)})
+	ss := newSubstraitServer(&l, store, admissionFromConfig(), resultCacheFromConfig())
 	if ss == nil {
 		t.Errorf("Expected non-nil Substrait server")
 	}
+	validPlan, err := proto.Marshal(testPlan(employeesRead()))
+	if err != nil {
+		t.Fatalf("failed to encode plan: %v", err)
+	}
 	dummyRequest := &QueryExecutionRequest{
 		SqlStatement:     "SELECT * FROM table",
-		SubstraitLogical: []byte("CgJTUxIMCgpTZWxlY3QgKiBGUk9NIHRhYmxl"),
+		SubstraitLogical: validPlan,
 		Id:               "GenerateDTMoneyOHaasdavdasvasdvada",
 		Source: &SourceType{
-			S3Source: "s3://my-bucket/data/table.parquet",
+			S3Source: "s3://data/table.parquet",
 			Mime:     "application/vnd.apache.parquet",
 		},
 	}
@@ -42,7 +51,32 @@ func TestDummyInput(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 	if resp.ErrorType.ErrorType != ReturnTypes_SUCCESS {
-		t.Errorf("Expected SUCCESS, got %v", resp.ErrorType.ErrorType)
+		t.Errorf("Expected SUCCESS, got %v", resp.ErrorType)
+	}
+	if resp.S3ResultLink == "" {
+		t.Errorf("Expected a result link")
+	}
+
+	dummyRequest.SubstraitLogical = []byte("CgJTUxIMCgpTZWxlY3QgKiBGUk9NIHRhYmxl")
+	resp, err = ss.ExecuteQuery(context.Background(), dummyRequest)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if resp.ErrorType.ErrorType != ReturnTypes_PARSE_ERROR {
+		t.Errorf("Expected PARSE_ERROR, got %v", resp.ErrorType.ErrorType)
+	}
+
+	unsupportedPlan, err := proto.Marshal(testPlan(&substraitpb.Rel{RelType: &substraitpb.Rel_Set{Set: &substraitpb.SetRel{}}}))
+	if err != nil {
+		t.Fatalf("failed to encode plan: %v", err)
+	}
+	dummyRequest.SubstraitLogical = unsupportedPlan
+	resp, err = ss.ExecuteQuery(context.Background(), dummyRequest)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if resp.ErrorType.ErrorType != ReturnTypes_UNSUPPORTED {
+		t.Errorf("Expected UNSUPPORTED, got %v", resp.ErrorType.ErrorType)
 	}
 }
 
@@ -53,3 +87,67 @@ func TestStartServer(t *testing.T) {
 	}
 
 }
+
+// counterValue reads a counter from the metrics registry, 0 when it wasn't reported yet
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	reg := metrics.NewRegistry(metrics.Gauges{
+		QueueLength:     func() int { return 0 },
+		RunningQueries:  func() int { return 0 },
+		ArrowBytesInUse: func() int64 { return 0 },
+	})
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+	next:
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue next
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestQueryMetrics(t *testing.T) {
+	store, _ := newFakeStore(t, map[string][]byte{"employees.csv": []byte(employeesCSV)})
+	ss := newSubstraitServer(nil, store, admissionFromConfig(), nil)
+	succeeded := map[string]string{"api": apiExecute}
+	failed := map[string]string{"api": apiExecute, "error_type": "SOURCE_ERROR"}
+	csv := map[string]string{"kind": "csv"}
+	before := map[string]float64{
+		"succeeded": counterValue(t, "optisql_queries_succeeded_total", succeeded),
+		"failed":    counterValue(t, "optisql_queries_failed_total", failed),
+		"rows":      counterValue(t, "optisql_source_rows_scanned_total", csv),
+		"bytes":     counterValue(t, "optisql_source_bytes_scanned_total", csv),
+	}
+
+	for _, source := range []string{"employees.csv", "missing.csv"} {
+		if _, err := ss.ExecuteQuery(context.Background(), &QueryExecutionRequest{
+			SqlStatement: "SELECT name FROM employees",
+			Source:       &SourceType{S3Source: source},
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := counterValue(t, "optisql_queries_succeeded_total", succeeded) - before["succeeded"]; got != 1 {
+		t.Fatalf("expected 1 more succeeded query, got %v", got)
+	}
+	if got := counterValue(t, "optisql_queries_failed_total", failed) - before["failed"]; got != 1 {
+		t.Fatalf("expected 1 more failed query, got %v", got)
+	}
+	if got := counterValue(t, "optisql_source_rows_scanned_total", csv) - before["rows"]; got != 4 {
+		t.Fatalf("expected 4 more csv rows scanned, got %v", got)
+	}
+	if got := counterValue(t, "optisql_source_bytes_scanned_total", csv) - before["bytes"]; got != float64(len(employeesCSV)) {
+		t.Fatalf("expected %d more csv bytes scanned, got %v", len(employeesCSV), got)
+	}
+}